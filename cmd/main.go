@@ -1,51 +1,85 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/juanbautista0/go-proxy/internal/application"
 	"github.com/juanbautista0/go-proxy/internal/domain"
 	"github.com/juanbautista0/go-proxy/internal/infrastructure"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
-	configPath := "config.yaml"
-	if len(os.Args) > 1 {
-		configPath = os.Args[1]
-	}
+	configSource := flag.String("config-source", "", "config origin: file path or consul://host:port/key")
+	flag.Parse()
 
-	// Verificar si el archivo existe
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("Config file not found: %s", configPath)
+	// El origen admite también el primer argumento, sea ruta o URL
+	source := "config.yaml"
+	if flag.NArg() > 0 {
+		source = flag.Arg(0)
+	}
+	if *configSource != "" {
+		source = *configSource
 	}
 
 	// Infraestructura
-	configManager := infrastructure.NewConfigManager(configPath)
-	actionExecutor := infrastructure.NewHTTPActionExecutor()
+	configManager, err := infrastructure.NewConfigManagerFromSource(source)
+	if err != nil {
+		slog.Error("Error opening config source", "source", source, "error", err)
+		os.Exit(1)
+	}
+	actionExecutor := infrastructure.NewTypedActionExecutor()
 	enterpriseBalancer := infrastructure.NewEnterpriseBalancer()
-	healthChecker := infrastructure.NewHealthChecker()
+	healthChecker := infrastructure.NewAdvancedHealthChecker()
+	serverDiscovery := infrastructure.NewServerDiscovery()
 
 	// Cargar configuración inicial
 	config, err := configManager.Load()
 	if err != nil {
-		log.Fatal("Error loading config:", err)
+		slog.Error("Error loading config", "error", err)
+		os.Exit(1)
+	}
+	infrastructure.ConfigureLogger(config.Logging)
+	// Los backends con discovery usan los servidores resueltos en DNS
+	serverDiscovery.Sync(config.Backends)
+	config = serverDiscovery.Apply(config)
+	if err := configManager.Watch(); err != nil {
+		slog.Warn("Config watch disabled", "error", err)
+	}
+
+	// Persistencia opcional de métricas entre reinicios
+	var metricsPersister *infrastructure.MetricsPersister
+	if cfg := config.Metrics.Persistence; cfg != nil {
+		store, err := infrastructure.NewMetricsStore(cfg)
+		if err != nil {
+			slog.Warn("Metrics persistence disabled", "error", err)
+		} else {
+			metricsPersister = infrastructure.NewMetricsPersister(store, enterpriseBalancer, cfg.Interval)
+			metricsPersister.Start()
+			slog.Info("Metrics persistence enabled", "store", cfg.Store)
+		}
 	}
 
 	// Aplicación
+	healthChecker.SetObserver(enterpriseBalancer)
 	proxyService := application.NewProxyService(enterpriseBalancer, healthChecker)
 	
 	// Sistema de triggers inteligente
 	smartTrigger := application.NewSmartTriggerService(actionExecutor, proxyService)
 	triggerService := application.NewHybridTriggerService(smartTrigger, actionExecutor)
-	log.Println("🧠 Smart Trigger System enabled")
+	slog.Info("Smart trigger system enabled")
 
 	proxyService.UpdateConfig(config)
+	proxyService.StartSessionSweeper(0)
 	triggerService.Start(config, proxyService.GetMetrics())
 
 	// Iniciar health checks
@@ -53,36 +87,82 @@ func main() {
 		healthChecker.Start(&backend)
 	}
 
+	// Aplica una configuración con los servidores descubiertos ya incluidos. Lo
+	// llaman las recargas y el descubrimiento desde goroutines distintas.
+	var applyMu sync.Mutex
+	applyServers := func(newConfig *domain.Config) {
+		applyMu.Lock()
+		defer applyMu.Unlock()
+		proxyService.UpdateConfig(newConfig)
+		// Los servidores añadidos no entran en rotación hasta su primer health check
+		for _, backend := range newConfig.Backends {
+			healthChecker.Start(&backend)
+		}
+		healthChecker.StopRemovedBackends(newConfig.Backends)
+		// Sin reiniciar: se conservan cooldowns y ventanas del SmartTrigger
+		triggerService.Reconfigure(newConfig)
+	}
+
 	// Callback para cambios de configuración
 	configManager.AddCallback(func(newConfig *domain.Config) {
-		log.Println("Config updated, reloading...")
-		proxyService.UpdateConfig(newConfig)
-		triggerService.Stop()
-		triggerService.Start(newConfig, proxyService.GetMetrics())
+		infrastructure.ConfigureLogger(newConfig.Logging)
+		slog.Info("Config updated, reloading")
+		serverDiscovery.SyncInBackground(newConfig.Backends)
+		applyServers(serverDiscovery.Apply(newConfig))
+	})
+	// Un cambio en DNS se aplica sobre la configuración vigente
+	serverDiscovery.OnChange(func() {
+		if current := configManager.GetConfig(); current != nil {
+			applyServers(serverDiscovery.Apply(current))
+		}
 	})
 
 	// Servidor de métricas
 	metricsServer := infrastructure.NewMetricsServer(proxyService)
 	metricsServer.SetLoadBalancer(enterpriseBalancer)
+	metricsServer.SetHealthChecker(healthChecker)
+	metricsServer.SetTriggerMetrics(triggerService)
+	enterpriseBalancer.SetLatencyBuckets(config.Metrics.LatencyBuckets)
+	enterpriseBalancer.SetPercentiles(config.Metrics.Percentiles)
+	enterpriseBalancer.SetLatencySamples(config.Metrics.LatencySamples)
+	metricsServer.SetCORS(&config.CORS)
+	configManager.AddCallback(func(newConfig *domain.Config) {
+		enterpriseBalancer.SetLatencyBuckets(newConfig.Metrics.LatencyBuckets)
+		enterpriseBalancer.SetPercentiles(newConfig.Metrics.Percentiles)
+		enterpriseBalancer.SetLatencySamples(newConfig.Metrics.LatencySamples)
+		metricsServer.SetCORS(&newConfig.CORS)
+	})
 	go func() {
-		log.Println("Metrics server starting on :8081")
+		slog.Info("Metrics server starting", "addr", ":8081")
 		if err := metricsServer.Start(8081); err != nil {
-			log.Printf("Metrics server error: %v", err)
+			slog.Error("Metrics server error", "error", err)
 		}
 	}()
 
 	// API de configuración
 	configAPI := infrastructure.NewConfigAPI(configManager)
 	configAPI.SetLoadBalancer(enterpriseBalancer)
+	configAPI.SetHealthChecker(healthChecker)
+	configAPI.SetProxyMetrics(proxyService)
+	configAPI.SetMetricsSimulator(proxyService)
 	go func() {
-		log.Println("Config API starting on :8082")
+		slog.Info("Config API starting", "addr", ":8082")
 		http.ListenAndServe(":8082", configAPI)
 	}()
 
-	// Servidor HTTP
+	// Servidor HTTP (acepta también HTTP/2 sin TLS para clientes gRPC)
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", config.Proxy.Port),
-		Handler: proxyService,
+		Handler: h2c.NewHandler(proxyService, &http2.Server{}),
+	}
+	// Con proxy.tls el puerto termina TLS; el SNI llega a la request en r.TLS
+	if config.Proxy.TLS != nil {
+		tlsConfig, err := infrastructure.NewProxyTLSConfig(config.Proxy.TLS)
+		if err != nil {
+			slog.Error("Failed to load proxy TLS certificates", "error", err)
+			os.Exit(1)
+		}
+		server.TLSConfig = tlsConfig
 	}
 
 	// Métricas en goroutine separada
@@ -100,13 +180,24 @@ func main() {
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		<-sigCh
 
-		log.Println("Shutting down...")
+		slog.Info("Shutting down")
 		triggerService.Stop()
+		serverDiscovery.Stop()
+		healthChecker.Stop()
+		proxyService.StopSessionSweeper()
+		if metricsPersister != nil {
+			metricsPersister.Stop()
+		}
 		server.Close()
 	}()
 
-	log.Printf("Proxy server starting on port %d", config.Proxy.Port)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		log.Fatal("Server error:", err)
+	slog.Info("Proxy server starting", "port", config.Proxy.Port, "tls", server.TLSConfig != nil)
+	serve := server.ListenAndServe
+	if server.TLSConfig != nil {
+		serve = func() error { return server.ListenAndServeTLS("", "") }
+	}
+	if err := serve(); err != http.ErrServerClosed {
+		slog.Error("Server error", "error", err)
+		os.Exit(1)
 	}
 }
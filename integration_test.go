@@ -61,7 +61,7 @@ actions:
 	}
 
 	loadBalancer := infrastructure.NewEnterpriseBalancer()
-	healthChecker := infrastructure.NewHealthChecker()
+	healthChecker := infrastructure.NewAdvancedHealthChecker()
 	actionExecutor := infrastructure.NewHTTPActionExecutor()
 	
 	proxyService := application.NewProxyService(loadBalancer, healthChecker)
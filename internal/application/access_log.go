@@ -0,0 +1,62 @@
+package application
+
+import (
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// accessRecorder captura el status y los bytes de la respuesta para el access
+// log. Unwrap permite a http.ResponseController (flush, hijack de WebSocket)
+// llegar al ResponseWriter original.
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (a *accessRecorder) WriteHeader(status int) {
+	// Las respuestas 1xx no son la respuesta final, salvo el upgrade a WebSocket
+	if a.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		a.status = status
+	}
+	a.ResponseWriter.WriteHeader(status)
+}
+
+func (a *accessRecorder) Write(p []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(p)
+	a.bytes += int64(n)
+	return n, err
+}
+
+func (a *accessRecorder) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// shouldLogAccess aplica el muestreo; los errores del servidor no se descartan
+func shouldLogAccess(logging *domain.LoggingConfig, status int) bool {
+	if status >= 500 {
+		return true
+	}
+	return rand.Float64() < logging.AccessLogSampleRate()
+}
+
+func (p *ProxyServiceImpl) logAccess(logging *domain.LoggingConfig, rec *accessRecorder, r *http.Request, clientIP string, start time.Time) {
+	status := rec.status
+	if status == 0 {
+		// Sin respuesta escrita: el cliente se fue antes de que llegara
+		status = 499
+	}
+	if !shouldLogAccess(logging, status) {
+		return
+	}
+	slog.Info("access", "request_id", r.Header.Get(requestIDHeader), "client_ip", clientIP,
+		"method", r.Method, "uri", r.URL.RequestURI(), "proto", r.Proto,
+		"status", status, "bytes", rec.bytes, "duration", time.Since(start))
+}
@@ -0,0 +1,159 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// bodyRouter es un body_match ya compilado
+type bodyRouter struct {
+	routes       []*bodyRoute
+	maxBodyBytes int64
+}
+
+// bodyRoute es una regla body_match; los servidores permitidos se guardan en
+// un headerRoute para compartir la selección y los reintentos con header_match
+type bodyRoute struct {
+	path  []string
+	value string
+	regex *regexp.Regexp
+	route *headerRoute
+}
+
+// compileBodyMatch devuelve nil si body_match no está activado. La
+// configuración ya fue validada; una regla inválida se descarta con un log.
+func compileBodyMatch(cfg *domain.BodyMatchCfg) *bodyRouter {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	router := &bodyRouter{maxBodyBytes: cfg.MaxBodyBytes}
+	if router.maxBodyBytes <= 0 {
+		router.maxBodyBytes = domain.DefaultBodyMatchMaxBodyBytes
+	}
+	for _, rule := range cfg.Rules {
+		if rule.Path == "" || len(rule.Servers) == 0 {
+			slog.Warn("body_match rule ignored: path and servers are required")
+			continue
+		}
+		route := &bodyRoute{
+			path:  strings.Split(rule.Path, "."),
+			value: rule.Value,
+			route: &headerRoute{servers: make(map[string]bool, len(rule.Servers))},
+		}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				slog.Warn("body_match rule ignored: invalid regex", "path", rule.Path, "error", err)
+				continue
+			}
+			route.regex = re
+		}
+		for _, serverURL := range rule.Servers {
+			route.route.servers[serverURL] = true
+		}
+		router.routes = append(router.routes, route)
+	}
+	return router
+}
+
+func (b *bodyRoute) matches(doc interface{}) bool {
+	value, ok := jsonField(doc, b.path)
+	if !ok {
+		return false
+	}
+	switch {
+	case b.regex != nil:
+		return b.regex.MatchString(value)
+	case b.value != "":
+		return value == b.value
+	default:
+		return true
+	}
+}
+
+// jsonField recorre el documento por las claves del path y devuelve el valor
+// como texto: las cadenas tal cual y el resto en su forma JSON (42, true, null)
+func jsonField(doc interface{}, path []string) (string, bool) {
+	for _, key := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, exists := node[key]
+			if !exists {
+				return "", false
+			}
+			doc = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			doc = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	if value, ok := doc.(string); ok {
+		return value, true
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
+
+// replayBody vuelve a presentar al upstream lo ya leído del cuerpo seguido de
+// lo que quede; Close cierra el cuerpo original
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// matchBodyRoute lee el cuerpo hasta max_body_bytes, lo deja de nuevo en
+// r.Body y devuelve la primera regla que coincide. Un cuerpo mayor, vacío o
+// que no es JSON no se inspecciona y sigue sin regla; el error solo se
+// devuelve si falla la lectura, con el cuerpo ya perdido.
+func (p *ProxyServiceImpl) matchBodyRoute(r *http.Request) (*headerRoute, error) {
+	p.mu.RLock()
+	router := p.bodyRouter
+	p.mu.RUnlock()
+
+	if router == nil || r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil, nil
+	}
+	if r.ContentLength > router.maxBodyBytes {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, router.maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+	if int64(len(body)) > router.maxBodyBytes {
+		return nil, nil
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil
+	}
+	for _, route := range router.routes {
+		if route.matches(doc) {
+			return route.route, nil
+		}
+	}
+	return nil, nil
+}
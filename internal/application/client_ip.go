@@ -0,0 +1,72 @@
+package application
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// getClientIP devuelve la IP del cliente, que alimenta el hash consistente y
+// los logs. X-Forwarded-For y X-Real-IP solo se aceptan si la conexión llega
+// desde un proxy de trusted_proxies; si no, cualquier cliente podría falsearlos.
+func (p *ProxyServiceImpl) getClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	p.mu.RLock()
+	trusted := p.trustedProxies
+	p.mu.RUnlock()
+
+	if !isTrustedProxy(trusted, net.ParseIP(remote)) {
+		return remote
+	}
+
+	// De derecha a izquierda: cada proxy de confianza añadió la IP de quien le
+	// conectó, así que el cliente es la primera dirección que no es de confianza
+	if hops := forwardedHops(r); len(hops) > 0 {
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(hops[i])
+			if ip == nil {
+				break
+			}
+			client = hops[i]
+			if !isTrustedProxy(trusted, ip) {
+				break
+			}
+		}
+		return client
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+	return remote
+}
+
+// forwardedHops une todas las cabeceras X-Forwarded-For en orden
+func forwardedHops(r *http.Request) []string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+func isTrustedProxy(trusted []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// backendLimiter cuenta las requests en curso de un backend y, con
+// max_concurrent_requests, las limita con un semáforo
+type backendLimiter struct {
+	slots    chan struct{} // nil sin límite
+	inFlight *int64        // compartido entre recargas para no perder la cuenta
+	waiting  int64
+	rejected *int64 // requests sin hueco; también se conserva entre recargas
+}
+
+// buildLimiters crea los limitadores de la nueva configuración. Un backend que
+// conserva su límite mantiene el semáforo, con las requests que ya lo ocupan.
+func buildLimiters(backends []domain.Backend, previous map[string]*backendLimiter) map[string]*backendLimiter {
+	limiters := make(map[string]*backendLimiter, len(backends))
+	for _, backend := range backends {
+		old := previous[backend.Name]
+		if old != nil && cap(old.slots) == backend.MaxConcurrentRequests {
+			limiters[backend.Name] = old
+			continue
+		}
+
+		limiter := &backendLimiter{inFlight: new(int64), rejected: new(int64)}
+		if old != nil {
+			limiter.inFlight = old.inFlight
+			limiter.rejected = old.rejected
+		}
+		if backend.MaxConcurrentRequests > 0 {
+			limiter.slots = make(chan struct{}, backend.MaxConcurrentRequests)
+		}
+		limiters[backend.Name] = limiter
+	}
+	return limiters
+}
+
+// acquireBackendSlot reserva un hueco del backend. Con la cola habilitada espera
+// hasta max_wait; si no, falla al momento. La función devuelta libera el hueco
+// y puede llamarse más de una vez.
+func (p *ProxyServiceImpl) acquireBackendSlot(r *http.Request, backend *domain.Backend) (func(), bool) {
+	p.mu.RLock()
+	limiter := p.limiters[backend.Name]
+	p.mu.RUnlock()
+	if limiter == nil {
+		return func() {}, true
+	}
+
+	if limiter.slots != nil {
+		select {
+		case limiter.slots <- struct{}{}:
+		default:
+			if !limiter.wait(r, backend.Queue) {
+				// Un cliente que abandona no es presión del backend
+				if r.Context().Err() != context.Canceled {
+					atomic.AddInt64(limiter.rejected, 1)
+				}
+				return nil, false
+			}
+		}
+	}
+
+	atomic.AddInt64(limiter.inFlight, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			atomic.AddInt64(limiter.inFlight, -1)
+			if limiter.slots != nil {
+				<-limiter.slots
+			}
+		})
+	}, true
+}
+
+func (l *backendLimiter) wait(r *http.Request, queue domain.QueueCfg) bool {
+	if !queue.IsEnabled() {
+		return false
+	}
+	if atomic.AddInt64(&l.waiting, 1) > int64(queue.MaxDepth) {
+		atomic.AddInt64(&l.waiting, -1)
+		return false
+	}
+	defer atomic.AddInt64(&l.waiting, -1)
+
+	timer := time.NewTimer(queue.MaxWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// backendInFlight devuelve las requests en curso de cada backend
+func (p *ProxyServiceImpl) backendInFlight() map[string]int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	inFlight := make(map[string]int64, len(p.limiters))
+	for name, limiter := range p.limiters {
+		inFlight[name] = atomic.LoadInt64(limiter.inFlight)
+	}
+	return inFlight
+}
+
+// BackendQueueStats implementa domain.QueueStatsProvider
+func (p *ProxyServiceImpl) BackendQueueStats() map[string]domain.QueueStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	stats := make(map[string]domain.QueueStats, len(p.limiters))
+	for name, limiter := range p.limiters {
+		stats[name] = domain.QueueStats{
+			Depth:    atomic.LoadInt64(&limiter.waiting),
+			Rejected: atomic.LoadInt64(limiter.rejected),
+		}
+	}
+	return stats
+}
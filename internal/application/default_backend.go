@@ -0,0 +1,96 @@
+package application
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// defaultBackend reenvía a proxy.default_backend las requests que no tienen
+// backend o cuyo backend se quedó sin servidores disponibles
+type defaultBackend struct {
+	target   *url.URL
+	inFlight int64
+	requests int64
+	failures int64
+}
+
+// buildDefaultBackend conserva los contadores mientras el upstream no cambie;
+// la URL ya fue validada
+func buildDefaultBackend(rawURL string, previous *defaultBackend) *defaultBackend {
+	if rawURL == "" {
+		return nil
+	}
+	if previous != nil && previous.target.String() == rawURL {
+		return previous
+	}
+	target, err := domain.ParseServerURL(rawURL)
+	if err != nil {
+		return nil
+	}
+	return &defaultBackend{target: target}
+}
+
+// serveDefaultBackend devuelve false si no hay default_backend configurado
+func (p *ProxyServiceImpl) serveDefaultBackend(w http.ResponseWriter, r *http.Request, start time.Time) bool {
+	p.mu.RLock()
+	fallback := p.fallback
+	p.mu.RUnlock()
+	if fallback == nil {
+		return false
+	}
+
+	atomic.AddInt64(&fallback.requests, 1)
+	atomic.AddInt64(&fallback.inFlight, 1)
+	defer atomic.AddInt64(&fallback.inFlight, -1)
+
+	proxy := httputil.NewSingleHostReverseProxy(fallback.target)
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		resp.Header.Del(requestIDHeader)
+
+		p.mu.RLock()
+		currentConfig := p.config
+		p.mu.RUnlock()
+		if currentConfig != nil {
+			injectResponseHeaders(resp, &currentConfig.Proxy, &domain.Backend{})
+			injectUpstreamHeader(resp, &currentConfig.Proxy, fallback.target.String())
+		}
+
+		success := resp.StatusCode < 500
+		if !success {
+			atomic.AddInt64(&fallback.failures, 1)
+		}
+		p.updateGlobalMetrics(time.Since(start), success)
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		atomic.AddInt64(&fallback.failures, 1)
+		p.updateGlobalMetrics(time.Since(start), false)
+
+		p.mu.RLock()
+		currentConfig := p.config
+		p.mu.RUnlock()
+
+		slog.Warn("Default backend request failed", "request_id", r.Header.Get(requestIDHeader), "method", r.Method,
+			"path", r.URL.Path, "default_backend", fallback.target.String(), "error", err)
+		p.writeError(w, r, currentConfig, http.StatusBadGateway, "Bad Gateway")
+	}
+	proxy.ServeHTTP(forwardInformational(w), r)
+	return true
+}
+
+// defaultBackendStats devuelve requests en curso, totales y fallidas del default_backend
+func (p *ProxyServiceImpl) defaultBackendStats() (inFlight, requests, failures int64, ok bool) {
+	p.mu.RLock()
+	fallback := p.fallback
+	p.mu.RUnlock()
+	if fallback == nil {
+		return 0, 0, 0, false
+	}
+	return atomic.LoadInt64(&fallback.inFlight), atomic.LoadInt64(&fallback.requests), atomic.LoadInt64(&fallback.failures), true
+}
@@ -0,0 +1,76 @@
+package application
+
+import (
+	"sync"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// errorClassifier es la configuración error_classification del backend. Los
+// pesos fraccionarios se acumulan por servidor y cada vez que la suma llega a
+// 1 la respuesta cuenta como fallo, así la tasa de error, el circuit breaker y
+// el SmartTrigger ven la proporción configurada sin cambiar UpdateStats
+type errorClassifier struct {
+	client float64
+	server float64
+	codes  map[int]float64
+
+	mu   *sync.Mutex
+	debt map[string]float64
+}
+
+// buildErrorClassifier conserva lo acumulado por servidor entre recargas
+func buildErrorClassifier(cfg *domain.ErrorClassificationCfg, previous *errorClassifier) *errorClassifier {
+	if cfg == nil {
+		return nil
+	}
+	classifier := &errorClassifier{
+		client: cfg.ClientErrors,
+		server: cfg.ServerErrorWeight(),
+		codes:  cfg.Codes,
+		mu:     &sync.Mutex{},
+		debt:   make(map[string]float64),
+	}
+	if previous != nil {
+		classifier.mu, classifier.debt = previous.mu, previous.debt
+	}
+	return classifier
+}
+
+// weight devuelve cuánto cuenta un código como fallo
+func (c *errorClassifier) weight(status int) float64 {
+	if weight, ok := c.codes[status]; ok {
+		return weight
+	}
+	switch {
+	case status >= 500:
+		return c.server
+	case status >= 400:
+		return c.client
+	}
+	return 0
+}
+
+// success indica si la respuesta cuenta como éxito para el servidor; sin
+// error_classification solo los 5xx son fallo
+func (c *errorClassifier) success(serverURL string, status int) bool {
+	if c == nil {
+		return status < 500
+	}
+	weight := c.weight(status)
+	if weight <= 0 {
+		return true
+	}
+	if weight >= 1 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.debt[serverURL] += weight
+	if c.debt[serverURL] >= 1 {
+		c.debt[serverURL]--
+		return false
+	}
+	return true
+}
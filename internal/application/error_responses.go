@@ -0,0 +1,75 @@
+package application
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// rejectReasonHeader acompaña a los 503 por falta de servidor para distinguir
+// si hace falta escalar (capacity) o arreglar los servidores (unhealthy)
+const rejectReasonHeader = "X-Proxy-Reject-Reason"
+
+// writeRejected responde 503 con el motivo del rechazo y lo cuenta
+func (p *ProxyServiceImpl) writeRejected(w http.ResponseWriter, r *http.Request, config *domain.Config, reason, message string) {
+	if reason == domain.RejectCapacity {
+		atomic.AddInt64(&p.metrics.CapacityRejections, 1)
+	} else {
+		atomic.AddInt64(&p.metrics.UnhealthyRejections, 1)
+	}
+	w.Header().Set(rejectReasonHeader, reason)
+	p.writeError(w, r, config, http.StatusServiceUnavailable, message)
+}
+
+// writeError responde con la página de error configurada para el status code
+// o, si no existe, con el texto plano por defecto.
+func (p *ProxyServiceImpl) writeError(w http.ResponseWriter, r *http.Request, config *domain.Config, statusCode int, message string) {
+	if config == nil {
+		http.Error(w, message, statusCode)
+		return
+	}
+
+	errorResponse, exists := config.Proxy.ErrorResponses[statusCode]
+	if !exists || errorResponse.Body == "" {
+		http.Error(w, message, statusCode)
+		return
+	}
+
+	contentType := errorResponse.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(renderErrorBody(errorResponse.Body, contentType, r, statusCode, message)))
+}
+
+// renderErrorBody sustituye las variables soportadas en el cuerpo:
+// {{status}}, {{status_text}}, {{message}} y {{request_id}}.
+func renderErrorBody(body, contentType string, r *http.Request, statusCode int, message string) string {
+	escape := func(value string) string { return value }
+	switch {
+	case strings.Contains(contentType, "html"):
+		escape = html.EscapeString
+	case strings.Contains(contentType, "json"):
+		escape = func(value string) string {
+			encoded, _ := json.Marshal(value)
+			return string(encoded[1 : len(encoded)-1])
+		}
+	}
+
+	replacer := strings.NewReplacer(
+		"{{status}}", strconv.Itoa(statusCode),
+		"{{status_text}}", escape(http.StatusText(statusCode)),
+		"{{message}}", escape(message),
+		"{{request_id}}", escape(r.Header.Get("X-Request-ID")),
+	)
+	return replacer.Replace(body)
+}
@@ -0,0 +1,60 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+	"github.com/juanbautista0/go-proxy/internal/infrastructure"
+)
+
+func TestProxyService_CustomErrorResponse(t *testing.T) {
+	lb := infrastructure.NewEnterpriseBalancer()
+	hc := &mockHealthChecker{}
+	service := NewProxyService(lb, hc)
+
+	config := &domain.Config{
+		Proxy: domain.ProxyConfig{
+			ErrorResponses: map[int]domain.ErrorResponseConfig{
+				http.StatusServiceUnavailable: {
+					Body:        `{"error":"{{status_text}}","request_id":"{{request_id}}"}`,
+					ContentType: "application/json",
+				},
+			},
+		},
+		Backends: []domain.Backend{
+			{Name: "test-backend", Servers: []domain.Server{}},
+		},
+	}
+	service.UpdateConfig(config)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", `abc"123`)
+	w := httptest.NewRecorder()
+
+	service.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected content type application/json, got %s", ct)
+	}
+	expected := `{"error":"Service Unavailable","request_id":"abc\"123"}`
+	if w.Body.String() != expected {
+		t.Errorf("expected body %s, got %s", expected, w.Body.String())
+	}
+}
+
+func TestRenderErrorBody_EscapesHTML(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "<script>")
+
+	body := renderErrorBody("<p>{{status}} {{request_id}}</p>", "text/html", req, http.StatusBadGateway, "")
+
+	expected := "<p>502 &lt;script&gt;</p>"
+	if body != expected {
+		t.Errorf("expected %s, got %s", expected, body)
+	}
+}
@@ -0,0 +1,70 @@
+package application
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// grpcFailureCodes son los grpc-status que indican un fallo del servidor
+// (UNKNOWN, DEADLINE_EXCEEDED, INTERNAL, UNAVAILABLE, DATA_LOSS). El resto,
+// como NOT_FOUND o INVALID_ARGUMENT, son errores de la llamada: igual que un
+// 4xx, no penalizan al servidor.
+var grpcFailureCodes = map[string]bool{
+	"2":  true,
+	"4":  true,
+	"13": true,
+	"14": true,
+	"15": true,
+}
+
+func isGRPCResponse(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc")
+}
+
+// grpcSucceeded lee grpc-status de los trailers o, en respuestas
+// trailers-only, de las cabeceras. Sin grpc-status el stream está roto.
+func grpcSucceeded(resp *http.Response) bool {
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+	}
+	return status != "" && !grpcFailureCodes[status]
+}
+
+// grpcStatusBody retrasa el reporte al balanceador hasta el final del stream.
+// Así la conexión activa del servidor cubre la vida del stream y no solo la
+// espera de cabeceras, y least-connections reparte por streams en curso.
+type grpcStatusBody struct {
+	io.ReadCloser
+	resp   *http.Response
+	report func(success bool)
+	once   sync.Once
+}
+
+func newGRPCStatusBody(resp *http.Response, report func(success bool)) *grpcStatusBody {
+	return &grpcStatusBody{ReadCloser: resp.Body, resp: resp, report: report}
+}
+
+func (b *grpcStatusBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	switch {
+	case err == io.EOF:
+		b.finish(grpcSucceeded(b.resp))
+	case err != nil:
+		b.finish(false)
+	}
+	return n, err
+}
+
+// Close antes de EOF significa que el cliente canceló: no es culpa del servidor
+func (b *grpcStatusBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(true)
+	return err
+}
+
+func (b *grpcStatusBody) finish(success bool) {
+	b.once.Do(func() { b.report(success) })
+}
@@ -0,0 +1,136 @@
+package application
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+	"github.com/juanbautista0/go-proxy/internal/infrastructure"
+)
+
+// headerRoute es una regla header_match ya compilada
+type headerRoute struct {
+	header     string
+	value      string
+	regex      *regexp.Regexp
+	serverName string
+	servers    map[string]bool
+}
+
+// compileHeaderRoutes compila las reglas del backend; las reglas inválidas se
+// descartan con un log para no tumbar la recarga de configuración
+func compileHeaderRoutes(rules []domain.HeaderMatchRule) []*headerRoute {
+	var routes []*headerRoute
+	for _, rule := range rules {
+		if (rule.Header == "" && rule.ServerName == "") || len(rule.Servers) == 0 {
+			slog.Warn("header_match rule ignored: header or server_name and servers are required")
+			continue
+		}
+
+		route := &headerRoute{
+			header:     http.CanonicalHeaderKey(rule.Header),
+			value:      rule.Value,
+			serverName: strings.ToLower(rule.ServerName),
+			servers:    make(map[string]bool, len(rule.Servers)),
+		}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				slog.Warn("header_match rule ignored: invalid regex", "header", rule.Header, "error", err)
+				continue
+			}
+			route.regex = re
+		}
+		for _, serverURL := range rule.Servers {
+			route.servers[serverURL] = true
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func (h *headerRoute) matches(r *http.Request) bool {
+	if h.serverName != "" && !matchServerName(h.serverName, requestServerName(r)) {
+		return false
+	}
+	if h.header == "" {
+		return true
+	}
+	values := r.Header.Values(h.header)
+	for _, value := range values {
+		switch {
+		case h.regex != nil:
+			if h.regex.MatchString(value) {
+				return true
+			}
+		case h.value != "":
+			if value == h.value {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func (h *headerRoute) allows(server *domain.Server) bool {
+	return h.servers[server.URL]
+}
+
+// matchHeaderRoute devuelve la primera regla que coincide con la request
+func (p *ProxyServiceImpl) matchHeaderRoute(r *http.Request) *headerRoute {
+	p.mu.RLock()
+	routes := p.headerRoutes
+	p.mu.RUnlock()
+
+	for _, route := range routes {
+		if route.matches(r) {
+			return route
+		}
+	}
+	return nil
+}
+
+// selectServer delega en el balanceador limitando la selección a los
+// servidores de la regla, si la hay. Sin servidor devuelve el motivo; solo
+// EnterpriseBalancer distingue la falta de capacidad, con otros balanceadores
+// es siempre domain.RejectUnhealthy.
+func (p *ProxyServiceImpl) selectServer(backend *domain.Backend, clientIP string, route *headerRoute) (*domain.Server, string) {
+	if eb, ok := p.loadBalancer.(*infrastructure.EnterpriseBalancer); ok {
+		var allowed func(*domain.Server) bool
+		if route != nil {
+			allowed = route.allows
+		}
+		return eb.SelectServerWithReason(backend, clientIP, allowed)
+	}
+	server := p.loadBalancer.SelectServer(backend, clientIP)
+	if server != nil && (route == nil || route.allows(server)) {
+		return server, ""
+	}
+	return nil, domain.RejectUnhealthy
+}
+
+// selectRetryServer elige un servidor distinto de los ya intentados para
+// reintentar; con EnterpriseBalancer se descartan además circuitos abiertos y
+// servidores no sanos
+func (p *ProxyServiceImpl) selectRetryServer(backend *domain.Backend, clientIP string, route *headerRoute, tried map[string]bool) *domain.Server {
+	allowed := func(server *domain.Server) bool {
+		return !tried[server.URL] && (route == nil || route.allows(server))
+	}
+	if eb, ok := p.loadBalancer.(*infrastructure.EnterpriseBalancer); ok {
+		return eb.SelectRetryServer(backend, clientIP, allowed)
+	}
+	server := p.loadBalancer.SelectServer(backend, clientIP)
+	if server == nil {
+		return nil
+	}
+	if !allowed(server) {
+		// Liberar la conexión contada en la selección
+		p.loadBalancer.UpdateStats(server, 0, true)
+		return nil
+	}
+	return server
+}
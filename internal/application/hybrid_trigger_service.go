@@ -1,43 +1,72 @@
 package application
 
 import (
-	"log"
+	"log/slog"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/juanbautista0/go-proxy/internal/domain"
+	"github.com/juanbautista0/go-proxy/internal/infrastructure"
 )
 
-// HybridTriggerService - Wrapper que integra SmartTrigger con el sistema existente
+// HybridTriggerService - Wrapper que integra SmartTrigger con el sistema existente.
+// Mantiene un SmartTrigger por backend para que cada uno escale de forma independiente.
 type HybridTriggerService struct {
 	smartTrigger *SmartTriggerService
 	executor     domain.ActionExecutor
 	config       *domain.Config
 	stopCh       chan struct{}
 	running      bool
+	// Para solo el bucle de evaluación, que Reconfigure reinicia si cambia su intervalo
+	monitorStop chan struct{}
+
+	// mu protege triggers y config entre recargas y evaluaciones
+	mu       sync.Mutex
+	triggers map[string]*SmartTriggerService
+
+	metricsMu   sync.RWMutex
+	lastMetrics map[string]domain.TriggerMetrics
+	history     []domain.TriggerEvent
+	// Tiempo hasta que el score vuelve a la banda neutra tras cada acción
+	recovery map[recoveryKey]*infrastructure.LatencyHistogram
+
+	// Minuto en que se disparó cada entrada de triggers.schedule; protegido por mu
+	scheduleFired map[int]string
 }
 
+// maxTriggerHistory limita las acciones guardadas en memoria
+const maxTriggerHistory = 100
+
 func NewHybridTriggerService(smartTrigger *SmartTriggerService, executor domain.ActionExecutor) *HybridTriggerService {
 	return &HybridTriggerService{
 		smartTrigger: smartTrigger,
 		executor:     executor,
+		triggers:     make(map[string]*SmartTriggerService),
+		lastMetrics:  make(map[string]domain.TriggerMetrics),
+		recovery:     make(map[recoveryKey]*infrastructure.LatencyHistogram),
 	}
 }
 
 func (h *HybridTriggerService) Start(config *domain.Config, metrics *domain.TrafficMetrics) error {
+	h.mu.Lock()
 	h.config = config
+	h.syncBackendTriggers(config)
+	h.scheduleFired = make(map[int]string)
+	h.mu.Unlock()
+
 	h.stopCh = make(chan struct{})
+	h.monitorStop = make(chan struct{})
 	h.running = true
 
-	// Configurar SmartTrigger con parámetros del config
-	h.configureSmartTrigger(config)
-	h.smartTrigger.SetConfig(config)
-
 	// Iniciar monitoreo inteligente
-	go h.smartMonitorLoop()
+	go h.smartMonitorLoop(h.monitorStop, config.Triggers.Smart)
+	go h.scheduleLoop(h.stopCh)
 
-	log.Printf("🧠 Smart Trigger Service started - Interval: %v, Cooldown: %v",
-		config.Triggers.Smart.EvaluationInterval,
-		config.Triggers.Smart.Cooldown)
+	slog.Info("Smart trigger service started",
+		"interval", config.Triggers.Smart.EvaluationInterval,
+		"cooldown", config.Triggers.Smart.Cooldown,
+		"dry_run", config.Triggers.Smart.DryRun)
 
 	return nil
 }
@@ -46,100 +75,285 @@ func (h *HybridTriggerService) Stop() error {
 	if h.running {
 		h.running = false
 		close(h.stopCh)
-		log.Println("🛑 Smart Trigger Service stopped")
+		close(h.monitorStop)
+		slog.Info("Smart trigger service stopped")
 	}
 	return nil
 }
 
-// configureSmartTrigger - Configura el SmartTrigger con parámetros del YAML
-func (h *HybridTriggerService) configureSmartTrigger(config *domain.Config) {
+// Reconfigure aplica una config recargada sin reiniciar el servicio: cada
+// backend conserva su SmartTrigger con el cooldown y el historial de sus
+// ventanas, que solo se vacían si cambia su tamaño. El bucle de evaluación se
+// reinicia únicamente si cambian evaluation_interval o jitter.
+func (h *HybridTriggerService) Reconfigure(config *domain.Config) error {
+	if !h.running {
+		return h.Start(config, nil)
+	}
+
+	h.mu.Lock()
+	previous := h.config.Triggers.Smart
+	h.config = config
+	h.syncBackendTriggers(config)
+	h.mu.Unlock()
+
 	smart := config.Triggers.Smart
+	if previous.EvaluationInterval != smart.EvaluationInterval || previous.JitterFraction() != smart.JitterFraction() {
+		close(h.monitorStop)
+		h.monitorStop = make(chan struct{})
+		go h.smartMonitorLoop(h.monitorStop, smart)
+		slog.Info("Smart trigger evaluation loop restarted", "interval", smart.EvaluationInterval)
+	}
+	return nil
+}
+
+// syncBackendTriggers crea o reconfigura un SmartTrigger por backend. Los
+// existentes se conservan entre recargas para no perder su cooldown; el primer
+// backend usa el SmartTrigger recibido en el constructor.
+func (h *HybridTriggerService) syncBackendTriggers(config *domain.Config) {
+	current := make(map[string]*SmartTriggerService, len(config.Backends))
+	for i := range config.Backends {
+		backend := &config.Backends[i]
+
+		trigger, exists := h.triggers[backend.Name]
+		if !exists {
+			if len(current) == 0 && !h.isTracked(h.smartTrigger) {
+				trigger = h.smartTrigger
+			} else {
+				trigger = NewSmartTriggerService(h.executor, h.smartTrigger.proxyService)
+			}
+		}
+
+		trigger.SetConfig(config)
+		trigger.SetBackend(backend)
+		h.configureSmartTrigger(trigger, backend.Name, backend.EffectiveSmartTrigger(config.Triggers.Smart))
+		current[backend.Name] = trigger
+	}
+	h.triggers = current
+
+	// Descartar métricas de backends eliminados
+	h.metricsMu.Lock()
+	for name := range h.lastMetrics {
+		if _, ok := current[name]; !ok {
+			delete(h.lastMetrics, name)
+		}
+	}
+	for key := range h.recovery {
+		if _, ok := current[key.backend]; !ok {
+			delete(h.recovery, key)
+		}
+	}
+	h.metricsMu.Unlock()
+}
+
+func (h *HybridTriggerService) isTracked(trigger *SmartTriggerService) bool {
+	for _, existing := range h.triggers {
+		if existing == trigger {
+			return true
+		}
+	}
+	return false
+}
 
+// configureSmartTrigger - Configura el SmartTrigger con parámetros del YAML
+func (h *HybridTriggerService) configureSmartTrigger(trigger *SmartTriggerService, backendName string, smart domain.SmartTrigger) {
 	// Actualizar configuración del SmartTrigger
-	h.smartTrigger.thresholds.ScaleUp = smart.ScaleUpScore
-	h.smartTrigger.thresholds.ScaleDown = smart.ScaleDownScore
-	h.smartTrigger.cooldownPeriod = smart.Cooldown
+	trigger.thresholds.ScaleUp = smart.ScaleUpScore
+	trigger.thresholds.ScaleDown = smart.ScaleDownScore
+	trigger.weights = scoreWeights(smart.ScoreWeights())
+	trigger.cooldownPeriod = smart.Cooldown
 
 	// Recrear ventanas de tiempo con nueva configuración
 	shortSamples := int(smart.ShortWindow.Seconds() / smart.EvaluationInterval.Seconds())
 	longSamples := int(smart.LongWindow.Seconds() / (smart.EvaluationInterval.Seconds() * 6)) // 6x menos frecuente
 
-	h.smartTrigger.shortWindow = NewTimeWindow(smart.ShortWindow, max(shortSamples, 3))
-	h.smartTrigger.longWindow = NewTimeWindow(smart.LongWindow, max(longSamples, 3))
+	// Una recarga que no cambia el tamaño de una ventana conserva su historial
+	shortReset := resizeWindow(&trigger.shortWindow, smart.ShortWindow, max(shortSamples, 3))
+	longReset := resizeWindow(&trigger.longWindow, smart.LongWindow, max(longSamples, 3))
 
-	log.Printf("📊 Smart Trigger configured - Short: %v (%d samples), Long: %v (%d samples)",
-		smart.ShortWindow, shortSamples, smart.LongWindow, longSamples)
+	slog.Info("Smart trigger configured", "backend", backendName,
+		"short_window", smart.ShortWindow, "short_samples", shortSamples, "short_reset", shortReset,
+		"long_window", smart.LongWindow, "long_samples", longSamples, "long_reset", longReset,
+		"cooldown", smart.Cooldown)
+}
+
+// resizeWindow recrea la ventana solo si cambió su duración o sus muestras
+func resizeWindow(window **TimeWindow, duration time.Duration, samples int) bool {
+	if *window != nil && (*window).duration == duration && (*window).size == samples {
+		return false
+	}
+	*window = NewTimeWindow(duration, samples)
+	return true
 }
 
 // smartMonitorLoop - Loop principal del monitoreo inteligente
-func (h *HybridTriggerService) smartMonitorLoop() {
-	ticker := time.NewTicker(h.config.Triggers.Smart.EvaluationInterval)
-	defer ticker.Stop()
+func (h *HybridTriggerService) smartMonitorLoop(stop <-chan struct{}, smart domain.SmartTrigger) {
+	// Intervalo con jitter para que varias instancias no disparen webhooks a la vez
+	interval := smart.EvaluationInterval
+	jitter := smart.JitterFraction()
+	if interval <= 0 {
+		slog.Error("Smart trigger disabled: evaluation_interval must be positive", "interval", interval)
+		return
+	}
+	timer := time.NewTimer(domain.Jitter(interval, jitter))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			h.evaluateAndExecute()
-		case <-h.stopCh:
+			timer.Reset(domain.Jitter(interval, jitter))
+		case <-stop:
 			return
 		}
 	}
 }
 
-// evaluateAndExecute - Evalúa y ejecuta acciones basadas en SmartTrigger
+// evaluateAndExecute - Evalúa y ejecuta acciones basadas en SmartTrigger, un backend a la vez
 func (h *HybridTriggerService) evaluateAndExecute() {
-	// Obtener score detallado para debugging
-	scoreDetail := h.smartTrigger.CalculateScore()
-	decision := h.smartTrigger.EvaluateTrigger()
-
-	// Log detallado de componentes del score
-	log.Printf("📊 Score Components: RPS=%.6f, Latency=%.6f, Error=%.6f, Conn=%.6f, Total=%.6f",
-		scoreDetail.RPSScore, scoreDetail.LatencyScore, scoreDetail.ErrorScore, scoreDetail.ConnScore, scoreDetail.TotalScore)
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	// Log de decisión para debugging
-	log.Printf("🔍 Smart Decision: Action=%s, Score=%.6f, Trend=%s, Stability=%.6f, Confidence=%.6f, CanTrigger=%v",
-		decision.Action, decision.Score, decision.Trend, decision.Stability, decision.Confidence, decision.CanTrigger)
+	for i := range h.config.Backends {
+		backend := &h.config.Backends[i]
+		if trigger, ok := h.triggers[backend.Name]; ok {
+			h.evaluateBackend(backend, trigger)
+		}
+	}
+}
 
-	// Log de thresholds para comparación
-	log.Printf("⚖️  Thresholds: ScaleUp=%.6f, ScaleDown=%.6f, StabilityMin=%.6f",
-		h.config.Triggers.Smart.ScaleUpScore, h.config.Triggers.Smart.ScaleDownScore, h.config.Triggers.Smart.StabilityThreshold)
+// evaluateBackend requiere h.mu tomado
+func (h *HybridTriggerService) evaluateBackend(backend *domain.Backend, trigger *SmartTriggerService) {
+	decision := trigger.EvaluateTrigger()
+	scoreDetail := decision.Components
+	smart := trigger.smartConfig()
+
+	// Detalle de cada evaluación: solo en debug, se repite cada evaluation_interval
+	shortAvg := trigger.shortWindow.GetAverage()
+	longAvg := trigger.longWindow.GetAverage()
+	slog.Debug("Smart trigger evaluation", "backend", backend.Name,
+		slog.Group("score",
+			"rps", scoreDetail.RPSScore, "latency", scoreDetail.LatencyScore,
+			"error", scoreDetail.ErrorScore, "conn", scoreDetail.ConnScore,
+			"queue", scoreDetail.QueueScore, "total", scoreDetail.TotalScore),
+		slog.Group("decision",
+			"action", decision.Action, "score", decision.Score, "trend", decision.Trend,
+			"stability", decision.Stability, "confidence", decision.Confidence, "can_trigger", decision.CanTrigger),
+		slog.Group("thresholds",
+			"scale_up", smart.ScaleUpScore, "scale_down", smart.ScaleDownScore, "stability_min", smart.StabilityThreshold),
+		"short_avg", shortAvg, "long_avg", longAvg, "simulated", scoreDetail.Simulated,
+		"cooldown_remaining", trigger.effectiveCooldown()-time.Since(trigger.cooldownStart()))
+
+	if decision.DesiredServers > 0 {
+		slog.Debug("Smart trigger target tracking", "backend", backend.Name,
+			"rps", scoreDetail.RPS, "target_per_server", smart.TargetRPSPerServer,
+			"current_servers", decision.CurrentServers, "desired_servers", decision.DesiredServers)
+	}
 
-	// Log adicional para debugging
-	shortAvg := h.smartTrigger.shortWindow.GetAverage()
-	longAvg := h.smartTrigger.longWindow.GetAverage()
-	log.Printf("🔧 Debug: shortAvg=%.6f, longAvg=%.6f, cooldownRemaining=%.1fs",
-		shortAvg, longAvg, h.smartTrigger.cooldownPeriod.Seconds()-time.Since(h.smartTrigger.lastTrigger).Seconds())
+	h.observeRecovery(backend.Name, trigger, decision)
 
 	// Ejecutar acción si es necesario
 	if decision.Action != "none" && decision.CanTrigger {
-		h.executeSmartAction(decision)
+		h.executeSmartAction(backend, trigger, decision)
+		// lastTrigger solo avanza si la acción se ejecutó de verdad (no en dry_run)
+		if trigger.lastTrigger.Equal(decision.Timestamp) {
+			startRecovery(trigger, decision.Action, decision.Timestamp)
+		}
 	} else {
-		log.Printf("ℹ️  No action: %s", decision.Reason)
+		slog.Debug("Smart trigger no action", "backend", backend.Name, "reason", decision.Reason)
 	}
+
+	h.applyLoadShedding(backend, trigger, decision)
+
+	// Registrar después de ejecutar para que el cooldown refleje una acción recién disparada
+	h.recordMetrics(backend.Name, trigger, decision, shortAvg, longAvg)
 }
 
-// executeSmartAction - Ejecuta la acción determinada por el SmartTrigger
-func (h *HybridTriggerService) executeSmartAction(decision *TriggerDecision) {
+// applyLoadShedding fija en el proxy la fracción de requests del backend que se
+// descartan mientras hace falta escalar. En dry_run solo se registra.
+func (h *HybridTriggerService) applyLoadShedding(backend *domain.Backend, trigger *SmartTriggerService, decision *TriggerDecision) {
+	shedder, ok := trigger.proxyService.(domain.LoadShedder)
+	if !ok {
+		return
+	}
+
+	rate := 0.0
+	if backend.LoadShedding != nil {
+		rate = backend.LoadShedding.ShedRate(decision.Excess)
+	}
+	if rate > 0 && h.config.Triggers.Smart.DryRun {
+		slog.Debug("Smart trigger dry run: load shedding not applied", "backend", backend.Name,
+			"rate", rate, "excess", decision.Excess)
+		rate = 0
+	}
+
+	previous := shedder.SetShedRate(backend.Name, rate)
+	switch {
+	case rate > 0 && previous == 0:
+		slog.Warn("Load shedding started", "backend", backend.Name, "rate", rate, "excess", decision.Excess)
+	case rate == 0 && previous > 0:
+		slog.Info("Load shedding stopped", "backend", backend.Name)
+	}
+}
+
+// recordMetrics guarda la última evaluación del backend para el endpoint de métricas
+func (h *HybridTriggerService) recordMetrics(backendName string, trigger *SmartTriggerService, decision *TriggerDecision, shortAvg, longAvg float64) {
+	h.metricsMu.Lock()
+	defer h.metricsMu.Unlock()
+
+	score := decision.Components
+	h.lastMetrics[backendName] = domain.TriggerMetrics{
+		Backend:       backendName,
+		RPSScore:      score.RPSScore,
+		LatencyScore:  score.LatencyScore,
+		ErrorScore:    score.ErrorScore,
+		ConnScore:     score.ConnScore,
+		QueueScore:    score.QueueScore,
+		TotalScore:    score.TotalScore,
+		ShortAvg:      shortAvg,
+		LongAvg:       longAvg,
+		TrendSlope:    decision.Slope,
+		Stability:     decision.Stability,
+		Confidence:    decision.Confidence,
+		CooldownUntil: trigger.cooldownStart().Add(trigger.effectiveCooldown()),
+		EvaluatedAt:   decision.Timestamp,
+		Simulated:     score.Simulated,
+	}
+}
+
+// GetTriggerMetrics implementa domain.TriggerMetricsProvider
+func (h *HybridTriggerService) GetTriggerMetrics() []domain.TriggerMetrics {
+	h.metricsMu.RLock()
+	defer h.metricsMu.RUnlock()
+
+	metrics := make([]domain.TriggerMetrics, 0, len(h.lastMetrics))
+	for _, m := range h.lastMetrics {
+		metrics = append(metrics, m)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Backend < metrics[j].Backend })
+	return metrics
+}
+
+// executeSmartAction - Ejecuta la acción determinada por el SmartTrigger del backend
+func (h *HybridTriggerService) executeSmartAction(backend *domain.Backend, trigger *SmartTriggerService, decision *TriggerDecision) {
 	var actionName string
-	var emoji string
+
+	highAction, lowAction := backend.ScaleActions(h.config.Triggers.Traffic)
 
 	switch decision.Action {
 	case "scale_up":
 		// VALIDACIÓN CRÍTICA: Verificar max_servers antes de scale_up
-		if !h.canScaleUp() {
+		if !h.canScaleUp(backend, trigger, decision.Timestamp) {
 			// Scale up blocked: Already at maximum servers
 			return
 		}
-		actionName = h.config.Triggers.Traffic.HighAction
-		emoji = "🚀"
+		actionName = highAction
 	case "scale_down":
 		// VALIDACIÓN CRÍTICA: Verificar min_servers antes de scale_down
-		if !h.canScaleDown() {
+		if !h.canScaleDown(backend, trigger) {
 			//Scale down blocked: Already at minimum servers
 			return
 		}
-		actionName = h.config.Triggers.Traffic.LowAction
-		emoji = "📉"
+		actionName = lowAction
 	default:
 		return
 	}
@@ -147,75 +361,206 @@ func (h *HybridTriggerService) executeSmartAction(decision *TriggerDecision) {
 	// Buscar configuración de la acción
 	actionConfig, exists := h.config.Actions[actionName]
 	if !exists {
-		log.Printf("❌ Action '%s' not found in config", actionName)
+		slog.Error("Smart trigger action not found in config", "backend", backend.Name, "action", actionName)
 		return
 	}
 
-	// Ejecutar acción
-	err := h.executor.Execute(actionName, actionConfig)
-	if err != nil {
-		log.Printf("❌ Failed to execute %s: %v", actionName, err)
+	// target_tracking puede necesitar varios pasos para llegar a los servidores deseados
+	steps := 1
+	if decision.DesiredServers > 0 {
+		steps = decision.DesiredServers - decision.CurrentServers
+		if steps < 0 {
+			steps = -steps
+		}
+	}
+	// Los servidores que aún no están sanos ya cuentan para max_servers
+	if decision.Action == "scale_up" {
+		_, maxServers := serverLimits(backend)
+		if room := maxServers - configuredServerCount(backend, trigger); steps > room {
+			steps = room
+		}
+	}
+
+	// Dry run: registrar sin llamar al webhook ni tocar lastTrigger. Las
+	// decisiones sobre métricas sintéticas nunca ejecutan acciones reales.
+	if h.config.Triggers.Smart.DryRun || decision.simulated() {
+		trigger.simulatedTrigger = decision.Timestamp
+		h.recordRepeat(backend.Name, trigger, decision.Action)
+		h.recordEvent(backend.Name, actionName, decision, steps, true)
+		slog.Info("Smart trigger dry run: action not executed", "backend", backend.Name, "action", actionName,
+			"steps", steps, "score", decision.Score, "confidence", decision.Confidence, "reason", decision.Reason,
+			"simulated", decision.simulated())
 		return
 	}
 
+	// Ejecutar acción
+	for step := 1; step <= steps; step++ {
+		if decision.DesiredServers > 0 {
+			actionConfig.Payload = map[string]interface{}{
+				"backend":         backend.Name,
+				"action":          decision.Action,
+				"current_servers": decision.CurrentServers,
+				"desired_servers": decision.DesiredServers,
+				"step":            step,
+				"steps":           steps,
+			}
+		}
+		if err := h.executor.Execute(actionName, actionConfig); err != nil {
+			slog.Error("Smart trigger action failed", "backend", backend.Name, "action", actionName,
+				"step", step, "steps", steps, "error", err)
+			if step == 1 {
+				return
+			}
+			steps = step - 1
+			break
+		}
+	}
+
 	// Actualizar estado del SmartTrigger
-	h.smartTrigger.lastTrigger = decision.Timestamp
-	h.smartTrigger.lastAction = decision.Action
+	trigger.lastTrigger = decision.Timestamp
+	if decision.Action == "scale_up" {
+		trigger.scaleUpPending = decision.Timestamp
+		trigger.scaleUpBaseline = activeServerCount(trigger)
+	}
+	h.recordRepeat(backend.Name, trigger, decision.Action)
+	h.recordEvent(backend.Name, actionName, decision, steps, false)
 
-	// Log exitoso
-	log.Printf("%s SMART TRIGGER: %s executed (Score: %.3f, Confidence: %.3f, Reason: %s)",
-		emoji, actionName, decision.Score, decision.Confidence, decision.Reason)
+	slog.Info("Smart trigger action executed", "backend", backend.Name, "action", actionName,
+		"steps", steps, "score", decision.Score, "confidence", decision.Confidence, "reason", decision.Reason)
 }
 
-// canScaleUp - Valida si se puede hacer scale up basado en max_servers
-func (h *HybridTriggerService) canScaleUp() bool {
-	// Obtener servidores activos actuales
-	serverStats := h.smartTrigger.proxyService.GetServerStats()
-	activeServers := 0
+// recordRepeat registra la acción en el SmartTrigger y avisa si el cooldown ha crecido
+func (h *HybridTriggerService) recordRepeat(backendName string, trigger *SmartTriggerService, action string) {
+	trigger.recordAction(action)
+	if cooldown := trigger.effectiveCooldown(); cooldown > trigger.cooldownPeriod {
+		slog.Info("Smart trigger cooldown extended", "backend", backendName, "action", action,
+			"repeats", trigger.repeatCount, "cooldown", cooldown)
+	}
+}
 
-	for _, server := range serverStats {
+// recordEvent añade la acción al historial, descartando las más antiguas
+func (h *HybridTriggerService) recordEvent(backendName, actionName string, decision *TriggerDecision, steps int, dryRun bool) {
+	h.metricsMu.Lock()
+	defer h.metricsMu.Unlock()
+
+	event := domain.TriggerEvent{
+		Backend:    backendName,
+		Action:     decision.Action,
+		ActionName: actionName,
+		Score:      decision.Score,
+		Confidence: decision.Confidence,
+		Reason:     decision.Reason,
+		DryRun:     dryRun,
+		Simulated:  decision.simulated(),
+		Timestamp:  decision.Timestamp,
+	}
+	if decision.DesiredServers > 0 {
+		event.DesiredServers = decision.DesiredServers
+		event.Steps = steps
+	}
+	h.history = append(h.history, event)
+	if len(h.history) > maxTriggerHistory {
+		h.history = h.history[len(h.history)-maxTriggerHistory:]
+	}
+}
+
+// GetTriggerHistory implementa domain.TriggerMetricsProvider
+func (h *HybridTriggerService) GetTriggerHistory() []domain.TriggerEvent {
+	h.metricsMu.RLock()
+	defer h.metricsMu.RUnlock()
+	return append([]domain.TriggerEvent(nil), h.history...)
+}
+
+// activeServerCount cuenta los servidores sanos y activos del backend
+func activeServerCount(trigger *SmartTriggerService) int {
+	activeServers := 0
+	for _, server := range trigger.scopedServerStats() {
 		if server.Healthy && server.Active {
 			activeServers++
 		}
 	}
+	return activeServers
+}
 
-	// Obtener max_servers de la configuración
-	maxServers := 10 // Default de seguridad para evitar escalado infinito
-	if len(h.config.Backends) > 0 {
-		if h.config.Backends[0].MaxServers > 0 {
-			maxServers = h.config.Backends[0].MaxServers
-		}
+// configuredServerCount cuenta todos los servidores del backend, también los
+// que aún arrancan o no han pasado su health check
+func configuredServerCount(backend *domain.Backend, trigger *SmartTriggerService) int {
+	if backend != nil && len(backend.Servers) > 0 {
+		return len(backend.Servers)
 	}
+	return len(trigger.scopedServerStats())
+}
+
+// serverLimits devuelve min_servers y max_servers del backend con sus defaults de seguridad
+func serverLimits(backend *domain.Backend) (minServers, maxServers int) {
+	minServers = 1  // Default de seguridad para evitar outages
+	maxServers = 10 // Default de seguridad para evitar escalado infinito
+	if backend == nil {
+		return minServers, maxServers
+	}
+	if backend.MinServers > 0 {
+		minServers = backend.MinServers
+	}
+	if backend.MaxServers > 0 {
+		maxServers = backend.MaxServers
+	}
+	return minServers, maxServers
+}
+
+// canScaleUp - Valida si se puede hacer scale up basado en max_servers del
+// backend. Cuentan todos los servidores configurados, sanos o no, y el
+// scale-up anterior debe haber aportado un servidor sano: así unos servidores
+// lentos en arrancar no provocan un scale-up tras otro.
+func (h *HybridTriggerService) canScaleUp(backend *domain.Backend, trigger *SmartTriggerService, now time.Time) bool {
+	configuredServers := configuredServerCount(backend, trigger)
+	activeServers := activeServerCount(trigger)
+	_, maxServers := serverLimits(backend)
 
-	log.Printf("📊 Server Count Check: Active=%d, Max=%d, CanScaleUp=%v",
-		activeServers, maxServers, activeServers < maxServers)
+	slog.Debug("Smart trigger server count check", "backend", backend.Name,
+		"configured", configuredServers, "active", activeServers, "max", maxServers,
+		"can_scale_up", configuredServers < maxServers)
 
 	// Solo permitir scale up si tenemos menos servidores que el máximo
-	return activeServers < maxServers
+	if configuredServers >= maxServers {
+		return false
+	}
+	return h.scaleUpSettled(backend, trigger, activeServers, now)
 }
 
-// canScaleDown - Valida si se puede hacer scale down basado en min_servers
-func (h *HybridTriggerService) canScaleDown() bool {
-	// Obtener servidores activos actuales
-	serverStats := h.smartTrigger.proxyService.GetServerStats()
-	activeServers := 0
-
-	for _, server := range serverStats {
-		if server.Healthy && server.Active {
-			activeServers++
-		}
+// scaleUpSettled indica si el último scale-up ya tuvo efecto: hay más
+// servidores sanos que cuando se disparó o venció scale_up_settle_timeout
+func (h *HybridTriggerService) scaleUpSettled(backend *domain.Backend, trigger *SmartTriggerService, activeServers int, now time.Time) bool {
+	if trigger.scaleUpPending.IsZero() {
+		return true
+	}
+	if activeServers > trigger.scaleUpBaseline {
+		trigger.scaleUpPending = time.Time{}
+		return true
 	}
 
-	// Obtener min_servers de la configuración
-	minServers := 1 // Default de seguridad para evitar outages
-	if len(h.config.Backends) > 0 {
-		if h.config.Backends[0].MinServers > 0 {
-			minServers = h.config.Backends[0].MinServers
-		}
+	timeout := trigger.smartConfig().ScaleUpSettleTimeout
+	if timeout <= 0 {
+		timeout = domain.DefaultScaleUpSettleTimeout
+	}
+	if waited := now.Sub(trigger.scaleUpPending); waited >= timeout {
+		slog.Warn("Smart trigger scale-up did not add a healthy server", "backend", backend.Name,
+			"waited", waited, "healthy", activeServers)
+		trigger.scaleUpPending = time.Time{}
+		return true
 	}
 
-	log.Printf("📊 Server Count Check: Active=%d, Min=%d, CanScaleDown=%v",
-		activeServers, minServers, activeServers > minServers)
+	slog.Debug("Smart trigger scale up blocked: previous scale-up pending", "backend", backend.Name,
+		"healthy", activeServers, "baseline", trigger.scaleUpBaseline, "since", trigger.scaleUpPending)
+	return false
+}
+
+// canScaleDown - Valida si se puede hacer scale down basado en min_servers del backend
+func (h *HybridTriggerService) canScaleDown(backend *domain.Backend, trigger *SmartTriggerService) bool {
+	activeServers := activeServerCount(trigger)
+	minServers, _ := serverLimits(backend)
+
+	slog.Debug("Smart trigger server count check", "backend", backend.Name,
+		"active", activeServers, "min", minServers, "can_scale_down", activeServers > minServers)
 
 	// Solo permitir scale down si tenemos más servidores que el mínimo
 	return activeServers > minServers
@@ -0,0 +1,64 @@
+package application
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errUpstreamStalled se devuelve cuando el servidor deja de enviar el cuerpo
+// durante más de body_idle_timeout
+var errUpstreamStalled = errors.New("upstream response body stalled")
+
+// idleTimeoutBody aborta la copia del cuerpo si una lectura espera más de
+// timeout sin recibir datos: cerrar el cuerpo corta la conexión con el servidor
+// y desbloquea la lectura. El reloj solo corre mientras se espera al servidor,
+// no mientras el cliente consume. Como grpcStatusBody, retrasa el reporte al
+// balanceador hasta el final del cuerpo para que el atasco cuente como fallo.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+	report  func(stalled bool)
+	once    sync.Once
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, report func(stalled bool)) *idleTimeoutBody {
+	b := &idleTimeoutBody{ReadCloser: body, timeout: timeout, report: report}
+	b.timer = time.AfterFunc(timeout, func() {
+		b.stalled.Store(true)
+		body.Close()
+	})
+	b.timer.Stop()
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+
+	if b.stalled.Load() {
+		b.finish(true)
+		return n, errUpstreamStalled
+	}
+	if err != nil {
+		b.finish(false)
+	}
+	return n, err
+}
+
+// Close antes del final significa que el cliente canceló: no es un atasco
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.finish(b.stalled.Load())
+	return err
+}
+
+func (b *idleTimeoutBody) finish(stalled bool) {
+	b.once.Do(func() { b.report(stalled) })
+}
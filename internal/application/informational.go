@@ -0,0 +1,64 @@
+package application
+
+import "net/http"
+
+// informationalWriter reenvía las respuestas 1xx del backend (100 Continue,
+// 103 Early Hints) sin perder las cabeceras que el proxy fijó antes de
+// reenviar, como X-Request-ID o la cookie de afinidad. httputil.ReverseProxy
+// vacía Header() tras escribir cada 1xx, y sin esto esas cabeceras viajarían
+// en la 1xx y faltarían en la respuesta final.
+type informationalWriter struct {
+	http.ResponseWriter
+	preset http.Header
+	// Se escribió una 1xx y Header() perdió las cabeceras del proxy
+	cleared bool
+}
+
+// forwardInformational envuelve w justo antes de reenviar la request
+func forwardInformational(w http.ResponseWriter) http.ResponseWriter {
+	return &informationalWriter{ResponseWriter: w, preset: w.Header().Clone()}
+}
+
+func (iw *informationalWriter) WriteHeader(status int) {
+	header := iw.ResponseWriter.Header()
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		// ReverseProxy añade las cabeceras de la 1xx detrás de las del proxy
+		if !iw.cleared {
+			for key, values := range iw.preset {
+				if rest := header[key]; len(rest) >= len(values) {
+					header[key] = rest[len(values):]
+				}
+				if len(header[key]) == 0 {
+					delete(header, key)
+				}
+			}
+		}
+		iw.cleared = true
+		iw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	iw.restore()
+	iw.ResponseWriter.WriteHeader(status)
+}
+
+func (iw *informationalWriter) Write(p []byte) (int, error) {
+	iw.restore()
+	return iw.ResponseWriter.Write(p)
+}
+
+// restore devuelve las cabeceras del proxy delante de las del backend, como
+// habrían quedado sin respuestas 1xx
+func (iw *informationalWriter) restore() {
+	if !iw.cleared {
+		return
+	}
+	iw.cleared = false
+	header := iw.ResponseWriter.Header()
+	for key, values := range iw.preset {
+		header[key] = append(append([]string(nil), values...), header[key]...)
+	}
+}
+
+func (iw *informationalWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
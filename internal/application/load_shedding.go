@@ -0,0 +1,95 @@
+package application
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// loadShedder guarda la fracción de requests de un backend que se descarta,
+// fijada por el SmartTrigger, y cuántas se han descartado
+type loadShedder struct {
+	rate atomic.Uint64 // bits de un float64
+	shed int64
+}
+
+// SetShedRate implementa domain.LoadShedder
+func (p *ProxyServiceImpl) SetShedRate(backend string, rate float64) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	shedder := p.shedders[backend]
+	if shedder == nil {
+		if rate <= 0 {
+			return 0
+		}
+		if p.shedders == nil {
+			p.shedders = make(map[string]*loadShedder)
+		}
+		shedder = &loadShedder{}
+		p.shedders[backend] = shedder
+	}
+	return math.Float64frombits(shedder.rate.Swap(math.Float64bits(rate)))
+}
+
+// pruneShedders descarta el estado de los backends eliminados o sin
+// load_shedding; requiere p.mu tomado
+func (p *ProxyServiceImpl) pruneShedders(backends []domain.Backend) {
+	enabled := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		enabled[backend.Name] = backend.LoadShedding != nil
+	}
+	for name := range p.shedders {
+		if !enabled[name] {
+			delete(p.shedders, name)
+		}
+	}
+}
+
+// shouldShed decide al azar, con la fracción vigente, si se descarta la request
+func (p *ProxyServiceImpl) shouldShed(backend *domain.Backend) bool {
+	if backend.LoadShedding == nil {
+		return false
+	}
+	p.mu.RLock()
+	shedder := p.shedders[backend.Name]
+	p.mu.RUnlock()
+	if shedder == nil {
+		return false
+	}
+
+	rate := math.Float64frombits(shedder.rate.Load())
+	if rate <= 0 || rand.Float64() >= rate {
+		return false
+	}
+	atomic.AddInt64(&shedder.shed, 1)
+	return true
+}
+
+// writeShed responde 503 indicando al cliente cuándo reintentar
+func (p *ProxyServiceImpl) writeShed(w http.ResponseWriter, r *http.Request, config *domain.Config, retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = domain.DefaultLoadSheddingRetryAfter
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	p.writeError(w, r, config, http.StatusServiceUnavailable, "Server overloaded, retry later")
+}
+
+// shedStats devuelve la fracción vigente y las requests descartadas por backend
+func (p *ProxyServiceImpl) shedStats() (map[string]float64, map[string]int64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	rates := make(map[string]float64, len(p.shedders))
+	shed := make(map[string]int64, len(p.shedders))
+	for name, shedder := range p.shedders {
+		rates[name] = math.Float64frombits(shedder.rate.Load())
+		shed[name] = atomic.LoadInt64(&shedder.shed)
+	}
+	return rates, shed
+}
@@ -0,0 +1,25 @@
+package application
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// methodAllowed aplica allowed_methods/denied_methods del backend. Un preflight
+// CORS se evalúa por el método que anuncia: así un backend de solo lectura no
+// necesita listar OPTIONS para servir GET cross-origin, y el navegador recibe
+// el rechazo antes de enviar una escritura bloqueada.
+func methodAllowed(backend *domain.Backend, r *http.Request) bool {
+	if requested := r.Header.Get("Access-Control-Request-Method"); r.Method == http.MethodOptions && requested != "" {
+		return !backend.DeniesMethod(http.MethodOptions) && backend.AllowsMethod(requested)
+	}
+	return backend.AllowsMethod(r.Method)
+}
+
+// writeMethodNotAllowed responde 405 anunciando los métodos aceptados
+func (p *ProxyServiceImpl) writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, config *domain.Config, backend *domain.Backend) {
+	w.Header().Set("Allow", strings.Join(backend.PermittedMethods(), ", "))
+	p.writeError(w, r, config, http.StatusMethodNotAllowed, "Method not allowed")
+}
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// SimulateMetrics implementa domain.MetricsSimulator
+func (p *ProxyServiceImpl) SimulateMetrics(simulation *domain.SimulatedMetrics) {
+	p.simulation.Store(simulation)
+}
+
+// SimulatedMetrics implementa domain.MetricsSimulator; una simulación
+// expirada se descarta para que no vuelva a aplicarse
+func (p *ProxyServiceImpl) SimulatedMetrics(now time.Time) *domain.SimulatedMetrics {
+	simulation := p.simulation.Load()
+	if simulation == nil {
+		return nil
+	}
+	if !now.Before(simulation.ExpiresAt) {
+		p.simulation.CompareAndSwap(simulation, nil)
+		return nil
+	}
+	return simulation
+}
+
+// simulatedMetrics devuelve la simulación que aplica a este trigger. Se vuelve
+// a comprobar debug.trigger_simulation para que desactivarlo en caliente la
+// corte aunque no haya expirado.
+func (s *SmartTriggerService) simulatedMetrics(now time.Time) *domain.SimulatedMetrics {
+	if s.config == nil || !s.config.Debug.TriggerSimulation {
+		return nil
+	}
+	simulator, ok := s.proxyService.(domain.MetricsSimulator)
+	if !ok {
+		return nil
+	}
+	simulation := simulator.SimulatedMetrics(now)
+	if simulation == nil {
+		return nil
+	}
+	backend := ""
+	if s.backend != nil {
+		backend = s.backend.Name
+	}
+	if !simulation.AppliesTo(backend) {
+		return nil
+	}
+	return simulation
+}
@@ -0,0 +1,160 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+const (
+	defaultMirrorTimeout      = domain.DefaultMirrorTimeout
+	defaultMirrorMaxBodyBytes = domain.DefaultMirrorMaxBodyBytes
+	// Límite de réplicas en curso: con el mirror lento se descartan en lugar
+	// de acumular goroutines y memoria
+	maxMirrorInFlight = 100
+)
+
+// hopHeaders son cabeceras de la conexión del cliente que no se replican
+var hopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// mirrorBody copia el body mientras lo lee la request primaria, sin retrasarla.
+// Si supera el límite deja de copiar y la réplica se descarta. El transporte
+// puede seguir leyendo tras la respuesta, de ahí el mutex.
+type mirrorBody struct {
+	io.ReadCloser
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+	complete bool
+}
+
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > 0 && !b.overflow {
+		if int64(b.buf.Len()+n) > b.limit {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.complete = true
+	}
+	return n, err
+}
+
+// captured devuelve una copia del body si se leyó completo dentro del límite
+func (b *mirrorBody) captured() ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.overflow || !b.complete {
+		return nil, false
+	}
+	return bytes.Clone(b.buf.Bytes()), true
+}
+
+// mirrorCapture es una request elegida para replicarse al terminar la primaria
+type mirrorCapture struct {
+	cfg  domain.MirrorCfg
+	body *mirrorBody
+}
+
+// captureMirror decide por muestreo si la request se replica y, en ese caso,
+// empieza a copiar su body
+func (p *ProxyServiceImpl) captureMirror(backend *domain.Backend, r *http.Request) *mirrorCapture {
+	if backend.Mirror == nil || rand.Float64()*100 >= backend.Mirror.SamplePercent {
+		return nil
+	}
+
+	capture := &mirrorCapture{cfg: *backend.Mirror}
+	if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+		limit := capture.cfg.MaxBodyBytes
+		if limit <= 0 {
+			limit = defaultMirrorMaxBodyBytes
+		}
+		if r.ContentLength > limit {
+			atomic.AddInt64(&p.metrics.MirrorDropped, 1)
+			return nil
+		}
+		capture.body = &mirrorBody{ReadCloser: r.Body, limit: limit}
+		r.Body = capture.body
+	}
+	return capture
+}
+
+// sendMirror replica la request en segundo plano una vez servida la primaria,
+// de modo que el mirror nunca suma latencia al cliente
+func (p *ProxyServiceImpl) sendMirror(capture *mirrorCapture, r *http.Request) {
+	var body []byte
+	if capture.body != nil {
+		var ok bool
+		if body, ok = capture.body.captured(); !ok {
+			atomic.AddInt64(&p.metrics.MirrorDropped, 1)
+			return
+		}
+	}
+
+	target, err := domain.ParseServerURL(capture.cfg.URL)
+	if err != nil {
+		atomic.AddInt64(&p.metrics.MirrorFailures, 1)
+		return
+	}
+	mirrorURL := *target
+	mirrorURL.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(r.URL.Path, "/")
+	mirrorURL.RawQuery = r.URL.RawQuery
+
+	timeout := capture.cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultMirrorTimeout
+	}
+
+	select {
+	case p.mirrorSlots <- struct{}{}:
+	default:
+		atomic.AddInt64(&p.metrics.MirrorDropped, 1)
+		return
+	}
+
+	// La request original termina al volver ServeHTTP: copiar lo necesario
+	method, host, header := r.Method, r.Host, r.Header.Clone()
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+	go func() {
+		defer func() { <-p.mirrorSlots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, method, mirrorURL.String(), bytes.NewReader(body))
+		if err != nil {
+			atomic.AddInt64(&p.metrics.MirrorFailures, 1)
+			return
+		}
+		req.Header = header
+		req.Host = host
+
+		atomic.AddInt64(&p.metrics.MirrorRequests, 1)
+		resp, err := p.mirrorClient.Do(req)
+		if err != nil {
+			atomic.AddInt64(&p.metrics.MirrorFailures, 1)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			atomic.AddInt64(&p.metrics.MirrorFailures, 1)
+		}
+	}()
+}
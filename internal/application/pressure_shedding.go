@@ -0,0 +1,161 @@
+package application
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// heapObjectsMetric es la memoria del heap ocupada por objetos, vivos o aún
+// sin recolectar; a diferencia de runtime.ReadMemStats no detiene el mundo
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// pressureReading es una lectura de la presión del proceso
+type pressureReading struct {
+	cpu        time.Duration // CPU acumulada del proceso; 0 si no se puede medir
+	goroutines int
+	heapBytes  uint64
+}
+
+// readPressure lee la presión actual del proceso
+func readPressure() pressureReading {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	var heap uint64
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		heap = sample[0].Value.Uint64()
+	}
+	cpu, _ := processCPUTime()
+	return pressureReading{cpu: cpu, goroutines: runtime.NumGoroutine(), heapBytes: heap}
+}
+
+// pressureMonitor muestrea la presión del proceso como mucho una vez por
+// sample_interval, desde las propias requests, y decide si se rechazan las
+// nuevas. Las que ya están en curso no se ven afectadas.
+type pressureMonitor struct {
+	// Lectura sustituible en tests; nil usa readPressure
+	read func() pressureReading
+
+	// Estado leído en cada request sin tomar mu
+	active    atomic.Bool
+	sampledAt atomic.Int64 // UnixNano del último muestreo
+	sampling  atomic.Bool
+	shedding  atomic.Bool
+	shed      int64
+
+	mu    sync.Mutex
+	last  pressureReading
+	stats domain.PressureStats
+}
+
+// shouldShed devuelve si la request nueva se rechaza por presión del proceso
+func (m *pressureMonitor) shouldShed(cfg *domain.PressureSheddingCfg, now time.Time) bool {
+	if cfg == nil {
+		m.disable()
+		return false
+	}
+	m.sample(cfg, now)
+	if !m.shedding.Load() {
+		return false
+	}
+	atomic.AddInt64(&m.shed, 1)
+	return true
+}
+
+// sample toma una lectura si venció el intervalo; si otra request ya está
+// muestreando no la espera
+func (m *pressureMonitor) sample(cfg *domain.PressureSheddingCfg, now time.Time) {
+	interval := cfg.SampleInterval
+	if interval <= 0 {
+		interval = domain.DefaultPressureSampleInterval
+	}
+	due := !m.active.Load() || now.UnixNano()-m.sampledAt.Load() >= int64(interval)
+	if !due || !m.sampling.CompareAndSwap(false, true) {
+		return
+	}
+	defer m.sampling.Store(false)
+
+	read := m.read
+	if read == nil {
+		read = readPressure
+	}
+	reading := read()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// CPU consumida entre muestreos respecto a la disponible con GOMAXPROCS
+	cpuPercent := 0.0
+	if m.active.Load() && reading.cpu > m.last.cpu {
+		if elapsed := now.Sub(m.stats.SampledAt); elapsed > 0 {
+			available := float64(elapsed) * float64(runtime.GOMAXPROCS(0))
+			cpuPercent = float64(reading.cpu-m.last.cpu) / available * 100
+		}
+	}
+	m.last = reading
+	m.sampledAt.Store(now.UnixNano())
+	m.active.Store(true)
+	m.stats = domain.PressureStats{
+		Enabled:    true,
+		CPUPercent: cpuPercent,
+		Goroutines: reading.goroutines,
+		HeapBytes:  reading.heapBytes,
+		SampledAt:  now,
+	}
+
+	var reason string
+	switch {
+	case cfg.MaxCPUPercent > 0 && cpuPercent >= cfg.MaxCPUPercent:
+		reason = "cpu"
+	case cfg.MaxGoroutines > 0 && reading.goroutines >= cfg.MaxGoroutines:
+		reason = "goroutines"
+	case cfg.MaxHeapBytes > 0 && reading.heapBytes >= uint64(cfg.MaxHeapBytes):
+		reason = "heap"
+	}
+	shedding := reason != ""
+	m.stats.Shedding = shedding
+	if m.shedding.Swap(shedding) == shedding {
+		return
+	}
+	if shedding {
+		slog.Warn("Proxy under pressure, shedding new requests", "reason", reason,
+			"cpu_percent", cpuPercent, "goroutines", reading.goroutines, "heap_bytes", reading.heapBytes)
+	} else {
+		slog.Info("Proxy pressure relieved, accepting new requests",
+			"cpu_percent", cpuPercent, "goroutines", reading.goroutines, "heap_bytes", reading.heapBytes)
+	}
+}
+
+// disable olvida el último muestreo al quitar proxy.load_shedding
+func (m *pressureMonitor) disable() {
+	if !m.active.Load() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active.Store(false)
+	m.last = pressureReading{}
+	m.stats = domain.PressureStats{}
+	m.shedding.Store(false)
+}
+
+// snapshot devuelve el último muestreo y las requests rechazadas
+func (m *pressureMonitor) snapshot() domain.PressureStats {
+	m.mu.Lock()
+	stats := m.stats
+	m.mu.Unlock()
+	stats.Shed = atomic.LoadInt64(&m.shed)
+	return stats
+}
+
+// writePressureShed rechaza la request y cierra la conexión para que el
+// cliente no siga enviando por ella mientras el proceso está saturado
+func (p *ProxyServiceImpl) writePressureShed(w http.ResponseWriter, r *http.Request, config *domain.Config) {
+	w.Header().Set("Connection", "close")
+	p.writeShed(w, r, config, config.Proxy.LoadShedding.RetryAfter)
+}
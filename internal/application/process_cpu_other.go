@@ -0,0 +1,10 @@
+//go:build !unix
+
+package application
+
+import "time"
+
+// processCPUTime no está disponible fuera de unix; max_cpu_percent no se aplica
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
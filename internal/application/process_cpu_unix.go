@@ -0,0 +1,17 @@
+//go:build unix
+
+package application
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime devuelve la CPU de usuario y sistema consumida por el proceso
+func processCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
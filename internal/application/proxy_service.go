@@ -1,10 +1,16 @@
 package application
 
 import (
+	"container/list"
+	"errors"
+	"hash/fnv"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,13 +21,36 @@ import (
 )
 
 type ProxyServiceImpl struct {
-	config        *domain.Config
-	metrics       *domain.TrafficMetrics
-	mu            sync.RWMutex
-	requestCount  int64
-	loadBalancer  domain.LoadBalancer
-	healthChecker domain.HealthChecker
-	sessions      map[string]string
+	config         *domain.Config
+	metrics        *domain.TrafficMetrics
+	mu             sync.RWMutex
+	requestCount   int64
+	loadBalancer   domain.LoadBalancer
+	healthChecker  domain.HealthChecker
+	sessions       map[string]*list.Element
+	sessionLRU     *list.List
+	sweeperStop    chan struct{}
+	headerRoutes   []*headerRoute
+	trustedProxies []*net.IPNet
+	// response_rewrite compilado del backend; nil si no está configurado
+	rewriter *responseRewriter
+	// error_classification del backend; nil deja solo los 5xx como fallo
+	classifier *errorClassifier
+	// Cliente propio del mirror: no comparte pool ni métricas con los servidores
+	mirrorClient *http.Client
+	mirrorSlots  chan struct{}
+	// Requests en curso por backend y su max_concurrent_requests
+	limiters map[string]*backendLimiter
+	// Descarte de carga por backend mientras el SmartTrigger pide escalar
+	shedders map[string]*loadShedder
+	// proxy.default_backend; nil si no está configurado
+	fallback *defaultBackend
+	// body_match compilado del backend; nil si no está activado
+	bodyRouter *bodyRouter
+	// proxy.load_shedding: presión del propio proceso
+	pressure pressureMonitor
+	// Métricas sintéticas para el SmartTrigger (debug.trigger_simulation)
+	simulation atomic.Pointer[domain.SimulatedMetrics]
 }
 
 func NewProxyService(lb domain.LoadBalancer, hc domain.HealthChecker) *ProxyServiceImpl {
@@ -29,7 +58,10 @@ func NewProxyService(lb domain.LoadBalancer, hc domain.HealthChecker) *ProxyServ
 		metrics:       &domain.TrafficMetrics{},
 		loadBalancer:  lb,
 		healthChecker: hc,
-		sessions:      make(map[string]string),
+		sessions:      make(map[string]*list.Element),
+		sessionLRU:    list.New(),
+		mirrorClient:  &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()},
+		mirrorSlots:   make(chan struct{}, maxMirrorInFlight),
 	}
 }
 
@@ -39,100 +71,388 @@ func (p *ProxyServiceImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	atomic.AddInt64(&p.metrics.ActiveConnections, 1)
 	defer atomic.AddInt64(&p.metrics.ActiveConnections, -1)
 
+	// El mismo ID viaja al backend, vuelve al cliente y aparece en el log
+	w.Header().Set(requestIDHeader, ensureRequestID(r))
+
 	p.mu.RLock()
 	config := p.config
 	p.mu.RUnlock()
 
+	if config != nil && config.Logging.AccessLog {
+		rec := &accessRecorder{ResponseWriter: w}
+		w = rec
+		defer p.logAccess(&config.Logging, rec, r, p.getClientIP(r), start)
+	}
+	// Se registra después del access log para que este vea el 500
+	defer p.recoverPanic(w, r, config, start)
+
+	// Con el proceso saturado se rechazan solo las requests nuevas; las que ya
+	// están en curso terminan con normalidad
+	if config != nil && p.pressure.shouldShed(config.Proxy.LoadShedding, start) {
+		p.writePressureShed(w, r, config)
+		return
+	}
+
+	// Con strict_sni una conexión no se reutiliza para otro nombre (p. ej. por
+	// coalescing de HTTP/2): 421 hace que el cliente abra una conexión nueva
+	if config != nil && config.Proxy.TLS != nil && config.Proxy.TLS.StrictSNI && misdirected(r) {
+		p.writeError(w, r, config, http.StatusMisdirectedRequest, "Misdirected Request")
+		return
+	}
+
 	if config == nil || len(config.Backends) == 0 {
-		http.Error(w, "No backends available", http.StatusServiceUnavailable)
+		if p.serveDefaultBackend(w, r, start) {
+			return
+		}
+		p.writeError(w, r, config, http.StatusServiceUnavailable, "No backends available")
 		return
 	}
 
 	backend := &config.Backends[0]
+
+	// Modo mantenimiento: responder sin seleccionar servidor
+	if backend.Maintenance.Enabled {
+		p.serveMaintenance(w, &backend.Maintenance)
+		return
+	}
+
+	if !methodAllowed(backend, r) {
+		p.writeMethodNotAllowed(w, r, config, backend)
+		return
+	}
+
+	// Con sobrecarga confirmada se rechaza parte del exceso antes de que llegue a los servidores
+	if p.shouldShed(backend) {
+		p.writeShed(w, r, config, backend.LoadShedding.RetryAfter)
+		return
+	}
+
+	// Limitar tamaño del body antes de contactar cualquier servidor
+	if limit := p.maxRequestBodyBytes(config, backend); limit > 0 {
+		if r.ContentLength > limit {
+			p.writeError(w, r, config, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	// body_match solo se evalúa si ninguna regla header_match coincide. El
+	// cuerpo se lee antes de reservar hueco para que un cliente lento no lo ocupe.
+	route := p.matchHeaderRoute(r)
+	if route == nil {
+		var err error
+		if route, err = p.matchBodyRoute(r); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				p.writeError(w, r, config, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			p.writeError(w, r, config, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	r, cancel := withRequestBudget(r, backend, start)
+	defer cancel()
+
+	// El hueco se libera con defer para cubrir también los panics del proxy
+	release, ok := p.acquireBackendSlot(r, backend)
+	if !ok {
+		if budgetExhausted(r) {
+			p.writeError(w, r, config, http.StatusGatewayTimeout, "Gateway Timeout")
+			return
+		}
+		p.writeRejected(w, r, config, domain.RejectCapacity, "Backend concurrency limit reached")
+		return
+	}
+	defer release()
+
 	clientIP := p.getClientIP(r)
-	server := p.selectServerWithRetry(backend, clientIP, r)
+	server, reason := p.selectServerWithRetry(backend, clientIP, r, route)
 
 	if server == nil {
-		http.Error(w, "No active servers", http.StatusServiceUnavailable)
+		if budgetExhausted(r) {
+			p.writeError(w, r, config, http.StatusGatewayTimeout, "Gateway Timeout")
+			return
+		}
+		if p.serveDefaultBackend(w, r, start) {
+			return
+		}
+		p.writeRejected(w, r, config, reason, "No active servers")
+		return
+	}
+
+	target, err := domain.ParseBackendServerURL(server.URL)
+	if err != nil {
+		// Liberar la conexión contada en la selección
+		p.loadBalancer.UpdateStats(server, time.Since(start), false)
+		p.writeError(w, r, config, http.StatusServiceUnavailable, "Invalid backend server URL")
 		return
 	}
 
-	target, _ := url.Parse(server.URL)
-	proxy := p.createIntelligentProxy(target, server, start)
-	proxy.ServeHTTP(w, r)
+	wrapRequestTrailers(r)
+
+	if backend.StickySessions && sessionID(r, backend) == "" {
+		p.setAffinityCookie(w, r, backend, server)
+	}
+
+	capture := p.captureMirror(backend, r)
+
+	attempts := &retryAttempts{tried: map[string]bool{server.URL: true}, remaining: retryCount(backend)}
+	proxy := p.createIntelligentProxy(target, server, backend, route, start, attempts)
+	proxy.ServeHTTP(forwardInformational(w), r)
+
+	if capture != nil {
+		p.sendMirror(capture, r)
+	}
 }
 
-func (p *ProxyServiceImpl) selectServerWithRetry(backend *domain.Backend, clientIP string, r *http.Request) *domain.Server {
-	if backend.StickySessions {
-		if sessionServer := p.getSessionServer(r, backend); sessionServer != nil {
-			return sessionServer
-		}
+func (p *ProxyServiceImpl) serveMaintenance(w http.ResponseWriter, maintenance *domain.MaintenanceCfg) {
+	statusCode := maintenance.StatusCode
+	if statusCode == 0 {
+		statusCode = domain.DefaultMaintenanceStatusCode
+	}
+	contentType := maintenance.ContentType
+	if contentType == "" {
+		contentType = domain.DefaultMaintenanceContentType
+	}
+	body := maintenance.Body
+	if body == "" {
+		body = domain.DefaultMaintenanceBody
 	}
 
-	retries := backend.Retries
-	if retries == 0 {
-		retries = 3
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(body))
+}
+
+// maxRequestBodyBytes devuelve el límite del backend o, si no está definido, el global.
+func (p *ProxyServiceImpl) maxRequestBodyBytes(config *domain.Config, backend *domain.Backend) int64 {
+	if backend.MaxRequestBodyBytes > 0 {
+		return backend.MaxRequestBodyBytes
 	}
+	return config.Proxy.MaxRequestBodyBytes
+}
 
-	for i := 0; i < retries; i++ {
-		server := p.loadBalancer.SelectServer(backend, clientIP)
+// selectServerWithRetry devuelve el servidor elegido o, si no lo hay, el
+// motivo del último intento (domain.RejectCapacity o domain.RejectUnhealthy)
+func (p *ProxyServiceImpl) selectServerWithRetry(backend *domain.Backend, clientIP string, r *http.Request, route *headerRoute) (*domain.Server, string) {
+	// La sesión solo se respeta si su servidor pertenece a la regla de cabecera
+	if backend.StickySessions {
+		if sessionServer := p.getSessionServer(r, backend, route); sessionServer != nil {
+			return sessionServer, ""
+		}
+		if affinityServer := p.getAffinityServer(r, backend); affinityServer != nil && (route == nil || route.allows(affinityServer)) {
+			return affinityServer, ""
+		}
+	}
+
+	reason := domain.RejectUnhealthy
+	for i := 0; i < retryCount(backend); i++ {
+		var server *domain.Server
+		server, reason = p.selectServer(backend, clientIP, route)
 		if server != nil {
 			if backend.StickySessions {
-				p.setSessionServer(r, server)
+				p.setSessionServer(r, backend, server)
 			}
-			return server
+			return server, ""
+		}
+		// Con cola el balanceador ya esperó max_wait: no reintentar
+		if backend.Queue.IsEnabled() {
+			break
+		}
+		select {
+		case <-time.After(time.Millisecond * 100):
+		case <-r.Context().Done():
+			return nil, reason
 		}
-		time.Sleep(time.Millisecond * 100)
 	}
-	return nil
+	return nil, reason
 }
 
 func (p *ProxyServiceImpl) UpdateConfig(config *domain.Config) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.config = config
+	p.headerRoutes = nil
+	p.bodyRouter = nil
+	p.rewriter = nil
+	previousClassifier := p.classifier
+	p.classifier = nil
+	p.limiters = buildLimiters(config.Backends, p.limiters)
+	p.pruneShedders(config.Backends)
+	p.fallback = buildDefaultBackend(config.Proxy.DefaultBackend, p.fallback)
+	// La configuración ya fue validada; una entrada inválida solo deja la lista vacía
+	p.trustedProxies, _ = domain.ParseTrustedProxies(config.Proxy.TrustedProxies)
 	
 	// Actualizar servidores en el balanceador
 	if len(config.Backends) > 0 {
+		p.headerRoutes = compileHeaderRoutes(config.Backends[0].HeaderMatch)
+		p.bodyRouter = compileBodyMatch(config.Backends[0].BodyMatch)
+		p.rewriter = compileResponseRewrite(config.Backends[0].ResponseRewrite)
+		p.classifier = buildErrorClassifier(config.Backends[0].ErrorClassification, previousClassifier)
 		if eb, ok := p.loadBalancer.(*infrastructure.EnterpriseBalancer); ok {
-			eb.UpdateServers(config.Backends[0].Servers, &config.Backends[0])
+			eb.UpdateBackends(config.Backends)
 		}
 	}
 	
 	return nil
 }
 
+// transportFor devuelve el transporte compartido del servidor, con el trace que
+// cuenta la reutilización de conexiones; nil usa http.DefaultTransport
+func (p *ProxyServiceImpl) transportFor(server *domain.Server) http.RoundTripper {
+	if eb, ok := p.loadBalancer.(*infrastructure.EnterpriseBalancer); ok {
+		transport := eb.GetTransport(server.URL)
+		if trace := eb.GetConnTrace(server.URL); transport != nil && trace != nil {
+			return &tracedTransport{base: transport, trace: trace}
+		}
+		return transport
+	}
+	return nil
+}
+
+// tracedTransport añade el ClientTrace del servidor a cada request saliente
+type tracedTransport struct {
+	base  http.RoundTripper
+	trace *httptrace.ClientTrace
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), t.trace)))
+}
+
 func (p *ProxyServiceImpl) GetMetrics() *domain.TrafficMetrics {
 	count := atomic.LoadInt64(&p.requestCount)
 	p.metrics.RequestsPerSecond = int(count)
 	p.metrics.TotalRequests = atomic.LoadInt64(&p.requestCount)
 	p.metrics.LastUpdated = time.Now()
+	atomic.StoreInt64(&p.metrics.ActiveSessions, p.sessionCount())
+	p.metrics.BackendInFlight = p.backendInFlight()
+	p.metrics.BackendShedRate, p.metrics.BackendShed = p.shedStats()
+	p.metrics.Pressure = p.pressure.snapshot()
+	if inFlight, requests, failures, ok := p.defaultBackendStats(); ok {
+		p.metrics.BackendInFlight[domain.DefaultBackendName] = inFlight
+		p.metrics.DefaultBackendRequests = requests
+		p.metrics.DefaultBackendFailures = failures
+	}
 	atomic.StoreInt64(&p.requestCount, 0)
 	return p.metrics
 }
 
+// ResetMetrics implementa domain.MetricsResetter. Los contadores de cada
+// servidor viven en el balanceador y se reinician allí.
+func (p *ProxyServiceImpl) ResetMetrics() {
+	atomic.StoreInt64(&p.requestCount, 0)
+	atomic.StoreInt64(&p.metrics.TotalRequests, 0)
+	atomic.StoreInt64(&p.metrics.MirrorRequests, 0)
+	atomic.StoreInt64(&p.metrics.MirrorFailures, 0)
+	atomic.StoreInt64(&p.metrics.MirrorDropped, 0)
+	atomic.StoreInt64(&p.metrics.StalledResponses, 0)
+	atomic.StoreInt64(&p.metrics.CapacityRejections, 0)
+	atomic.StoreInt64(&p.metrics.UnhealthyRejections, 0)
+	atomic.StoreInt64(&p.pressure.shed, 0)
+	p.metrics.AverageResponseTime = 0
+	p.metrics.ErrorRate = 0
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, shedder := range p.shedders {
+		atomic.StoreInt64(&shedder.shed, 0)
+	}
+	if p.fallback != nil {
+		atomic.StoreInt64(&p.fallback.requests, 0)
+		atomic.StoreInt64(&p.fallback.failures, 0)
+	}
+}
+
 func (p *ProxyServiceImpl) GetServerStats() map[string]*domain.Server {
 	// Obtener métricas reales del load balancer
 	return p.loadBalancer.GetServerMetrics()
 }
 
-func (p *ProxyServiceImpl) getClientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return strings.Split(xff, ",")[0]
-	}
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+// retryCount devuelve los reintentos del backend (3 por defecto)
+func retryCount(backend *domain.Backend) int {
+	if backend.Retries == 0 {
+		return domain.DefaultRetries
 	}
-	host, _, _ := net.SplitHostPort(r.RemoteAddr)
-	return host
+	return backend.Retries
+}
+
+// retryAttempts acompaña a una request a través de sus reintentos: servidores
+// ya probados y reintentos que quedan
+type retryAttempts struct {
+	tried     map[string]bool
+	remaining int
 }
 
-func (p *ProxyServiceImpl) createIntelligentProxy(target *url.URL, server *domain.Server, start time.Time) *httputil.ReverseProxy {
+func (p *ProxyServiceImpl) createIntelligentProxy(target *url.URL, server *domain.Server, backend *domain.Backend, route *headerRoute, start time.Time, attempts *retryAttempts) *httputil.ReverseProxy {
 	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = p.transportFor(server)
+	forwardRequestTrailers(proxy)
+	applyUpstreamHost(proxy, backend, target)
 
 	proxy.ModifyResponse = func(resp *http.Response) error {
 		duration := time.Since(start)
-		success := resp.StatusCode < 500
+
+		// El ID ya va en la respuesta; si el backend lo devuelve no se duplica
+		resp.Header.Del(requestIDHeader)
+
+		p.mu.RLock()
+		currentConfig := p.config
+		rewriter := p.rewriter
+		classifier := p.classifier
+		p.mu.RUnlock()
+		if currentConfig != nil {
+			injectResponseHeaders(resp, &currentConfig.Proxy, backend)
+			injectUpstreamHeader(resp, &currentConfig.Proxy, server.URL)
+		}
+
+		// Upgrade (WebSocket): el handshake es la request; la conexión se
+		// sigue aparte mientras dure para no inflar latencias ni ActiveConns
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			p.loadBalancer.UpdateStats(server, duration, true)
+			p.updateGlobalMetrics(duration, true)
+			if tracker, ok := p.loadBalancer.(domain.UpgradeTracker); ok {
+				resp.Body = trackUpgradedBody(resp.Body, tracker.TrackUpgrade(server))
+			}
+			return nil
+		}
+
+		// gRPC: el resultado llega en los trailers al terminar el stream
+		if backend.Protocol == infrastructure.ProtocolGRPC && isGRPCResponse(resp) {
+			resp.Body = newGRPCStatusBody(resp, func(success bool) {
+				p.loadBalancer.UpdateStats(server, duration, success)
+				p.updateGlobalMetrics(duration, success)
+			})
+			return nil
+		}
+
+		// El remapeo va primero: el éxito se decide con el código que ve el cliente
+		remapStatus(resp, backend)
+		success := classifier.success(server.URL, resp.StatusCode)
+
+		// Un error leyendo el cuerpo llega al ErrorHandler, que lo contabiliza
+		if rewriter != nil {
+			if err := rewriter.rewrite(resp); err != nil {
+				return err
+			}
+		}
+
+		// Con body_idle_timeout el resultado se conoce al terminar el cuerpo
+		if idle := backend.Transport.BodyIdleTimeout; idle > 0 && !isEventStream(resp) {
+			resp.Body = newIdleTimeoutBody(resp.Body, idle, func(stalled bool) {
+				if stalled {
+					atomic.AddInt64(&p.metrics.StalledResponses, 1)
+					slog.Warn("Upstream response stalled", "server", server.URL, "body_idle_timeout", idle)
+				}
+				p.loadBalancer.UpdateStats(server, duration, success && !stalled)
+				p.updateGlobalMetrics(duration, success && !stalled)
+			})
+			return nil
+		}
+
 		p.loadBalancer.UpdateStats(server, duration, success)
 		
 		// Actualizar métricas globales
@@ -147,70 +467,173 @@ func (p *ProxyServiceImpl) createIntelligentProxy(target *url.URL, server *domai
 
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 		duration := time.Since(start)
+
+		p.mu.RLock()
+		currentConfig := p.config
+		p.mu.RUnlock()
+
+		// Body demasiado grande: error del cliente, no penalizar al servidor
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			p.loadBalancer.UpdateStats(server, duration, true)
+			p.writeError(w, r, currentConfig, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+
 		p.loadBalancer.UpdateStats(server, duration, false)
 		p.updateGlobalMetrics(duration, false)
-		
-		// Retry logic para alta disponibilidad
-		if p.shouldRetry(err) {
-			p.mu.RLock()
-			currentConfig := p.config
-			p.mu.RUnlock()
-			
-			if currentConfig != nil && len(currentConfig.Backends) > 0 {
-				if retryServer := p.loadBalancer.SelectServer(&currentConfig.Backends[0], p.getClientIP(r)); retryServer != nil && retryServer.URL != server.URL {
-					retryTarget, _ := url.Parse(retryServer.URL)
-					retryProxy := httputil.NewSingleHostReverseProxy(retryTarget)
-					retryProxy.ServeHTTP(w, r)
+
+		// Plazo total agotado: no quedan reintentos posibles
+		if budgetExhausted(r) {
+			slog.Warn("Request budget exceeded", "request_id", r.Header.Get(requestIDHeader), "method", r.Method, "path", r.URL.Path,
+				"request_timeout", backend.RequestTimeout, "server", server.URL, "attempts", len(attempts.tried))
+			p.writeError(w, r, currentConfig, http.StatusGatewayTimeout, "Gateway Timeout")
+			return
+		}
+
+		// Retry logic para alta disponibilidad: cada reintento va a un servidor
+		// distinto, sano y con el circuito cerrado, con su propio ErrorHandler
+		if p.shouldRetry(err) && attempts.remaining > 0 {
+			if retryServer := p.selectRetryServer(backend, p.getClientIP(r), route, attempts.tried); retryServer != nil {
+				attempts.remaining--
+				attempts.tried[retryServer.URL] = true
+
+				retryTarget, err := domain.ParseBackendServerURL(retryServer.URL)
+				if err != nil {
+					p.loadBalancer.UpdateStats(retryServer, 0, false)
+					p.writeError(w, r, currentConfig, http.StatusServiceUnavailable, "Invalid backend server URL")
 					return
 				}
+				p.createIntelligentProxy(retryTarget, retryServer, backend, route, time.Now(), attempts).ServeHTTP(w, r)
+				return
 			}
 		}
-		
-		http.Error(w, "Service Temporarily Unavailable", http.StatusServiceUnavailable)
+
+		slog.Warn("Request failed", "request_id", r.Header.Get(requestIDHeader), "method", r.Method, "path", r.URL.Path,
+			"server", server.URL, "attempts", len(attempts.tried), "error", err)
+		p.writeError(w, r, currentConfig, http.StatusServiceUnavailable, "Service Temporarily Unavailable")
 	}
 
+	configureStreaming(proxy, backend)
 	return proxy
 }
 
-func (p *ProxyServiceImpl) getSessionServer(r *http.Request, backend *domain.Backend) *domain.Server {
-	sessionID := p.getSessionID(r)
-	if sessionID == "" {
+// getSessionServer devuelve el servidor de la sesión si está disponible. Si
+// está caído o ya no existe devuelve el respaldo de la sesión, el mismo en
+// todas las instancias del proxy; la sesión solo se fija en él si el original
+// desapareció o sticky_failback es false.
+func (p *ProxyServiceImpl) getSessionServer(r *http.Request, backend *domain.Backend, route *headerRoute) *domain.Server {
+	id := sessionID(r, backend)
+	if id == "" {
 		return nil
 	}
 
-	p.mu.RLock()
-	serverURL, exists := p.sessions[sessionID]
-	p.mu.RUnlock()
-
+	ttl, max := sessionLimits(backend)
+	serverURL, exists := p.lookupSession(id, ttl)
 	if !exists {
 		return nil
 	}
 
+	configured := false
 	for i := range backend.Servers {
 		server := &backend.Servers[i]
-		if server.URL == serverURL && server.Active && server.Healthy {
+		if server.URL != serverURL {
+			continue
+		}
+		if server.Active && server.Healthy {
+			// Fuera de la regla de cabecera la sesión se reasigna con la selección normal
+			if route != nil && !route.allows(server) {
+				return nil
+			}
 			return server
 		}
+		configured = true
+		break
 	}
-	return nil
+
+	backup := sessionBackup(id, serverURL, backend, route)
+	if backup != nil && (!configured || !backend.StickyFailbackEnabled()) {
+		p.storeSession(id, backup.URL, max)
+	}
+	return backup
 }
 
-func (p *ProxyServiceImpl) setSessionServer(r *http.Request, server *domain.Server) {
-	sessionID := p.getSessionID(r)
-	if sessionID == "" {
+func (p *ProxyServiceImpl) setSessionServer(r *http.Request, backend *domain.Backend, server *domain.Server) {
+	id := sessionID(r, backend)
+	if id == "" {
 		return
 	}
 
-	p.mu.Lock()
-	p.sessions[sessionID] = server.URL
-	p.mu.Unlock()
+	_, max := sessionLimits(backend)
+	p.storeSession(id, server.URL, max)
 }
 
-func (p *ProxyServiceImpl) getSessionID(r *http.Request) string {
-	if cookie, err := r.Cookie("JSESSIONID"); err == nil {
-		return cookie.Value
+const (
+	defaultAffinityCookieName = domain.DefaultAffinityCookieName
+	defaultAffinityCookieTTL  = domain.DefaultAffinityCookieTTL
+)
+
+// affinityValue identifica al servidor sin exponer su URL interna. La cookie
+// lleva el servidor elegido, así que no hace falta guardar estado por cliente.
+func affinityValue(serverURL string) string {
+	h := fnv.New64a()
+	h.Write([]byte(serverURL))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func affinityCookieName(backend *domain.Backend) string {
+	if backend.StickyCookie.Name != "" {
+		return backend.StickyCookie.Name
 	}
-	return r.Header.Get("X-Session-ID")
+	return defaultAffinityCookieName
+}
+
+func (p *ProxyServiceImpl) getAffinityServer(r *http.Request, backend *domain.Backend) *domain.Server {
+	cookie, err := r.Cookie(affinityCookieName(backend))
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	for i := range backend.Servers {
+		server := &backend.Servers[i]
+		if affinityValue(server.URL) == cookie.Value && server.Active && server.Healthy {
+			return server
+		}
+	}
+	return nil
+}
+
+// setAffinityCookie emite la cookie de afinidad si falta o apunta a otro servidor
+func (p *ProxyServiceImpl) setAffinityCookie(w http.ResponseWriter, r *http.Request, backend *domain.Backend, server *domain.Server) {
+	name := affinityCookieName(backend)
+	value := affinityValue(server.URL)
+	if cookie, err := r.Cookie(name); err == nil && cookie.Value == value {
+		return
+	}
+
+	ttl := backend.StickyCookie.TTL
+	if ttl == 0 {
+		ttl = defaultAffinityCookieTTL
+	}
+
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(backend.StickyCookie.SameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		// SameSite=None solo es aceptada por los navegadores con Secure
+		Secure:   backend.StickyCookie.Secure || sameSite == http.SameSiteNoneMode,
+		SameSite: sameSite,
+	})
 }
 
 func (p *ProxyServiceImpl) updateGlobalMetrics(duration time.Duration, success bool) {
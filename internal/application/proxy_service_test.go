@@ -1,13 +1,30 @@
 package application
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/juanbautista0/go-proxy/internal/domain"
 	"github.com/juanbautista0/go-proxy/internal/infrastructure"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func TestProxyService_UpdateConfig(t *testing.T) {
@@ -45,44 +62,2256 @@ func TestProxyService_UpdateConfig(t *testing.T) {
 	}
 }
 
+func TestProxyService_UpdateConfig_PreservesServerState(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	newConfig := func(extra ...domain.Server) *domain.Config {
+		return &domain.Config{Backends: []domain.Backend{
+			{Name: "web", Servers: append([]domain.Server{{URL: backendServer.URL, Weight: 1, Active: true}}, extra...)},
+			{Name: "api", Servers: []domain.Server{{URL: "http://localhost:4001", Weight: 1, Active: true}}},
+		}}
+	}
+	service.UpdateConfig(newConfig())
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+	}
+	// Estado acumulado en el servidor del segundo backend
+	for i := 0; i < 3; i++ {
+		lb.UpdateStats(&domain.Server{URL: "http://localhost:4001"}, time.Millisecond, false)
+	}
+
+	// La recarga añade un servidor; los que no cambian conservan su estado
+	service.UpdateConfig(newConfig(domain.Server{URL: "http://localhost:3009", Weight: 1, Active: true}))
+
+	stats := lb.GetServerMetrics()
+	if len(stats) != 3 {
+		t.Fatalf("expected servers from every backend, got %d", len(stats))
+	}
+	if got := stats[backendServer.URL].TotalRequests; got != 5 {
+		t.Errorf("expected untouched server to keep 5 requests, got %d", got)
+	}
+	if got := stats["http://localhost:4001"].FailedRequests; got != 3 {
+		t.Errorf("expected second backend server to keep 3 failures, got %d", got)
+	}
+	if got := stats["http://localhost:3009"].TotalRequests; got != 0 {
+		t.Errorf("expected new server to start from zero, got %d", got)
+	}
+
+	// El primer backend nunca recibe servidores del segundo
+	for i := 0; i < 20; i++ {
+		server := lb.SelectServer(&service.config.Backends[0], "127.0.0.1")
+		if server == nil || server.URL == "http://localhost:4001" {
+			t.Fatalf("expected a server of the first backend, got %v", server)
+		}
+		lb.UpdateStats(server, time.Millisecond, true)
+	}
+}
+
 func TestProxyService_ServeHTTP_NoBackends(t *testing.T) {
 	lb := infrastructure.NewEnterpriseBalancer()
 	hc := &mockHealthChecker{}
 	service := NewProxyService(lb, hc)
 
-	// No config set
-	req := httptest.NewRequest("GET", "/", nil)
+	// No config set
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+
+	service.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+}
+
+func TestProxyService_ServeHTTP_NoActiveServers(t *testing.T) {
+	lb := infrastructure.NewEnterpriseBalancer()
+	hc := &mockHealthChecker{}
+	service := NewProxyService(lb, hc)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:    "test-backend",
+				Servers: []domain.Server{}, // No servers
+			},
+		},
+	}
+	service.UpdateConfig(config)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+
+	service.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+}
+
+func TestProxyService_ServeHTTP_Maintenance(t *testing.T) {
+	lb := infrastructure.NewEnterpriseBalancer()
+	hc := &mockHealthChecker{}
+	service := NewProxyService(lb, hc)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name: "test-backend",
+				Servers: []domain.Server{
+					{URL: "http://localhost:3001", Weight: 1, Active: true},
+				},
+				Maintenance: domain.MaintenanceCfg{
+					Enabled:     true,
+					Body:        `{"status":"maintenance"}`,
+					ContentType: "application/json",
+				},
+			},
+		},
+	}
+	service.UpdateConfig(config)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+
+	service.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected content type application/json, got %s", ct)
+	}
+	if w.Body.String() != `{"status":"maintenance"}` {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+
+	// No debe seleccionarse ningún servidor
+	if stats := lb.GetServerMetrics()["http://localhost:3001"]; stats.TotalRequests != 0 {
+		t.Errorf("expected no requests routed, got %d", stats.TotalRequests)
+	}
+}
+
+func TestProxyService_ServeHTTP_MethodFilter(t *testing.T) {
+	var hits int64
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:           "read-only",
+				Servers:        []domain.Server{{URL: backendServer.URL, Weight: 1, Active: true}},
+				AllowedMethods: []string{"GET", "head"},
+			},
+		},
+	}
+	service.UpdateConfig(config)
+
+	tests := []struct {
+		method    string
+		preflight string
+		expected  int
+	}{
+		{"GET", "", http.StatusOK},
+		{"HEAD", "", http.StatusOK},
+		{"POST", "", http.StatusMethodNotAllowed},
+		{"DELETE", "", http.StatusMethodNotAllowed},
+		// El preflight se evalúa por el método anunciado, no por OPTIONS
+		{"OPTIONS", "GET", http.StatusOK},
+		{"OPTIONS", "PUT", http.StatusMethodNotAllowed},
+		{"OPTIONS", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt64(&hits, 0)
+		req := httptest.NewRequest(tt.method, "/", nil)
+		if tt.preflight != "" {
+			req.Header.Set("Origin", "https://app.example.com")
+			req.Header.Set("Access-Control-Request-Method", tt.preflight)
+		}
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, req)
+
+		if w.Code != tt.expected {
+			t.Errorf("%s (preflight %q): expected status %d, got %d", tt.method, tt.preflight, tt.expected, w.Code)
+		}
+		if tt.expected == http.StatusMethodNotAllowed {
+			if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+				t.Errorf("%s: expected Allow \"GET, HEAD\", got %q", tt.method, allow)
+			}
+			if atomic.LoadInt64(&hits) != 0 {
+				t.Errorf("%s: rejected request reached the backend", tt.method)
+			}
+		}
+	}
+
+	// Solo denied_methods: el resto de métodos estándar siguen permitidos
+	config.Backends[0].AllowedMethods = nil
+	config.Backends[0].DeniedMethods = []string{"DELETE", "OPTIONS"}
+	service.UpdateConfig(config)
+
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected POST to be allowed, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("DELETE", "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected DELETE to be denied, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, POST, PUT, PATCH" {
+		t.Errorf("unexpected Allow header %q", allow)
+	}
+
+	// Con OPTIONS denegado tampoco pasan los preflight
+	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected preflight to be denied, got %d", w.Code)
+	}
+}
+
+func TestProxyService_ServeHTTP_RequestBodyLimit(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	hc := &mockHealthChecker{}
+	service := NewProxyService(lb, hc)
+
+	config := &domain.Config{
+		Proxy: domain.ProxyConfig{MaxRequestBodyBytes: 1024},
+		Backends: []domain.Backend{
+			{
+				Name: "test-backend",
+				Servers: []domain.Server{
+					{URL: backendServer.URL, Weight: 1, Active: true},
+				},
+				MaxRequestBodyBytes: 10,
+			},
+		},
+	}
+	service.UpdateConfig(config)
+
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		expected      int
+	}{
+		{name: "within limit", body: "small", contentLength: 5, expected: http.StatusOK},
+		{name: "declared length exceeds limit", body: strings.Repeat("x", 100), contentLength: 100, expected: http.StatusRequestEntityTooLarge},
+		{name: "chunked body exceeds limit", body: strings.Repeat("x", 100), contentLength: -1, expected: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req.ContentLength = tt.contentLength
+			w := httptest.NewRecorder()
+
+			service.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestProxyService_ServeHTTP_ReusesServerTransport(t *testing.T) {
+	var newConns int64
+	backendServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	backendServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&newConns, 1)
+		}
+	}
+	backendServer.Start()
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:    "test-backend",
+				Servers: []domain.Server{{URL: backendServer.URL, Weight: 1, Active: true}},
+			},
+		},
+	})
+
+	transport := lb.GetTransport(backendServer.URL)
+	if transport == nil {
+		t.Fatal("expected a transport for the server")
+	}
+
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+	}
+
+	if conns := atomic.LoadInt64(&newConns); conns != 1 {
+		t.Errorf("expected sequential requests to reuse 1 connection, got %d", conns)
+	}
+	if lb.GetTransport(backendServer.URL) != transport {
+		t.Error("expected the same transport to be reused across requests")
+	}
+
+	// Las métricas del pool reflejan la misma reutilización
+	stats := lb.GetServerMetrics()[backendServer.URL]
+	if stats.NewConns != 1 || stats.ReusedConns != 19 {
+		t.Errorf("expected 1 new and 19 reused connections, got %d and %d", stats.NewConns, stats.ReusedConns)
+	}
+	if stats.OpenConns != 1 || stats.IdleConns != 1 {
+		t.Errorf("expected 1 open idle connection, got open=%d idle=%d", stats.OpenConns, stats.IdleConns)
+	}
+}
+
+func TestProxyService_ServeHTTP_H2CBackend(t *testing.T) {
+	release := make(chan struct{})
+	backendServer := httptest.NewUnstartedServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2 toward backend, got %s", r.Proto)
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "first\n")
+		w.(http.Flusher).Flush()
+
+		// El segundo mensaje solo se envía cuando el cliente recibió el primero
+		<-release
+		io.WriteString(w, "second\n")
+		w.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{}))
+	backendServer.Start()
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:     "grpc-backend",
+				Servers:  []domain.Server{{URL: backendServer.URL, Weight: 1, Active: true}},
+				Protocol: infrastructure.ProtocolH2C,
+			},
+		},
+	})
+
+	proxyServer := httptest.NewServer(service)
+	defer proxyServer.Close()
+
+	resp, err := http.Get(proxyServer.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "first\n" {
+		t.Fatalf("expected streamed first message, got %q (%v)", line, err)
+	}
+	close(release)
+
+	rest, err := io.ReadAll(reader)
+	if err != nil || string(rest) != "second\n" {
+		t.Fatalf("expected second message, got %q (%v)", rest, err)
+	}
+	if resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("expected Grpc-Status trailer 0, got %q", resp.Trailer.Get("Grpc-Status"))
+	}
+}
+
+func TestProxyService_ServeHTTP_GRPCStatusAccounting(t *testing.T) {
+	release := make(chan struct{})
+	backendServer := httptest.NewUnstartedServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		w.Header().Set("Content-Type", "application/grpc")
+		if r.URL.Query().Get("trailers_only") != "" {
+			w.Header().Set("Grpc-Status", status)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "message\n")
+		w.(http.Flusher).Flush()
+		if r.URL.Query().Get("hold") != "" {
+			<-release
+		}
+		w.Header().Set("Grpc-Status", status)
+	}), &http2.Server{}))
+	backendServer.Start()
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:     "grpc-backend",
+				Servers:  []domain.Server{{URL: backendServer.URL, Weight: 1, Active: true}},
+				Protocol: infrastructure.ProtocolGRPC,
+			},
+		},
+	})
+
+	proxyServer := httptest.NewServer(service)
+	defer proxyServer.Close()
+
+	call := func(query string) {
+		t.Helper()
+		resp, err := http.Get(proxyServer.URL + "/pkg.Service/Method?" + query)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected HTTP 200, got %d", resp.StatusCode)
+		}
+	}
+	stats := func() *domain.Server {
+		return lb.GetServerMetrics()[backendServer.URL]
+	}
+
+	// HTTP 200 con UNAVAILABLE en el trailer cuenta como fallo
+	call("status=14")
+	if got := stats().FailedRequests; got != 1 {
+		t.Errorf("expected UNAVAILABLE to count as failure, got %d failures", got)
+	}
+
+	// NOT_FOUND es un error de la llamada, no del servidor
+	call("status=5")
+	call("status=0")
+	if got := stats().FailedRequests; got != 1 {
+		t.Errorf("expected NOT_FOUND and OK to count as success, got %d failures", got)
+	}
+
+	// Respuesta trailers-only: grpc-status viaja en las cabeceras
+	call("status=13&trailers_only=1")
+	if got := stats().FailedRequests; got != 2 {
+		t.Errorf("expected trailers-only INTERNAL to count as failure, got %d failures", got)
+	}
+
+	// La conexión sigue activa mientras el stream está abierto
+	resp, err := http.Get(proxyServer.URL + "/pkg.Service/Stream?status=0&hold=1")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	bufio.NewReader(resp.Body).ReadString('\n')
+	if conns := stats().CurrentConns; conns != 1 {
+		t.Errorf("expected an active connection during the stream, got %d", conns)
+	}
+	close(release)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if conns := stats().CurrentConns; conns != 0 {
+		t.Errorf("expected the connection to be released after the stream, got %d", conns)
+	}
+	if got := stats().TotalRequests; got != 5 {
+		t.Errorf("expected 5 accounted requests, got %d", got)
+	}
+}
+
+func TestProxyService_ServeHTTP_Mirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, "primary")
+	}))
+	defer primary.Close()
+
+	type mirrored struct {
+		method, path, body string
+	}
+	received := make(chan mirrored, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// Un mirror lento no debe retrasar al cliente
+		time.Sleep(200 * time.Millisecond)
+		received <- mirrored{r.Method, r.URL.RequestURI(), string(body)}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:    "test-backend",
+				Servers: []domain.Server{{URL: primary.URL, Weight: 1, Active: true}},
+				Mirror:  &domain.MirrorCfg{URL: shadow.URL, SamplePercent: 100, MaxBodyBytes: 16},
+			},
+		},
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("POST", "/orders?id=7", strings.NewReader("payload")))
+	if w.Body.String() != "primary" {
+		t.Fatalf("expected primary response, got %q", w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("mirror delayed the primary response: %v", elapsed)
+	}
+
+	select {
+	case got := <-received:
+		if got != (mirrored{"POST", "/orders?id=7", "payload"}) {
+			t.Errorf("unexpected mirrored request %+v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("request was not mirrored")
+	}
+
+	// Body mayor que max_body_bytes: no se replica
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", 32))))
+	if w.Body.String() != "primary" {
+		t.Fatalf("expected primary response, got %q", w.Body.String())
+	}
+
+	metrics := service.GetMetrics()
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&metrics.MirrorFailures) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := atomic.LoadInt64(&metrics.MirrorRequests); got != 1 {
+		t.Errorf("expected 1 mirror request, got %d", got)
+	}
+	if got := atomic.LoadInt64(&metrics.MirrorFailures); got != 1 {
+		t.Errorf("expected the mirror 500 to be recorded, got %d failures", got)
+	}
+	if got := atomic.LoadInt64(&metrics.MirrorDropped); got != 1 {
+		t.Errorf("expected the oversized body to be dropped, got %d", got)
+	}
+
+	// Las métricas del servidor primario no incluyen el mirror
+	if stats := lb.GetServerMetrics()[primary.URL]; stats.FailedRequests != 0 || stats.TotalRequests != 2 {
+		t.Errorf("mirror affected primary stats: %d requests, %d failures", stats.TotalRequests, stats.FailedRequests)
+	}
+}
+
+func TestProxyService_ServeHTTP_StickyAffinityCookie(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		}))
+	}
+	backendA := newBackend("a")
+	defer backendA.Close()
+	backendB := newBackend("b")
+	defer backendB.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name: "test-backend",
+				Servers: []domain.Server{
+					{URL: backendA.URL, Weight: 1, Active: true, Healthy: true},
+					{URL: backendB.URL, Weight: 1, Active: true, Healthy: true},
+				},
+				StickySessions: true,
+				StickyCookie: domain.StickyCookieCfg{
+					Name:     "affinity",
+					TTL:      10 * time.Minute,
+					SameSite: "strict",
+					Secure:   true,
+				},
+			},
+		},
+	})
+
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "affinity" {
+		t.Fatalf("expected affinity cookie to be issued, got %v", cookies)
+	}
+	cookie := cookies[0]
+	if cookie.MaxAge != 600 || !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("unexpected cookie attributes: %+v", cookie)
+	}
+	if strings.Contains(cookie.Value, "127.0.0.1") {
+		t.Error("expected cookie not to expose the server URL")
+	}
+	first := w.Body.String()
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, req)
+
+		if w.Body.String() != first {
+			t.Fatalf("request %d: expected pinned server %q, got %q", i, first, w.Body.String())
+		}
+		if len(w.Result().Cookies()) != 0 {
+			t.Errorf("request %d: expected no new cookie for a pinned client", i)
+		}
+	}
+
+	// Clientes con identificador de sesión propio no reciben la cookie
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Session-ID", "client-session")
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("expected no affinity cookie when the client has a session id")
+	}
+}
+
+func TestSessionID_AffinitySources(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend domain.Backend
+		setup   func(r *http.Request)
+		want    string
+	}{
+		{"default cookie", domain.Backend{}, func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "JSESSIONID", Value: "java"})
+		}, "java"},
+		{"default header", domain.Backend{}, func(r *http.Request) {
+			r.Header.Set("X-Session-ID", "header")
+		}, "header"},
+		{"custom cookie", domain.Backend{AffinityCookie: "PHPSESSID"}, func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: "JSESSIONID", Value: "java"})
+			r.AddCookie(&http.Cookie{Name: "PHPSESSID", Value: "php"})
+		}, "php"},
+		{"custom header", domain.Backend{AffinityHeader: "X-User"}, func(r *http.Request) {
+			r.Header.Set("X-User", "user-1")
+		}, "user-1"},
+		{"query", domain.Backend{AffinityQuery: "sid"}, func(r *http.Request) {
+			r.URL.RawQuery = "sid=query-1"
+		}, "query-1"},
+		{"cookie before query", domain.Backend{AffinityCookie: "connect.sid", AffinityQuery: "sid"}, func(r *http.Request) {
+			r.URL.RawQuery = "sid=query-1"
+			r.AddCookie(&http.Cookie{Name: "connect.sid", Value: "node"})
+		}, "node"},
+		{"query not configured", domain.Backend{}, func(r *http.Request) {
+			r.URL.RawQuery = "sid=query-1"
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			tt.setup(req)
+			if got := sessionID(req, &tt.backend); got != tt.want {
+				t.Errorf("expected session id %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProxyService_SessionTableEviction(t *testing.T) {
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	backend := &domain.Backend{
+		Name:        "test-backend",
+		Servers:     []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true, Healthy: true}},
+		SessionTTL:  time.Minute,
+		MaxSessions: 2,
+	}
+	server := &backend.Servers[0]
+	request := func(sessionID string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Session-ID", sessionID)
+		return req
+	}
+
+	service.setSessionServer(request("a"), backend, server)
+	service.setSessionServer(request("b"), backend, server)
+	// Usar "a" la convierte en la más reciente: al llenarse se descarta "b"
+	if service.getSessionServer(request("a"), backend, nil) == nil {
+		t.Fatal("expected session a to be stored")
+	}
+	service.setSessionServer(request("c"), backend, server)
+
+	if service.getSessionServer(request("b"), backend, nil) != nil {
+		t.Error("expected least recently used session b to be evicted")
+	}
+	if service.getSessionServer(request("a"), backend, nil) == nil || service.getSessionServer(request("c"), backend, nil) == nil {
+		t.Error("expected sessions a and c to be kept")
+	}
+	if got := service.GetMetrics().ActiveSessions; got != 2 {
+		t.Errorf("expected 2 active sessions in metrics, got %d", got)
+	}
+
+	// El sweeper elimina las sesiones inactivas más que session_ttl
+	if removed := service.sweepSessions(time.Now().Add(2*time.Minute), time.Minute); removed != 2 {
+		t.Errorf("expected 2 expired sessions to be swept, got %d", removed)
+	}
+	if got := service.GetMetrics().ActiveSessions; got != 0 {
+		t.Errorf("expected empty session table, got %d", got)
+	}
+
+	// Una sesión caducada tampoco se respeta antes de que pase el sweeper
+	backend.SessionTTL = time.Nanosecond
+	service.setSessionServer(request("d"), backend, server)
+	time.Sleep(time.Millisecond)
+	if service.getSessionServer(request("d"), backend, nil) != nil {
+		t.Error("expected expired session to be ignored")
+	}
+	if got := service.sessionCount(); got != 0 {
+		t.Errorf("expected expired session to be removed on lookup, got %d entries", got)
+	}
+}
+
+func TestProxyService_StickySessionFailover(t *testing.T) {
+	newBackend := func(failback bool) *domain.Backend {
+		return &domain.Backend{
+			Name: "test-backend",
+			Servers: []domain.Server{
+				{URL: "http://localhost:3001", Weight: 1, Active: true, Healthy: true},
+				{URL: "http://localhost:3002", Weight: 1, Active: true, Healthy: true},
+				{URL: "http://localhost:3003", Weight: 1, Active: true, Healthy: true},
+				{URL: "http://localhost:3004", Weight: 1, Active: true, Healthy: true},
+			},
+			StickySessions: true,
+			StickyFailback: &failback,
+		}
+	}
+	request := func() *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Session-ID", "user-42")
+		return req
+	}
+	failover := func(t *testing.T, service *ProxyServiceImpl, backend *domain.Backend) string {
+		t.Helper()
+		backend.Servers[0].Healthy = false
+		backup := service.getSessionServer(request(), backend, nil)
+		if backup == nil || backup.URL == backend.Servers[0].URL {
+			t.Fatalf("expected a backup server while the primary is down, got %v", backup)
+		}
+		for i := 0; i < 10; i++ {
+			if got := service.getSessionServer(request(), backend, nil); got == nil || got.URL != backup.URL {
+				t.Fatalf("request %d: expected the same backup %s, got %v", i, backup.URL, got)
+			}
+		}
+		return backup.URL
+	}
+
+	t.Run("failback to the recovered primary", func(t *testing.T) {
+		service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+		backend := newBackend(true)
+		service.setSessionServer(request(), backend, &backend.Servers[0])
+		backupURL := failover(t, service, backend)
+
+		// Otra instancia del proxy con la misma sesión elige el mismo respaldo
+		other := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+		other.setSessionServer(request(), backend, &backend.Servers[0])
+		if got := other.getSessionServer(request(), backend, nil); got == nil || got.URL != backupURL {
+			t.Errorf("expected every proxy instance to fail over to %s, got %v", backupURL, got)
+		}
+
+		// La caída de otro servidor no mueve la sesión de su respaldo
+		for i := range backend.Servers[1:] {
+			if server := &backend.Servers[i+1]; server.URL != backupURL {
+				server.Healthy = false
+				break
+			}
+		}
+		if got := service.getSessionServer(request(), backend, nil); got == nil || got.URL != backupURL {
+			t.Errorf("expected backup %s to survive another server going down, got %v", backupURL, got)
+		}
+
+		backend.Servers[0].Healthy = true
+		if got := service.getSessionServer(request(), backend, nil); got == nil || got.URL != backend.Servers[0].URL {
+			t.Errorf("expected session to return to the recovered primary, got %v", got)
+		}
+	})
+
+	t.Run("re-pin to the backup without failback", func(t *testing.T) {
+		service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+		backend := newBackend(false)
+		service.setSessionServer(request(), backend, &backend.Servers[0])
+		backupURL := failover(t, service, backend)
+
+		backend.Servers[0].Healthy = true
+		if got := service.getSessionServer(request(), backend, nil); got == nil || got.URL != backupURL {
+			t.Errorf("expected session to stay on backup %s, got %v", backupURL, got)
+		}
+	})
+
+	t.Run("re-pin when the primary is removed", func(t *testing.T) {
+		service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+		backend := newBackend(true)
+		service.storeSession("user-42", "http://localhost:3009", defaultMaxSessions)
+
+		backup := service.getSessionServer(request(), backend, nil)
+		if backup == nil {
+			t.Fatal("expected a backup for a session whose server was removed")
+		}
+		if serverURL, _ := service.lookupSession("user-42", time.Minute); serverURL != backup.URL {
+			t.Errorf("expected session to be re-pinned to %s, got %s", backup.URL, serverURL)
+		}
+	})
+
+	t.Run("no backup when every server is down", func(t *testing.T) {
+		service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+		backend := newBackend(true)
+		service.setSessionServer(request(), backend, &backend.Servers[0])
+		for i := range backend.Servers {
+			backend.Servers[i].Healthy = false
+		}
+		if got := service.getSessionServer(request(), backend, nil); got != nil {
+			t.Errorf("expected no server, got %s", got.URL)
+		}
+	})
+}
+
+func TestProxyService_SessionSweeper(t *testing.T) {
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	backend := domain.Backend{
+		Name:       "test-backend",
+		Servers:    []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}},
+		SessionTTL: time.Millisecond,
+	}
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+	service.storeSession("a", "http://localhost:3001", defaultMaxSessions)
+
+	service.StartSessionSweeper(5 * time.Millisecond)
+	defer service.StopSessionSweeper()
+
+	deadline := time.Now().Add(time.Second)
+	for service.sessionCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sweeper to evict the idle session")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProxyService_ServeHTTP_HeaderMatch(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		}))
+	}
+	stable := newBackend("stable")
+	defer stable.Close()
+	canary := newBackend("canary")
+	defer canary.Close()
+	tenant := newBackend("tenant")
+	defer tenant.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name: "test-backend",
+				Servers: []domain.Server{
+					{URL: stable.URL, Weight: 1, Active: true, Healthy: true},
+					{URL: canary.URL, Weight: 1, Active: true, Healthy: true},
+					{URL: tenant.URL, Weight: 1, Active: true, Healthy: true},
+				},
+				HeaderMatch: []domain.HeaderMatchRule{
+					{Header: "X-Canary", Value: "true", Servers: []string{canary.URL}},
+					{Header: "X-Tenant", Regex: "^acme-", Servers: []string{tenant.URL}},
+					{Header: "X-Broken", Regex: "(", Servers: []string{canary.URL}},
+				},
+			},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected string
+	}{
+		{"exact value", "X-Canary", "true", "canary"},
+		{"regex", "x-tenant", "acme-eu", "tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set(tt.header, tt.value)
+				w := httptest.NewRecorder()
+				service.ServeHTTP(w, req)
+
+				if w.Body.String() != tt.expected {
+					t.Fatalf("request %d: expected %q, got %q", i, tt.expected, w.Body.String())
+				}
+			}
+		})
+	}
+
+	// Sin coincidencia (o con una regla inválida) se usa todo el pool
+	seen := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Canary", "false")
+		req.Header.Set("X-Broken", "x")
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, req)
+		seen[w.Body.String()] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected unmatched requests to use the whole pool, got %v", seen)
+	}
+}
+
+func TestProxyService_ServeHTTP_BodyMatch(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			io.WriteString(w, name+":"+string(body))
+		}))
+	}
+	general := newBackend("general")
+	defer general.Close()
+	payments := newBackend("payments")
+	defer payments.Close()
+	vip := newBackend("vip")
+	defer vip.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{{
+			Name: "test-backend",
+			Servers: []domain.Server{
+				{URL: general.URL, Weight: 1, Active: true, Healthy: true},
+				{URL: payments.URL, Weight: 1, Active: true, Healthy: true},
+				{URL: vip.URL, Weight: 1, Active: true, Healthy: true},
+			},
+			HeaderMatch: []domain.HeaderMatchRule{
+				{Header: "X-Replay", Servers: []string{general.URL}},
+			},
+			BodyMatch: &domain.BodyMatchCfg{
+				Enabled:      true,
+				MaxBodyBytes: 64,
+				Rules: []domain.BodyMatchRule{
+					{Path: "event_type", Value: "payment.succeeded", Servers: []string{payments.URL}},
+					{Path: "data.items.0.sku", Regex: "^vip-", Servers: []string{vip.URL}},
+				},
+			},
+		}},
+	})
+
+	tests := []struct {
+		name     string
+		body     string
+		header   string
+		expected string
+	}{
+		{"exact value", `{"event_type":"payment.succeeded","id":1}`, "", "payments"},
+		{"nested array regex", `{"data":{"items":[{"sku":"vip-42"}]}}`, "", "vip"},
+		{"header rule first", `{"event_type":"payment.succeeded"}`, "X-Replay", "general"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest("POST", "/webhook", strings.NewReader(tt.body))
+				if tt.header != "" {
+					req.Header.Set(tt.header, "1")
+				}
+				w := httptest.NewRecorder()
+				service.ServeHTTP(w, req)
+
+				// El upstream recibe el cuerpo completo aunque se haya inspeccionado
+				if expected := tt.expected + ":" + tt.body; w.Body.String() != expected {
+					t.Fatalf("request %d: expected %q, got %q", i, expected, w.Body.String())
+				}
+			}
+		})
+	}
+
+	// Sin coincidencia, sin JSON o por encima de max_body_bytes se usa todo el
+	// pool y el cuerpo llega intacto
+	large := `{"event_type":"payment.succeeded","padding":"` + strings.Repeat("x", 64) + `"}`
+	for _, body := range []string{`{"event_type":"refund"}`, "not json", large} {
+		seen := make(map[string]bool)
+		for i := 0; i < 30; i++ {
+			req := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+			// Sin Content-Length el límite se detecta al leer
+			req.ContentLength = -1
+			w := httptest.NewRecorder()
+			service.ServeHTTP(w, req)
+
+			name, received, _ := strings.Cut(w.Body.String(), ":")
+			if received != body {
+				t.Fatalf("expected body %q to reach the upstream intact, got %q", body, received)
+			}
+			seen[name] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("expected unmatched body %q to use the whole pool, got %v", body, seen)
+		}
+	}
+}
+
+func TestProxyService_ServeHTTP_ServerNameRouting(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		}))
+	}
+	shop := newBackend("shop")
+	defer shop.Close()
+	tenants := newBackend("tenants")
+	defer tenants.Close()
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name: "test-backend",
+				Servers: []domain.Server{
+					{URL: shop.URL, Weight: 1, Active: true, Healthy: true},
+					{URL: tenants.URL, Weight: 1, Active: true, Healthy: true},
+				},
+				HeaderMatch: []domain.HeaderMatchRule{
+					{ServerName: "Shop.Example.com", Servers: []string{shop.URL}},
+					{ServerName: "*.tenants.example.com", Servers: []string{tenants.URL}},
+				},
+			},
+		},
+	}
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(config)
+
+	tests := []struct {
+		name     string
+		host     string
+		sni      string
+		expected string
+	}{
+		{"sni", "shop.example.com", "shop.example.com", "shop"},
+		{"sni wins over host", "acme.tenants.example.com", "shop.example.com", "shop"},
+		{"wildcard sni", "acme.tenants.example.com", "acme.tenants.example.com", "tenants"},
+		{"host without tls", "acme.tenants.example.com:8080", "", "tenants"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Host = tt.host
+				if tt.sni != "" {
+					req.TLS = &tls.ConnectionState{ServerName: tt.sni}
+				}
+				w := httptest.NewRecorder()
+				service.ServeHTTP(w, req)
+
+				if w.Body.String() != tt.expected {
+					t.Fatalf("request %d: expected %q, got %q", i, tt.expected, w.Body.String())
+				}
+			}
+		})
+	}
+
+	// Con strict_sni un Host distinto del SNI se rechaza con 421
+	config.Proxy.TLS = &domain.ProxyTLSCfg{StrictSNI: true}
+	service.UpdateConfig(config)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "acme.tenants.example.com"
+	req.TLS = &tls.ConnectionState{ServerName: "shop.example.com"}
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusMisdirectedRequest {
+		t.Errorf("expected status 421, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Host = "shop.example.com:443"
+	req.TLS = &tls.ConnectionState{ServerName: "shop.example.com"}
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "shop" {
+		t.Errorf("expected matching host to be served, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMatchServerName(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"shop.example.com", "shop.example.com", true},
+		{"shop.example.com", "api.example.com", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "a.b.example.com", false},
+		{"*.example.com", ".example.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchServerName(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchServerName(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProxyService_ServeHTTP_WebSocketUpgrade(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			kind, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(kind, message)
+		}
+	}))
+	defer upstream.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true, Healthy: true}},
+	}}})
+	proxy := httptest.NewServer(service)
+	defer proxy.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxy.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial through proxy: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, message, err := conn.ReadMessage(); err != nil || string(message) != "ping" {
+		t.Fatalf("expected echo, got %q (%v)", message, err)
+	}
+
+	// La conexión abierta cuenta como upgrade, no como request en curso
+	stats := service.GetServerStats()[upstream.URL]
+	if stats.UpgradedConns != 1 || stats.CurrentConns != 0 {
+		t.Errorf("expected 1 upgraded and 0 active connections, got %d and %d", stats.UpgradedConns, stats.CurrentConns)
+	}
+	if stats.TotalRequests != 1 || stats.FailedRequests != 0 {
+		t.Errorf("expected the handshake as one successful request, got %d requests and %d failures", stats.TotalRequests, stats.FailedRequests)
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats = service.GetServerStats()[upstream.URL]
+		if stats.UpgradedConns == 0 && stats.UpgradedClosed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the upgraded connection to close, got %d open and %d closed", stats.UpgradedConns, stats.UpgradedClosed)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProxyService_ServeHTTP_UnixSocketBackend(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen on unix socket: %v", err)
+	}
+	upstream := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-Host", r.Host)
+		w.Write([]byte(r.URL.Path))
+	})}
+	go upstream.Serve(listener)
+	defer upstream.Close()
+
+	serverURL := "unix://" + socket
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: serverURL, Weight: 1, Active: true, Healthy: true}},
+	}}})
+
+	req := httptest.NewRequest("GET", "http://example.com/api/users", nil)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 through the socket, got %d: %s", w.Code, w.Body.String())
+	}
+	if body := w.Body.String(); body != "/api/users" {
+		t.Errorf("expected path /api/users, got %q", body)
+	}
+	// El Host del cliente llega al backend aunque la dirección sea el socket
+	if host := w.Header().Get("X-Seen-Host"); host != "example.com" {
+		t.Errorf("expected Host example.com, got %q", host)
+	}
+	if stats := service.GetServerStats()[serverURL]; stats.TotalRequests != 1 {
+		t.Errorf("expected the request counted for %s, got %d", serverURL, stats.TotalRequests)
+	}
+}
+
+func TestProxyService_ServeHTTP_ErrorClassification(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.WriteHeader(status)
+	}))
+	defer upstream.Close()
+
+	backend := domain.Backend{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true, Healthy: true}},
+	}
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	send := func(status, n int) {
+		for i := 0; i < n; i++ {
+			w := httptest.NewRecorder()
+			service.ServeHTTP(w, httptest.NewRequest("GET", "/?status="+strconv.Itoa(status), nil))
+		}
+	}
+	failed := func() int64 { return service.GetServerStats()[upstream.URL].FailedRequests }
+
+	// Por defecto los 4xx no son fallo
+	send(404, 4)
+	send(429, 2)
+	if got := failed(); got != 0 {
+		t.Fatalf("expected 4xx to count as success by default, got %d failures", got)
+	}
+
+	backend.ErrorClassification = &domain.ErrorClassificationCfg{
+		ClientErrors: 0.5,
+		Codes:        map[int]float64{429: 1, 503: 0},
+	}
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	send(404, 4)
+	if got := failed(); got != 2 {
+		t.Errorf("expected half of the 404s to count as failures, got %d", got)
+	}
+	send(429, 2)
+	if got := failed(); got != 4 {
+		t.Errorf("expected every 429 to count as a failure, got %d", got)
+	}
+	send(503, 3)
+	send(500, 1)
+	if got := failed(); got != 5 {
+		t.Errorf("expected 503 ignored and 500 counted, got %d failures", got)
+	}
+}
+
+func TestProxyService_ServeHTTP_InvalidServerURL(t *testing.T) {
+	server := &domain.Server{URL: "localhost:3001", Weight: 1, Active: true, Healthy: true}
+	lb := &staticLoadBalancer{server: server}
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{Name: "test-backend", Servers: []domain.Server{*server}},
+		},
+	})
+
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Invalid backend server URL") {
+		t.Errorf("expected clear error message, got %q", w.Body.String())
+	}
+	if lb.failures != 1 {
+		t.Errorf("expected the selection to be released as a failure, got %d", lb.failures)
+	}
+}
+
+func TestProxyService_ServeHTTP_RetrySkipsOpenCircuits(t *testing.T) {
+	newDeadServer := func() string {
+		dead := httptest.NewServer(http.NotFoundHandler())
+		dead.Close()
+		return dead.URL
+	}
+	var trippedHits int64
+	tripped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&trippedHits, 1)
+	}))
+	defer tripped.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer good.Close()
+
+	newService := func(servers ...string) (*ProxyServiceImpl, *infrastructure.EnterpriseBalancer) {
+		backend := domain.Backend{
+			Name:    "test-backend",
+			Retries: 3,
+			CircuitBreaker: domain.CircuitBreakerCfg{
+				FailureThreshold: 5,
+				RecoveryTimeout:  time.Minute,
+				LastResort:       true,
+			},
+		}
+		for _, serverURL := range servers {
+			backend.Servers = append(backend.Servers, domain.Server{URL: serverURL, Weight: 1, Active: true})
+		}
+		lb := infrastructure.NewEnterpriseBalancer()
+		service := NewProxyService(lb, &mockHealthChecker{})
+		service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+		// Abrir el circuito del servidor tripped
+		for i := 0; i < 10; i++ {
+			lb.UpdateStats(&domain.Server{URL: tripped.URL}, time.Millisecond, false)
+		}
+		return service, lb
+	}
+
+	service, lb := newService(newDeadServer(), newDeadServer(), tripped.URL, good.URL)
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Fatalf("request %d: expected retry to reach the healthy server, got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+	if conns := lb.GetServerMetrics()[good.URL].CurrentConns; conns != 0 {
+		t.Errorf("expected retried requests to release their connections, got %d", conns)
+	}
+
+	// Sin alternativa sana: un único 503, sin recurrir al circuito abierto
+	service, _ = newService(newDeadServer(), tripped.URL)
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503 without healthy alternatives, got %d", w.Code)
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != "Service Temporarily Unavailable" {
+			t.Errorf("expected a single error body, got %q", body)
+		}
+	}
+	if hits := atomic.LoadInt64(&trippedHits); hits != 0 {
+		t.Errorf("expected no retries to the open circuit, got %d", hits)
+	}
+}
+
+func TestProxyService_ServeHTTP_ResponseHeaderTimeout(t *testing.T) {
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer hung.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name: "test-backend",
+				Servers: []domain.Server{
+					{URL: hung.URL, Weight: 1, Active: true, Healthy: true},
+				},
+				Transport: domain.TransportCfg{ResponseHeaderTimeout: 50 * time.Millisecond},
+			},
+		},
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("expected to fail fast, took %v", elapsed)
+	}
+	if stats := service.GetServerStats()[hung.URL]; stats == nil || stats.FailedRequests != 1 {
+		t.Errorf("expected the timeout to be recorded as a failure, got %+v", stats)
+	}
+}
+
+func TestProxyService_ServeHTTP_RequestBudget(t *testing.T) {
+	var hits int64
+	newHungServer := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&hits, 1)
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}))
+	}
+	servers := []*httptest.Server{newHungServer(), newHungServer(), newHungServer()}
+	backend := domain.Backend{
+		Name:    "test-backend",
+		Retries: 3,
+		// Cada intento agota su timeout de cabeceras y se reintenta
+		Transport:      domain.TransportCfg{ResponseHeaderTimeout: 100 * time.Millisecond},
+		RequestTimeout: 150 * time.Millisecond,
+	}
+	for _, server := range servers {
+		defer server.Close()
+		backend.Servers = append(backend.Servers, domain.Server{URL: server.URL, Weight: 1, Active: true})
+	}
+
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504 once the budget elapsed, got %d", w.Code)
+	}
+	// Sin plazo total serían tres intentos de 100ms
+	if elapsed := time.Since(start); elapsed >= 250*time.Millisecond {
+		t.Errorf("expected the budget to bound the retries, took %v", elapsed)
+	}
+	if got := atomic.LoadInt64(&hits); got != 2 {
+		t.Errorf("expected the initial attempt and one retry, got %d attempts", got)
+	}
+}
+
+func TestProxyService_ServeHTTP_RequestID(t *testing.T) {
+	var upstreamID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamID = r.Header.Get("X-Request-ID")
+		// Un backend que devuelve el ID no debe duplicar la cabecera
+		w.Header().Set("X-Request-ID", upstreamID)
+	}))
+	defer server.Close()
+
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: server.URL, Weight: 1, Active: true}},
+	}}})
+
+	// Sin ID del cliente se genera un UUID
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	generated := w.Header().Values("X-Request-ID")
+	if len(generated) != 1 || len(generated[0]) != 36 || generated[0][14] != '4' {
+		t.Fatalf("expected one generated UUID v4, got %v", generated)
+	}
+	if upstreamID != generated[0] {
+		t.Errorf("expected backend to receive %q, got %q", generated[0], upstreamID)
+	}
+
+	// El ID del cliente se conserva
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "client-id-123")
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); got != "client-id-123" || upstreamID != "client-id-123" {
+		t.Errorf("expected client ID to be propagated and echoed, got %q upstream and %q downstream", upstreamID, got)
+	}
+
+	// Un ID inválido se reemplaza
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "bad id\x01")
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Request-ID"); got == "bad id\x01" || len(got) != 36 {
+		t.Errorf("expected invalid client ID to be replaced, got %q", got)
+	}
+
+	// Las respuestas de error también llevan el ID
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{{Name: "test-backend"}}})
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Request-ID") == "" {
+		t.Errorf("expected error response with request ID, got %d %q", w.Code, w.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestProxyService_ServeHTTP_AccessLogSampling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	rate := 0.0
+	config := &domain.Config{
+		Logging: domain.LoggingConfig{AccessLog: true, SampleRate: &rate},
+		Backends: []domain.Backend{{
+			Name:    "test-backend",
+			Servers: []domain.Server{{URL: server.URL, Weight: 1, Active: true}},
+		}},
+	}
+	balancer := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(balancer, &mockHealthChecker{})
+	service.UpdateConfig(config)
+
+	req := httptest.NewRequest("GET", "/sampled", nil)
+	req.Header.Set("X-Request-ID", "sampled-out")
+	service.ServeHTTP(httptest.NewRecorder(), req)
+	if strings.Contains(buf.String(), "sampled-out") {
+		t.Errorf("expected 2xx to be sampled out with rate 0, got log %q", buf.String())
+	}
+
+	// Los errores se registran siempre
+	config.Backends[0].Servers = nil
+	service.UpdateConfig(config)
+	req = httptest.NewRequest("GET", "/failing", nil)
+	req.Header.Set("X-Request-ID", "always-logged")
+	service.ServeHTTP(httptest.NewRecorder(), req)
+	if line := buf.String(); !strings.Contains(line, "always-logged") || !strings.Contains(line, "uri=/failing proto=HTTP/1.1 status=503") {
+		t.Errorf("expected 5xx access log entry, got %q", line)
+	}
+
+	rate = 1
+	req = httptest.NewRequest("GET", "/logged", nil)
+	req.Header.Set("X-Request-ID", "sampled-in")
+	config.Backends[0].Servers = []domain.Server{{URL: server.URL, Weight: 1, Active: true}}
+	service.UpdateConfig(config)
+	// El servidor vuelve como nuevo: entra en rotación tras su health check
+	balancer.ReportHealth(server.URL, true)
+	service.ServeHTTP(httptest.NewRecorder(), req)
+	if line := buf.String(); !strings.Contains(line, "sampled-in") || !strings.Contains(line, "status=200 bytes=2 ") {
+		t.Errorf("expected 2xx access log entry with rate 1, got %q", line)
+	}
+}
+
+func TestProxyService_ServeHTTP_StatusRemap(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/teapot":
+			w.WriteHeader(http.StatusTeapot)
+		case "/legacy-error":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer upstream.Close()
+
+	lb := &staticLoadBalancer{server: &domain.Server{URL: upstream.URL, Weight: 1, Active: true}}
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{{
+		Name:        "test-backend",
+		Servers:     []domain.Server{*lb.server},
+		StatusRemap: map[int]int{http.StatusTeapot: http.StatusServiceUnavailable, http.StatusBadGateway: http.StatusOK},
+	}}})
+
+	tests := []struct {
+		path         string
+		wantStatus   int
+		wantFailures int
+	}{
+		// 418 -> 503: el cliente y el circuit breaker lo ven como fallo
+		{"/teapot", http.StatusServiceUnavailable, 1},
+		// 502 -> 200: deja de contar como fallo
+		{"/legacy-error", http.StatusOK, 1},
+		// Sin entrada en el mapa el código no cambia
+		{"/", http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.wantStatus, w.Code)
+		}
+		if lb.failures != tt.wantFailures {
+			t.Errorf("%s: expected %d recorded failures, got %d", tt.path, tt.wantFailures, lb.failures)
+		}
+	}
+}
+
+func TestProxyService_ServeHTTP_ConcurrencyLimit(t *testing.T) {
+	unblock := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-unblock
+		}
+	}))
+	defer upstream.Close()
+
+	backend := domain.Backend{
+		Name:                  "test-backend",
+		Servers:               []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+		MaxConcurrentRequests: 1,
+	}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		done <- w.Code
+	}()
+	waitForInFlight(t, service, "test-backend", 1)
+
+	// Sin cola, lo que supera el tope recibe 503 sin llegar al servidor
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 over the concurrency limit, got %d", w.Code)
+	}
+	if rejected := service.BackendQueueStats()["test-backend"].Rejected; rejected != 1 {
+		t.Errorf("expected 1 rejected request, got %d", rejected)
+	}
+
+	// Con cola, la request espera a que se libere el hueco
+	backend.Queue = domain.QueueCfg{MaxDepth: 1, MaxWait: 2 * time.Second}
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+	queued := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		queued <- w.Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(unblock)
+
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected first request to succeed, got %d", code)
+	}
+	if code := <-queued; code != http.StatusOK {
+		t.Errorf("expected queued request to succeed once the slot was released, got %d", code)
+	}
+	if got := service.GetMetrics().BackendInFlight["test-backend"]; got != 0 {
+		t.Errorf("expected no requests in flight, got %d", got)
+	}
+}
+
+func TestProxyService_ServeHTTP_RejectReason(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-unblock
+		}
+	}))
+	defer upstream.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{{
+		Name:    "test-backend",
+		Retries: 1,
+		Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true, MaxConnections: 1}},
+	}}})
+
+	done := make(chan struct{})
+	go func() {
+		service.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		close(done)
+	}()
+	<-started
+
+	// El único servidor está en max_connections: hace falta capacidad
 	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Proxy-Reject-Reason") != domain.RejectCapacity {
+		t.Errorf("expected 503 with reason capacity, got %d %q", w.Code, w.Header().Get("X-Proxy-Reject-Reason"))
+	}
+	close(unblock)
+	<-done
+
+	// Con el circuito abierto el problema es el servidor, no la capacidad
+	lb.ForceCircuitState(upstream.URL, infrastructure.CircuitOpen)
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Proxy-Reject-Reason") != domain.RejectUnhealthy {
+		t.Errorf("expected 503 with reason unhealthy, got %d %q", w.Code, w.Header().Get("X-Proxy-Reject-Reason"))
+	}
+
+	metrics := service.GetMetrics()
+	if metrics.CapacityRejections != 1 || metrics.UnhealthyRejections != 1 {
+		t.Errorf("expected one rejection of each kind, got %d capacity and %d unhealthy", metrics.CapacityRejections, metrics.UnhealthyRejections)
+	}
+}
+
+func TestProxyService_BackendSlotReleasedOnPanic(t *testing.T) {
+	backend := domain.Backend{Name: "test-backend", MaxConcurrentRequests: 1}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	func() {
+		defer func() { recover() }()
+		release, ok := service.acquireBackendSlot(httptest.NewRequest("GET", "/", nil), &backend)
+		if !ok {
+			t.Fatal("expected a free slot")
+		}
+		defer release()
+		panic(http.ErrAbortHandler)
+	}()
+
+	if _, ok := service.acquireBackendSlot(httptest.NewRequest("GET", "/", nil), &backend); !ok {
+		t.Error("expected the slot to be released after the panic")
+	}
+}
 
+// waitForInFlight espera a que el backend tenga want requests en curso
+func waitForInFlight(t *testing.T, service *ProxyServiceImpl, backend string, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for service.backendInFlight()[backend] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d requests in flight on %s", want, backend)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// panickingLoadBalancer simula un fallo de programación durante la selección
+type panickingLoadBalancer struct {
+	staticLoadBalancer
+}
+
+func (p *panickingLoadBalancer) SelectServer(backend *domain.Backend, clientIP string) *domain.Server {
+	panic("nil target")
+}
+
+func TestProxyService_ServeHTTP_RecoversPanic(t *testing.T) {
+	service := NewProxyService(&panickingLoadBalancer{}, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}},
+	}}})
+	service.metrics.TotalRequests = 1
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "panic-request")
+	w := httptest.NewRecorder()
 	service.ServeHTTP(w, req)
 
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 after a panic, got %d", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != "Internal Server Error" {
+		t.Errorf("expected a clean error body, got %q", body)
+	}
+	if w.Header().Get("X-Request-ID") != "panic-request" {
+		t.Errorf("expected request ID on the 500 response, got %q", w.Header().Get("X-Request-ID"))
+	}
+	if service.metrics.ErrorRate == 0 {
+		t.Error("expected the panic to be recorded as a failure")
+	}
+	if got := service.backendInFlight()["test-backend"]; got != 0 {
+		t.Errorf("expected the backend slot to be released, got %d in flight", got)
+	}
+}
+
+func TestProxyService_RecoverPanic_PropagatesAbortHandler(t *testing.T) {
+	service := NewProxyService(&staticLoadBalancer{}, &mockHealthChecker{})
+	w := httptest.NewRecorder()
+	defer func() {
+		if recovered := recover(); recovered != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to propagate, got %v", recovered)
+		}
+		if w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("expected no error response for an aborted handler, got %d %q", w.Code, w.Body.String())
+		}
+	}()
+
+	defer service.recoverPanic(w, httptest.NewRequest("GET", "/", nil), nil, time.Now())
+	panic(http.ErrAbortHandler)
+}
+
+func TestProxyService_ServeHTTP_ResponseHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	}))
+	defer upstream.Close()
+
+	config := &domain.Config{
+		Proxy: domain.ProxyConfig{ResponseHeaders: map[string]string{
+			"x-content-type-options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Strict-Transport-Security": "max-age=31536000",
+		}},
+		Backends: []domain.Backend{{
+			Name:    "test-backend",
+			Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+			// El backend quita HSTS y cambia la CSP
+			ResponseHeaders: map[string]string{
+				"Strict-Transport-Security": "",
+				"Content-Security-Policy":   "default-src 'self'",
+			},
+		}},
+	}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(config)
+
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	expected := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "SAMEORIGIN", // lo envía el backend
+		"Strict-Transport-Security": "",
+		"Content-Security-Policy":   "default-src 'self'",
+	}
+	for name, value := range expected {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("expected %s %q, got %q", name, value, got)
+		}
+	}
+
+	config.Proxy.OverrideResponseHeaders = true
+	service.UpdateConfig(config)
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Values("X-Frame-Options"); len(got) != 1 || got[0] != "DENY" {
+		t.Errorf("expected override to replace the backend header, got %v", got)
+	}
+}
+
+func TestProxyService_ServeHTTP_UpstreamHeader(t *testing.T) {
+	var spoof bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if spoof {
+			w.Header().Set("X-Upstream-Server", "http://spoofed")
+		}
+	}))
+	defer upstream.Close()
+
+	config := &domain.Config{
+		Backends: []domain.Backend{{
+			Name:    "test-backend",
+			Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+		}},
+	}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(config)
+
+	// Desactivada por defecto: la respuesta no revela el servidor
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Get("X-Upstream-Server"); got != "" {
+		t.Errorf("expected no upstream header by default, got %q", got)
+	}
+
+	config.Proxy.UpstreamHeader = "X-Upstream-Server"
+	service.UpdateConfig(config)
+	spoof = true
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Values("X-Upstream-Server"); len(got) != 1 || got[0] != upstream.URL {
+		t.Errorf("expected upstream header %q, got %v", upstream.URL, got)
+	}
+}
+
+func TestProxyService_ServeHTTP_PressureShedding(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+	}))
+	defer upstream.Close()
+
+	config := &domain.Config{
+		Proxy: domain.ProxyConfig{LoadShedding: &domain.PressureSheddingCfg{
+			MaxGoroutines:  100,
+			SampleInterval: time.Nanosecond,
+			RetryAfter:     3 * time.Second,
+		}},
+		Backends: []domain.Backend{{
+			Name:    "test-backend",
+			Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+		}},
+	}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(config)
+	var goroutines atomic.Int64
+	goroutines.Store(10)
+	service.pressure.read = func() pressureReading {
+		return pressureReading{goroutines: int(goroutines.Load())}
+	}
+
+	// Una request ya en curso termina aunque el proceso entre en presión
+	inFlight := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		inFlight <- w.Code
+	}()
+	<-started
+
+	goroutines.Store(500)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
 	if w.Code != http.StatusServiceUnavailable {
-		t.Errorf("expected status 503, got %d", w.Code)
+		t.Fatalf("expected 503 under pressure, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("expected Retry-After 3, got %q", got)
+	}
+	if got := w.Header().Get("Connection"); got != "close" {
+		t.Errorf("expected Connection close, got %q", got)
+	}
+
+	close(release)
+	if code := <-inFlight; code != http.StatusOK {
+		t.Errorf("expected in-flight request to complete, got %d", code)
+	}
+
+	metrics := service.GetMetrics()
+	if !metrics.Pressure.Shedding || metrics.Pressure.Goroutines != 500 || metrics.Pressure.Shed != 1 {
+		t.Errorf("expected shedding state in metrics, got %+v", metrics.Pressure)
+	}
+
+	// Al bajar la presión se vuelven a aceptar requests
+	goroutines.Store(10)
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 once pressure is relieved, got %d", w.Code)
+	}
+	if service.GetMetrics().Pressure.Shedding {
+		t.Error("expected shedding to stop once pressure is relieved")
 	}
 }
 
-func TestProxyService_ServeHTTP_NoActiveServers(t *testing.T) {
-	lb := infrastructure.NewEnterpriseBalancer()
-	hc := &mockHealthChecker{}
-	service := NewProxyService(lb, hc)
+func TestProxyService_ServeHTTP_InformationalResponses(t *testing.T) {
+	var expect string
+	var received int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect = r.Header.Get("Expect")
+		if r.URL.Path == "/too-large" {
+			// Rechazar sin leer: el cliente no llega a enviar el body
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Header().Set("Link", "</app.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		// Leer el body envía el 100 Continue
+		received, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Del("Link")
+		w.Write([]byte("uploaded"))
+	}))
+	defer upstream.Close()
 
 	config := &domain.Config{
+		Backends: []domain.Backend{{
+			Name:    "test-backend",
+			Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+		}},
+	}
+	balancer := infrastructure.NewEnterpriseBalancer()
+	balancer.UpdateBackends(config.Backends)
+	service := NewProxyService(balancer, &mockHealthChecker{})
+	service.UpdateConfig(config)
+	proxy := httptest.NewServer(service)
+	defer proxy.Close()
+
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+	upload := func(path string) (*http.Response, []int, []textproto.MIMEHeader) {
+		var codes []int
+		var headers []textproto.MIMEHeader
+		trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			codes = append(codes, code)
+			headers = append(headers, header)
+			return nil
+		}}
+		req, _ := http.NewRequest("POST", proxy.URL+path, bytes.NewReader(make([]byte, 4<<20)))
+		req.Header.Set("Expect", "100-continue")
+		resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		if err != nil {
+			t.Fatalf("upload failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp, codes, headers
+	}
+
+	resp, codes, headers := upload("/upload")
+	if resp.StatusCode != http.StatusOK || received != 4<<20 {
+		t.Fatalf("expected the whole upload forwarded, got %d with %d bytes", resp.StatusCode, received)
+	}
+	if expect != "100-continue" {
+		t.Errorf("expected Expect forwarded to the backend, got %q", expect)
+	}
+	if len(codes) != 2 || codes[0] != http.StatusEarlyHints || codes[1] != http.StatusContinue {
+		t.Fatalf("expected 103 and 100 forwarded, got %v", codes)
+	}
+	if headers[0].Get("Link") == "" || headers[0].Get(requestIDHeader) != "" {
+		t.Errorf("expected only the backend's early hints, got %v", headers[0])
+	}
+	// Las cabeceras fijadas por el proxy sobreviven a las 1xx
+	if resp.Header.Get(requestIDHeader) == "" || resp.Header.Get("Link") != "" {
+		t.Errorf("expected request ID and no early hints in the final response, got %v", resp.Header)
+	}
+
+	resp, codes, _ = upload("/too-large")
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || len(codes) != 0 {
+		t.Errorf("expected 413 without 1xx, got %d and %v", resp.StatusCode, codes)
+	}
+}
+
+func TestProxyService_ServeHTTP_UpstreamHost(t *testing.T) {
+	var receivedHost string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHost = r.Host
+	}))
+	defer upstream.Close()
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+
+	tests := []struct {
+		name         string
+		upstreamHost string
+		want         string
+	}{
+		{"default preserves the client host", "", "app.example.com"},
+		{"preserve", domain.UpstreamHostPreserve, "app.example.com"},
+		{"rewrite to the server host", domain.UpstreamHostRewrite, upstreamHost},
+		{"literal value", "internal.example.com:8443", "internal.example.com:8443"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+			service.UpdateConfig(&domain.Config{
+				Backends: []domain.Backend{{
+					Name:         "test-backend",
+					Servers:      []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+					UpstreamHost: tt.upstreamHost,
+				}},
+			})
+
+			receivedHost = ""
+			w := httptest.NewRecorder()
+			service.ServeHTTP(w, httptest.NewRequest("GET", "http://app.example.com/", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", w.Code)
+			}
+			if receivedHost != tt.want {
+				t.Errorf("expected upstream Host %q, got %q", tt.want, receivedHost)
+			}
+		})
+	}
+}
+
+// checksumBody fija el trailer de la request al terminar de enviar el body, como haría un cliente gRPC-web
+type checksumBody struct {
+	io.Reader
+	req *http.Request
+}
+
+func (b *checksumBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.req.Trailer.Set("X-Checksum", "abc123")
+	}
+	return n, err
+}
+
+func TestProxyService_ServeHTTP_Trailers(t *testing.T) {
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if got := r.Trailer.Get("X-Checksum"); got != "abc123" {
+			t.Errorf("expected request trailer X-Checksum abc123, got %q", got)
+		}
+
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "payload")
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+	}))
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
 		Backends: []domain.Backend{
 			{
 				Name:    "test-backend",
-				Servers: []domain.Server{}, // No servers
+				Servers: []domain.Server{{URL: backendServer.URL, Weight: 1, Active: true}},
+			},
+		},
+	})
+
+	proxyServer := httptest.NewServer(service)
+	defer proxyServer.Close()
+
+	req, _ := http.NewRequest("POST", proxyServer.URL, nil)
+	req.Trailer = http.Header{"X-Checksum": nil}
+	req.Body = io.NopCloser(&checksumBody{Reader: strings.NewReader("request"), req: req})
+	req.ContentLength = -1
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Errorf("expected announced trailer Grpc-Status 0, got %q", got)
+	}
+	if got := resp.Trailer.Get("Grpc-Message"); got != "ok" {
+		t.Errorf("expected undeclared trailer Grpc-Message ok, got %q", got)
+	}
+}
+
+func TestProxyService_ServeHTTP_EventStream(t *testing.T) {
+	release := make(chan struct{})
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+
+		// El segundo evento solo se emite cuando el cliente recibió el primero
+		select {
+		case <-release:
+		case <-time.After(2 * time.Second):
+			return
+		}
+		io.WriteString(w, "data: second\n\n")
+	}))
+	defer backendServer.Close()
+
+	lb := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(lb, &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:    "test-backend",
+				Servers: []domain.Server{{URL: backendServer.URL, Weight: 1, Active: true}},
+				// Un intervalo largo no debe retrasar los eventos SSE
+				FlushInterval: domain.FlushInterval(time.Hour),
 			},
 		},
+	})
+
+	proxyServer := httptest.NewServer(service)
+	defer proxyServer.Close()
+
+	resp, err := http.Get(proxyServer.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
 	}
-	service.UpdateConfig(config)
+	defer resp.Body.Close()
 
-	req := httptest.NewRequest("GET", "/", nil)
-	w := httptest.NewRecorder()
+	reader := bufio.NewReader(resp.Body)
+	first := make(chan string, 1)
+	go func() {
+		line, _ := reader.ReadString('\n')
+		first <- line
+	}()
 
-	service.ServeHTTP(w, req)
+	select {
+	case line := <-first:
+		if line != "data: first\n" {
+			t.Fatalf("expected first event, got %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first event was buffered by the proxy")
+	}
+	close(release)
 
-	if w.Code != http.StatusServiceUnavailable {
-		t.Errorf("expected status 503, got %d", w.Code)
+	rest, _ := io.ReadAll(reader)
+	if !strings.Contains(string(rest), "data: second") {
+		t.Errorf("expected second event, got %q", rest)
+	}
+}
+
+// defaultTransportBalancer oculta el EnterpriseBalancer para que el proxy use http.DefaultTransport
+type defaultTransportBalancer struct {
+	*infrastructure.EnterpriseBalancer
+}
+
+func BenchmarkProxyService_ServeHTTP_Transport(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		lb   func() domain.LoadBalancer
+	}{
+		{name: "default_transport", lb: func() domain.LoadBalancer {
+			return defaultTransportBalancer{infrastructure.NewEnterpriseBalancer()}
+		}},
+		{name: "server_transport", lb: func() domain.LoadBalancer {
+			return infrastructure.NewEnterpriseBalancer()
+		}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			var newConns int64
+			backendServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			backendServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					atomic.AddInt64(&newConns, 1)
+				}
+			}
+			backendServer.Start()
+			defer backendServer.Close()
+
+			service := NewProxyService(bm.lb(), &mockHealthChecker{})
+			service.UpdateConfig(&domain.Config{
+				Backends: []domain.Backend{
+					{
+						Name:    "bench-backend",
+						Servers: []domain.Server{{URL: backendServer.URL, Weight: 1, Active: true, MaxConnections: 10000}},
+					},
+				},
+			})
+
+			// Ráfagas de requests concurrentes: entre ráfagas las conexiones quedan
+			// inactivas y solo sobreviven las que admite MaxIdleConnsPerHost
+			const burst = 32
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for j := 0; j < burst; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						service.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+					}()
+				}
+				wg.Wait()
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(atomic.LoadInt64(&newConns))/float64(b.N), "conns/burst")
+			http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+		})
 	}
 }
 
@@ -142,22 +2371,57 @@ func TestProxyService_GetClientIP(t *testing.T) {
 	lb := infrastructure.NewEnterpriseBalancer()
 	hc := &mockHealthChecker{}
 	service := NewProxyService(lb, hc)
+	service.UpdateConfig(&domain.Config{
+		Proxy: domain.ProxyConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10"}},
+	})
 
 	tests := []struct {
-		name     string
-		headers  map[string]string
+		name       string
+		headers    map[string]string
 		remoteAddr string
-		expected string
+		expected   string
 	}{
 		{
-			name:     "X-Forwarded-For header",
-			headers:  map[string]string{"X-Forwarded-For": "192.168.1.1, 10.0.0.1"},
-			expected: "192.168.1.1",
+			name:       "X-Forwarded-For from trusted proxy",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			remoteAddr: "10.0.0.1:12345",
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For skips trusted hops right to left",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.0.0.2"},
+			remoteAddr: "10.0.0.1:12345",
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "All hops trusted",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			remoteAddr: "192.0.2.10:12345",
+			expected:   "10.0.0.3",
+		},
+		{
+			name:       "X-Real-IP from trusted proxy",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.8"},
+			remoteAddr: "10.0.0.1:12345",
+			expected:   "203.0.113.8",
 		},
 		{
-			name:     "X-Real-IP header",
-			headers:  map[string]string{"X-Real-IP": "192.168.1.2"},
-			expected: "192.168.1.2",
+			name:       "Spoofed X-Forwarded-For from untrusted client",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.5"},
+			remoteAddr: "198.51.100.9:12345",
+			expected:   "198.51.100.9",
+		},
+		{
+			name:       "Spoofed X-Real-IP from untrusted client",
+			headers:    map[string]string{"X-Real-IP": "203.0.113.8"},
+			remoteAddr: "198.51.100.9:12345",
+			expected:   "198.51.100.9",
+		},
+		{
+			name:       "Forged leftmost entry behind trusted proxy",
+			headers:    map[string]string{"X-Forwarded-For": "1.2.3.4, garbage, 198.51.100.9"},
+			remoteAddr: "10.0.0.1:12345",
+			expected:   "198.51.100.9",
 		},
 		{
 			name:       "RemoteAddr fallback",
@@ -169,11 +2433,11 @@ func TestProxyService_GetClientIP(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := httptest.NewRequest("GET", "/", nil)
-			
+
 			for key, value := range tt.headers {
 				req.Header.Set(key, value)
 			}
-			
+
 			if tt.remoteAddr != "" {
 				req.RemoteAddr = tt.remoteAddr
 			}
@@ -184,6 +2448,15 @@ func TestProxyService_GetClientIP(t *testing.T) {
 			}
 		})
 	}
+
+	// Sin trusted_proxies nunca se aceptan cabeceras reenviadas
+	untrusted := NewProxyService(lb, hc)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if ip := untrusted.getClientIP(req); ip != "10.0.0.1" {
+		t.Errorf("expected RemoteAddr without trusted proxies, got %s", ip)
+	}
 }
 
 func TestProxyService_ShouldRetry(t *testing.T) {
@@ -261,10 +2534,248 @@ func (m *mockHealthChecker) Start(backend *domain.Backend) error { return nil }
 func (m *mockHealthChecker) Stop() error { return nil }
 func (m *mockHealthChecker) IsHealthy(serverURL string) bool { return true }
 
+// staticLoadBalancer devuelve siempre el mismo servidor
+type staticLoadBalancer struct {
+	server   *domain.Server
+	failures int
+}
+
+func (s *staticLoadBalancer) SelectServer(backend *domain.Backend, clientIP string) *domain.Server {
+	return s.server
+}
+
+func (s *staticLoadBalancer) UpdateStats(server *domain.Server, responseTime time.Duration, success bool) {
+	if !success {
+		s.failures++
+	}
+}
+
+func (s *staticLoadBalancer) GetServerMetrics() map[string]*domain.Server { return nil }
+
 type mockError struct {
 	msg string
 }
 
 func (e *mockError) Error() string {
 	return e.msg
-}
\ No newline at end of file
+}
+func TestProxyService_ServeHTTP_LoadShedding(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	backend := domain.Backend{
+		Name:         "test-backend",
+		Servers:      []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+		LoadShedding: &domain.LoadSheddingCfg{ExcessFraction: 1, MaxPercent: 100, RetryAfter: 1500 * time.Millisecond},
+	}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	// Sin fracción fijada por el SmartTrigger no se descarta nada
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 without shedding, got %d", w.Code)
+	}
+
+	if previous := service.SetShedRate("test-backend", 1); previous != 0 {
+		t.Errorf("expected previous rate 0, got %v", previous)
+	}
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while shedding, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After 2, got %q", got)
+	}
+
+	metrics := service.GetMetrics()
+	if metrics.BackendShedRate["test-backend"] != 1 || metrics.BackendShed["test-backend"] != 1 {
+		t.Errorf("expected rate 1 and one shed request, got %v / %v", metrics.BackendShedRate, metrics.BackendShed)
+	}
+
+	// Quitar load_shedding de la configuración deja de descartar al momento
+	backend.LoadShedding = nil
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 once load_shedding is removed, got %d", w.Code)
+	}
+}
+
+func TestProxyService_ServeHTTP_DefaultBackend(t *testing.T) {
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("fallback"))
+	}))
+	defer fallback.Close()
+
+	balancer := infrastructure.NewEnterpriseBalancer()
+	service := NewProxyService(balancer, &mockHealthChecker{})
+
+	// Sin backends configurados responde el default_backend
+	service.UpdateConfig(&domain.Config{Proxy: domain.ProxyConfig{DefaultBackend: fallback.URL}})
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "fallback" {
+		t.Fatalf("expected fallback response without backends, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Backend sin servidores disponibles
+	backend := domain.Backend{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: "http://localhost:1", Weight: 1, Active: true}},
+		Retries: 1,
+	}
+	service.UpdateConfig(&domain.Config{Proxy: domain.ProxyConfig{DefaultBackend: fallback.URL}, Backends: []domain.Backend{backend}})
+	balancer.ReportHealth("http://localhost:1", false)
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "fallback" {
+		t.Fatalf("expected fallback response without active servers, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/broken", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected fallback 500 passed through, got %d", w.Code)
+	}
+
+	// Los contadores se conservan al recargar con el mismo upstream
+	metrics := service.GetMetrics()
+	if metrics.DefaultBackendRequests != 3 || metrics.DefaultBackendFailures != 1 {
+		t.Errorf("expected 3 requests and 1 failure, got %d / %d", metrics.DefaultBackendRequests, metrics.DefaultBackendFailures)
+	}
+	if _, ok := metrics.BackendInFlight[domain.DefaultBackendName]; !ok {
+		t.Errorf("expected %q backend in metrics, got %v", domain.DefaultBackendName, metrics.BackendInFlight)
+	}
+
+	// Sin default_backend se mantiene el 503
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without default_backend, got %d", w.Code)
+	}
+}
+
+func TestProxyService_ServeHTTP_StalledUpstreamBody(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Cabeceras y parte del cuerpo, y después el servidor se queda callado
+		w.Header().Set("Content-Length", "100")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	backend := domain.Backend{
+		Name:      "test-backend",
+		Servers:   []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+		Transport: domain.TransportCfg{BodyIdleTimeout: 100 * time.Millisecond},
+	}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	done := make(chan struct{})
+	w := httptest.NewRecorder()
+	go func() {
+		defer close(done)
+		service.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the stalled body copy to be aborted")
+	}
+
+	if got := w.Body.String(); got != "partial" {
+		t.Errorf("expected the partial body before the stall, got %q", got)
+	}
+	if stalled := service.GetMetrics().StalledResponses; stalled != 1 {
+		t.Errorf("expected 1 stalled response, got %d", stalled)
+	}
+	stats := service.GetServerStats()[upstream.URL]
+	if stats.FailedRequests != 1 {
+		t.Errorf("expected the stall counted as a failure, got %d failures", stats.FailedRequests)
+	}
+	if stats.CurrentConns != 0 {
+		t.Errorf("expected the connection slot released, got %d", stats.CurrentConns)
+	}
+}
+
+func TestProxyService_ServeHTTP_ResponseRewrite(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write([]byte("</body>"))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"url":"http://internal.local"}`))
+		case "/large":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(strings.Repeat("x", 64) + "</body>"))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`<a href="http://internal.local/x">x</a></body>`))
+		}
+	}))
+	defer upstream.Close()
+
+	backend := domain.Backend{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: upstream.URL, Weight: 1, Active: true}},
+		ResponseRewrite: &domain.ResponseRewriteCfg{
+			Rules: []domain.RewriteRule{
+				{Find: `http://internal\.local`, Replace: "https://example.com"},
+				{Find: `</body>`, Replace: `<div id="banner"></div></body>`},
+			},
+			ContentTypes: []string{"text/html"},
+			MaxBodyBytes: 64,
+		},
+	}
+	service := NewProxyService(infrastructure.NewEnterpriseBalancer(), &mockHealthChecker{})
+	service.UpdateConfig(&domain.Config{Backends: []domain.Backend{backend}})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", `<a href="https://example.com/x">x</a><div id="banner"></div></body>`},
+		{"/gzip", "</body>"},
+		{"/json", `{"url":"http://internal.local"}`},
+		{"/large", strings.Repeat("x", 64) + "</body>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			// Con Accept-Encoding explícito el transporte no descomprime la respuesta
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			service.ServeHTTP(w, req)
+
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("expected body %q, got %q", tt.want, got)
+			}
+			if tt.path == "/" {
+				if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(tt.want)) {
+					t.Errorf("expected Content-Length %d, got %q", len(tt.want), got)
+				}
+				if etag := w.Header().Get("ETag"); etag != "" {
+					t.Errorf("expected ETag removed from the rewritten body, got %q", etag)
+				}
+			}
+		})
+	}
+}
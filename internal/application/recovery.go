@@ -0,0 +1,29 @@
+package application
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// recoverPanic convierte un panic durante la request en un 500 y lo cuenta
+// como fallo. http.ErrAbortHandler se relanza: lo usa ReverseProxy para
+// cortar una respuesta ya empezada y net/http lo trata sin volcar el stack.
+func (p *ProxyServiceImpl) recoverPanic(w http.ResponseWriter, r *http.Request, config *domain.Config, start time.Time) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+		panic(recovered)
+	}
+
+	slog.Error("Panic serving request", "request_id", r.Header.Get(requestIDHeader), "method", r.Method, "path", r.URL.Path,
+		"panic", recovered, "stack", string(debug.Stack()))
+	p.updateGlobalMetrics(time.Since(start), false)
+	p.writeError(w, r, config, http.StatusInternalServerError, "Internal Server Error")
+}
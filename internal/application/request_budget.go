@@ -0,0 +1,26 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// withRequestBudget fija en el contexto de la request el plazo total del
+// backend, contado desde que empezó ServeHTTP. El intento inicial y todos los
+// reintentos comparten ese plazo en lugar de tener uno propio cada uno.
+func withRequestBudget(r *http.Request, backend *domain.Backend, start time.Time) (*http.Request, context.CancelFunc) {
+	if backend.RequestTimeout <= 0 {
+		return r, func() {}
+	}
+	ctx, cancel := context.WithDeadline(r.Context(), start.Add(backend.RequestTimeout))
+	return r.WithContext(ctx), cancel
+}
+
+// budgetExhausted indica que venció el plazo total de la request
+func budgetExhausted(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.DeadlineExceeded)
+}
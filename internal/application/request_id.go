@@ -0,0 +1,46 @@
+package application
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// Un ID más largo o con caracteres de control no se acepta del cliente:
+	// acaba en logs y cabeceras de respuesta
+	maxRequestIDLength = 128
+)
+
+// ensureRequestID conserva el X-Request-ID del cliente o genera uno nuevo y lo
+// deja en la request, de modo que el backend recibe el mismo ID
+func ensureRequestID(r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+		r.Header.Set(requestIDHeader, id)
+	}
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID genera un UUID v4
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,43 @@
+package application
+
+import (
+	"net/http"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// injectResponseHeaders añade las cabeceras de proxy.response_headers con los
+// cambios del backend. Una cabecera que ya envía el backend se respeta salvo
+// con override_response_headers.
+func injectResponseHeaders(resp *http.Response, proxy *domain.ProxyConfig, backend *domain.Backend) {
+	if len(proxy.ResponseHeaders) == 0 && len(backend.ResponseHeaders) == 0 {
+		return
+	}
+
+	headers := make(map[string]string, len(proxy.ResponseHeaders)+len(backend.ResponseHeaders))
+	for name, value := range proxy.ResponseHeaders {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	for name, value := range backend.ResponseHeaders {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+
+	for name, value := range headers {
+		if value == "" {
+			continue
+		}
+		if _, sent := resp.Header[name]; sent && !proxy.OverrideResponseHeaders {
+			continue
+		}
+		resp.Header.Set(name, value)
+	}
+}
+
+// injectUpstreamHeader añade proxy.upstream_header con la URL del servidor que
+// atendió la request; sustituye la que pudiera enviar el propio servidor
+func injectUpstreamHeader(resp *http.Response, proxy *domain.ProxyConfig, serverURL string) {
+	if proxy.UpstreamHeader == "" {
+		return
+	}
+	resp.Header.Set(proxy.UpstreamHeader, serverURL)
+}
@@ -0,0 +1,109 @@
+package application
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// responseRewriter es la configuración response_rewrite ya compilada
+type responseRewriter struct {
+	rules        []compiledRewriteRule
+	contentTypes map[string]bool
+	maxBodyBytes int64
+}
+
+type compiledRewriteRule struct {
+	find    *regexp.Regexp
+	replace []byte
+}
+
+// compileResponseRewrite compila las reglas del backend; igual que header_match,
+// una regla inválida se descarta con un log en vez de tumbar la recarga
+func compileResponseRewrite(cfg *domain.ResponseRewriteCfg) *responseRewriter {
+	if cfg == nil {
+		return nil
+	}
+	rewriter := &responseRewriter{
+		contentTypes: make(map[string]bool, len(cfg.ContentTypes)),
+		maxBodyBytes: cfg.MaxBodyBytes,
+	}
+	for _, rule := range cfg.Rules {
+		re, err := regexp.Compile(rule.Find)
+		if err != nil {
+			slog.Warn("response_rewrite rule ignored: invalid regex", "find", rule.Find, "error", err)
+			continue
+		}
+		rewriter.rules = append(rewriter.rules, compiledRewriteRule{find: re, replace: []byte(rule.Replace)})
+	}
+	for _, contentType := range cfg.ContentTypes {
+		rewriter.contentTypes[strings.ToLower(contentType)] = true
+	}
+	if len(rewriter.rules) == 0 {
+		return nil
+	}
+	return rewriter
+}
+
+// applies indica si la respuesta se puede reescribir: cuerpo sin comprimir,
+// de un tipo configurado y que no sea un stream
+func (rw *responseRewriter) applies(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	if resp.ContentLength > rw.maxBodyBytes {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return rw.contentTypes[mediaType]
+}
+
+// rewrite reemplaza el cuerpo de la respuesta aplicando las reglas en orden.
+// Si el cuerpo supera max_body_bytes se entrega intacto: lo ya leído se
+// antepone al resto sin reescribir nada.
+func (rw *responseRewriter) rewrite(resp *http.Response) error {
+	if !rw.applies(resp) {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, rw.maxBodyBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > rw.maxBodyBytes {
+		resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return nil
+	}
+	resp.Body.Close()
+
+	for _, rule := range rw.rules {
+		body = rule.find.ReplaceAll(body, rule.replace)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.Header.Del("Transfer-Encoding")
+	// El ETag del backend ya no describe el cuerpo que recibe el cliente
+	resp.Header.Del("ETag")
+	return nil
+}
+
+// prefixedBody devuelve lo ya leído seguido del resto del cuerpo original
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,106 @@
+package application
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// scheduleCheckInterval revisa triggers.schedule varias veces por minuto; cada
+// entrada se dispara una sola vez en su minuto
+const scheduleCheckInterval = 15 * time.Second
+
+func (h *HybridTriggerService) scheduleLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			h.runSchedule(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// runSchedule dispara las entradas cuyo HH:MM coincide con now
+func (h *HybridTriggerService) runSchedule(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	minute := now.Format("2006-01-02 15:04")
+	for i, entry := range h.config.Triggers.Schedule {
+		if !scheduleMatches(entry.Time, now) || h.scheduleFired[i] == minute {
+			continue
+		}
+		h.scheduleFired[i] = minute
+
+		if entry.Servers > 0 {
+			h.reconcileSchedule(entry, now)
+			continue
+		}
+		if action, exists := h.config.Actions[entry.Action]; exists {
+			slog.Info("Scheduled action", "time", entry.Time, "action", entry.Action)
+			if err := h.executor.Execute(entry.Action, action); err != nil {
+				slog.Error("Scheduled action failed", "time", entry.Time, "action", entry.Action, "error", err)
+			}
+		}
+	}
+}
+
+func scheduleMatches(scheduled string, now time.Time) bool {
+	at, err := time.Parse("15:04", scheduled)
+	return err == nil && at.Hour() == now.Hour() && at.Minute() == now.Minute()
+}
+
+// reconcileSchedule lleva el backend a los servidores de la entrada con sus
+// acciones de escalado, paso a paso como target_tracking. La acción reinicia el
+// cooldown del SmartTrigger, que no la deshace enseguida. Requiere h.mu tomado.
+func (h *HybridTriggerService) reconcileSchedule(entry domain.ScheduleTrigger, now time.Time) {
+	var backend *domain.Backend
+	for i := range h.config.Backends {
+		if h.config.Backends[i].Name == entry.Backend {
+			backend = &h.config.Backends[i]
+		}
+	}
+	trigger, ok := h.triggers[entry.Backend]
+	if backend == nil || !ok {
+		slog.Warn("Scheduled target for unknown backend", "time", entry.Time, "backend", entry.Backend)
+		return
+	}
+
+	minServers, maxServers := serverLimits(backend)
+	desired := entry.Servers
+	if desired < minServers {
+		desired = minServers
+	}
+	if desired > maxServers {
+		desired = maxServers
+	}
+	current := activeServerCount(trigger)
+
+	decision := &TriggerDecision{
+		Reason:         fmt.Sprintf("schedule %s: %d servers", entry.Time, entry.Servers),
+		Confidence:     1,
+		CanTrigger:     true,
+		Timestamp:      now,
+		CurrentServers: current,
+		DesiredServers: desired,
+	}
+	switch {
+	case desired > current:
+		decision.Action = "scale_up"
+	case desired < current:
+		decision.Action = "scale_down"
+	default:
+		slog.Info("Scheduled target already met", "time", entry.Time, "backend", backend.Name, "servers", current)
+		return
+	}
+
+	slog.Info("Scheduled target", "time", entry.Time, "backend", backend.Name,
+		"current_servers", current, "desired_servers", desired)
+	h.executeSmartAction(backend, trigger, decision)
+}
@@ -0,0 +1,43 @@
+package application
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// requestServerName devuelve el nombre que pidió el cliente: el SNI del
+// handshake TLS si lo hubo o, en claro o sin SNI, el Host sin puerto
+func requestServerName(r *http.Request) string {
+	if r.TLS != nil && r.TLS.ServerName != "" {
+		return strings.ToLower(r.TLS.ServerName)
+	}
+	return strings.ToLower(hostWithoutPort(r.Host))
+}
+
+// hostWithoutPort quita el puerto de un Host, también en IPv6 ([::1]:8080)
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// matchServerName compara en minúsculas; "*.example.com" cubre un único nivel
+// (a.example.com, no example.com ni a.b.example.com), como en los certificados
+func matchServerName(pattern, name string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		label, rest, found := strings.Cut(name, ".")
+		return found && label != "" && rest == suffix
+	}
+	return pattern == name
+}
+
+// misdirected indica que la request llegó por una conexión TLS negociada para
+// otro nombre: el Host no coincide con el SNI del handshake
+func misdirected(r *http.Request) bool {
+	if r.TLS == nil || r.TLS.ServerName == "" {
+		return false
+	}
+	return !strings.EqualFold(hostWithoutPort(r.Host), r.TLS.ServerName)
+}
@@ -0,0 +1,214 @@
+package application
+
+import (
+	"container/list"
+	"hash/fnv"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+const (
+	defaultSessionTTL           = domain.DefaultSessionTTL
+	defaultMaxSessions          = domain.DefaultMaxSessions
+	defaultSessionSweepInterval = time.Minute
+)
+
+// sessionEntry es una sesión sticky; la lista LRU la mantiene ordenada por
+// último acceso, con la más reciente al frente
+type sessionEntry struct {
+	id         string
+	serverURL  string
+	lastAccess time.Time
+}
+
+func sessionLimits(backend *domain.Backend) (time.Duration, int) {
+	ttl, max := defaultSessionTTL, defaultMaxSessions
+	if backend.SessionTTL > 0 {
+		ttl = backend.SessionTTL
+	}
+	if backend.MaxSessions > 0 {
+		max = backend.MaxSessions
+	}
+	return ttl, max
+}
+
+// sessionID devuelve el ID de sesión de la aplicación: la cookie, la cabecera
+// o el parámetro de query configurados en el backend, en ese orden
+func sessionID(r *http.Request, backend *domain.Backend) string {
+	cookieName, headerName := backend.AffinityCookie, backend.AffinityHeader
+	if cookieName == "" {
+		cookieName = domain.DefaultSessionCookie
+	}
+	if headerName == "" {
+		headerName = domain.DefaultSessionHeader
+	}
+
+	if cookie, err := r.Cookie(cookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if id := r.Header.Get(headerName); id != "" {
+		return id
+	}
+	if backend.AffinityQuery != "" {
+		return r.URL.Query().Get(backend.AffinityQuery)
+	}
+	return ""
+}
+
+// sessionBackup elige el respaldo de una sesión cuyo servidor está caído con
+// hashing de rendezvous sobre el ID de sesión: solo depende del ID y de las
+// URLs, así que todas las instancias del proxy eligen el mismo, y si cae otro
+// servidor solo se mueven las sesiones que estaban en él
+func sessionBackup(id, primaryURL string, backend *domain.Backend, route *headerRoute) *domain.Server {
+	var backup *domain.Server
+	var best uint64
+	for i := range backend.Servers {
+		server := &backend.Servers[i]
+		if server.URL == primaryURL || !server.Active || !server.Healthy {
+			continue
+		}
+		if route != nil && !route.allows(server) {
+			continue
+		}
+		if weight := rendezvousWeight(id, server.URL); backup == nil || weight > best {
+			backup, best = server, weight
+		}
+	}
+	return backup
+}
+
+// rendezvousWeight mezcla el FNV-1a de la sesión y la URL con el finalizador
+// de MurmurHash3 para que IDs parecidos no caigan siempre en el mismo servidor
+func rendezvousWeight(id, serverURL string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(serverURL))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// lookupSession devuelve el servidor de la sesión y renueva su último acceso;
+// una sesión caducada se elimina aunque el sweeper aún no haya pasado
+func (p *ProxyServiceImpl) lookupSession(sessionID string, ttl time.Duration) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	element, exists := p.sessions[sessionID]
+	if !exists {
+		return "", false
+	}
+	entry := element.Value.(*sessionEntry)
+	now := time.Now()
+	if now.Sub(entry.lastAccess) > ttl {
+		p.removeSessionLocked(element)
+		return "", false
+	}
+	entry.lastAccess = now
+	p.sessionLRU.MoveToFront(element)
+	return entry.serverURL, true
+}
+
+// storeSession guarda la sesión y, si la tabla supera max, descarta las menos usadas
+func (p *ProxyServiceImpl) storeSession(sessionID, serverURL string, max int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if element, exists := p.sessions[sessionID]; exists {
+		entry := element.Value.(*sessionEntry)
+		entry.serverURL = serverURL
+		entry.lastAccess = now
+		p.sessionLRU.MoveToFront(element)
+		return
+	}
+
+	p.sessions[sessionID] = p.sessionLRU.PushFront(&sessionEntry{id: sessionID, serverURL: serverURL, lastAccess: now})
+	for p.sessionLRU.Len() > max {
+		p.removeSessionLocked(p.sessionLRU.Back())
+	}
+}
+
+// sweepSessions elimina las sesiones inactivas más de ttl y devuelve cuántas
+func (p *ProxyServiceImpl) sweepSessions(now time.Time, ttl time.Duration) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	removed := 0
+	for element := p.sessionLRU.Back(); element != nil; element = p.sessionLRU.Back() {
+		if now.Sub(element.Value.(*sessionEntry).lastAccess) <= ttl {
+			break
+		}
+		p.removeSessionLocked(element)
+		removed++
+	}
+	return removed
+}
+
+func (p *ProxyServiceImpl) removeSessionLocked(element *list.Element) {
+	delete(p.sessions, element.Value.(*sessionEntry).id)
+	p.sessionLRU.Remove(element)
+}
+
+func (p *ProxyServiceImpl) sessionCount() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return int64(p.sessionLRU.Len())
+}
+
+// StartSessionSweeper limpia periódicamente las sesiones caducadas, para que
+// las que no vuelven a usarse no ocupen memoria indefinidamente
+func (p *ProxyServiceImpl) StartSessionSweeper(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSessionSweepInterval
+	}
+
+	p.mu.Lock()
+	if p.sweeperStop != nil {
+		p.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	p.sweeperStop = stop
+	p.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				p.mu.RLock()
+				config := p.config
+				p.mu.RUnlock()
+
+				ttl := defaultSessionTTL
+				if config != nil && len(config.Backends) > 0 {
+					ttl, _ = sessionLimits(&config.Backends[0])
+				}
+				p.sweepSessions(now, ttl)
+				atomic.StoreInt64(&p.metrics.ActiveSessions, p.sessionCount())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopSessionSweeper detiene el sweeper iniciado con StartSessionSweeper
+func (p *ProxyServiceImpl) StopSessionSweeper() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sweeperStop != nil {
+		close(p.sweeperStop)
+		p.sweeperStop = nil
+	}
+}
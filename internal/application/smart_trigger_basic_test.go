@@ -37,18 +37,529 @@ func TestSmartTriggerService_BasicMetrics(t *testing.T) {
 	}
 }
 
+func TestHybridTriggerService_RecordsTriggerMetrics(t *testing.T) {
+	smartTrigger := NewSmartTriggerService(&mockActionExecutor{}, &mockProxyService{})
+	hybrid := NewHybridTriggerService(smartTrigger, &mockActionExecutor{})
+	config := &domain.Config{
+		Backends: []domain.Backend{{Name: "web"}},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{EvaluationInterval: 5 * time.Second, ScaleUpScore: 0.45, ScaleDownScore: 0.15, StabilityThreshold: 0.4},
+		},
+	}
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+
+	if metrics := hybrid.GetTriggerMetrics(); len(metrics) != 0 {
+		t.Fatalf("expected no trigger metrics before the first evaluation, got %v", metrics)
+	}
+
+	hybrid.evaluateAndExecute()
+
+	metrics := hybrid.GetTriggerMetrics()
+	if len(metrics) != 1 || metrics[0].Backend != "web" {
+		t.Fatalf("expected trigger metrics for backend web, got %v", metrics)
+	}
+	if metrics[0].EvaluatedAt.IsZero() {
+		t.Error("expected evaluation timestamp to be set")
+	}
+	expectedCooldown := smartTrigger.lastTrigger.Add(smartTrigger.cooldownPeriod)
+	if !metrics[0].CooldownUntil.Equal(expectedCooldown) {
+		t.Errorf("expected cooldown until %v, got %v", expectedCooldown, metrics[0].CooldownUntil)
+	}
+}
+
+func TestHybridTriggerService_ReconfigureKeepsState(t *testing.T) {
+	smartTrigger := NewSmartTriggerService(&mockActionExecutor{}, &mockProxyService{})
+	hybrid := NewHybridTriggerService(smartTrigger, &mockActionExecutor{})
+	smart := domain.SmartTrigger{
+		EvaluationInterval: time.Hour, ShortWindow: 30 * time.Second, LongWindow: 5 * time.Minute,
+		Cooldown: 3 * time.Minute, ScaleUpScore: 0.75, ScaleDownScore: 0.25,
+	}
+	config := &domain.Config{Backends: []domain.Backend{{Name: "web"}}, Triggers: domain.TriggerConfig{Smart: smart}}
+	hybrid.Start(config, nil)
+	defer hybrid.Stop()
+
+	hybrid.evaluateAndExecute()
+	hybrid.evaluateAndExecute()
+	lastTrigger := time.Now().Add(-time.Minute)
+	smartTrigger.lastTrigger = lastTrigger
+	shortWindow, longWindow := smartTrigger.shortWindow, smartTrigger.longWindow
+
+	// Cambiar umbrales o el cooldown no toca el historial
+	smart.ScaleUpScore = 0.8
+	smart.Cooldown = 5 * time.Minute
+	hybrid.Reconfigure(&domain.Config{Backends: []domain.Backend{{Name: "web"}}, Triggers: domain.TriggerConfig{Smart: smart}})
+
+	if smartTrigger.shortWindow != shortWindow || smartTrigger.longWindow != longWindow {
+		t.Fatal("expected windows to survive a reload that keeps their size")
+	}
+	if smartTrigger.shortWindow.index != 2 {
+		t.Errorf("expected 2 samples kept in the short window, got %d", smartTrigger.shortWindow.index)
+	}
+	if !smartTrigger.lastTrigger.Equal(lastTrigger) {
+		t.Errorf("expected lastTrigger %v preserved, got %v", lastTrigger, smartTrigger.lastTrigger)
+	}
+	if smartTrigger.thresholds.ScaleUp != 0.8 || smartTrigger.cooldownPeriod != 5*time.Minute {
+		t.Errorf("expected new thresholds applied, got scale_up %v cooldown %v", smartTrigger.thresholds.ScaleUp, smartTrigger.cooldownPeriod)
+	}
+
+	// Otra duración de la ventana corta solo reinicia esa ventana
+	smart.ShortWindow = time.Minute
+	hybrid.Reconfigure(&domain.Config{Backends: []domain.Backend{{Name: "web"}}, Triggers: domain.TriggerConfig{Smart: smart}})
+	if smartTrigger.shortWindow == shortWindow || smartTrigger.shortWindow.index != 0 {
+		t.Error("expected the short window to reset when its size changes")
+	}
+	if smartTrigger.longWindow != longWindow {
+		t.Error("expected the long window to be kept")
+	}
+	if !smartTrigger.lastTrigger.Equal(lastTrigger) {
+		t.Errorf("expected lastTrigger preserved across window resize, got %v", smartTrigger.lastTrigger)
+	}
+}
+
+func TestHybridTriggerService_ScalesBackendsIndependently(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			// Backend A saturado: latencia, errores y conexiones altas
+			"http://a1:3001": {URL: "http://a1:3001", Active: true, Healthy: true, TotalRequests: 100, FailedRequests: 50,
+				CurrentConns: 1000, ResponseTime: 2 * time.Second},
+			// Backend B ocioso
+			"http://b1:3001": {URL: "http://b1:3001", Active: true, Healthy: true},
+			"http://b2:3001": {URL: "http://b2:3001", Active: true, Healthy: true},
+		},
+	}
+	executor := &mockActionExecutor{}
+	hybrid := NewHybridTriggerService(NewSmartTriggerService(executor, proxyService), executor)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:         "a",
+				Servers:      []domain.Server{{URL: "http://a1:3001"}},
+				SmartTrigger: &domain.BackendTrigger{ScaleUpScore: 0.5, HighAction: "scale_a_up"},
+			},
+			{
+				Name:    "b",
+				Servers: []domain.Server{{URL: "http://b1:3001"}, {URL: "http://b2:3001"}},
+			},
+		},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				EvaluationInterval:  5 * time.Second,
+				ShortWindow:         30 * time.Second,
+				LongWindow:          5 * time.Minute,
+				ScaleUpScore:        0.9,
+				ScaleDownScore:      0.15,
+				LongAvgScaleDownMax: 1,
+			},
+			Traffic: domain.TrafficTrigger{HighAction: "scale_up", LowAction: "scale_down"},
+		},
+		Actions: map[string]domain.ActionConfig{
+			"scale_a_up": {URL: "http://hooks/a/up"},
+			"scale_up":   {URL: "http://hooks/up"},
+			"scale_down": {URL: "http://hooks/down"},
+		},
+	}
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+
+	// La estabilidad necesita al menos dos muestras por ventana
+	hybrid.evaluateAndExecute()
+	hybrid.evaluateAndExecute()
+
+	if len(executor.executedActions) != 2 {
+		t.Fatalf("expected one action per backend, got %v", executor.executedActions)
+	}
+	executed := map[string]bool{}
+	for _, action := range executor.executedActions {
+		executed[action] = true
+	}
+	// Con el umbral global (0.9) A no escalaría: se aplica su override
+	if !executed["scale_a_up"] || !executed["scale_down"] {
+		t.Errorf("expected scale_a_up for A and scale_down for B, got %v", executor.executedActions)
+	}
+
+	metrics := hybrid.GetTriggerMetrics()
+	if len(metrics) != 2 || metrics[0].Backend != "a" || metrics[1].Backend != "b" {
+		t.Fatalf("expected metrics for both backends, got %v", metrics)
+	}
+	if metrics[0].TotalScore <= metrics[1].TotalScore {
+		t.Errorf("expected backend A to score higher than B, got %.3f <= %.3f", metrics[0].TotalScore, metrics[1].TotalScore)
+	}
+}
+
+func TestHybridTriggerService_DryRunSkipsExecution(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			"http://b1:3001": {URL: "http://b1:3001", Active: true, Healthy: true},
+			"http://b2:3001": {URL: "http://b2:3001", Active: true, Healthy: true},
+		},
+	}
+	executor := &mockActionExecutor{}
+	smartTrigger := NewSmartTriggerService(executor, proxyService)
+	hybrid := NewHybridTriggerService(smartTrigger, executor)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{Name: "b", Servers: []domain.Server{{URL: "http://b1:3001"}, {URL: "http://b2:3001"}}},
+		},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				EvaluationInterval:  5 * time.Second,
+				Cooldown:            time.Minute,
+				ShortWindow:         30 * time.Second,
+				LongWindow:          5 * time.Minute,
+				ScaleUpScore:        0.9,
+				ScaleDownScore:      0.15,
+				LongAvgScaleDownMax: 1,
+				DryRun:              true,
+			},
+			Traffic: domain.TrafficTrigger{HighAction: "scale_up", LowAction: "scale_down"},
+		},
+		Actions: map[string]domain.ActionConfig{
+			"scale_up":   {URL: "http://hooks/up"},
+			"scale_down": {URL: "http://hooks/down"},
+		},
+	}
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+	lastTrigger := smartTrigger.lastTrigger
+
+	hybrid.evaluateAndExecute()
+	hybrid.evaluateAndExecute()
+
+	if len(executor.executedActions) != 0 {
+		t.Fatalf("expected no executed actions in dry run, got %v", executor.executedActions)
+	}
+	if !smartTrigger.lastTrigger.Equal(lastTrigger) {
+		t.Error("expected lastTrigger to stay untouched in dry run")
+	}
+
+	history := hybrid.GetTriggerHistory()
+	if len(history) != 1 || !history[0].DryRun || history[0].ActionName != "scale_down" {
+		t.Fatalf("expected one simulated scale_down, got %+v", history)
+	}
+
+	// El cooldown simulado debe frenar la siguiente decisión
+	hybrid.evaluateAndExecute()
+	if len(hybrid.GetTriggerHistory()) != 1 {
+		t.Errorf("expected simulated cooldown to block the next action, got %+v", hybrid.GetTriggerHistory())
+	}
+}
+
+func TestHybridTriggerService_SimulatedMetricsNeverExecute(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			"http://b1:3001": {URL: "http://b1:3001", Active: true, Healthy: true},
+			"http://b2:3001": {URL: "http://b2:3001", Active: true, Healthy: true},
+		},
+	}
+	executor := &mockActionExecutor{}
+	smartTrigger := NewSmartTriggerService(executor, proxyService)
+	hybrid := NewHybridTriggerService(smartTrigger, executor)
+
+	// Sin dry_run: solo la simulación debe impedir que se ejecute la acción
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{Name: "b", Servers: []domain.Server{{URL: "http://b1:3001"}, {URL: "http://b2:3001"}}},
+		},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				EvaluationInterval:  5 * time.Second,
+				Cooldown:            time.Minute,
+				ShortWindow:         30 * time.Second,
+				LongWindow:          5 * time.Minute,
+				ScaleUpScore:        0.9,
+				ScaleDownScore:      0.15,
+				LongAvgScaleDownMax: 1,
+			},
+			Traffic: domain.TrafficTrigger{HighAction: "scale_up", LowAction: "scale_down"},
+		},
+		Actions: map[string]domain.ActionConfig{
+			"scale_up":   {Type: domain.ActionTypeExec, Command: "/usr/bin/scale-up"},
+			"scale_down": {Type: domain.ActionTypeExec, Command: "/usr/bin/scale-down"},
+		},
+		Debug: domain.DebugConfig{TriggerSimulation: true},
+	}
+	rps := 0.0
+	proxyService.SimulateMetrics(&domain.SimulatedMetrics{RPS: &rps, ExpiresAt: time.Now().Add(time.Minute)})
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+	lastTrigger := smartTrigger.lastTrigger
+
+	hybrid.evaluateAndExecute()
+	hybrid.evaluateAndExecute()
+
+	if len(executor.executedActions) != 0 {
+		t.Fatalf("expected no executed actions on simulated metrics, got %v", executor.executedActions)
+	}
+	if !smartTrigger.lastTrigger.Equal(lastTrigger) {
+		t.Error("expected lastTrigger to stay untouched on simulated metrics")
+	}
+	history := hybrid.GetTriggerHistory()
+	if len(history) != 1 || !history[0].DryRun || !history[0].Simulated || history[0].ActionName != "scale_down" {
+		t.Fatalf("expected one simulated dry-run scale_down, got %+v", history)
+	}
+
+	// El cooldown de la acción simulada frena la siguiente decisión
+	hybrid.evaluateAndExecute()
+	if len(hybrid.GetTriggerHistory()) != 1 {
+		t.Errorf("expected simulated cooldown to block the next action, got %+v", hybrid.GetTriggerHistory())
+	}
+}
+
+func TestHybridTriggerService_TargetTrackingIssuesDelta(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			"http://b1:3001": {URL: "http://b1:3001", Active: true, Healthy: true},
+			"http://b2:3001": {URL: "http://b2:3001", Active: true, Healthy: true},
+		},
+	}
+	executor := &mockActionExecutor{}
+	smartTrigger := NewSmartTriggerService(executor, proxyService)
+	hybrid := NewHybridTriggerService(smartTrigger, executor)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:       "b",
+				Servers:    []domain.Server{{URL: "http://b1:3001"}, {URL: "http://b2:3001"}},
+				MinServers: 2,
+				MaxServers: 4,
+			},
+		},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				EvaluationInterval: 5 * time.Second,
+				ShortWindow:        30 * time.Second,
+				LongWindow:         5 * time.Minute,
+				Mode:               domain.TriggerModeTargetTracking,
+				TargetRPSPerServer: 100,
+			},
+			Traffic: domain.TrafficTrigger{HighAction: "scale_up", LowAction: "scale_down"},
+		},
+		Actions: map[string]domain.ActionConfig{
+			"scale_up":   {URL: "http://hooks/up"},
+			"scale_down": {URL: "http://hooks/down"},
+		},
+	}
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+
+	// Primera muestra sin RPS: min_servers mantiene el backend en objetivo
+	hybrid.evaluateAndExecute()
+	if len(executor.executedActions) != 0 {
+		t.Fatalf("expected no action on target, got %v", executor.executedActions)
+	}
+
+	// ~450 RPS piden 5 servidores; max_servers lo limita a 4, es decir, dos pasos
+	proxyService.serverStats["http://b1:3001"].TotalRequests = 450
+	smartTrigger.lastRPSSample = time.Now().Add(-time.Second)
+	hybrid.evaluateAndExecute()
+
+	if len(executor.executedActions) != 2 || executor.executedActions[0] != "scale_up" {
+		t.Fatalf("expected two scale_up actions, got %v", executor.executedActions)
+	}
+	payload := executor.payloads[1]
+	if payload["desired_servers"] != 4 || payload["current_servers"] != 2 || payload["step"] != 2 {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+
+	history := hybrid.GetTriggerHistory()
+	if len(history) != 1 || history[0].DesiredServers != 4 || history[0].Steps != 2 {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
+
+func TestHybridTriggerService_SlowStartingServersDoNotOverProvision(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			"http://b1:3001": {URL: "http://b1:3001", Active: true, Healthy: true},
+			"http://b2:3001": {URL: "http://b2:3001", Active: true, Healthy: true},
+		},
+	}
+	executor := &mockActionExecutor{}
+	smartTrigger := NewSmartTriggerService(executor, proxyService)
+	hybrid := NewHybridTriggerService(smartTrigger, executor)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:       "b",
+				Servers:    []domain.Server{{URL: "http://b1:3001"}, {URL: "http://b2:3001"}},
+				MinServers: 2,
+				MaxServers: 5,
+			},
+		},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				EvaluationInterval:   5 * time.Second,
+				ShortWindow:          30 * time.Second,
+				LongWindow:           5 * time.Minute,
+				Mode:                 domain.TriggerModeTargetTracking,
+				TargetRPSPerServer:   100,
+				ScaleUpSettleTimeout: 10 * time.Minute,
+			},
+			Traffic: domain.TrafficTrigger{HighAction: "scale_up", LowAction: "scale_down"},
+		},
+		Actions: map[string]domain.ActionConfig{
+			"scale_up":   {URL: "http://hooks/up"},
+			"scale_down": {URL: "http://hooks/down"},
+		},
+	}
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+
+	// evaluate simula un segundo de tráfico a rps requests por segundo
+	evaluate := func(rps int64) {
+		proxyService.serverStats["http://b1:3001"].TotalRequests += rps
+		smartTrigger.lastRPSSample = time.Now().Add(-time.Second)
+		hybrid.evaluateAndExecute()
+	}
+	// addServer registra el servidor que aporta el webhook, aún sin health check
+	addServer := func(url string) {
+		config.Backends[0].Servers = append(config.Backends[0].Servers, domain.Server{URL: url})
+		proxyService.serverStats[url] = &domain.Server{URL: url, Active: true}
+	}
+
+	evaluate(300)
+	if len(executor.executedActions) != 1 {
+		t.Fatalf("expected one scale_up for 300 RPS on 2 servers, got %v", executor.executedActions)
+	}
+	addServer("http://b3:3001")
+
+	// El servidor nuevo sigue arrancando: no se dispara otro scale-up
+	for i := 0; i < 3; i++ {
+		evaluate(300)
+	}
+	if len(executor.executedActions) != 1 {
+		t.Fatalf("expected scale-up to wait for the new server, got %v", executor.executedActions)
+	}
+
+	// Cuando el servidor pasa su health check se puede volver a escalar
+	proxyService.serverStats["http://b3:3001"].Healthy = true
+	evaluate(400)
+	if len(executor.executedActions) != 2 {
+		t.Fatalf("expected a second scale_up once the new server is healthy, got %v", executor.executedActions)
+	}
+	addServer("http://b4:3001")
+
+	// Sin servidor sano nuevo, la espera termina con scale_up_settle_timeout
+	evaluate(500)
+	if len(executor.executedActions) != 2 {
+		t.Fatalf("expected scale-up to wait for b4, got %v", executor.executedActions)
+	}
+	smartTrigger.scaleUpPending = time.Now().Add(-11 * time.Minute)
+	evaluate(500)
+	if len(executor.executedActions) != 3 {
+		t.Fatalf("expected scale_up after the settle timeout, got %v", executor.executedActions)
+	}
+	addServer("http://b5:3001")
+
+	// Cinco servidores configurados alcanzan max_servers aunque solo tres estén sanos
+	smartTrigger.scaleUpPending = time.Now().Add(-11 * time.Minute)
+	evaluate(500)
+	if len(executor.executedActions) != 3 {
+		t.Errorf("expected max_servers to count servers still starting, got %v", executor.executedActions)
+	}
+}
+
+func TestHybridTriggerService_LoadSheddingBounded(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			"http://b1:3001": {URL: "http://b1:3001", Active: true, Healthy: true},
+			"http://b2:3001": {URL: "http://b2:3001", Active: true, Healthy: true},
+		},
+	}
+	executor := &mockActionExecutor{}
+	smartTrigger := NewSmartTriggerService(executor, proxyService)
+	hybrid := NewHybridTriggerService(smartTrigger, executor)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:         "b",
+				Servers:      []domain.Server{{URL: "http://b1:3001"}, {URL: "http://b2:3001"}},
+				MaxServers:   2,
+				LoadShedding: &domain.LoadSheddingCfg{ExcessFraction: 0.5, MaxPercent: 20},
+			},
+		},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				EvaluationInterval: 5 * time.Second,
+				ShortWindow:        30 * time.Second,
+				LongWindow:         5 * time.Minute,
+				Mode:               domain.TriggerModeTargetTracking,
+				TargetRPSPerServer: 100,
+			},
+		},
+	}
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+
+	hybrid.evaluateAndExecute()
+	if rate := proxyService.shedRates["b"]; rate != 0 {
+		t.Fatalf("expected no shedding without excess load, got %v", rate)
+	}
+
+	// 400 RPS sobre una capacidad de 200: la mitad del exceso sería 25%, max_percent lo deja en 20%
+	proxyService.serverStats["http://b1:3001"].TotalRequests = 400
+	smartTrigger.lastRPSSample = time.Now().Add(-time.Second)
+	hybrid.evaluateAndExecute()
+	if rate := proxyService.shedRates["b"]; rate != 0.2 {
+		t.Fatalf("expected shedding capped at 0.2, got %v", rate)
+	}
+
+	// La carga vuelve a la capacidad: se deja de descartar
+	smartTrigger.lastRPSSample = time.Now().Add(-time.Second)
+	hybrid.evaluateAndExecute()
+	if rate := proxyService.shedRates["b"]; rate != 0 {
+		t.Errorf("expected shedding to stop, got %v", rate)
+	}
+}
+
 // Mock implementations
 type mockActionExecutor struct {
 	executedActions []string
+	payloads        []map[string]interface{}
 }
 
 func (m *mockActionExecutor) Execute(actionName string, config domain.ActionConfig) error {
 	m.executedActions = append(m.executedActions, actionName)
+	m.payloads = append(m.payloads, config.Payload)
 	return nil
 }
 
 type mockProxyService struct {
-	metrics *domain.TrafficMetrics
+	metrics     *domain.TrafficMetrics
+	serverStats map[string]*domain.Server
+	shedRates   map[string]float64
+	queueStats  map[string]domain.QueueStats
+	simulation  *domain.SimulatedMetrics
+}
+
+func (m *mockProxyService) SimulateMetrics(simulation *domain.SimulatedMetrics) {
+	m.simulation = simulation
+}
+
+func (m *mockProxyService) SimulatedMetrics(now time.Time) *domain.SimulatedMetrics {
+	if m.simulation == nil || !now.Before(m.simulation.ExpiresAt) {
+		return nil
+	}
+	return m.simulation
+}
+
+func (m *mockProxyService) BackendQueueStats() map[string]domain.QueueStats {
+	return m.queueStats
+}
+
+func (m *mockProxyService) SetShedRate(backend string, rate float64) float64 {
+	if m.shedRates == nil {
+		m.shedRates = make(map[string]float64)
+	}
+	previous := m.shedRates[backend]
+	m.shedRates[backend] = rate
+	return previous
 }
 
 func (m *mockProxyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
@@ -60,5 +571,242 @@ func (m *mockProxyService) GetMetrics() *domain.TrafficMetrics {
 	return m.metrics
 }
 func (m *mockProxyService) GetServerStats() map[string]*domain.Server {
-	return make(map[string]*domain.Server)
-}
\ No newline at end of file
+	if m.serverStats == nil {
+		return make(map[string]*domain.Server)
+	}
+	return m.serverStats
+}
+func TestSmartTriggerService_CooldownBackoff(t *testing.T) {
+	trigger := NewSmartTriggerService(&mockActionExecutor{}, &mockProxyService{})
+	trigger.SetConfig(&domain.Config{
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				ScaleUpScore:    0.9,
+				ScaleDownScore:  0.15,
+				Cooldown:        time.Minute,
+				CooldownBackoff: 2,
+				MaxCooldown:     3 * time.Minute,
+			},
+		},
+	})
+	trigger.cooldownPeriod = time.Minute
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute}
+	for i, want := range expected {
+		trigger.recordAction("scale_up")
+		if got := trigger.effectiveCooldown(); got != want {
+			t.Errorf("repeat %d: expected cooldown %v, got %v", i+1, want, got)
+		}
+	}
+
+	// Otra acción reinicia la racha
+	trigger.recordAction("scale_down")
+	if got := trigger.effectiveCooldown(); got != time.Minute {
+		t.Errorf("expected base cooldown after a different action, got %v", got)
+	}
+
+	// Un score en la banda neutra también la reinicia
+	trigger.recordAction("scale_down")
+	trigger.EvaluateTrigger()
+	if trigger.repeatCount != 0 || trigger.effectiveCooldown() != time.Minute {
+		t.Errorf("expected backoff reset in neutral band, got repeatCount=%d cooldown=%v",
+			trigger.repeatCount, trigger.effectiveCooldown())
+	}
+}
+
+func TestHybridTriggerService_ScheduledTargetReconciles(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			"http://b1:3001": {URL: "http://b1:3001", Active: true, Healthy: true},
+			"http://b2:3001": {URL: "http://b2:3001", Active: true, Healthy: true},
+		},
+	}
+	executor := &mockActionExecutor{}
+	hybrid := NewHybridTriggerService(NewSmartTriggerService(executor, proxyService), executor)
+
+	config := &domain.Config{
+		Backends: []domain.Backend{
+			{
+				Name:       "b",
+				Servers:    []domain.Server{{URL: "http://b1:3001"}, {URL: "http://b2:3001"}},
+				MinServers: 1,
+				MaxServers: 5,
+			},
+		},
+		Triggers: domain.TriggerConfig{
+			Smart: domain.SmartTrigger{
+				EvaluationInterval: 5 * time.Second,
+				ShortWindow:        30 * time.Second,
+				LongWindow:         5 * time.Minute,
+			},
+			Traffic: domain.TrafficTrigger{HighAction: "scale_up", LowAction: "scale_down"},
+			Schedule: []domain.ScheduleTrigger{
+				{Time: "08:00", Backend: "b", Servers: 8},
+				{Time: "20:00", Backend: "b", Servers: 1},
+				{Time: "12:30", Action: "lunch"},
+			},
+		},
+		Actions: map[string]domain.ActionConfig{
+			"scale_up":   {URL: "http://hooks/up"},
+			"scale_down": {URL: "http://hooks/down"},
+			"lunch":      {URL: "http://hooks/lunch"},
+		},
+	}
+	hybrid.Start(config, nil)
+	hybrid.Stop()
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+
+	// 8 servidores pedidos, max_servers lo limita a 5: tres pasos desde 2
+	hybrid.runSchedule(day.Add(8 * time.Hour))
+	hybrid.runSchedule(day.Add(8*time.Hour + 30*time.Second))
+	if len(executor.executedActions) != 3 || executor.executedActions[0] != "scale_up" {
+		t.Fatalf("expected three scale_up actions once per minute, got %v", executor.executedActions)
+	}
+	if payload := executor.payloads[2]; payload["desired_servers"] != 5 || payload["step"] != 3 {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+
+	// Fuera de un límite de ventana no se hace nada
+	hybrid.runSchedule(day.Add(9 * time.Hour))
+	if len(executor.executedActions) != 3 {
+		t.Fatalf("expected no action between boundaries, got %v", executor.executedActions)
+	}
+
+	// Las entradas con action siguen disparando su acción
+	hybrid.runSchedule(day.Add(12*time.Hour + 30*time.Minute))
+	if len(executor.executedActions) != 4 || executor.executedActions[3] != "lunch" {
+		t.Fatalf("expected the scheduled lunch action, got %v", executor.executedActions)
+	}
+
+	// Los servidores del mock no cambian: a las 20:00 se baja de 2 a 1
+	hybrid.runSchedule(day.Add(20 * time.Hour))
+	if len(executor.executedActions) != 5 || executor.executedActions[4] != "scale_down" {
+		t.Fatalf("expected one scale_down action, got %v", executor.executedActions)
+	}
+
+	history := hybrid.GetTriggerHistory()
+	if len(history) != 2 || history[0].DesiredServers != 5 || history[1].DesiredServers != 1 {
+		t.Errorf("unexpected history: %+v", history)
+	}
+}
+
+func TestSmartTriggerService_QueueScore(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{"http://s1:3001": {URL: "http://s1:3001"}},
+		queueStats:  map[string]domain.QueueStats{"web": {}},
+	}
+	backend := &domain.Backend{
+		Name:    "web",
+		Servers: []domain.Server{{URL: "http://s1:3001"}},
+		Queue:   domain.QueueCfg{MaxDepth: 10, MaxWait: time.Second},
+	}
+	trigger := NewSmartTriggerService(&mockActionExecutor{}, proxyService)
+	trigger.SetBackend(backend)
+	trigger.weights = scoreWeights(domain.ScoreWeightsCfg{RPS: 0.5, Queue: 0.5})
+
+	if score := trigger.CalculateScore(); score.QueueScore != 0 {
+		t.Errorf("expected no queue pressure, got %v", score.QueueScore)
+	}
+
+	// Cola a medias
+	proxyService.queueStats["web"] = domain.QueueStats{Depth: 5}
+	if score := trigger.CalculateScore(); score.QueueScore != 0.5 {
+		t.Errorf("expected queue score 0.5 for a half-full queue, got %v", score.QueueScore)
+	}
+
+	// 10 rechazos frente a 90 requests servidas: 10% de rechazos satura el componente
+	proxyService.serverStats["http://s1:3001"].TotalRequests = 90
+	proxyService.queueStats["web"] = domain.QueueStats{Rejected: 10}
+	score := trigger.CalculateScore()
+	if score.QueueScore != 1.0 {
+		t.Errorf("expected queue score 1.0 with 10%% rejections, got %v", score.QueueScore)
+	}
+	if score.TotalScore < 0.5 {
+		t.Errorf("expected the queue weight reflected in the total score, got %v", score.TotalScore)
+	}
+
+	// Sin nuevos rechazos ni cola la presión desaparece
+	proxyService.serverStats["http://s1:3001"].TotalRequests = 200
+	if score := trigger.CalculateScore(); score.QueueScore != 0 {
+		t.Errorf("expected queue score back to 0, got %v", score.QueueScore)
+	}
+}
+
+func TestSmartTriggerService_SimulatedMetrics(t *testing.T) {
+	proxyService := &mockProxyService{
+		serverStats: map[string]*domain.Server{
+			"http://s1:3001": {URL: "http://s1:3001", TotalRequests: 100, ResponseTime: 20 * time.Millisecond},
+			"http://s2:3001": {URL: "http://s2:3001", TotalRequests: 100, ResponseTime: 20 * time.Millisecond},
+		},
+	}
+	backend := &domain.Backend{Name: "web", Servers: []domain.Server{{URL: "http://s1:3001"}, {URL: "http://s2:3001"}}}
+	config := &domain.Config{Backends: []domain.Backend{*backend}}
+	trigger := NewSmartTriggerService(&mockActionExecutor{}, proxyService)
+	trigger.SetConfig(config)
+	trigger.SetBackend(backend)
+
+	rps, errorRate, connections := 2000.0, 0.2, int64(1200)
+	latency := 2 * time.Second
+	proxyService.SimulateMetrics(&domain.SimulatedMetrics{
+		RPS: &rps, Latency: &latency, ErrorRate: &errorRate, Connections: &connections,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+
+	// Sin debug.trigger_simulation la simulación se ignora
+	if score := trigger.CalculateScore(); score.Simulated || score.LatencyScore != 0 {
+		t.Fatalf("expected measured metrics while simulation is disabled, got %+v", score)
+	}
+
+	config.Debug.TriggerSimulation = true
+	score := trigger.CalculateScore()
+	if !score.Simulated || score.RPS != rps {
+		t.Fatalf("expected simulated metrics, got %+v", score)
+	}
+	if score.RPSScore != 1.0 || score.LatencyScore != 1.0 || score.ErrorScore != 1.0 || score.ConnScore != 1.0 {
+		t.Errorf("expected saturated components from synthetic metrics, got %+v", score)
+	}
+
+	// Solo los campos enviados sustituyen a los medidos
+	proxyService.SimulateMetrics(&domain.SimulatedMetrics{Latency: &latency, ExpiresAt: time.Now().Add(time.Minute)})
+	if score := trigger.CalculateScore(); score.LatencyScore != 1.0 || score.ErrorScore != 0 || score.ConnScore != 0 {
+		t.Errorf("expected only latency simulated, got %+v", score)
+	}
+
+	// Una simulación de otro backend no le afecta
+	proxyService.SimulateMetrics(&domain.SimulatedMetrics{Backend: "api", Latency: &latency, ExpiresAt: time.Now().Add(time.Minute)})
+	if score := trigger.CalculateScore(); score.Simulated {
+		t.Error("expected simulation scoped to another backend to be ignored")
+	}
+
+	proxyService.SimulateMetrics(&domain.SimulatedMetrics{Latency: &latency, ExpiresAt: time.Now().Add(-time.Second)})
+	if score := trigger.CalculateScore(); score.Simulated {
+		t.Error("expected expired simulation to be ignored")
+	}
+}
+
+func TestHybridTriggerService_RecordsRecoveryTime(t *testing.T) {
+	hybrid := NewHybridTriggerService(NewSmartTriggerService(&mockActionExecutor{}, &mockProxyService{}), &mockActionExecutor{})
+	trigger := NewSmartTriggerService(&mockActionExecutor{}, &mockProxyService{})
+	start := time.Now()
+
+	// La repetición no reinicia el reloj: se mide desde la primera acción
+	startRecovery(trigger, "scale_up", start)
+	startRecovery(trigger, "scale_up", start.Add(time.Minute))
+	hybrid.observeRecovery("web", trigger, &TriggerDecision{Timestamp: start.Add(90 * time.Second)})
+	if len(hybrid.GetTriggerRecovery()) != 0 {
+		t.Fatal("expected no observation while the score is outside the neutral band")
+	}
+	hybrid.observeRecovery("web", trigger, &TriggerDecision{Timestamp: start.Add(2 * time.Minute), Neutral: true})
+
+	// Sin acción pendiente, volver a la banda neutra no registra nada
+	hybrid.observeRecovery("web", trigger, &TriggerDecision{Timestamp: start.Add(3 * time.Minute), Neutral: true})
+
+	recovery := hybrid.GetTriggerRecovery()
+	if len(recovery) != 1 || recovery[0].Backend != "web" || recovery[0].Action != "scale_up" {
+		t.Fatalf("expected one scale_up histogram for web, got %+v", recovery)
+	}
+	if recovery[0].Count != 1 || recovery[0].Sum != 2*time.Minute {
+		t.Errorf("expected a single 2m observation, got count=%d sum=%v", recovery[0].Count, recovery[0].Sum)
+	}
+}
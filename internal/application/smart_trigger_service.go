@@ -28,9 +28,37 @@ type SmartTriggerService struct {
 	lastAction     string
 	cooldownPeriod time.Duration
 
+	// Última acción simulada en dry_run o con métricas sintéticas: no toca
+	// lastTrigger pero mantiene el cooldown
+	simulatedTrigger time.Time
+	// La última evaluación usó métricas de debug.trigger_simulation
+	simulating bool
+
+	// Veces seguidas que se ha disparado lastAction sin volver a la banda neutra
+	repeatCount int
+
 	// Estado interno
 	lastScore      float64
 	lastEvaluation time.Time
+
+	// Backend evaluado; nil evalúa todos los servidores con las métricas globales
+	backend           *domain.Backend
+	lastTotalRequests int64
+	lastRPSSample     time.Time
+
+	// Última lectura de la cola, para calcular la tasa de rechazos
+	lastQueueRequests int64
+	lastRejected      int64
+
+	// Primera acción real de la racha en curso y cuándo se ejecutó; se mide
+	// hasta que el score vuelve a la banda neutra
+	recoveryAction string
+	recoveryStart  time.Time
+
+	// Último scale-up ejecutado que aún no aportó un servidor sano: cuándo se
+	// disparó y cuántos servidores sanos había entonces
+	scaleUpPending  time.Time
+	scaleUpBaseline int
 }
 
 // ScoreWeights - Pesos para el cálculo del score compuesto
@@ -39,6 +67,7 @@ type ScoreWeights struct {
 	Latency     float64 // Tiempo de respuesta promedio
 	ErrorRate   float64 // Tasa de errores
 	Connections float64 // Conexiones activas
+	Queue       float64 // Cola de max_concurrent_requests y rechazos
 }
 
 // ScoreThresholds - Umbrales para decisiones de escalado
@@ -54,6 +83,9 @@ type TriggerScore struct {
 	LatencyScore float64
 	ErrorScore   float64
 	ConnScore    float64
+	QueueScore   float64
+	RPS          float64 // RPS medido, usado por target_tracking
+	Simulated    bool    // métricas de debug.trigger_simulation
 	Timestamp    time.Time
 	ShouldScale  string // "up", "down", "none"
 	Confidence   float64
@@ -74,11 +106,30 @@ type TriggerDecision struct {
 	Action     string  // "scale_up", "scale_down", "none"
 	Score      float64 // Score actual
 	Trend      string  // "increasing", "stable", "decreasing"
+	Slope      float64 // Pendiente de la ventana corta
 	Confidence float64 // 0.0 - 1.0
 	Stability  float64 // Qué tan estable es la tendencia
 	Reason     string  // Razón de la decisión
 	CanTrigger bool    // Si puede disparar (cooldown)
 	Timestamp  time.Time
+	Components *TriggerScore // Score detallado usado en la decisión
+
+	// Solo en target_tracking: servidores sanos actuales y deseados
+	CurrentServers int
+	DesiredServers int
+
+	// Fracción (0-1) de la carga que excede la capacidad actual cuando la
+	// necesidad de escalar está confirmada, aunque el cooldown impida actuar
+	Excess float64
+
+	// El score está en la banda neutra (o, en target_tracking, en el objetivo)
+	Neutral bool
+}
+
+// simulated indica si la decisión se tomó con métricas sintéticas; las del
+// schedule no tienen score
+func (d *TriggerDecision) simulated() bool {
+	return d.Components != nil && d.Components.Simulated
 }
 
 func NewSmartTriggerService(executor domain.ActionExecutor, proxyService domain.ProxyService) *SmartTriggerService {
@@ -86,13 +137,8 @@ func NewSmartTriggerService(executor domain.ActionExecutor, proxyService domain.
 		executor:     executor,
 		proxyService: proxyService,
 
-		// Pesos balanceados basados en impacto en performance
-		weights: ScoreWeights{
-			RPS:         0.30, // 30% - Volumen de tráfico
-			Latency:     0.25, // 25% - Performance percibida
-			ErrorRate:   0.25, // 25% - Calidad del servicio
-			Connections: 0.20, // 20% - Saturación de recursos
-		},
+		// Pesos balanceados basados en impacto en performance; triggers.smart.weights los sobrescribe
+		weights: scoreWeights(domain.DefaultScoreWeights),
 
 		// Thresholds por defecto (serán configurados desde YAML)
 		thresholds: ScoreThresholds{
@@ -113,6 +159,96 @@ func (s *SmartTriggerService) SetConfig(config *domain.Config) {
 	s.config = config
 }
 
+// SetBackend limita el scoring a los servidores del backend y aplica sus overrides
+func (s *SmartTriggerService) SetBackend(backend *domain.Backend) {
+	s.backend = backend
+}
+
+// smartConfig devuelve triggers.smart con los overrides del backend aplicados
+func (s *SmartTriggerService) smartConfig() domain.SmartTrigger {
+	var global domain.SmartTrigger
+	if s.config != nil {
+		global = s.config.Triggers.Smart
+	}
+	if s.backend != nil {
+		return s.backend.EffectiveSmartTrigger(global)
+	}
+	return global
+}
+
+// scopedServerStats devuelve solo las estadísticas de los servidores del backend
+func (s *SmartTriggerService) scopedServerStats() map[string]*domain.Server {
+	serverStats := s.proxyService.GetServerStats()
+	if s.backend == nil {
+		return serverStats
+	}
+
+	scoped := make(map[string]*domain.Server, len(s.backend.Servers))
+	for _, server := range s.backend.Servers {
+		if stats, ok := serverStats[server.URL]; ok {
+			scoped[server.URL] = stats
+		}
+	}
+	return scoped
+}
+
+// backendRPS calcula las requests por segundo del backend a partir del
+// incremento de TotalRequests de sus servidores desde la última evaluación
+func (s *SmartTriggerService) backendRPS(totalRequests int64, now time.Time) float64 {
+	rps := 0.0
+	if !s.lastRPSSample.IsZero() && totalRequests >= s.lastTotalRequests {
+		if elapsed := now.Sub(s.lastRPSSample).Seconds(); elapsed > 0 {
+			rps = float64(totalRequests-s.lastTotalRequests) / elapsed
+		}
+	}
+	s.lastTotalRequests = totalRequests
+	s.lastRPSSample = now
+	return rps
+}
+
+// cooldownStart devuelve desde cuándo corre el cooldown; en dry_run o durante
+// una simulación cuentan también las acciones simuladas para reproducir la
+// cadencia real
+func (s *SmartTriggerService) cooldownStart() time.Time {
+	if (s.smartConfig().DryRun || s.simulating) && s.simulatedTrigger.After(s.lastTrigger) {
+		return s.simulatedTrigger
+	}
+	return s.lastTrigger
+}
+
+// defaultMaxCooldownFactor limita el backoff cuando no se configura max_cooldown
+const defaultMaxCooldownFactor = domain.DefaultMaxCooldownFactor
+
+// effectiveCooldown devuelve el cooldown actual aplicando el backoff
+// exponencial por acciones repetidas
+func (s *SmartTriggerService) effectiveCooldown() time.Duration {
+	smart := s.smartConfig()
+	if smart.CooldownBackoff <= 1 || s.repeatCount <= 1 {
+		return s.cooldownPeriod
+	}
+
+	maxCooldown := smart.MaxCooldown
+	if maxCooldown <= 0 {
+		maxCooldown = s.cooldownPeriod * defaultMaxCooldownFactor
+	}
+
+	cooldown := float64(s.cooldownPeriod) * math.Pow(smart.CooldownBackoff, float64(s.repeatCount-1))
+	if cooldown >= float64(maxCooldown) {
+		return maxCooldown
+	}
+	return time.Duration(cooldown)
+}
+
+// recordAction anota la acción disparada (real o simulada) para el backoff
+func (s *SmartTriggerService) recordAction(action string) {
+	if action == s.lastAction {
+		s.repeatCount++
+	} else {
+		s.repeatCount = 1
+	}
+	s.lastAction = action
+}
+
 // GetLastDecision - Obtiene la última decisión para debugging
 func (s *SmartTriggerService) GetLastDecision() *TriggerDecision {
 	return s.EvaluateTrigger()
@@ -120,8 +256,7 @@ func (s *SmartTriggerService) GetLastDecision() *TriggerDecision {
 
 // CalculateScore - Calcula el score compuesto basado en métricas actuales
 func (s *SmartTriggerService) CalculateScore() *TriggerScore {
-	metrics := s.proxyService.GetMetrics()
-	serverStats := s.proxyService.GetServerStats()
+	serverStats := s.scopedServerStats()
 
 	now := time.Now()
 
@@ -134,7 +269,8 @@ func (s *SmartTriggerService) CalculateScore() *TriggerScore {
 	for _, server := range serverStats {
 		totalRequests += server.TotalRequests
 		totalFailures += server.FailedRequests
-		totalConnections += server.CurrentConns
+		// Un WebSocket abierto es carga sostenida aunque su request ya terminó
+		totalConnections += server.CurrentConns + server.UpgradedConns
 		avgLatency += server.ResponseTime
 	}
 
@@ -142,17 +278,46 @@ func (s *SmartTriggerService) CalculateScore() *TriggerScore {
 		avgLatency = avgLatency / time.Duration(len(serverStats))
 	}
 
+	// Con varios backends cada uno mide su propio tráfico
+	var rps float64
+	if s.backend != nil {
+		rps = s.backendRPS(totalRequests, now)
+	} else {
+		rps = float64(s.proxyService.GetMetrics().RequestsPerSecond)
+	}
+
 	// Calcular scores individuales (0.0 - 1.0)
-	rpsScore := s.calculateRPSScore(float64(metrics.RequestsPerSecond))
-	latencyScore := s.calculateLatencyScore(avgLatency)
 	errorScore := s.calculateErrorScore(totalRequests, totalFailures)
+
+	// Las métricas sintéticas sustituyen a las medidas para afinar umbrales
+	simulation := s.simulatedMetrics(now)
+	s.simulating = simulation != nil
+	if simulation != nil {
+		if simulation.RPS != nil {
+			rps = *simulation.RPS
+		}
+		if simulation.Latency != nil {
+			avgLatency = *simulation.Latency
+		}
+		if simulation.ErrorRate != nil {
+			errorScore = errorRateScore(*simulation.ErrorRate)
+		}
+		if simulation.Connections != nil {
+			totalConnections = *simulation.Connections
+		}
+	}
+
+	rpsScore := s.calculateRPSScore(rps)
+	latencyScore := s.calculateLatencyScore(avgLatency)
 	connScore := s.calculateConnectionScore(totalConnections, len(serverStats))
+	queueScore := s.calculateQueueScore(totalRequests)
 
 	// Score compuesto ponderado
 	totalScore := (rpsScore * s.weights.RPS) +
 		(latencyScore * s.weights.Latency) +
 		(errorScore * s.weights.ErrorRate) +
-		(connScore * s.weights.Connections)
+		(connScore * s.weights.Connections) +
+		(queueScore * s.weights.Queue)
 
 	// Determinar acción de escalado
 	shouldScale := "none"
@@ -172,6 +337,9 @@ func (s *SmartTriggerService) CalculateScore() *TriggerScore {
 		LatencyScore: latencyScore,
 		ErrorScore:   errorScore,
 		ConnScore:    connScore,
+		QueueScore:   queueScore,
+		RPS:          rps,
+		Simulated:    simulation != nil,
 		Timestamp:    now,
 		ShouldScale:  shouldScale,
 		Confidence:   confidence,
@@ -229,14 +397,18 @@ func (s *SmartTriggerService) calculateLatencyScore(latency time.Duration) float
 	}
 }
 
-// calculateErrorScore - Score basado en tasa de errores (0.0 - 1.0)
+// calculateErrorScore - Score basado en tasa de errores (0.0 - 1.0). Los
+// fallos ya llegan ponderados por el error_classification del backend
 func (s *SmartTriggerService) calculateErrorScore(totalReqs, failedReqs int64) float64 {
 	if totalReqs == 0 {
 		return 0.0
 	}
 
-	errorRate := float64(failedReqs) / float64(totalReqs)
+	return errorRateScore(float64(failedReqs) / float64(totalReqs))
+}
 
+// errorRateScore convierte la fracción de requests fallidas en score
+func errorRateScore(errorRate float64) float64 {
 	// 0% errores = 0.0, 1% = 0.2, 5% = 0.6, 10%+ = 1.0
 	if errorRate <= 0.01 { // 1%
 		return errorRate * 20 // 0-0.2
@@ -249,6 +421,69 @@ func (s *SmartTriggerService) calculateErrorScore(totalReqs, failedReqs int64) f
 	return 0.6 + (errorRate-0.05)*8 // 0.6-1.0
 }
 
+// scoreWeights convierte triggers.smart.weights a los pesos del scorer
+func scoreWeights(w domain.ScoreWeightsCfg) ScoreWeights {
+	return ScoreWeights{
+		RPS:         w.RPS,
+		Latency:     w.Latency,
+		ErrorRate:   w.ErrorRate,
+		Connections: w.Connections,
+		Queue:       w.Queue,
+	}
+}
+
+// queueState suma la cola y los rechazos de los backends evaluados, junto con
+// la profundidad máxima configurada; ok es false si el proxy no los expone
+func (s *SmartTriggerService) queueState() (depth, maxDepth, rejected int64, ok bool) {
+	provider, isProvider := s.proxyService.(domain.QueueStatsProvider)
+	if !isProvider {
+		return 0, 0, 0, false
+	}
+	stats := provider.BackendQueueStats()
+
+	backends := []domain.Backend(nil)
+	if s.backend != nil {
+		backends = []domain.Backend{*s.backend}
+	} else if s.config != nil {
+		backends = s.config.Backends
+	}
+	for _, backend := range backends {
+		if backend.Queue.IsEnabled() {
+			maxDepth += int64(backend.Queue.MaxDepth)
+		}
+		depth += stats[backend.Name].Depth
+		rejected += stats[backend.Name].Rejected
+	}
+	return depth, maxDepth, rejected, true
+}
+
+// calculateQueueScore - Score basado en la cola de max_concurrent_requests (0.0 - 1.0)
+// La cola llena o los rechazos por saturación aparecen antes que la subida de
+// latencia, así que el score toma la mayor de las dos señales:
+// ocupación de la cola (0-1) y rechazos desde la última evaluación (5%+ = 1.0)
+func (s *SmartTriggerService) calculateQueueScore(totalRequests int64) float64 {
+	depth, maxDepth, rejected, ok := s.queueState()
+	if !ok {
+		return 0.0
+	}
+
+	rejectScore := 0.0
+	newRequests := totalRequests - s.lastQueueRequests
+	newRejected := rejected - s.lastRejected
+	// Tras un reset de métricas los contadores bajan: esa evaluación no cuenta
+	if newRequests >= 0 && newRejected > 0 {
+		rejectScore = math.Min(1.0, float64(newRejected)/float64(newRequests+newRejected)*20)
+	}
+	s.lastQueueRequests = totalRequests
+	s.lastRejected = rejected
+
+	depthScore := 0.0
+	if maxDepth > 0 {
+		depthScore = math.Min(1.0, float64(depth)/float64(maxDepth))
+	}
+	return math.Max(depthScore, rejectScore)
+}
+
 // calculateConnectionScore - Score basado en conexiones activas (0.0 - 1.0)
 // Basado en capacidades típicas de servidores Go HTTP
 func (s *SmartTriggerService) calculateConnectionScore(totalConns int64, serverCount int) float64 {
@@ -390,9 +625,11 @@ func (s *SmartTriggerService) EvaluateTrigger() *TriggerDecision {
 	trend, slope := s.shortWindow.GetTrend()
 	stability := s.shortWindow.GetStability()
 	
+	smart := s.smartConfig()
+
 	// Aplicar threshold configurable para tendencia
 	if s.config != nil {
-		threshold := s.config.Triggers.Smart.TrendThreshold
+		threshold := smart.TrendThreshold
 		if slope > threshold {
 			trend = "increasing"
 		} else if slope < -threshold {
@@ -402,47 +639,129 @@ func (s *SmartTriggerService) EvaluateTrigger() *TriggerDecision {
 		}
 	}
 
+	// Target tracking decide por servidores deseados en lugar de por umbrales de score
+	if smart.Mode == domain.TriggerModeTargetTracking {
+		return s.evaluateTargetTracking(currentScore, trend, slope, stability, now, smart)
+	}
+
+	// Solo considerar acción si hay suficiente estabilidad y está fuera de cooldown
+	shortAvg := s.shortWindow.GetAverage()
+	longAvg := s.longWindow.GetAverage()
+
+	// El score ha vuelto a la banda neutra: el backoff se reinicia
+	neutral := shortAvg > smart.ScaleDownScore && shortAvg < smart.ScaleUpScore
+	if neutral {
+		s.repeatCount = 0
+	}
+
 	// Verificar cooldown
-	canTrigger := now.Sub(s.lastTrigger) > s.cooldownPeriod
+	cooldownStart := s.cooldownStart()
+	cooldown := s.effectiveCooldown()
+	canTrigger := now.Sub(cooldownStart) > cooldown
 
 	// Lógica de decisión inteligente
 	decision := &TriggerDecision{
 		Action:     "none",
 		Score:      currentScore.TotalScore,
 		Trend:      trend,
+		Slope:      slope,
 		Confidence: 0.0,
 		Stability:  stability,
 		CanTrigger: canTrigger,
 		Timestamp:  now,
+		Components: currentScore,
+		Neutral:    neutral,
 	}
 
-	// Solo considerar acción si hay suficiente estabilidad y está fuera de cooldown
-	shortAvg := s.shortWindow.GetAverage()
-	longAvg := s.longWindow.GetAverage()
-	
 	// Usar thresholds de configuración YAML
-	scaleUpThreshold := s.config.Triggers.Smart.ScaleUpScore
-	scaleDownThreshold := s.config.Triggers.Smart.ScaleDownScore
+	scaleUpThreshold := smart.ScaleUpScore
+	scaleDownThreshold := smart.ScaleDownScore
 	
-	if stability > s.config.Triggers.Smart.StabilityThreshold && canTrigger {
+	// Sobrecarga confirmada: el score por encima del umbral se toma como exceso
+	if stability > smart.StabilityThreshold && shortAvg >= scaleUpThreshold && longAvg > smart.LongAvgScaleUpMin && shortAvg > 0 {
+		decision.Excess = (shortAvg - scaleUpThreshold) / shortAvg
+	}
+
+	if stability > smart.StabilityThreshold && canTrigger {
 		// Scale Up: Score alto Y tendencia creciente Y confirmación
-		if shortAvg >= scaleUpThreshold && longAvg > s.config.Triggers.Smart.LongAvgScaleUpMin {
+		if shortAvg >= scaleUpThreshold && longAvg > smart.LongAvgScaleUpMin {
 			decision.Action = "scale_up"
 			decision.Confidence = math.Min(1.0, (shortAvg-scaleUpThreshold)*2 + stability)
 			decision.Reason = fmt.Sprintf("High load: avg=%.2f, trend=%s, stability=%.2f", shortAvg, trend, stability)
 		}
 		// Scale Down: Score bajo Y confirmación sostenida
-		if shortAvg <= scaleDownThreshold && longAvg < s.config.Triggers.Smart.LongAvgScaleDownMax {
+		if shortAvg <= scaleDownThreshold && longAvg < smart.LongAvgScaleDownMax {
 			decision.Action = "scale_down"
 			decision.Confidence = math.Min(1.0, (scaleDownThreshold-shortAvg)*2 + stability)
 			decision.Reason = fmt.Sprintf("Low load: avg=%.2f, trend=%s, stability=%.2f", shortAvg, trend, stability)
 		}
 	} else {
 		if !canTrigger {
-			decision.Reason = fmt.Sprintf("Cooldown active (%.0fs remaining)", s.cooldownPeriod.Seconds()-now.Sub(s.lastTrigger).Seconds())
+			decision.Reason = fmt.Sprintf("Cooldown active (%.0fs remaining)", cooldown.Seconds()-now.Sub(cooldownStart).Seconds())
 		} else {
-			decision.Reason = fmt.Sprintf("Insufficient stability: %.2f < %.2f", stability, s.config.Triggers.Smart.StabilityThreshold)
+			decision.Reason = fmt.Sprintf("Insufficient stability: %.2f < %.2f", stability, smart.StabilityThreshold)
+		}
+	}
+
+	return decision
+}
+
+// evaluateTargetTracking calcula cuántos servidores sanos hacen falta para
+// mantener el RPS medio por servidor cerca de target_rps_per_server
+func (s *SmartTriggerService) evaluateTargetTracking(score *TriggerScore, trend string, slope, stability float64, now time.Time, smart domain.SmartTrigger) *TriggerDecision {
+	current := activeServerCount(s)
+	minServers, maxServers := serverLimits(s.backend)
+
+	desired := int(math.Ceil(score.RPS / smart.TargetRPSPerServer))
+	if desired < minServers {
+		desired = minServers
+	}
+	if desired > maxServers {
+		desired = maxServers
+	}
+
+	// En el objetivo: equivale a la banda neutra del modo score
+	if desired == current {
+		s.repeatCount = 0
+	}
+
+	cooldownStart := s.cooldownStart()
+	cooldown := s.effectiveCooldown()
+	canTrigger := now.Sub(cooldownStart) > cooldown
+
+	decision := &TriggerDecision{
+		Action:         "none",
+		Score:          score.TotalScore,
+		Trend:          trend,
+		Slope:          slope,
+		Stability:      stability,
+		CanTrigger:     canTrigger,
+		Timestamp:      now,
+		Components:     score,
+		CurrentServers: current,
+		DesiredServers: desired,
+		Neutral:        desired == current,
+	}
+
+	// Exceso respecto a lo que soportan los servidores actuales, también con max_servers alcanzado
+	if capacity := float64(current) * smart.TargetRPSPerServer; score.RPS > capacity {
+		decision.Excess = (score.RPS - capacity) / score.RPS
+	}
+
+	delta := desired - current
+	switch {
+	case delta == 0:
+		decision.Reason = fmt.Sprintf("On target: %d servers for %.1f RPS", current, score.RPS)
+	case !canTrigger:
+		decision.Reason = fmt.Sprintf("Cooldown active (%.0fs remaining)", cooldown.Seconds()-now.Sub(cooldownStart).Seconds())
+	default:
+		decision.Action = "scale_up"
+		if delta < 0 {
+			decision.Action = "scale_down"
 		}
+		decision.Confidence = math.Min(1.0, math.Abs(float64(delta))/float64(max(current, 1)))
+		decision.Reason = fmt.Sprintf("Target tracking: %.1f RPS at %.1f/server needs %d servers, have %d",
+			score.RPS, smart.TargetRPSPerServer, desired, current)
 	}
 
 	return decision
@@ -0,0 +1,20 @@
+package application
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// remapStatus sustituye el código de la respuesta según status_remap del
+// backend. Se aplica antes de evaluar el éxito, así que un 200 remapeado a 503
+// cuenta como fallo para el circuit breaker y un 418 remapeado a 200 como éxito.
+func remapStatus(resp *http.Response, backend *domain.Backend) {
+	to, ok := backend.StatusRemap[resp.StatusCode]
+	if !ok {
+		return
+	}
+	resp.StatusCode = to
+	resp.Status = fmt.Sprintf("%d %s", to, http.StatusText(to))
+}
@@ -0,0 +1,46 @@
+package application
+
+import (
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+	"github.com/juanbautista0/go-proxy/internal/infrastructure"
+)
+
+// flushIntervalFor devuelve el FlushInterval del backend: el configurado en
+// flush_interval o, para h2c y grpc, flush inmediato de cada frame gRPC
+func flushIntervalFor(backend *domain.Backend) time.Duration {
+	if backend.FlushInterval != 0 {
+		return time.Duration(backend.FlushInterval)
+	}
+	if backend.Protocol == infrastructure.ProtocolH2C || backend.Protocol == infrastructure.ProtocolGRPC {
+		return -1
+	}
+	return 0
+}
+
+// configureStreaming aplica el flush del backend y lo fuerza a inmediato en
+// respuestas SSE, para que un flush_interval pensado para descargas no
+// agrupe los eventos. Debe llamarse después de asignar ModifyResponse.
+func configureStreaming(proxy *httputil.ReverseProxy, backend *domain.Backend) {
+	proxy.FlushInterval = flushIntervalFor(backend)
+
+	modifyResponse := proxy.ModifyResponse
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if isEventStream(resp) {
+			proxy.FlushInterval = -1
+		}
+		if modifyResponse != nil {
+			return modifyResponse(resp)
+		}
+		return nil
+	}
+}
+
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
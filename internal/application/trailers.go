@@ -0,0 +1,46 @@
+package application
+
+import (
+	"io"
+	"net/http"
+	"net/http/httputil"
+)
+
+// trailerBody copia los trailers de la request entrante a la saliente al llegar
+// a EOF. El servidor solo rellena r.Trailer después de leer el body completo,
+// pero ReverseProxy clona el mapa antes, por lo que sin esto el backend recibe
+// las claves anunciadas con valores vacíos.
+type trailerBody struct {
+	io.ReadCloser
+	src http.Header
+	dst http.Header
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF && b.dst != nil {
+		for key, values := range b.src {
+			b.dst[key] = values
+		}
+	}
+	return n, err
+}
+
+// wrapRequestTrailers prepara el body para reenviar trailers si la request los anuncia
+func wrapRequestTrailers(r *http.Request) {
+	if len(r.Trailer) == 0 || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	r.Body = &trailerBody{ReadCloser: r.Body, src: r.Trailer}
+}
+
+// forwardRequestTrailers enlaza el body envuelto con el mapa de trailers de la request saliente
+func forwardRequestTrailers(proxy *httputil.ReverseProxy) {
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		if body, ok := req.Body.(*trailerBody); ok {
+			body.dst = req.Trailer
+		}
+	}
+}
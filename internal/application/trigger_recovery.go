@@ -0,0 +1,76 @@
+package application
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+	"github.com/juanbautista0/go-proxy/internal/infrastructure"
+)
+
+// recoveryKey identifica un histograma de recuperación
+type recoveryKey struct {
+	backend string
+	action  string
+}
+
+// startRecovery empieza a medir con la primera acción real de una racha. Las
+// repeticiones no reinician el reloj: lo que interesa es cuánto tarda la
+// capacidad añadida (o retirada) en notarse, no el tiempo desde la última.
+// Una acción en sentido contrario empieza una medición nueva.
+func startRecovery(trigger *SmartTriggerService, action string, at time.Time) {
+	if trigger.recoveryAction == action && !trigger.recoveryStart.IsZero() {
+		return
+	}
+	trigger.recoveryAction = action
+	trigger.recoveryStart = at
+}
+
+// observeRecovery cierra la medición en curso cuando la evaluación vuelve a la
+// banda neutra y la añade al histograma del backend y la acción
+func (h *HybridTriggerService) observeRecovery(backendName string, trigger *SmartTriggerService, decision *TriggerDecision) {
+	if !decision.Neutral || trigger.recoveryStart.IsZero() {
+		return
+	}
+	elapsed := decision.Timestamp.Sub(trigger.recoveryStart)
+	key := recoveryKey{backend: backendName, action: trigger.recoveryAction}
+	trigger.recoveryAction = ""
+	trigger.recoveryStart = time.Time{}
+
+	h.metricsMu.Lock()
+	histogram, exists := h.recovery[key]
+	if !exists {
+		histogram = infrastructure.NewLatencyHistogram(domain.DefaultRecoveryBuckets)
+		h.recovery[key] = histogram
+	}
+	histogram.Observe([]time.Duration{elapsed})
+	h.metricsMu.Unlock()
+
+	slog.Info("Smart trigger score back to neutral", "backend", backendName, "action", key.action, "after", elapsed)
+}
+
+// GetTriggerRecovery implementa domain.TriggerRecoveryProvider
+func (h *HybridTriggerService) GetTriggerRecovery() []domain.TriggerRecovery {
+	h.metricsMu.RLock()
+	defer h.metricsMu.RUnlock()
+
+	recovery := make([]domain.TriggerRecovery, 0, len(h.recovery))
+	for key, histogram := range h.recovery {
+		recovery = append(recovery, domain.TriggerRecovery{
+			Backend: key.backend,
+			Action:  key.action,
+			Buckets: histogram.Buckets,
+			Counts:  append([]int64(nil), histogram.Counts...),
+			Sum:     histogram.Sum,
+			Count:   histogram.Count,
+		})
+	}
+	sort.Slice(recovery, func(i, j int) bool {
+		if recovery[i].Backend != recovery[j].Backend {
+			return recovery[i].Backend < recovery[j].Backend
+		}
+		return recovery[i].Action < recovery[j].Action
+	})
+	return recovery
+}
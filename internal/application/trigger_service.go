@@ -2,6 +2,7 @@ package application
 
 import (
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
@@ -61,8 +62,8 @@ func (t *TriggerServiceImpl) monitorTraffic() {
 			if rps >= trigger.HighThreshold && t.currentState != "high" {
 				if now.Sub(t.lastHighTrigger) > t.cooldownPeriod {
 					if action, exists := t.config.Actions[trigger.HighAction]; exists {
-						fmt.Printf("🔥 HIGH TRAFFIC TRIGGER: %d RPS >= %d threshold, executing %s\n", 
-							rps, trigger.HighThreshold, trigger.HighAction)
+						slog.Info("High traffic trigger", "rps", rps,
+							"threshold", trigger.HighThreshold, "action", trigger.HighAction)
 						t.executor.Execute(trigger.HighAction, action)
 						t.lastHighTrigger = now
 						t.currentState = "high"
@@ -74,8 +75,8 @@ func (t *TriggerServiceImpl) monitorTraffic() {
 			if rps <= trigger.LowThreshold && t.currentState != "low" {
 				if now.Sub(t.lastLowTrigger) > t.cooldownPeriod {
 					if action, exists := t.config.Actions[trigger.LowAction]; exists {
-						fmt.Printf("📉 LOW TRAFFIC TRIGGER: %d RPS <= %d threshold, executing %s\n", 
-							rps, trigger.LowThreshold, trigger.LowAction)
+						slog.Info("Low traffic trigger", "rps", rps,
+							"threshold", trigger.LowThreshold, "action", trigger.LowAction)
 						t.executor.Execute(trigger.LowAction, action)
 						t.lastLowTrigger = now
 						t.currentState = "low"
@@ -86,8 +87,8 @@ func (t *TriggerServiceImpl) monitorTraffic() {
 			// Resetear estado si el tráfico vuelve a normal
 			if rps > trigger.LowThreshold && rps < trigger.HighThreshold {
 				if t.currentState != "normal" {
-					fmt.Printf("✅ TRAFFIC NORMALIZED: %d RPS (between %d and %d)\n", 
-						rps, trigger.LowThreshold, trigger.HighThreshold)
+					slog.Info("Traffic normalized", "rps", rps,
+						"low_threshold", trigger.LowThreshold, "high_threshold", trigger.HighThreshold)
 					t.currentState = "normal"
 				}
 			}
@@ -0,0 +1,33 @@
+package application
+
+import (
+	"io"
+	"sync"
+)
+
+// upgradedBody envuelve la conexión con el servidor tras un 101. ReverseProxy
+// la cierra cuando termina la copia en ambos sentidos, así que el cierre marca
+// el fin de la sesión WebSocket. Debe seguir siendo io.ReadWriteCloser para
+// que ReverseProxy pueda usarla.
+type upgradedBody struct {
+	io.ReadWriteCloser
+	closed func()
+	once   sync.Once
+}
+
+func (b *upgradedBody) Close() error {
+	err := b.ReadWriteCloser.Close()
+	b.once.Do(b.closed)
+	return err
+}
+
+// trackUpgradedBody devuelve el cuerpo que avisa al cerrarse; si no es una
+// conexión bidireccional se deja tal cual y ReverseProxy rechazará el upgrade
+func trackUpgradedBody(body io.ReadCloser, closed func()) io.ReadCloser {
+	conn, ok := body.(io.ReadWriteCloser)
+	if !ok {
+		closed()
+		return body
+	}
+	return &upgradedBody{ReadWriteCloser: conn, closed: closed}
+}
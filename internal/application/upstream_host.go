@@ -0,0 +1,30 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// applyUpstreamHost fija el Host que recibe el servidor según upstream_host.
+// El Director de NewSingleHostReverseProxy solo cambia la URL, así que sin
+// configurar se conserva el Host del cliente.
+func applyUpstreamHost(proxy *httputil.ReverseProxy, backend *domain.Backend, target *url.URL) {
+	var host string
+	switch backend.UpstreamHost {
+	case "", domain.UpstreamHostPreserve:
+		return
+	case domain.UpstreamHostRewrite:
+		host = target.Host
+	default:
+		host = backend.UpstreamHost
+	}
+
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = host
+	}
+}
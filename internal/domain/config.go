@@ -1,6 +1,17 @@
 package domain
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Proxy    ProxyConfig             `yaml:"proxy"`
@@ -8,40 +19,371 @@ type Config struct {
 	Triggers TriggerConfig           `yaml:"triggers"`
 	Actions  map[string]ActionConfig `yaml:"actions"`
 	Security SecurityConfig          `yaml:"security"`
+	Metrics  MetricsConfig           `yaml:"metrics,omitempty"`
+	CORS     CORSConfig              `yaml:"cors,omitempty"`
+	Logging  LoggingConfig           `yaml:"logging,omitempty"`
+	Debug    DebugConfig             `yaml:"debug,omitempty"`
+}
+
+// DebugConfig activa herramientas de diagnóstico; todas desactivadas por defecto
+type DebugConfig struct {
+	// Perfiles de net/http/pprof en /debug/pprof/ de la API de configuración,
+	// solo con admin key
+	PprofEnabled bool `yaml:"pprof_enabled,omitempty"`
+	// Métricas sintéticas para el SmartTrigger en /debug/trigger-simulation,
+	// solo con admin key; nunca en producción
+	TriggerSimulation bool `yaml:"trigger_simulation,omitempty"`
 }
 
 type ProxyConfig struct {
-	Port int `yaml:"port"`
+	Port                int                         `yaml:"port"`
+	ErrorResponses      map[int]ErrorResponseConfig `yaml:"error_responses,omitempty"`
+	MaxRequestBodyBytes int64                       `yaml:"max_request_body_bytes,omitempty"`
+	TrustedProxies      []string                    `yaml:"trusted_proxies,omitempty"` // CIDR o IP cuyos X-Forwarded-For se aceptan
+	// Cabeceras añadidas a todas las respuestas de los backends (p. ej. de seguridad);
+	// solo se añaden si el backend no las envía, salvo con override_response_headers
+	ResponseHeaders         map[string]string `yaml:"response_headers,omitempty"`
+	OverrideResponseHeaders bool              `yaml:"override_response_headers,omitempty"`
+	// Upstream de último recurso cuando no hay backends o el backend no tiene
+	// servidores disponibles; sus métricas se atribuyen al backend "default"
+	DefaultBackend string `yaml:"default_backend,omitempty"`
+	// Termina TLS en el puerto del proxy; nil sirve HTTP en claro (y h2c)
+	TLS *ProxyTLSCfg `yaml:"tls,omitempty"`
+	// Cabecera de respuesta con la URL del servidor que atendió la request,
+	// p. ej. X-Upstream-Server; vacía (por defecto) no expone nada
+	UpstreamHeader string `yaml:"upstream_header,omitempty"`
+	// Rechaza las requests nuevas con 503 + Retry-After mientras el propio
+	// proceso esté saturado; nil lo desactiva
+	LoadShedding *PressureSheddingCfg `yaml:"load_shedding,omitempty"`
+}
+
+// PressureSheddingCfg fija los umbrales de presión del proceso del proxy a
+// partir de los que se dejan de aceptar requests nuevas; 0 desactiva cada uno
+type PressureSheddingCfg struct {
+	MaxCPUPercent  float64       `yaml:"max_cpu_percent,omitempty"` // % de los núcleos de GOMAXPROCS, 0 < n <= 100
+	MaxGoroutines  int           `yaml:"max_goroutines,omitempty"`
+	MaxHeapBytes   int64         `yaml:"max_heap_bytes,omitempty"`
+	SampleInterval time.Duration `yaml:"sample_interval,omitempty"` // por defecto 1s
+	RetryAfter     time.Duration `yaml:"retry_after,omitempty"`     // por defecto 10s
+}
+
+func (l *PressureSheddingCfg) validate() error {
+	if l.MaxCPUPercent == 0 && l.MaxGoroutines == 0 && l.MaxHeapBytes == 0 {
+		return fmt.Errorf("proxy.load_shedding requires max_cpu_percent, max_goroutines or max_heap_bytes")
+	}
+	if l.MaxCPUPercent < 0 || l.MaxCPUPercent > 100 {
+		return fmt.Errorf("proxy.load_shedding max_cpu_percent must be in (0, 100], got %g", l.MaxCPUPercent)
+	}
+	if l.MaxGoroutines < 0 || l.MaxHeapBytes < 0 {
+		return fmt.Errorf("proxy.load_shedding max_goroutines and max_heap_bytes must not be negative")
+	}
+	if l.SampleInterval < 0 || l.RetryAfter < 0 {
+		return fmt.Errorf("proxy.load_shedding sample_interval and retry_after must not be negative")
+	}
+	return nil
+}
+
+// ProxyTLSCfg configura la terminación TLS del proxy. Con varios certificados
+// se presenta el que cubre el SNI del cliente; el primero es el de reserva.
+type ProxyTLSCfg struct {
+	Certificates []TLSCertificateCfg `yaml:"certificates"`
+	// Responde 421 si el Host de la request no coincide con el SNI del handshake
+	StrictSNI bool `yaml:"strict_sni,omitempty"`
+}
+
+type TLSCertificateCfg struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (t *ProxyTLSCfg) validate() error {
+	if len(t.Certificates) == 0 {
+		return fmt.Errorf("proxy.tls requires at least one certificate")
+	}
+	for i, cert := range t.Certificates {
+		if cert.CertFile == "" || cert.KeyFile == "" {
+			return fmt.Errorf("proxy.tls.certificates[%d] requires cert_file and key_file", i)
+		}
+	}
+	return nil
+}
+
+// DefaultBackendName identifica en las métricas las requests servidas por
+// proxy.default_backend
+const DefaultBackendName = "default"
+
+type ErrorResponseConfig struct {
+	Body        string `yaml:"body"`
+	ContentType string `yaml:"content_type,omitempty"`
 }
 
 type Backend struct {
-	Name            string            `yaml:"name"`
-	Servers         []Server          `yaml:"servers"`
-	HealthCheck     string            `yaml:"health_check"`
-	BalanceMode     string            `yaml:"balance_mode,omitempty"`
-	StickySessions  bool              `yaml:"sticky_sessions,omitempty"`
-	HealthInterval  time.Duration     `yaml:"health_interval,omitempty"`
-	Timeout         time.Duration     `yaml:"timeout,omitempty"`
-	Retries         int               `yaml:"retries,omitempty"`
-	CircuitBreaker  CircuitBreakerCfg `yaml:"circuit_breaker,omitempty"`
-	MinServers      int               `yaml:"min_servers,omitempty"`
-	MaxServers      int               `yaml:"max_servers,omitempty"`
+	Name                string            `yaml:"name"`
+	Servers             []Server          `yaml:"servers"`
+	HealthCheck         string            `yaml:"health_check"`
+	HealthMethod        string            `yaml:"health_method,omitempty"`  // GET por defecto; HEAD, POST u OPTIONS
+	HealthHeaders       map[string]string `yaml:"health_headers,omitempty"` // p. ej. Authorization para endpoints protegidos
+	BalanceMode         string            `yaml:"balance_mode,omitempty"`
+	AdaptiveBalancing   *bool             `yaml:"adaptive_balancing,omitempty"` // false fija el algoritmo; por defecto true
+	StickySessions      bool              `yaml:"sticky_sessions,omitempty"`
+	StickyCookie        StickyCookieCfg   `yaml:"sticky_cookie,omitempty"`
+	HealthInterval      time.Duration     `yaml:"health_interval,omitempty"`
+	HealthJitter        *float64          `yaml:"health_jitter,omitempty"` // fracción aleatoria del intervalo (0-0.5); por defecto 0.1
+	HealthyThreshold    int               `yaml:"healthy_threshold,omitempty"`
+	UnhealthyThreshold  int               `yaml:"unhealthy_threshold,omitempty"`
+	Timeout             time.Duration     `yaml:"timeout,omitempty"`
+	RequestTimeout      time.Duration     `yaml:"request_timeout,omitempty"` // plazo total de la request, reintentos incluidos
+	Retries             int               `yaml:"retries,omitempty"`
+	CircuitBreaker      CircuitBreakerCfg `yaml:"circuit_breaker,omitempty"`
+	MinServers          int               `yaml:"min_servers,omitempty"`
+	MaxServers          int               `yaml:"max_servers,omitempty"`
+	Maintenance         MaintenanceCfg    `yaml:"maintenance,omitempty"`
+	MaxRequestBodyBytes int64             `yaml:"max_request_body_bytes,omitempty"`
+	Transport           TransportCfg      `yaml:"transport,omitempty"`
+	Protocol            string            `yaml:"protocol,omitempty"` // "http1" (default), "h2c" o "grpc"
+	HeaderMatch         []HeaderMatchRule `yaml:"header_match,omitempty"`
+	SmartTrigger        *BackendTrigger   `yaml:"smart_trigger,omitempty"`
+	Queue               QueueCfg          `yaml:"queue,omitempty"`
+	FlushInterval       FlushInterval     `yaml:"flush_interval,omitempty"`
+	AllowedMethods      []string          `yaml:"allowed_methods,omitempty"`
+	DeniedMethods       []string          `yaml:"denied_methods,omitempty"`
+	Mirror              *MirrorCfg        `yaml:"mirror,omitempty"`
+	SessionTTL          time.Duration     `yaml:"session_ttl,omitempty"`  // inactividad tras la que se olvida una sesión (30m)
+	MaxSessions         int               `yaml:"max_sessions,omitempty"` // tope de la tabla de sesiones, LRU (100000)
+	StatusRemap         map[int]int       `yaml:"status_remap,omitempty"` // código del servidor -> código para el cliente
+	// Sobrescribe proxy.response_headers para este backend; un valor vacío quita la cabecera global
+	ResponseHeaders map[string]string `yaml:"response_headers,omitempty"`
+	// Tope de requests simultáneas al backend entre todos sus servidores (0 = sin límite)
+	MaxConcurrentRequests int `yaml:"max_concurrent_requests,omitempty"`
+	// Descarta parte del exceso de carga mientras el SmartTrigger confirma que
+	// hace falta escalar; nil lo desactiva
+	LoadShedding *LoadSheddingCfg `yaml:"load_shedding,omitempty"`
+	// Reescritura del cuerpo de las respuestas de texto; nil la desactiva
+	ResponseRewrite *ResponseRewriteCfg `yaml:"response_rewrite,omitempty"`
+	// Los servidores añadidos en caliente no reciben tráfico hasta su primer
+	// health check correcto pasado este tiempo desde que se añadieron
+	ServerWarmup time.Duration `yaml:"server_warmup,omitempty"`
+	// De dónde sale el ID de sesión de la aplicación para sticky_sessions, en
+	// este orden; por defecto la cookie JSESSIONID y la cabecera X-Session-ID
+	AffinityCookie string `yaml:"affinity_cookie,omitempty"`
+	AffinityHeader string `yaml:"affinity_header,omitempty"`
+	AffinityQuery  string `yaml:"affinity_query,omitempty"` // parámetro de la query; sin valor por defecto
+	// Con el servidor de una sesión caído se usa un respaldo fijo por sesión;
+	// true (por defecto) vuelve al original cuando se recupera, false fija la
+	// sesión en el respaldo
+	StickyFailback *bool `yaml:"sticky_failback,omitempty"`
+	// Cuánto cuenta cada código como fallo para la tasa de error, el circuit
+	// breaker y el SmartTrigger; nil mantiene solo los 5xx como fallo
+	ErrorClassification *ErrorClassificationCfg `yaml:"error_classification,omitempty"`
+	// Enrutado por un campo JSON del cuerpo; nil o sin enabled lo desactiva
+	BodyMatch *BodyMatchCfg `yaml:"body_match,omitempty"`
+	// Host enviado a los servidores: "preserve" (por defecto) mantiene el del
+	// cliente, "rewrite" usa el del servidor y cualquier otro valor se envía tal cual
+	UpstreamHost string `yaml:"upstream_host,omitempty"`
+	// Con "dns_srv" los servidores salen del registro SRV discovery_name, que
+	// se vuelve a resolver cada discovery_interval; servers solo se usa hasta
+	// la primera resolución correcta. Vacío mantiene la lista estática.
+	Discovery         string        `yaml:"discovery,omitempty"`
+	DiscoveryName     string        `yaml:"discovery_name,omitempty"`     // p. ej. _http._tcp.api.service.consul
+	DiscoveryScheme   string        `yaml:"discovery_scheme,omitempty"`   // http (por defecto) o https
+	DiscoveryInterval time.Duration `yaml:"discovery_interval,omitempty"` // por defecto 30s
+}
+
+// DiscoveryDNSSRV descubre los servidores de un backend en un registro DNS SRV
+const DiscoveryDNSSRV = "dns_srv"
+
+// Modos de upstream_host; cualquier otro valor es un Host literal
+const (
+	UpstreamHostPreserve = "preserve"
+	UpstreamHostRewrite  = "rewrite"
+)
+
+// BodyMatchCfg restringe la selección según un campo JSON del cuerpo de la
+// request, p. ej. event_type en un gateway de webhooks. Obliga a leer el cuerpo
+// antes de elegir servidor, así que hay que activarlo con enabled y solo se
+// inspeccionan cuerpos de hasta max_body_bytes; los mayores pasan sin regla.
+type BodyMatchCfg struct {
+	Enabled      bool            `yaml:"enabled"`
+	Rules        []BodyMatchRule `yaml:"rules"`
+	MaxBodyBytes int64           `yaml:"max_body_bytes,omitempty"` // por defecto 64 KiB
+}
+
+// BodyMatchRule restringe a Servers las requests cuyo campo Path (claves
+// separadas por puntos, p. ej. data.object.type; un número indexa un array)
+// vale Value o cumple Regex; sin ninguno basta con que exista
+type BodyMatchRule struct {
+	Path    string   `yaml:"path"`
+	Value   string   `yaml:"value,omitempty"`
+	Regex   string   `yaml:"regex,omitempty"`
+	Servers []string `yaml:"servers"`
+}
+
+func (b *BodyMatchCfg) validate() error {
+	if !b.Enabled {
+		return nil
+	}
+	if len(b.Rules) == 0 {
+		return fmt.Errorf("body_match requires at least one rule")
+	}
+	for _, rule := range b.Rules {
+		if rule.Path == "" || len(rule.Servers) == 0 {
+			return fmt.Errorf("body_match rule requires path and servers")
+		}
+		for _, key := range strings.Split(rule.Path, ".") {
+			if key == "" {
+				return fmt.Errorf("body_match path %q has an empty key", rule.Path)
+			}
+		}
+		if rule.Regex != "" {
+			if _, err := regexp.Compile(rule.Regex); err != nil {
+				return fmt.Errorf("body_match regex %q: %v", rule.Regex, err)
+			}
+		}
+	}
+	if b.MaxBodyBytes < 0 {
+		return fmt.Errorf("body_match.max_body_bytes must not be negative")
+	}
+	return nil
+}
+
+// ErrorClassificationCfg pondera las respuestas como fallo entre 0 (éxito) y 1
+// (fallo). Un peso fraccionario cuenta como fallo esa proporción de las
+// respuestas: con 0.25, uno de cada cuatro 404 de un servidor.
+type ErrorClassificationCfg struct {
+	ClientErrors float64         `yaml:"client_errors,omitempty"` // peso de los 4xx; por defecto 0
+	ServerErrors *float64        `yaml:"server_errors,omitempty"` // peso de los 5xx; por defecto 1
+	Codes        map[int]float64 `yaml:"codes,omitempty"`         // por código, prevalece sobre la clase
+}
+
+// ServerErrorWeight devuelve el peso de los 5xx (1 si no se indica)
+func (e *ErrorClassificationCfg) ServerErrorWeight() float64 {
+	if e.ServerErrors == nil {
+		return DefaultServerErrorWeight
+	}
+	return *e.ServerErrors
+}
+
+func (e *ErrorClassificationCfg) validate() error {
+	if e.ClientErrors < 0 || e.ClientErrors > 1 {
+		return fmt.Errorf("error_classification client_errors must be between 0 and 1, got %g", e.ClientErrors)
+	}
+	if w := e.ServerErrorWeight(); w < 0 || w > 1 {
+		return fmt.Errorf("error_classification server_errors must be between 0 and 1, got %g", w)
+	}
+	for code, weight := range e.Codes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("error_classification code %d must be between 100 and 599", code)
+		}
+		if weight < 0 || weight > 1 {
+			return fmt.Errorf("error_classification weight for %d must be between 0 and 1, got %g", code, weight)
+		}
+	}
+	return nil
+}
+
+// ResponseRewriteCfg aplica reemplazos con expresiones regulares al cuerpo de
+// las respuestas del backend, p. ej. para inyectar un banner antes de </body>
+// o reescribir URLs absolutas. Solo toca cuerpos sin comprimir de los tipos
+// indicados y hasta max_body_bytes; el resto pasa sin cambios.
+type ResponseRewriteCfg struct {
+	Rules        []RewriteRule `yaml:"rules"`
+	ContentTypes []string      `yaml:"content_types,omitempty"`  // por defecto text/html
+	MaxBodyBytes int64         `yaml:"max_body_bytes,omitempty"` // por defecto 1 MiB
+}
+
+// RewriteRule reemplaza cada coincidencia de find (RE2) por replace, que
+// admite $1 y ${nombre}
+type RewriteRule struct {
+	Find    string `yaml:"find"`
+	Replace string `yaml:"replace"`
+}
+
+func (r *ResponseRewriteCfg) validate() error {
+	if len(r.Rules) == 0 {
+		return fmt.Errorf("response_rewrite requires at least one rule")
+	}
+	for _, rule := range r.Rules {
+		if rule.Find == "" {
+			return fmt.Errorf("response_rewrite rule requires find")
+		}
+		if _, err := regexp.Compile(rule.Find); err != nil {
+			return fmt.Errorf("response_rewrite find %q: %v", rule.Find, err)
+		}
+	}
+	if r.MaxBodyBytes < 0 {
+		return fmt.Errorf("response_rewrite.max_body_bytes must not be negative")
+	}
+	return nil
 }
 
 type Server struct {
-	URL                 string        `yaml:"url"`
-	Weight              int           `yaml:"weight"`
-	Active              bool          `yaml:"active,omitempty"`
-	MaxConnections      int           `yaml:"max_connections,omitempty"`
-	HealthCheckEndpoint string        `yaml:"health_check_endpoint,omitempty"`
-	CurrentConns        int64         `yaml:"-"`
-	TotalRequests       int64         `yaml:"-"`
-	FailedRequests      int64         `yaml:"-"`
-	ResponseTime        time.Duration `yaml:"-"`
-	LastHealthCheck     time.Time     `yaml:"-"`
-	Healthy             bool          `yaml:"-"`
-	CircuitOpen         bool          `yaml:"-"`
-	CircuitOpenUntil    time.Time     `yaml:"-"`
+	URL                 string            `yaml:"url"`
+	Weight              int               `yaml:"weight"`
+	Active              bool              `yaml:"active,omitempty"`
+	MaxConnections      int               `yaml:"max_connections,omitempty"`
+	HealthCheckEndpoint string            `yaml:"health_check_endpoint,omitempty"`
+	HealthMethod        string            `yaml:"health_method,omitempty"`  // sobrescribe el del backend
+	HealthHeaders       map[string]string `yaml:"health_headers,omitempty"` // se combinan con las del backend
+	Priority            int               `yaml:"priority,omitempty"`       // 0 = primario; valores mayores son backups
+	Labels              map[string]string `yaml:"labels,omitempty"`         // p. ej. class: spot; se exportan en las métricas
+	CurrentConns        int64             `yaml:"-"`
+	TotalRequests       int64             `yaml:"-"`
+	FailedRequests      int64             `yaml:"-"`
+	ResponseTime        time.Duration     `yaml:"-"`
+	LastHealthCheck     time.Time         `yaml:"-"`
+	Healthy             bool              `yaml:"-"`
+	CircuitOpen         bool              `yaml:"-"`
+	CircuitOpenUntil    time.Time         `yaml:"-"`
+	CircuitOverride     bool              `yaml:"-"` // estado del circuito fijado por un administrador
+	OpenConns           int64             `yaml:"-"`
+	IdleConns           int64             `yaml:"-"`
+	NewConns            int64             `yaml:"-"`
+	ReusedConns         int64             `yaml:"-"`
+	EffectiveWeight     float64           `yaml:"-"`
+	// Latencias por PercentileLabel; solo los percentiles con muestras suficientes
+	LatencyPercentiles map[string]time.Duration `yaml:"-"`
+	// Conexiones actualizadas (101, p. ej. WebSocket) abiertas y cerradas; no
+	// cuentan en CurrentConns
+	UpgradedConns  int64 `yaml:"-"`
+	UpgradedClosed int64 `yaml:"-"`
+}
+
+// Las etiquetas se convierten en dimensiones de Prometheus: el nombre debe ser
+// válido allí y no pisar las que ya usa el proxy
+var (
+	serverLabelName      = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	reservedServerLabels = map[string]bool{"server": true, "percentile": true, "le": true}
+)
+
+func validateServerLabels(labels map[string]string) error {
+	for name := range labels {
+		if !serverLabelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("label %q must match [a-zA-Z_][a-zA-Z0-9_]* and not start with __", name)
+		}
+		if reservedServerLabels[name] {
+			return fmt.Errorf("label %q is reserved", name)
+		}
+	}
+	return nil
+}
+
+// ParseLabelSelector separa un filtro "clave:valor" (p. ej. class:spot)
+func ParseLabelSelector(selector string) (string, string, error) {
+	name, value, found := strings.Cut(selector, ":")
+	if !found || name == "" {
+		return "", "", fmt.Errorf("label selector %q must be name:value", selector)
+	}
+	return name, value, nil
+}
+
+// MatchesLabels indica si el servidor tiene todas las etiquetas del selector
+func (s *Server) MatchesLabels(selector map[string]string) bool {
+	for name, value := range selector {
+		if current, ok := s.Labels[name]; !ok || current != value {
+			return false
+		}
+	}
+	return true
 }
 
 type TriggerConfig struct {
@@ -62,6 +404,202 @@ type SmartTrigger struct {
 	LongAvgScaleUpMin   float64       `yaml:"long_avg_scale_up_min"`
 	LongAvgScaleDownMax float64       `yaml:"long_avg_scale_down_max"`
 	TrendThreshold      float64       `yaml:"trend_threshold"`
+	DryRun              bool          `yaml:"dry_run,omitempty"` // Registra las decisiones sin ejecutar acciones
+	// Backoff del cooldown: cada repetición de la misma acción sin que el score
+	// vuelva a la banda neutra multiplica el cooldown por este factor (>1 lo activa)
+	CooldownBackoff float64       `yaml:"cooldown_backoff,omitempty"`
+	MaxCooldown     time.Duration `yaml:"max_cooldown,omitempty"` // Tope del backoff; por defecto 10x cooldown
+	// Modo de decisión: "score" (por defecto) o "target_tracking"
+	Mode               string  `yaml:"mode,omitempty"`
+	TargetRPSPerServer float64 `yaml:"target_rps_per_server,omitempty"` // Objetivo de RPS por servidor sano en target_tracking
+	// Fracción aleatoria (0-0.5) del intervalo de evaluación; por defecto 0.1
+	Jitter *float64 `yaml:"jitter,omitempty"`
+	// Peso de cada componente del score; nil usa DefaultScoreWeights
+	Weights *ScoreWeightsCfg `yaml:"weights,omitempty"`
+	// Tras un scale-up no se dispara otro hasta que haya un servidor sano más;
+	// pasado este tiempo sin él se deja de esperar. Por defecto 10m
+	ScaleUpSettleTimeout time.Duration `yaml:"scale_up_settle_timeout,omitempty"`
+}
+
+// ScoreWeightsCfg reparte el score compuesto entre sus componentes; deben
+// sumar 1. Queue mide la cola de max_concurrent_requests y sus rechazos.
+type ScoreWeightsCfg struct {
+	RPS         float64 `yaml:"rps"`
+	Latency     float64 `yaml:"latency"`
+	ErrorRate   float64 `yaml:"error_rate"`
+	Connections float64 `yaml:"connections"`
+	Queue       float64 `yaml:"queue"`
+}
+
+// ScoreWeights devuelve weights o DefaultScoreWeights si no está definido
+func (s SmartTrigger) ScoreWeights() ScoreWeightsCfg {
+	if s.Weights == nil {
+		return DefaultScoreWeights
+	}
+	return *s.Weights
+}
+
+func (w ScoreWeightsCfg) validate() error {
+	for _, weight := range []float64{w.RPS, w.Latency, w.ErrorRate, w.Connections, w.Queue} {
+		if weight < 0 {
+			return fmt.Errorf("weights must not be negative")
+		}
+	}
+	sum := w.RPS + w.Latency + w.ErrorRate + w.Connections + w.Queue
+	if math.Abs(sum-1) > 0.001 {
+		return fmt.Errorf("weights must add up to 1, got %g", sum)
+	}
+	return nil
+}
+
+// JitterFraction devuelve jitter o DefaultTimerJitter si no está definido
+func (s SmartTrigger) JitterFraction() float64 {
+	if s.Jitter == nil {
+		return DefaultTimerJitter
+	}
+	return *s.Jitter
+}
+
+// Modos de decisión del SmartTrigger
+const (
+	TriggerModeScore          = "score"
+	TriggerModeTargetTracking = "target_tracking"
+)
+
+// BackendTrigger sobrescribe para un backend los valores de triggers.smart y
+// las acciones de escalado; los campos vacíos heredan la configuración global
+type BackendTrigger struct {
+	ShortWindow         time.Duration `yaml:"short_window,omitempty"`
+	LongWindow          time.Duration `yaml:"long_window,omitempty"`
+	Cooldown            time.Duration `yaml:"cooldown,omitempty"`
+	StabilityThreshold  float64       `yaml:"stability_threshold,omitempty"`
+	ScaleUpScore        float64       `yaml:"scale_up_score,omitempty"`
+	ScaleDownScore      float64       `yaml:"scale_down_score,omitempty"`
+	LongAvgScaleUpMin   float64       `yaml:"long_avg_scale_up_min,omitempty"`
+	LongAvgScaleDownMax float64       `yaml:"long_avg_scale_down_max,omitempty"`
+	TrendThreshold      float64       `yaml:"trend_threshold,omitempty"`
+	CooldownBackoff     float64       `yaml:"cooldown_backoff,omitempty"`
+	MaxCooldown         time.Duration `yaml:"max_cooldown,omitempty"`
+	Mode                string        `yaml:"mode,omitempty"`
+	TargetRPSPerServer  float64       `yaml:"target_rps_per_server,omitempty"`
+	HighAction          string        `yaml:"high_action,omitempty"`
+	LowAction           string        `yaml:"low_action,omitempty"`
+}
+
+// EffectiveSmartTrigger aplica los overrides del backend sobre la configuración global
+func (b *Backend) EffectiveSmartTrigger(global SmartTrigger) SmartTrigger {
+	smart := global
+	o := b.SmartTrigger
+	if o == nil {
+		return smart
+	}
+	if o.ShortWindow > 0 {
+		smart.ShortWindow = o.ShortWindow
+	}
+	if o.LongWindow > 0 {
+		smart.LongWindow = o.LongWindow
+	}
+	if o.Cooldown > 0 {
+		smart.Cooldown = o.Cooldown
+	}
+	if o.StabilityThreshold > 0 {
+		smart.StabilityThreshold = o.StabilityThreshold
+	}
+	if o.ScaleUpScore > 0 {
+		smart.ScaleUpScore = o.ScaleUpScore
+	}
+	if o.ScaleDownScore > 0 {
+		smart.ScaleDownScore = o.ScaleDownScore
+	}
+	if o.LongAvgScaleUpMin > 0 {
+		smart.LongAvgScaleUpMin = o.LongAvgScaleUpMin
+	}
+	if o.LongAvgScaleDownMax > 0 {
+		smart.LongAvgScaleDownMax = o.LongAvgScaleDownMax
+	}
+	if o.TrendThreshold > 0 {
+		smart.TrendThreshold = o.TrendThreshold
+	}
+	if o.CooldownBackoff > 0 {
+		smart.CooldownBackoff = o.CooldownBackoff
+	}
+	if o.MaxCooldown > 0 {
+		smart.MaxCooldown = o.MaxCooldown
+	}
+	if o.Mode != "" {
+		smart.Mode = o.Mode
+	}
+	if o.TargetRPSPerServer > 0 {
+		smart.TargetRPSPerServer = o.TargetRPSPerServer
+	}
+	return smart
+}
+
+// ScaleActions devuelve las acciones de escalado del backend o, si no tiene, las globales
+func (b *Backend) ScaleActions(global TrafficTrigger) (high, low string) {
+	high, low = global.HighAction, global.LowAction
+	if b.SmartTrigger != nil {
+		if b.SmartTrigger.HighAction != "" {
+			high = b.SmartTrigger.HighAction
+		}
+		if b.SmartTrigger.LowAction != "" {
+			low = b.SmartTrigger.LowAction
+		}
+	}
+	return high, low
+}
+
+// standardMethods son los métodos que anuncia Allow cuando solo hay denied_methods
+var standardMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// AdaptiveBalancingEnabled indica si el balanceador puede cambiar de algoritmo
+func (b *Backend) AdaptiveBalancingEnabled() bool {
+	return b.AdaptiveBalancing == nil || *b.AdaptiveBalancing
+}
+
+// StickyFailbackEnabled indica si una sesión vuelve a su servidor original
+// cuando este se recupera
+func (b *Backend) StickyFailbackEnabled() bool {
+	return b.StickyFailback == nil || *b.StickyFailback
+}
+
+// AllowsMethod aplica allowed_methods y denied_methods; una lista de permitidos
+// vacía acepta cualquier método y denied_methods tiene prioridad
+func (b *Backend) AllowsMethod(method string) bool {
+	if b.DeniesMethod(method) {
+		return false
+	}
+	return len(b.AllowedMethods) == 0 || containsMethod(b.AllowedMethods, method)
+}
+
+// DeniesMethod indica si el método está en denied_methods
+func (b *Backend) DeniesMethod(method string) bool {
+	return containsMethod(b.DeniedMethods, method)
+}
+
+// PermittedMethods devuelve los métodos aceptados, para el header Allow
+func (b *Backend) PermittedMethods() []string {
+	candidates := standardMethods
+	if len(b.AllowedMethods) > 0 {
+		candidates = b.AllowedMethods
+	}
+
+	var permitted []string
+	for _, method := range candidates {
+		if !b.DeniesMethod(method) {
+			permitted = append(permitted, strings.ToUpper(method))
+		}
+	}
+	return permitted
+}
+
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
 }
 
 type TrafficTrigger struct {
@@ -73,12 +611,96 @@ type TrafficTrigger struct {
 
 type ScheduleTrigger struct {
 	Time   string `yaml:"time"`
-	Action string `yaml:"action"`
+	Action string `yaml:"action,omitempty"`
+	// Objetivo en lugar de acción: desde time, el backend debe tener servers
+	// servidores sanos; se alcanza con sus acciones de escalado
+	Backend string `yaml:"backend,omitempty"`
+	Servers int    `yaml:"servers,omitempty"`
 }
 
+func (s ScheduleTrigger) validate(backends []Backend) error {
+	if _, err := time.Parse("15:04", s.Time); err != nil {
+		return fmt.Errorf("time %q must be HH:MM", s.Time)
+	}
+	switch {
+	case s.Servers < 0:
+		return fmt.Errorf("servers must not be negative")
+	case s.Servers > 0 && s.Action != "":
+		return fmt.Errorf("set either action or servers, not both")
+	case s.Servers == 0 && s.Action == "":
+		return fmt.Errorf("action or servers is required")
+	case s.Servers == 0:
+		return nil
+	}
+	for _, backend := range backends {
+		if backend.Name == s.Backend {
+			return nil
+		}
+	}
+	return fmt.Errorf("servers requires an existing backend, got %q", s.Backend)
+}
+
+// Tipos de acción; vacío equivale a http
+const (
+	ActionTypeHTTP       = "http"
+	ActionTypeExec       = "exec"
+	ActionTypeKubernetes = "kubernetes"
+)
+
 type ActionConfig struct {
+	Type   string `yaml:"type,omitempty"` // http (por defecto), exec o kubernetes
 	URL    string `yaml:"url"`
 	Method string `yaml:"method"`
+	// exec: comando a ejecutar, sin shell, con variables de entorno adicionales
+	Command string            `yaml:"command,omitempty"`
+	Args    []string          `yaml:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	Timeout time.Duration     `yaml:"timeout,omitempty"` // exec y kubernetes; por defecto 30s
+	// kubernetes: Deployment a escalar
+	Kubernetes *KubernetesActionCfg `yaml:"kubernetes,omitempty"`
+	// Payload lo rellena el trigger al disparar la acción; se envía como cuerpo JSON
+	Payload map[string]interface{} `yaml:"-" json:"-"`
+}
+
+// KubernetesActionCfg escala un Deployment por el subrecurso /scale. Sin
+// api_server se usa la cuenta de servicio del pod.
+type KubernetesActionCfg struct {
+	Namespace   string `yaml:"namespace,omitempty"` // por defecto "default"
+	Deployment  string `yaml:"deployment"`
+	ScaleBy     int    `yaml:"scale_by"` // réplicas a sumar por ejecución; negativo para reducir
+	MinReplicas int    `yaml:"min_replicas,omitempty"`
+	MaxReplicas int    `yaml:"max_replicas,omitempty"` // 0 = sin tope
+	APIServer   string `yaml:"api_server,omitempty"`
+	TokenFile   string `yaml:"token_file,omitempty"`
+	CAFile      string `yaml:"ca_file,omitempty"`
+}
+
+func (a ActionConfig) validate() error {
+	if a.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	switch a.Type {
+	case "", ActionTypeHTTP:
+	case ActionTypeExec:
+		if a.Command == "" {
+			return fmt.Errorf("exec action requires command")
+		}
+	case ActionTypeKubernetes:
+		k := a.Kubernetes
+		switch {
+		case k == nil || k.Deployment == "":
+			return fmt.Errorf("kubernetes action requires kubernetes.deployment")
+		case k.ScaleBy == 0:
+			return fmt.Errorf("kubernetes.scale_by must not be 0")
+		case k.MinReplicas < 0 || k.MaxReplicas < 0:
+			return fmt.Errorf("kubernetes.min_replicas and max_replicas must not be negative")
+		case k.MaxReplicas > 0 && k.MaxReplicas < k.MinReplicas:
+			return fmt.Errorf("kubernetes.max_replicas must be at least min_replicas")
+		}
+	default:
+		return fmt.Errorf("unknown type %q (http, exec or kubernetes)", a.Type)
+	}
+	return nil
 }
 
 type TrafficMetrics struct {
@@ -88,6 +710,145 @@ type TrafficMetrics struct {
 	AverageResponseTime time.Duration
 	ErrorRate           float64
 	LastUpdated         time.Time
+	// Tráfico replicado a mirror; no cuenta en las métricas del servidor primario
+	MirrorRequests int64
+	MirrorFailures int64
+	MirrorDropped  int64
+	ActiveSessions int64 // entradas en la tabla de sesiones sticky
+	// Requests en curso por backend, para vigilar max_concurrent_requests
+	BackendInFlight map[string]int64
+	// Descarte de carga por backend: fracción actual y requests descartadas
+	BackendShedRate map[string]float64
+	BackendShed     map[string]int64
+	// Requests servidas por proxy.default_backend y cuántas fallaron (5xx o error de red)
+	DefaultBackendRequests int64
+	DefaultBackendFailures int64
+	// Respuestas abortadas porque el servidor dejó de enviar el cuerpo (body_idle_timeout)
+	StalledResponses int64
+	// 503 por falta de servidor, según la causa (RejectCapacity o RejectUnhealthy)
+	CapacityRejections  int64
+	UnhealthyRejections int64
+	// Presión del proceso del proxy en el último muestreo de proxy.load_shedding
+	Pressure PressureStats
+}
+
+// PressureStats es el último muestreo de la presión del proceso y cuántas
+// requests se han rechazado por ella
+type PressureStats struct {
+	Enabled    bool
+	Shedding   bool
+	CPUPercent float64 // % de los núcleos de GOMAXPROCS desde el muestreo anterior
+	Goroutines int
+	HeapBytes  uint64
+	Shed       int64
+	SampledAt  time.Time
+}
+
+// LoggingConfig controla el nivel y formato de los logs y el access log
+type LoggingConfig struct {
+	Level     string `yaml:"level,omitempty"`  // debug, info (por defecto), warn o error
+	Format    string `yaml:"format,omitempty"` // text (por defecto) o json
+	AccessLog bool   `yaml:"access_log,omitempty"`
+	// Fracción de requests correctas que se registran (0-1, por defecto 1);
+	// las respuestas 5xx se registran siempre
+	SampleRate *float64 `yaml:"sample_rate,omitempty"`
+}
+
+// Niveles y formatos de logging.level y logging.format
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// HealthJitterFraction devuelve health_jitter o DefaultTimerJitter si no está definido
+func (b *Backend) HealthJitterFraction() float64 {
+	if b.HealthJitter == nil {
+		return DefaultTimerJitter
+	}
+	return *b.HealthJitter
+}
+
+// Jitter desplaza d al azar dentro de ±fraction para que los timers de muchos
+// servidores e instancias no se alineen en ráfagas
+func Jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + fraction*(2*rand.Float64()-1)))
+}
+
+// InitialJitter devuelve un retraso inicial aleatorio entre 0 y fraction*d
+func InitialJitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return 0
+	}
+	return time.Duration(float64(d) * fraction * rand.Float64())
+}
+
+// AccessLogSampleRate devuelve sample_rate o 1 si no está definido
+func (l LoggingConfig) AccessLogSampleRate() float64 {
+	if l.SampleRate == nil {
+		return 1
+	}
+	return *l.SampleRate
+}
+
+type MetricsConfig struct {
+	LatencyBuckets []time.Duration        `yaml:"latency_buckets,omitempty"`
+	Percentiles    []float64              `yaml:"percentiles,omitempty"` // p. ej. [50, 90, 99.9]; por defecto 95 y 99
+	Persistence    *MetricsPersistenceCfg `yaml:"persistence,omitempty"` // Opcional: conserva los contadores entre reinicios
+	// Muestras de latencia por servidor para los percentiles (8 bytes cada
+	// una); por defecto 1000
+	LatencySamples int `yaml:"latency_samples,omitempty"`
+}
+
+// LatencySampleSize devuelve el tamaño del buffer de latencias por servidor
+func (m MetricsConfig) LatencySampleSize() int {
+	if m.LatencySamples <= 0 {
+		return DefaultLatencySamples
+	}
+	return m.LatencySamples
+}
+
+// PercentileMinSamples devuelve cuántas muestras hacen falta para que al
+// menos una quede por encima del percentil p (100 para p99, 1000 para p99.9)
+func PercentileMinSamples(p float64) int {
+	// Absorber el error de coma flotante: 100/(100-99.9) da 1000.0000000000001
+	return int(math.Ceil(100/(100-p) - 1e-9))
+}
+
+// PercentileLabel nombra un percentil en las métricas: 99.9 -> "p99.9"
+func PercentileLabel(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// MetricsPersistenceCfg configura dónde se guardan los contadores agregados
+type MetricsPersistenceCfg struct {
+	Store    string        `yaml:"store"`              // "file" o "redis"
+	Path     string        `yaml:"path,omitempty"`     // file: ruta del JSON
+	Address  string        `yaml:"address,omitempty"`  // redis: host:puerto
+	Password string        `yaml:"password,omitempty"` // redis: AUTH opcional
+	Key      string        `yaml:"key,omitempty"`      // redis: por defecto go-proxy:metrics
+	Interval time.Duration `yaml:"interval,omitempty"` // Frecuencia del snapshot; por defecto 30s
+}
+
+// Stores de persistencia de métricas soportados
+const (
+	MetricsStoreFile  = "file"
+	MetricsStoreRedis = "redis"
+)
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins,omitempty"`
+	AllowedMethods   []string      `yaml:"allowed_methods,omitempty"`
+	AllowedHeaders   []string      `yaml:"allowed_headers,omitempty"`
+	AllowCredentials bool          `yaml:"allow_credentials,omitempty"`
+	MaxAge           time.Duration `yaml:"max_age,omitempty"`
 }
 
 type SecurityConfig struct {
@@ -95,10 +856,147 @@ type SecurityConfig struct {
 	AdminAPIKeys []string `yaml:"admin_api_keys"`
 }
 
+// StickyCookieCfg define la cookie de afinidad que emite el proxy cuando el
+// cliente no trae identificador de sesión propio
+type StickyCookieCfg struct {
+	Name     string        `yaml:"name,omitempty"`
+	TTL      time.Duration `yaml:"ttl,omitempty"`
+	Secure   bool          `yaml:"secure,omitempty"`
+	SameSite string        `yaml:"same_site,omitempty"` // lax (default), strict o none
+}
+
+// HeaderMatchRule restringe a Servers las requests cuya cabecera Header
+// coincide con Value (exacto) o Regex; sin ninguno basta con que esté presente
+type HeaderMatchRule struct {
+	Header  string   `yaml:"header"`
+	Value   string   `yaml:"value,omitempty"`
+	Regex   string   `yaml:"regex,omitempty"`
+	Servers []string `yaml:"servers"`
+	// Nombre pedido por el cliente: el SNI con TLS o, si no lo hay, el Host.
+	// Admite un comodín de un nivel (*.example.com); con header deben cumplirse ambos
+	ServerName string `yaml:"server_name,omitempty"`
+}
+
+type TransportCfg struct {
+	MaxIdleConns        int           `yaml:"max_idle_conns,omitempty"` // tamaño total del pool de inactivas
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host,omitempty"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout,omitempty"`
+	ForceAttemptHTTP2   bool          `yaml:"force_attempt_http2,omitempty"`
+	// Timeouts de conexión y de espera de cabeceras; 0 usa los de Go
+	DialTimeout           time.Duration `yaml:"dial_timeout,omitempty"`
+	TLSHandshakeTimeout   time.Duration `yaml:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout time.Duration `yaml:"response_header_timeout,omitempty"`
+	// Espera máxima entre lecturas del cuerpo de la respuesta; 0 la desactiva.
+	// No se aplica a SSE ni gRPC, cuyos streams pueden callar sin estar rotos.
+	BodyIdleTimeout time.Duration `yaml:"body_idle_timeout,omitempty"`
+	// Espera al 100 Continue del servidor antes de enviar el body de una
+	// request con Expect: 100-continue; 0 usa el de Go (1s), negativo no espera
+	ExpectContinueTimeout time.Duration `yaml:"expect_continue_timeout,omitempty"`
+}
+
 type CircuitBreakerCfg struct {
-	FailureThreshold int           `yaml:"failure_threshold,omitempty"`
-	RecoveryTimeout  time.Duration `yaml:"recovery_timeout,omitempty"`
-	Enabled          bool          `yaml:"enabled,omitempty"`
+	FailureThreshold  int           `yaml:"failure_threshold,omitempty"`
+	RecoveryTimeout   time.Duration `yaml:"recovery_timeout,omitempty"`
+	Enabled           bool          `yaml:"enabled,omitempty"`
+	LastResort        bool          `yaml:"last_resort,omitempty"`
+	HalfOpenSuccesses int           `yaml:"half_open_successes,omitempty"` // éxitos seguidos en half-open para cerrar (5)
+}
+
+// QueueCfg retiene las requests cuando todos los servidores están en su
+// límite de conexiones; sin max_depth y max_wait se responde 503 al momento
+type QueueCfg struct {
+	MaxDepth int           `yaml:"max_depth,omitempty"`
+	MaxWait  time.Duration `yaml:"max_wait,omitempty"`
+}
+
+func (q QueueCfg) IsEnabled() bool {
+	return q.MaxDepth > 0 && q.MaxWait > 0
+}
+
+// FlushInterval es el intervalo de flush de respuestas hacia el cliente.
+// Acepta una duración ("100ms") o -1 para flush inmediato tras cada escritura.
+type FlushInterval time.Duration
+
+func (f *FlushInterval) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var n int
+	if err := unmarshal(&n); err == nil {
+		if n != -1 && n != 0 {
+			return fmt.Errorf("flush_interval: use -1 or a duration such as \"100ms\", got %d", n)
+		}
+		*f = FlushInterval(n)
+		return nil
+	}
+
+	var d time.Duration
+	if err := unmarshal(&d); err != nil {
+		return err
+	}
+	*f = FlushInterval(d)
+	return nil
+}
+
+// MarshalYAML escribe el formato que acepta UnmarshalYAML
+func (f FlushInterval) MarshalYAML() (interface{}, error) {
+	if f < 0 {
+		return -1, nil
+	}
+	return time.Duration(f).String(), nil
+}
+
+// MirrorCfg replica una muestra de las requests a un servidor sombra cuyas
+// respuestas se descartan
+type MirrorCfg struct {
+	URL           string        `yaml:"url"`
+	SamplePercent float64       `yaml:"sample_percent"`           // 0 < n <= 100
+	Timeout       time.Duration `yaml:"timeout,omitempty"`        // por defecto 5s
+	MaxBodyBytes  int64         `yaml:"max_body_bytes,omitempty"` // bodies mayores no se replican; por defecto 1MB
+}
+
+func (m *MirrorCfg) validate() error {
+	if _, err := ParseServerURL(m.URL); err != nil {
+		return err
+	}
+	if m.SamplePercent <= 0 || m.SamplePercent > 100 {
+		return fmt.Errorf("mirror sample_percent must be in (0, 100], got %g", m.SamplePercent)
+	}
+	return nil
+}
+
+// LoadSheddingCfg acota el tráfico que se rechaza con 503 + Retry-After
+// mientras llega la capacidad pedida por el SmartTrigger
+type LoadSheddingCfg struct {
+	ExcessFraction float64       `yaml:"excess_fraction"`       // parte del exceso de carga que se descarta, 0 < n <= 1
+	MaxPercent     float64       `yaml:"max_percent"`           // tope del tráfico total descartado, 0 < n <= 100
+	RetryAfter     time.Duration `yaml:"retry_after,omitempty"` // por defecto 10s
+}
+
+func (l *LoadSheddingCfg) validate() error {
+	if l.ExcessFraction <= 0 || l.ExcessFraction > 1 {
+		return fmt.Errorf("load_shedding excess_fraction must be in (0, 1], got %g", l.ExcessFraction)
+	}
+	if l.MaxPercent <= 0 || l.MaxPercent > 100 {
+		return fmt.Errorf("load_shedding max_percent must be in (0, 100], got %g", l.MaxPercent)
+	}
+	if l.RetryAfter < 0 {
+		return fmt.Errorf("load_shedding retry_after must not be negative")
+	}
+	return nil
+}
+
+// ShedRate devuelve la fracción de requests a descartar cuando excess (0-1)
+// de la carga supera la capacidad actual, sin pasar nunca de max_percent
+func (l *LoadSheddingCfg) ShedRate(excess float64) float64 {
+	if excess <= 0 {
+		return 0
+	}
+	return math.Min(math.Min(excess, 1)*l.ExcessFraction, l.MaxPercent/100)
+}
+
+type MaintenanceCfg struct {
+	Enabled     bool   `yaml:"enabled,omitempty"`
+	StatusCode  int    `yaml:"status_code,omitempty"`
+	Body        string `yaml:"body,omitempty"`
+	ContentType string `yaml:"content_type,omitempty"`
 }
 
 type BalanceMode string
@@ -109,4 +1007,320 @@ const (
 	Weighted      BalanceMode = "weighted"
 	IPHash        BalanceMode = "iphash"
 	LeastResponse BalanceMode = "leastresponse"
-)
\ No newline at end of file
+)
+
+// ErrInvalidConfig envuelve los errores de Validate
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Validate comprueba que la configuración se pueda usar para enrutar tráfico
+func (c *Config) Validate() error {
+	if _, err := ParseTrustedProxies(c.Proxy.TrustedProxies); err != nil {
+		return fmt.Errorf("%w: proxy.trusted_proxies: %v", ErrInvalidConfig, err)
+	}
+	if c.Proxy.TLS != nil {
+		if err := c.Proxy.TLS.validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+	if c.Proxy.UpstreamHeader != "" && !validHeaderName(c.Proxy.UpstreamHeader) {
+		return fmt.Errorf("%w: proxy.upstream_header: invalid header name %q", ErrInvalidConfig, c.Proxy.UpstreamHeader)
+	}
+	if c.Proxy.LoadShedding != nil {
+		if err := c.Proxy.LoadShedding.validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+	if c.Proxy.DefaultBackend != "" {
+		if _, err := ParseServerURL(c.Proxy.DefaultBackend); err != nil {
+			return fmt.Errorf("%w: proxy.default_backend: %v", ErrInvalidConfig, err)
+		}
+	}
+	for _, backend := range c.Backends {
+		if c.Proxy.DefaultBackend != "" && backend.Name == DefaultBackendName {
+			return fmt.Errorf("%w: backend name %q is reserved for proxy.default_backend", ErrInvalidConfig, DefaultBackendName)
+		}
+		if !validJitter(backend.HealthJitterFraction()) {
+			return fmt.Errorf("%w: backend %q: health_jitter must be between 0 and %g", ErrInvalidConfig, backend.Name, MaxTimerJitter)
+		}
+		if backend.ServerWarmup < 0 {
+			return fmt.Errorf("%w: backend %q: server_warmup must not be negative", ErrInvalidConfig, backend.Name)
+		}
+		if backend.CircuitBreaker.HalfOpenSuccesses < 0 {
+			return fmt.Errorf("%w: backend %q: circuit_breaker.half_open_successes must not be negative", ErrInvalidConfig, backend.Name)
+		}
+		if !validHealthMethod(backend.HealthMethod) {
+			return fmt.Errorf("%w: backend %q: health_method must be GET, HEAD, POST or OPTIONS", ErrInvalidConfig, backend.Name)
+		}
+		for _, server := range backend.Servers {
+			if _, err := ParseBackendServerURL(server.URL); err != nil {
+				return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+			}
+			if !validHealthMethod(server.HealthMethod) {
+				return fmt.Errorf("%w: backend %q: server %s: health_method must be GET, HEAD, POST or OPTIONS", ErrInvalidConfig, backend.Name, server.URL)
+			}
+			if err := validateServerLabels(server.Labels); err != nil {
+				return fmt.Errorf("%w: backend %q: server %s: %v", ErrInvalidConfig, backend.Name, server.URL, err)
+			}
+		}
+		if backend.Mirror != nil {
+			if err := backend.Mirror.validate(); err != nil {
+				return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+			}
+		}
+		if backend.LoadShedding != nil {
+			if err := backend.LoadShedding.validate(); err != nil {
+				return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+			}
+		}
+		if backend.ResponseRewrite != nil {
+			if err := backend.ResponseRewrite.validate(); err != nil {
+				return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+			}
+		}
+		if backend.ErrorClassification != nil {
+			if err := backend.ErrorClassification.validate(); err != nil {
+				return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+			}
+		}
+		if backend.BodyMatch != nil {
+			if err := backend.BodyMatch.validate(); err != nil {
+				return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+			}
+		}
+		if err := backend.validateDiscovery(); err != nil {
+			return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+		}
+		if !validUpstreamHost(backend.UpstreamHost) {
+			return fmt.Errorf("%w: backend %q: upstream_host must be preserve, rewrite or a host[:port], got %q", ErrInvalidConfig, backend.Name, backend.UpstreamHost)
+		}
+		// 0 usa el 503 por defecto; otro código inválido haría entrar en pánico a WriteHeader
+		if code := backend.Maintenance.StatusCode; code != 0 && !finalStatus(code) {
+			return fmt.Errorf("%w: backend %q: maintenance.status_code %d must be between 200 and 599", ErrInvalidConfig, backend.Name, code)
+		}
+		for from, to := range backend.StatusRemap {
+			if !finalStatus(from) || !finalStatus(to) {
+				return fmt.Errorf("%w: backend %q: status_remap %d -> %d: codes must be between 200 and 599", ErrInvalidConfig, backend.Name, from, to)
+			}
+		}
+		if c.Triggers.Smart.Enabled {
+			if err := backend.EffectiveSmartTrigger(c.Triggers.Smart).validateMode(); err != nil {
+				return fmt.Errorf("%w: backend %q: %v", ErrInvalidConfig, backend.Name, err)
+			}
+		}
+	}
+	for i, entry := range c.Triggers.Schedule {
+		if err := entry.validate(c.Backends); err != nil {
+			return fmt.Errorf("%w: triggers.schedule[%d]: %v", ErrInvalidConfig, i, err)
+		}
+	}
+	if !validJitter(c.Triggers.Smart.JitterFraction()) {
+		return fmt.Errorf("%w: triggers.smart.jitter must be between 0 and %g", ErrInvalidConfig, MaxTimerJitter)
+	}
+	if err := c.Triggers.Smart.ScoreWeights().validate(); err != nil {
+		return fmt.Errorf("%w: triggers.smart: %v", ErrInvalidConfig, err)
+	}
+	for name, action := range c.Actions {
+		if err := action.validate(); err != nil {
+			return fmt.Errorf("%w: action %q: %v", ErrInvalidConfig, name, err)
+		}
+	}
+	switch c.Logging.Level {
+	case "", LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		return fmt.Errorf("%w: logging.level must be debug, info, warn or error", ErrInvalidConfig)
+	}
+	switch c.Logging.Format {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("%w: logging.format must be text or json", ErrInvalidConfig)
+	}
+	if rate := c.Logging.AccessLogSampleRate(); rate < 0 || rate > 1 {
+		return fmt.Errorf("%w: logging.sample_rate must be between 0 and 1", ErrInvalidConfig)
+	}
+	for _, p := range c.Metrics.Percentiles {
+		if p <= 0 || p >= 100 {
+			return fmt.Errorf("%w: metrics.percentiles must be between 0 and 100 (exclusive), got %g", ErrInvalidConfig, p)
+		}
+	}
+	if c.Metrics.LatencySamples < 0 {
+		return fmt.Errorf("%w: metrics.latency_samples must not be negative", ErrInvalidConfig)
+	}
+	percentiles := c.Metrics.Percentiles
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+	// Con menos muestras el percentil no se publicaría nunca
+	for _, p := range percentiles {
+		if need := PercentileMinSamples(p); c.Metrics.LatencySampleSize() < need {
+			return fmt.Errorf("%w: metrics.latency_samples: %s needs at least %d samples, got %d", ErrInvalidConfig, PercentileLabel(p), need, c.Metrics.LatencySampleSize())
+		}
+	}
+	if p := c.Metrics.Persistence; p != nil {
+		switch {
+		case p.Store == MetricsStoreFile && p.Path == "":
+			return fmt.Errorf("%w: metrics.persistence: file store requires path", ErrInvalidConfig)
+		case p.Store == MetricsStoreRedis && p.Address == "":
+			return fmt.Errorf("%w: metrics.persistence: redis store requires address", ErrInvalidConfig)
+		case p.Store != MetricsStoreFile && p.Store != MetricsStoreRedis:
+			return fmt.Errorf("%w: metrics.persistence: unknown store %q", ErrInvalidConfig, p.Store)
+		}
+	}
+	return nil
+}
+
+func validJitter(fraction float64) bool {
+	return fraction >= 0 && fraction <= MaxTimerJitter
+}
+
+// validHealthMethod acepta los métodos sin efectos que tiene sentido usar en un
+// health check; vacío equivale a GET
+func validHealthMethod(method string) bool {
+	switch method {
+	case "", "GET", "HEAD", "POST", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+func (b *Backend) validateDiscovery() error {
+	switch b.Discovery {
+	case "":
+		return nil
+	case DiscoveryDNSSRV:
+	default:
+		return fmt.Errorf("discovery must be %q, got %q", DiscoveryDNSSRV, b.Discovery)
+	}
+	if b.DiscoveryName == "" {
+		return fmt.Errorf("discovery dns_srv requires discovery_name")
+	}
+	switch b.DiscoveryScheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("discovery_scheme must be http or https, got %q", b.DiscoveryScheme)
+	}
+	if b.DiscoveryInterval < 0 {
+		return fmt.Errorf("discovery_interval must not be negative")
+	}
+	return nil
+}
+
+// validUpstreamHost acepta los modos de upstream_host o un host[:port] sin
+// esquema, ruta ni espacios
+func validUpstreamHost(host string) bool {
+	switch host {
+	case "", UpstreamHostPreserve, UpstreamHostRewrite:
+		return true
+	}
+	if strings.ContainsAny(host, " \t\r\n/?#@") {
+		return false
+	}
+	parsed, err := url.Parse("//" + host)
+	return err == nil && parsed.Host == host && parsed.Hostname() != ""
+}
+
+// validHeaderName comprueba que name sea un token HTTP válido como nombre de cabecera
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// finalStatus indica si code es un status de respuesta final; los 1xx no se
+// pueden remapear
+func finalStatus(code int) bool {
+	return code >= 200 && code <= 599
+}
+
+func (s SmartTrigger) validateMode() error {
+	switch s.Mode {
+	case "", TriggerModeScore:
+		return nil
+	case TriggerModeTargetTracking:
+		if s.TargetRPSPerServer <= 0 {
+			return fmt.Errorf("target_tracking requires target_rps_per_server > 0")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown smart trigger mode %q", s.Mode)
+	}
+}
+
+// ParseTrustedProxies convierte la lista trusted_proxies en redes; una IP
+// suelta equivale a su /32 o /128
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ParseServerURL exige una URL absoluta http(s) con host, p.ej. http://10.0.0.1:8080
+func ParseServerURL(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server url %q: %v", rawURL, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server url %q: scheme must be http or https", rawURL)
+	}
+	if target.Host == "" {
+		return nil, fmt.Errorf("invalid server url %q: missing host", rawURL)
+	}
+	return target, nil
+}
+
+// Servidores que solo escuchan en un socket Unix: unix:///var/run/app.sock.
+// La URL de destino usa UnixSocketHost y el transporte conecta al socket; el
+// proxy conserva el Host del cliente.
+const (
+	UnixSocketScheme = "unix"
+	UnixSocketHost   = "localhost"
+)
+
+// UnixSocketPath devuelve la ruta del socket de un servidor unix://
+func UnixSocketPath(rawURL string) (string, bool) {
+	path, found := strings.CutPrefix(rawURL, UnixSocketScheme+"://")
+	if !found || !strings.HasPrefix(path, "/") {
+		return "", false
+	}
+	return path, true
+}
+
+// ParseBackendServerURL acepta, además de http(s), servidores unix:// con la
+// ruta absoluta del socket. Para estos devuelve http://localhost: la ruta de la
+// URL es la del socket, así que no admiten prefijo de path.
+func ParseBackendServerURL(rawURL string) (*url.URL, error) {
+	if !strings.HasPrefix(rawURL, UnixSocketScheme+":") {
+		return ParseServerURL(rawURL)
+	}
+	if _, ok := UnixSocketPath(rawURL); !ok {
+		return nil, fmt.Errorf("invalid server url %q: unix sockets need an absolute path, e.g. unix:///var/run/app.sock", rawURL)
+	}
+	return &url.URL{Scheme: "http", Host: UnixSocketHost}, nil
+}
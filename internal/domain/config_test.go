@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -136,4 +137,689 @@ func TestSmartTrigger_Validation(t *testing.T) {
 	if trigger.StabilityThreshold < 0 || trigger.StabilityThreshold > 1 {
 		t.Error("stability threshold should be between 0 and 1")
 	}
-}
\ No newline at end of file
+}
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"valid http", "http://localhost:3001", false},
+		{"valid https", "https://10.0.0.1:8443", false},
+		{"missing scheme", "localhost:3001", true},
+		{"unsupported scheme", "ftp://localhost:3001", true},
+		{"missing host", "http://", true},
+		{"unparseable", "http://[::1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Backends: []Backend{{Name: "web", Servers: []Server{{URL: tt.url}}}},
+			}
+
+			err := config.Validate()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidConfig) {
+					t.Errorf("expected ErrInvalidConfig, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateSmartTriggerMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		smart   SmartTrigger
+		wantErr bool
+	}{
+		{"default mode", SmartTrigger{Enabled: true}, false},
+		{"target tracking", SmartTrigger{Enabled: true, Mode: TriggerModeTargetTracking, TargetRPSPerServer: 100}, false},
+		{"target tracking without target", SmartTrigger{Enabled: true, Mode: TriggerModeTargetTracking}, true},
+		{"unknown mode", SmartTrigger{Enabled: true, Mode: "step"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Backends: []Backend{{Name: "web", Servers: []Server{{URL: "http://localhost:3001"}}}},
+				Triggers: TriggerConfig{Smart: tt.smart},
+			}
+
+			err := config.Validate()
+			if tt.wantErr != errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("wantErr=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateTrustedProxies(t *testing.T) {
+	valid := &Config{Proxy: ProxyConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10", "fd00::/8"}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	networks, _ := ParseTrustedProxies([]string{"192.0.2.10"})
+	if ones, bits := networks[0].Mask.Size(); ones != 32 || bits != 32 {
+		t.Errorf("expected single IP to become /32, got /%d of %d", ones, bits)
+	}
+
+	for _, entry := range []string{"10.0.0.0/33", "not-an-ip"} {
+		config := &Config{Proxy: ProxyConfig{TrustedProxies: []string{entry}}}
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%q: expected ErrInvalidConfig, got %v", entry, err)
+		}
+	}
+}
+
+func TestConfig_ValidateUpstreamHeader(t *testing.T) {
+	valid := &Config{Proxy: ProxyConfig{UpstreamHeader: "X-Upstream-Server"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	for _, name := range []string{"X Upstream", "X-Upstream:", "X-Úpstream"} {
+		config := &Config{Proxy: ProxyConfig{UpstreamHeader: name}}
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%q: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+}
+
+func TestConfig_ValidatePressureShedding(t *testing.T) {
+	valid := &Config{Proxy: ProxyConfig{LoadShedding: &PressureSheddingCfg{MaxCPUPercent: 90, MaxGoroutines: 10000}}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	for _, cfg := range []*PressureSheddingCfg{
+		{},
+		{MaxCPUPercent: 120},
+		{MaxCPUPercent: -1},
+		{MaxGoroutines: -5},
+		{MaxHeapBytes: 1 << 30, SampleInterval: -time.Second},
+	} {
+		config := &Config{Proxy: ProxyConfig{LoadShedding: cfg}}
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%+v: expected ErrInvalidConfig, got %v", cfg, err)
+		}
+	}
+}
+
+func TestConfig_ValidateUpstreamHost(t *testing.T) {
+	for _, host := range []string{"", UpstreamHostPreserve, UpstreamHostRewrite, "api.internal", "api.internal:8443", "[::1]:8080"} {
+		config := &Config{Backends: []Backend{{Name: "web", UpstreamHost: host}}}
+		if err := config.Validate(); err != nil {
+			t.Errorf("%q: expected no error, got %v", host, err)
+		}
+	}
+
+	for _, host := range []string{"http://api.internal", "api.internal/v1", "api internal", "user@api.internal", ":8080"} {
+		config := &Config{Backends: []Backend{{Name: "web", UpstreamHost: host}}}
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%q: expected ErrInvalidConfig, got %v", host, err)
+		}
+	}
+}
+
+func TestConfig_ValidateDiscovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend Backend
+		wantErr bool
+	}{
+		{"static", Backend{Name: "web"}, false},
+		{"dns_srv", Backend{Name: "web", Discovery: DiscoveryDNSSRV, DiscoveryName: "_http._tcp.web.service.consul", DiscoveryScheme: "https"}, false},
+		{"unknown mode", Backend{Name: "web", Discovery: "consul", DiscoveryName: "web"}, true},
+		{"missing name", Backend{Name: "web", Discovery: DiscoveryDNSSRV}, true},
+		{"invalid scheme", Backend{Name: "web", Discovery: DiscoveryDNSSRV, DiscoveryName: "_http._tcp.web", DiscoveryScheme: "grpc"}, true},
+		{"negative interval", Backend{Name: "web", Discovery: DiscoveryDNSSRV, DiscoveryName: "_http._tcp.web", DiscoveryInterval: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: []Backend{tt.backend}}
+			if err := config.Validate(); tt.wantErr != errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	effective := (&Config{Backends: []Backend{{Name: "web", Discovery: DiscoveryDNSSRV, DiscoveryName: "_http._tcp.web"}}}).WithDefaults()
+	if backend := effective.Backends[0]; backend.DiscoveryInterval != DefaultDiscoveryInterval || backend.DiscoveryScheme != DefaultDiscoveryScheme {
+		t.Errorf("expected discovery defaults, got %v / %q", backend.DiscoveryInterval, backend.DiscoveryScheme)
+	}
+}
+
+func TestConfig_ValidateMirror(t *testing.T) {
+	tests := []struct {
+		name    string
+		mirror  MirrorCfg
+		wantErr bool
+	}{
+		{"valid", MirrorCfg{URL: "http://shadow:3001", SamplePercent: 10}, false},
+		{"full sample", MirrorCfg{URL: "http://shadow:3001", SamplePercent: 100}, false},
+		{"missing sample", MirrorCfg{URL: "http://shadow:3001"}, true},
+		{"sample over 100", MirrorCfg{URL: "http://shadow:3001", SamplePercent: 150}, true},
+		{"invalid url", MirrorCfg{URL: "shadow:3001", SamplePercent: 10}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mirror := tt.mirror
+			config := &Config{Backends: []Backend{{Name: "web", Mirror: &mirror}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateUnixSocketServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"absolute socket", "unix:///var/run/app.sock", false},
+		{"relative socket", "unix://app.sock", true},
+		{"empty socket", "unix://", true},
+		{"http server", "http://localhost:3001", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: []Backend{{Name: "web", Servers: []Server{{URL: tt.url}}}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// El mirror sigue exigiendo una URL http(s)
+	mirror := &MirrorCfg{URL: "unix:///var/run/shadow.sock", SamplePercent: 10}
+	config := &Config{Backends: []Backend{{Name: "web", Mirror: mirror}}}
+	if err := config.Validate(); err == nil {
+		t.Error("expected unix mirror to be rejected")
+	}
+}
+
+func TestConfig_ValidateBodyMatch(t *testing.T) {
+	servers := []string{"http://localhost:3001"}
+	tests := []struct {
+		name      string
+		bodyMatch BodyMatchCfg
+		wantErr   bool
+	}{
+		{"valid", BodyMatchCfg{Enabled: true, Rules: []BodyMatchRule{{Path: "data.event_type", Value: "x", Servers: servers}}}, false},
+		{"disabled is not checked", BodyMatchCfg{}, false},
+		{"no rules", BodyMatchCfg{Enabled: true}, true},
+		{"missing servers", BodyMatchCfg{Enabled: true, Rules: []BodyMatchRule{{Path: "event_type"}}}, true},
+		{"empty key", BodyMatchCfg{Enabled: true, Rules: []BodyMatchRule{{Path: "data..type", Servers: servers}}}, true},
+		{"invalid regex", BodyMatchCfg{Enabled: true, Rules: []BodyMatchRule{{Path: "event_type", Regex: "(", Servers: servers}}}, true},
+		{"negative size", BodyMatchCfg{Enabled: true, MaxBodyBytes: -1, Rules: []BodyMatchRule{{Path: "event_type", Servers: servers}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodyMatch := tt.bodyMatch
+			config := &Config{Backends: []Backend{{Name: "web", BodyMatch: &bodyMatch}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateMaintenanceStatusCode(t *testing.T) {
+	for _, code := range []int{0, 200, 503, 599} {
+		config := &Config{Backends: []Backend{{Name: "web", Maintenance: MaintenanceCfg{Enabled: true, StatusCode: code}}}}
+		if err := config.Validate(); err != nil {
+			t.Errorf("%d: expected no error, got %v", code, err)
+		}
+	}
+
+	for _, code := range []int{42, 101, 600, 1200, -1} {
+		config := &Config{Backends: []Backend{{Name: "web", Maintenance: MaintenanceCfg{Enabled: true, StatusCode: code}}}}
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%d: expected ErrInvalidConfig, got %v", code, err)
+		}
+	}
+}
+
+func TestConfig_ValidateStatusRemap(t *testing.T) {
+	tests := []struct {
+		name    string
+		remap   map[int]int
+		wantErr bool
+	}{
+		{"valid", map[int]int{418: 503, 200: 502}, false},
+		{"informational target", map[int]int{200: 101}, true},
+		{"out of range source", map[int]int{600: 503}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: []Backend{{Name: "web", StatusRemap: tt.remap}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateLogging(t *testing.T) {
+	tests := []struct {
+		name    string
+		logging LoggingConfig
+		wantErr bool
+	}{
+		{"defaults", LoggingConfig{}, false},
+		{"debug json", LoggingConfig{Level: LogLevelDebug, Format: LogFormatJSON}, false},
+		{"unknown level", LoggingConfig{Level: "verbose"}, true},
+		{"unknown format", LoggingConfig{Format: "logfmt"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Logging: tt.logging}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_WithDefaults(t *testing.T) {
+	config := &Config{Backends: []Backend{
+		{Name: "web", Retries: 5},
+		{Name: "grpc", Protocol: ProtocolGRPC},
+	}}
+
+	effective := config.WithDefaults()
+
+	web := effective.Backends[0]
+	if web.Retries != 5 {
+		t.Errorf("expected explicit retries 5 to be kept, got %d", web.Retries)
+	}
+	if web.HealthInterval != DefaultHealthInterval || web.BalanceMode != DefaultBalanceMode {
+		t.Errorf("expected health interval and balance mode defaults, got %v / %q", web.HealthInterval, web.BalanceMode)
+	}
+	if web.Protocol != ProtocolHTTP1 || web.Transport.TLSHandshakeTimeout != DefaultTLSHandshakeTimeout {
+		t.Errorf("expected http1 transport defaults, got %q / %v", web.Protocol, web.Transport.TLSHandshakeTimeout)
+	}
+	if web.CircuitBreaker.HalfOpenSuccesses != DefaultHalfOpenSuccesses {
+		t.Errorf("expected half_open_successes %d, got %d", DefaultHalfOpenSuccesses, web.CircuitBreaker.HalfOpenSuccesses)
+	}
+	if got := effective.Triggers.Smart.ScaleUpSettleTimeout; got != DefaultScaleUpSettleTimeout {
+		t.Errorf("expected scale_up_settle_timeout %v, got %v", DefaultScaleUpSettleTimeout, got)
+	}
+	if web.StickyFailback == nil || !*web.StickyFailback {
+		t.Error("expected sticky_failback to default to true")
+	}
+
+	grpc := effective.Backends[1]
+	if grpc.FlushInterval != -1 {
+		t.Errorf("expected grpc flush interval -1, got %v", grpc.FlushInterval)
+	}
+	if grpc.Transport.MaxIdleConnsPerHost != 0 {
+		t.Errorf("expected no per-host pool for grpc, got %d", grpc.Transport.MaxIdleConnsPerHost)
+	}
+
+	if effective.Logging.Level != LogLevelInfo || effective.Logging.SampleRate == nil {
+		t.Errorf("expected logging defaults, got %+v", effective.Logging)
+	}
+	if config.Backends[0].HealthInterval != 0 || config.Logging.Level != "" {
+		t.Error("expected original config to be untouched")
+	}
+}
+
+func TestLoadSheddingCfg(t *testing.T) {
+	shedding := &LoadSheddingCfg{ExcessFraction: 0.5, MaxPercent: 10}
+
+	if rate := shedding.ShedRate(0); rate != 0 {
+		t.Errorf("expected no shedding without excess, got %v", rate)
+	}
+	if rate := shedding.ShedRate(0.1); rate != 0.05 {
+		t.Errorf("expected half of the excess, got %v", rate)
+	}
+	if rate := shedding.ShedRate(0.8); rate != 0.1 {
+		t.Errorf("expected rate capped at max_percent, got %v", rate)
+	}
+
+	invalid := []LoadSheddingCfg{
+		{ExcessFraction: 0, MaxPercent: 10},
+		{ExcessFraction: 1.5, MaxPercent: 10},
+		{ExcessFraction: 0.5, MaxPercent: 0},
+		{ExcessFraction: 0.5, MaxPercent: 150},
+	}
+	for _, cfg := range invalid {
+		config := &Config{Backends: []Backend{{Name: "web", LoadShedding: &cfg}}}
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected invalid config for %+v, got %v", cfg, err)
+		}
+	}
+}
+
+func TestConfig_ValidateHealthMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend Backend
+		wantErr bool
+	}{
+		{"default", Backend{Name: "web"}, false},
+		{"head", Backend{Name: "web", HealthMethod: "HEAD"}, false},
+		{"unsupported backend method", Backend{Name: "web", HealthMethod: "DELETE"}, true},
+		{"unsupported server method", Backend{Name: "web", Servers: []Server{{URL: "http://localhost:3001", HealthMethod: "get"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: []Backend{tt.backend}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidatePercentiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		percentiles []float64
+		wantErr     bool
+	}{
+		{"default", nil, false},
+		{"custom", []float64{50, 90, 99.9}, false},
+		{"zero", []float64{0}, true},
+		{"hundred", []float64{100}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Metrics: MetricsConfig{Percentiles: tt.percentiles}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if label := PercentileLabel(99.9); label != "p99.9" {
+		t.Errorf("expected p99.9, got %s", label)
+	}
+	if label := PercentileLabel(50); label != "p50" {
+		t.Errorf("expected p50, got %s", label)
+	}
+}
+
+func TestConfig_ValidateActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  ActionConfig
+		wantErr bool
+	}{
+		{"http default", ActionConfig{URL: "http://localhost/scale", Method: "POST"}, false},
+		{"exec", ActionConfig{Type: ActionTypeExec, Command: "/opt/scale.sh"}, false},
+		{"exec without command", ActionConfig{Type: ActionTypeExec}, true},
+		{"kubernetes", ActionConfig{Type: ActionTypeKubernetes, Kubernetes: &KubernetesActionCfg{Deployment: "api", ScaleBy: -1, MinReplicas: 1}}, false},
+		{"kubernetes without deployment", ActionConfig{Type: ActionTypeKubernetes}, true},
+		{"kubernetes zero scale_by", ActionConfig{Type: ActionTypeKubernetes, Kubernetes: &KubernetesActionCfg{Deployment: "api"}}, true},
+		{"kubernetes max below min", ActionConfig{Type: ActionTypeKubernetes, Kubernetes: &KubernetesActionCfg{Deployment: "api", ScaleBy: 1, MinReplicas: 3, MaxReplicas: 2}}, true},
+		{"unknown type", ActionConfig{Type: "ftp"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Actions: map[string]ActionConfig{"scale": tt.action}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	interval := 10 * time.Second
+	for i := 0; i < 1000; i++ {
+		if d := Jitter(interval, 0.2); d < 8*time.Second || d > 12*time.Second {
+			t.Fatalf("expected jittered interval within ±20%%, got %v", d)
+		}
+		if d := InitialJitter(interval, 0.2); d < 0 || d > 2*time.Second {
+			t.Fatalf("expected initial delay within 0-2s, got %v", d)
+		}
+	}
+	if d := Jitter(interval, 0); d != interval {
+		t.Errorf("expected no jitter with fraction 0, got %v", d)
+	}
+	if d := InitialJitter(interval, 0); d != 0 {
+		t.Errorf("expected no initial delay with fraction 0, got %v", d)
+	}
+}
+
+func TestConfig_ValidateJitter(t *testing.T) {
+	tooHigh, zero := 0.6, 0.0
+
+	if err := (&Config{Backends: []Backend{{Name: "web", HealthJitter: &zero}}}).Validate(); err != nil {
+		t.Errorf("expected health_jitter 0 to be valid, got %v", err)
+	}
+	if err := (&Config{Backends: []Backend{{Name: "web", HealthJitter: &tooHigh}}}).Validate(); err == nil {
+		t.Error("expected error for health_jitter above 0.5")
+	}
+	if err := (&Config{Triggers: TriggerConfig{Smart: SmartTrigger{Jitter: &tooHigh}}}).Validate(); err == nil {
+		t.Error("expected error for triggers.smart.jitter above 0.5")
+	}
+	if got := (SmartTrigger{}).JitterFraction(); got != DefaultTimerJitter {
+		t.Errorf("expected default jitter %v, got %v", DefaultTimerJitter, got)
+	}
+}
+
+func TestConfig_ValidateDefaultBackend(t *testing.T) {
+	valid := &Config{Proxy: ProxyConfig{DefaultBackend: "http://maintenance:8080"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected valid default_backend, got %v", err)
+	}
+
+	invalid := &Config{Proxy: ProxyConfig{DefaultBackend: "maintenance:8080"}}
+	if err := invalid.Validate(); err == nil {
+		t.Error("expected error for default_backend without scheme")
+	}
+
+	reserved := &Config{
+		Proxy:    ProxyConfig{DefaultBackend: "http://maintenance:8080"},
+		Backends: []Backend{{Name: DefaultBackendName}},
+	}
+	if err := reserved.Validate(); err == nil {
+		t.Error("expected error for backend named default alongside default_backend")
+	}
+}
+
+func TestConfig_ValidateSchedule(t *testing.T) {
+	backends := []Backend{{Name: "web"}}
+	tests := []struct {
+		name    string
+		entry   ScheduleTrigger
+		wantErr bool
+	}{
+		{"action", ScheduleTrigger{Time: "09:00", Action: "morning_scale"}, false},
+		{"target", ScheduleTrigger{Time: "20:00", Backend: "web", Servers: 2}, false},
+		{"invalid time", ScheduleTrigger{Time: "25:00", Action: "morning_scale"}, true},
+		{"both forms", ScheduleTrigger{Time: "09:00", Action: "morning_scale", Backend: "web", Servers: 2}, true},
+		{"empty", ScheduleTrigger{Time: "09:00"}, true},
+		{"unknown backend", ScheduleTrigger{Time: "09:00", Backend: "api", Servers: 2}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: backends, Triggers: TriggerConfig{Schedule: []ScheduleTrigger{tt.entry}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateResponseRewrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite *ResponseRewriteCfg
+		wantErr bool
+	}{
+		{"valid", &ResponseRewriteCfg{Rules: []RewriteRule{{Find: `</body>`, Replace: "<p>hi</p></body>"}}}, false},
+		{"no rules", &ResponseRewriteCfg{}, true},
+		{"empty find", &ResponseRewriteCfg{Rules: []RewriteRule{{Replace: "x"}}}, true},
+		{"invalid regex", &ResponseRewriteCfg{Rules: []RewriteRule{{Find: `(unclosed`}}}, true},
+		{"negative limit", &ResponseRewriteCfg{Rules: []RewriteRule{{Find: "a"}}, MaxBodyBytes: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: []Backend{{Name: "web", ResponseRewrite: tt.rewrite}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	defaulted := (&Config{Backends: []Backend{{Name: "web", ResponseRewrite: tests[0].rewrite}}}).WithDefaults()
+	rewrite := defaulted.Backends[0].ResponseRewrite
+	if len(rewrite.ContentTypes) != 1 || rewrite.ContentTypes[0] != "text/html" || rewrite.MaxBodyBytes != DefaultRewriteMaxBodyBytes {
+		t.Errorf("expected text/html and 1 MiB defaults, got %v / %d", rewrite.ContentTypes, rewrite.MaxBodyBytes)
+	}
+}
+
+func TestConfig_ValidateScoreWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights *ScoreWeightsCfg
+		wantErr bool
+	}{
+		{"defaults", nil, false},
+		{"with queue", &ScoreWeightsCfg{RPS: 0.25, Latency: 0.2, ErrorRate: 0.2, Connections: 0.15, Queue: 0.2}, false},
+		{"sum below 1", &ScoreWeightsCfg{RPS: 0.3, Latency: 0.3}, true},
+		{"negative", &ScoreWeightsCfg{RPS: 1.2, Queue: -0.2}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Triggers: TriggerConfig{Smart: SmartTrigger{Weights: tt.weights}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateProxyTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     *ProxyTLSCfg
+		wantErr bool
+	}{
+		{"plain http", nil, false},
+		{"certificates", &ProxyTLSCfg{Certificates: []TLSCertificateCfg{{CertFile: "a.crt", KeyFile: "a.key"}}, StrictSNI: true}, false},
+		{"no certificates", &ProxyTLSCfg{StrictSNI: true}, true},
+		{"missing key", &ProxyTLSCfg{Certificates: []TLSCertificateCfg{{CertFile: "a.crt"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Proxy: ProxyConfig{TLS: tt.tls}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateErrorClassification(t *testing.T) {
+	half := 0.5
+	tooHigh := 1.5
+	tests := []struct {
+		name           string
+		classification *ErrorClassificationCfg
+		wantErr        bool
+	}{
+		{"client errors", &ErrorClassificationCfg{ClientErrors: 0.25, Codes: map[int]float64{429: 1}}, false},
+		{"server errors", &ErrorClassificationCfg{ServerErrors: &half}, false},
+		{"server errors above 1", &ErrorClassificationCfg{ServerErrors: &tooHigh}, true},
+		{"negative client errors", &ErrorClassificationCfg{ClientErrors: -0.1}, true},
+		{"invalid code", &ErrorClassificationCfg{Codes: map[int]float64{700: 1}}, true},
+		{"invalid code weight", &ErrorClassificationCfg{Codes: map[int]float64{404: 2}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: []Backend{{Name: "api", ErrorClassification: tt.classification}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateLatencySamples(t *testing.T) {
+	tests := []struct {
+		name        string
+		samples     int
+		percentiles []float64
+		wantErr     bool
+	}{
+		{"default", 0, nil, false},
+		{"enough for defaults", 100, nil, false},
+		{"too few for p99", 50, nil, true},
+		{"small buffer with low percentiles", 20, []float64{50, 95}, false},
+		{"too few for p99.9", 500, []float64{99.9}, true},
+		{"negative", -1, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Metrics: MetricsConfig{LatencySamples: tt.samples, Percentiles: tt.percentiles}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateServerLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]string{"class": "spot", "zone_a": "us-east-1a"}, false},
+		{"invalid name", map[string]string{"instance-class": "spot"}, true},
+		{"reserved prefix", map[string]string{"__name__": "x"}, true},
+		{"reserved name", map[string]string{"server": "x"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Backends: []Backend{{Name: "api", Servers: []Server{{URL: "http://10.0.1.5:3001", Labels: tt.labels}}}}}
+			err := config.Validate()
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
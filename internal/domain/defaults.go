@@ -0,0 +1,333 @@
+package domain
+
+import "time"
+
+// Valores por defecto de la configuración. El proxy los aplica al usarla y
+// WithDefaults los materializa para mostrar la configuración efectiva.
+const (
+	DefaultRetries            = 3
+	DefaultHealthInterval     = 10 * time.Second
+	DefaultTimerJitter        = 0.1
+	MaxTimerJitter            = 0.5
+	DefaultHealthMethod       = "GET"
+	DefaultHealthyThreshold   = 2
+	DefaultUnhealthyThreshold = 3
+	DefaultBalanceMode        = "adaptive_weighted"
+	DefaultHalfOpenSuccesses  = 5
+
+	DefaultSessionTTL             = 30 * time.Minute
+	DefaultMaxSessions            = 100000
+	DefaultAffinityCookieName     = "GOPROXY_AFFINITY"
+	DefaultAffinityCookieTTL      = time.Hour
+	DefaultAffinityCookieSameSite = "lax"
+	DefaultSessionCookie          = "JSESSIONID"
+	DefaultSessionHeader          = "X-Session-ID"
+
+	DefaultMaintenanceStatusCode  = 503
+	DefaultMaintenanceContentType = "text/plain; charset=utf-8"
+	DefaultMaintenanceBody        = "Service under maintenance"
+
+	DefaultMirrorTimeout      = 5 * time.Second
+	DefaultMirrorMaxBodyBytes = 1 << 20
+
+	DefaultRewriteMaxBodyBytes = 1 << 20
+
+	DefaultBodyMatchMaxBodyBytes = 64 << 10
+
+	DefaultDiscoveryInterval = 30 * time.Second
+	DefaultDiscoveryScheme   = "http"
+
+	DefaultLoadSheddingRetryAfter = 10 * time.Second
+	DefaultPressureSampleInterval = time.Second
+	// Duración por defecto y máxima de una simulación de /debug/trigger-simulation
+	DefaultTriggerSimulationDuration = 5 * time.Minute
+	MaxTriggerSimulationDuration     = time.Hour
+
+	// Sin error_classification solo los 5xx cuentan como fallo
+	DefaultServerErrorWeight = 1.0
+
+	DefaultActionTimeout       = 30 * time.Second
+	DefaultKubernetesNamespace = "default"
+
+	// Transporte: MaxIdleConns y TLSHandshakeTimeout son los de http.DefaultTransport
+	DefaultMaxIdleConns        = 100
+	DefaultMaxIdleConnsPerHost = 100
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultDialTimeout         = 30 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+
+	DefaultMetricsPersistInterval = 30 * time.Second
+	DefaultRedisMetricsKey        = "go-proxy:metrics"
+	DefaultLatencySamples         = 1000
+
+	// Tope del backoff del SmartTrigger, en múltiplos de cooldown, sin max_cooldown
+	DefaultMaxCooldownFactor = 10
+	// Espera máxima a que un scale-up aporte un servidor sano antes de otro
+	DefaultScaleUpSettleTimeout = 10 * time.Minute
+)
+
+// Protocolos de backend; vacío equivale a ProtocolHTTP1
+const (
+	ProtocolHTTP1 = "http1"
+	ProtocolH2C   = "h2c"
+	ProtocolGRPC  = "grpc"
+)
+
+// DefaultLatencyBuckets se usan cuando metrics.latency_buckets no está configurado
+var DefaultLatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1000 * time.Millisecond,
+}
+
+// DefaultRecoveryBuckets son los límites del histograma de recuperación del
+// SmartTrigger: del orden de un cooldown típico
+var DefaultRecoveryBuckets = []time.Duration{
+	15 * time.Second, 30 * time.Second, time.Minute, 2 * time.Minute, 3 * time.Minute,
+	5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour,
+}
+
+// DefaultScoreWeights son los pesos históricos del score del SmartTrigger; la
+// cola no cuenta salvo que se configure
+var DefaultScoreWeights = ScoreWeightsCfg{RPS: 0.30, Latency: 0.25, ErrorRate: 0.25, Connections: 0.20}
+
+// DefaultRewriteContentTypes son los tipos que response_rewrite reescribe si no se indican
+var DefaultRewriteContentTypes = []string{"text/html"}
+
+// DefaultPercentiles se calculan cuando metrics.percentiles no está configurado
+var DefaultPercentiles = []float64{95, 99}
+
+// Métodos y cabeceras CORS permitidos en los preflight cuando no se configuran
+var (
+	DefaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	DefaultCORSHeaders = []string{"Content-Type", "X-API-KEY"}
+)
+
+// WithDefaults devuelve una copia de la configuración con todos los valores
+// por defecto explícitos. No modifica c.
+func (c *Config) WithDefaults() *Config {
+	effective := *c
+
+	effective.Backends = make([]Backend, len(c.Backends))
+	for i := range c.Backends {
+		effective.Backends[i] = c.Backends[i].withDefaults()
+	}
+
+	if c.Actions != nil {
+		effective.Actions = make(map[string]ActionConfig, len(c.Actions))
+		for name, action := range c.Actions {
+			effective.Actions[name] = action.withDefaults()
+		}
+	}
+
+	if len(effective.Metrics.LatencyBuckets) == 0 {
+		effective.Metrics.LatencyBuckets = append([]time.Duration(nil), DefaultLatencyBuckets...)
+	}
+	if len(effective.Metrics.Percentiles) == 0 {
+		effective.Metrics.Percentiles = append([]float64(nil), DefaultPercentiles...)
+	}
+	effective.Metrics.LatencySamples = c.Metrics.LatencySampleSize()
+	if p := c.Metrics.Persistence; p != nil {
+		persistence := *p
+		if persistence.Interval <= 0 {
+			persistence.Interval = DefaultMetricsPersistInterval
+		}
+		if persistence.Store == MetricsStoreRedis && persistence.Key == "" {
+			persistence.Key = DefaultRedisMetricsKey
+		}
+		effective.Metrics.Persistence = &persistence
+	}
+
+	// Los métodos y cabeceras CORS solo se usan si hay orígenes permitidos
+	if len(effective.CORS.AllowedOrigins) > 0 {
+		if len(effective.CORS.AllowedMethods) == 0 {
+			effective.CORS.AllowedMethods = append([]string(nil), DefaultCORSMethods...)
+		}
+		if len(effective.CORS.AllowedHeaders) == 0 {
+			effective.CORS.AllowedHeaders = append([]string(nil), DefaultCORSHeaders...)
+		}
+	}
+
+	if effective.Triggers.Smart.CooldownBackoff > 1 && effective.Triggers.Smart.MaxCooldown <= 0 {
+		effective.Triggers.Smart.MaxCooldown = effective.Triggers.Smart.Cooldown * DefaultMaxCooldownFactor
+	}
+
+	if effective.Triggers.Smart.ScaleUpSettleTimeout <= 0 {
+		effective.Triggers.Smart.ScaleUpSettleTimeout = DefaultScaleUpSettleTimeout
+	}
+
+	jitter := effective.Triggers.Smart.JitterFraction()
+	effective.Triggers.Smart.Jitter = &jitter
+
+	if effective.Logging.Level == "" {
+		effective.Logging.Level = LogLevelInfo
+	}
+	if effective.Logging.Format == "" {
+		effective.Logging.Format = LogFormatText
+	}
+	rate := effective.Logging.AccessLogSampleRate()
+	effective.Logging.SampleRate = &rate
+
+	return &effective
+}
+
+func (a ActionConfig) withDefaults() ActionConfig {
+	if a.Type == "" {
+		a.Type = ActionTypeHTTP
+	}
+	if a.Type != ActionTypeHTTP && a.Timeout <= 0 {
+		a.Timeout = DefaultActionTimeout
+	}
+	if a.Kubernetes != nil {
+		k := *a.Kubernetes
+		if k.Namespace == "" {
+			k.Namespace = DefaultKubernetesNamespace
+		}
+		a.Kubernetes = &k
+	}
+	return a
+}
+
+func (b Backend) withDefaults() Backend {
+	if b.Retries == 0 {
+		b.Retries = DefaultRetries
+	}
+	if b.HealthInterval == 0 {
+		b.HealthInterval = DefaultHealthInterval
+	}
+	if b.HealthMethod == "" {
+		b.HealthMethod = DefaultHealthMethod
+	}
+	jitter := b.HealthJitterFraction()
+	b.HealthJitter = &jitter
+	if b.HealthyThreshold <= 0 {
+		b.HealthyThreshold = DefaultHealthyThreshold
+	}
+	if b.UnhealthyThreshold <= 0 {
+		b.UnhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if b.CircuitBreaker.HalfOpenSuccesses <= 0 {
+		b.CircuitBreaker.HalfOpenSuccesses = DefaultHalfOpenSuccesses
+	}
+	if b.BalanceMode == "" {
+		b.BalanceMode = DefaultBalanceMode
+	}
+	adaptive := b.AdaptiveBalancingEnabled()
+	b.AdaptiveBalancing = &adaptive
+	failback := b.StickyFailbackEnabled()
+	b.StickyFailback = &failback
+
+	if b.Discovery != "" {
+		if b.DiscoveryScheme == "" {
+			b.DiscoveryScheme = DefaultDiscoveryScheme
+		}
+		if b.DiscoveryInterval <= 0 {
+			b.DiscoveryInterval = DefaultDiscoveryInterval
+		}
+	}
+
+	if b.StickyCookie.Name == "" {
+		b.StickyCookie.Name = DefaultAffinityCookieName
+	}
+	if b.StickyCookie.TTL == 0 {
+		b.StickyCookie.TTL = DefaultAffinityCookieTTL
+	}
+	if b.StickyCookie.SameSite == "" {
+		b.StickyCookie.SameSite = DefaultAffinityCookieSameSite
+	}
+	if b.AffinityCookie == "" {
+		b.AffinityCookie = DefaultSessionCookie
+	}
+	if b.AffinityHeader == "" {
+		b.AffinityHeader = DefaultSessionHeader
+	}
+	if b.SessionTTL <= 0 {
+		b.SessionTTL = DefaultSessionTTL
+	}
+	if b.MaxSessions <= 0 {
+		b.MaxSessions = DefaultMaxSessions
+	}
+
+	if b.Maintenance.StatusCode == 0 {
+		b.Maintenance.StatusCode = DefaultMaintenanceStatusCode
+	}
+	if b.Maintenance.ContentType == "" {
+		b.Maintenance.ContentType = DefaultMaintenanceContentType
+	}
+	if b.Maintenance.Body == "" {
+		b.Maintenance.Body = DefaultMaintenanceBody
+	}
+
+	if b.Mirror != nil {
+		mirror := *b.Mirror
+		if mirror.Timeout <= 0 {
+			mirror.Timeout = DefaultMirrorTimeout
+		}
+		if mirror.MaxBodyBytes <= 0 {
+			mirror.MaxBodyBytes = DefaultMirrorMaxBodyBytes
+		}
+		b.Mirror = &mirror
+	}
+
+	if b.ResponseRewrite != nil {
+		rewrite := *b.ResponseRewrite
+		if len(rewrite.ContentTypes) == 0 {
+			rewrite.ContentTypes = append([]string(nil), DefaultRewriteContentTypes...)
+		}
+		if rewrite.MaxBodyBytes == 0 {
+			rewrite.MaxBodyBytes = DefaultRewriteMaxBodyBytes
+		}
+		b.ResponseRewrite = &rewrite
+	}
+
+	if b.BodyMatch != nil && b.BodyMatch.MaxBodyBytes == 0 {
+		bodyMatch := *b.BodyMatch
+		bodyMatch.MaxBodyBytes = DefaultBodyMatchMaxBodyBytes
+		b.BodyMatch = &bodyMatch
+	}
+
+	if b.ErrorClassification != nil {
+		classification := *b.ErrorClassification
+		serverErrors := classification.ServerErrorWeight()
+		classification.ServerErrors = &serverErrors
+		b.ErrorClassification = &classification
+	}
+
+	if b.LoadShedding != nil && b.LoadShedding.RetryAfter <= 0 {
+		shedding := *b.LoadShedding
+		shedding.RetryAfter = DefaultLoadSheddingRetryAfter
+		b.LoadShedding = &shedding
+	}
+
+	if b.Protocol == "" {
+		b.Protocol = ProtocolHTTP1
+	}
+	multiplexed := b.Protocol == ProtocolH2C || b.Protocol == ProtocolGRPC
+	if multiplexed && b.FlushInterval == 0 {
+		b.FlushInterval = -1
+	}
+
+	if b.Transport.IdleConnTimeout <= 0 {
+		b.Transport.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if b.Transport.DialTimeout <= 0 {
+		b.Transport.DialTimeout = DefaultDialTimeout
+	}
+	// El transporte h2c no tiene pool por host ni TLS
+	if !multiplexed {
+		if b.Transport.MaxIdleConnsPerHost <= 0 {
+			b.Transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+		}
+		if b.Transport.MaxIdleConns <= 0 {
+			b.Transport.MaxIdleConns = max(DefaultMaxIdleConns, b.Transport.MaxIdleConnsPerHost)
+		}
+		if b.Transport.TLSHandshakeTimeout <= 0 {
+			b.Transport.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+		}
+	}
+	return b
+}
@@ -12,6 +12,63 @@ type ProxyService interface {
 	GetServerStats() map[string]*Server
 }
 
+// LoadShedder lo implementa el proxy para que el SmartTrigger fije qué fracción
+// de las requests de un backend se descarta; devuelve la fracción anterior
+type LoadShedder interface {
+	SetShedRate(backend string, rate float64) float64
+}
+
+// QueueStatsProvider lo implementa el proxy para que el SmartTrigger vea la
+// presión sobre max_concurrent_requests de cada backend
+type QueueStatsProvider interface {
+	BackendQueueStats() map[string]QueueStats
+}
+
+// QueueStats son las requests esperando hueco ahora mismo y las rechazadas
+// (cola llena, max_wait agotado o sin cola) desde el arranque
+type QueueStats struct {
+	Depth    int64
+	Rejected int64
+}
+
+// Motivos por los que una request se queda sin servidor: todos los candidatos
+// están en su límite de conexiones (hace falta escalar) o ninguno está
+// disponible por salud, circuito abierto o inactividad (hay que arreglarlos)
+const (
+	RejectCapacity  = "capacity"
+	RejectUnhealthy = "unhealthy"
+)
+
+// MetricsSimulator lo implementa el proxy para que el SmartTrigger use
+// métricas sintéticas en lugar de las medidas (debug.trigger_simulation)
+type MetricsSimulator interface {
+	// SimulateMetrics fija la simulación vigente; nil la detiene
+	SimulateMetrics(simulation *SimulatedMetrics)
+	// SimulatedMetrics devuelve la simulación vigente o nil si no hay o expiró
+	SimulatedMetrics(now time.Time) *SimulatedMetrics
+}
+
+// SimulatedMetrics sustituye hasta ExpiresAt las métricas que lee el
+// SmartTrigger; los campos nil conservan el valor medido
+type SimulatedMetrics struct {
+	Backend     string         // vacío aplica a todos los backends
+	RPS         *float64       // requests por segundo del backend
+	Latency     *time.Duration // latencia media de sus servidores
+	ErrorRate   *float64       // fracción de requests fallidas, 0-1
+	Connections *int64         // conexiones activas sumando todos sus servidores
+	ExpiresAt   time.Time
+}
+
+// AppliesTo indica si la simulación afecta al backend; "" es el trigger global
+func (s *SimulatedMetrics) AppliesTo(backend string) bool {
+	return s.Backend == "" || s.Backend == backend
+}
+
+// MetricsResetter pone a cero las métricas globales acumuladas del proxy
+type MetricsResetter interface {
+	ResetMetrics()
+}
+
 type ConfigRepository interface {
 	Load() (*Config, error)
 	Watch(callback func(*Config)) error
@@ -37,3 +94,93 @@ type LoadBalancer interface {
 	UpdateStats(server *Server, responseTime time.Duration, success bool)
 	GetServerMetrics() map[string]*Server
 }
+
+// UpgradeTracker lo implementan los balanceadores que siguen aparte las
+// conexiones que cambian de protocolo (WebSocket): una sola request que ocupa
+// al servidor durante horas. TrackUpgrade devuelve la función que la cierra.
+type UpgradeTracker interface {
+	TrackUpgrade(server *Server) (closed func())
+}
+
+// TriggerMetrics es la última evaluación del SmartTrigger de un backend, para exportarla como gauges
+type TriggerMetrics struct {
+	Backend       string
+	RPSScore      float64
+	LatencyScore  float64
+	ErrorScore    float64
+	ConnScore     float64
+	QueueScore    float64
+	TotalScore    float64
+	ShortAvg      float64
+	LongAvg       float64
+	TrendSlope    float64
+	Stability     float64
+	Confidence    float64
+	CooldownUntil time.Time
+	EvaluatedAt   time.Time
+	// El score se calculó con métricas de debug.trigger_simulation
+	Simulated bool
+}
+
+// TriggerRecoveryProvider lo implementa el servicio de triggers para exponer
+// cuánto tarda el score en volver a la banda neutra tras escalar
+type TriggerRecoveryProvider interface {
+	// GetTriggerRecovery devuelve un histograma por backend y acción, ordenados
+	GetTriggerRecovery() []TriggerRecovery
+}
+
+// TriggerRecovery es el histograma acumulado (estilo Prometheus "le") del
+// tiempo entre una acción de escalado y la vuelta del score a la banda
+// neutra. Counts tiene un elemento más que Buckets para el bucket +Inf.
+type TriggerRecovery struct {
+	Backend string
+	Action  string
+	Buckets []time.Duration
+	Counts  []int64
+	Sum     time.Duration
+	Count   int64
+}
+
+// TriggerEvent es una acción de escalado disparada, o simulada en dry_run
+type TriggerEvent struct {
+	Backend    string    `json:"backend"`
+	Action     string    `json:"action"`
+	ActionName string    `json:"action_name"`
+	Score      float64   `json:"score"`
+	Confidence float64   `json:"confidence"`
+	Reason     string    `json:"reason"`
+	DryRun     bool      `json:"dry_run"`
+	Simulated  bool      `json:"simulated,omitempty"` // decidida con métricas sintéticas; siempre dry_run
+	Timestamp  time.Time `json:"timestamp"`
+	// Solo en target_tracking: servidores deseados y acciones emitidas
+	DesiredServers int `json:"desired_servers,omitempty"`
+	Steps          int `json:"steps,omitempty"`
+}
+
+// ServerCounters son los contadores acumulados de un servidor que se conservan entre reinicios
+type ServerCounters struct {
+	RequestCount int64 `json:"request_count"`
+	SuccessCount int64 `json:"success_count"`
+	FailureCount int64 `json:"failure_count"`
+	TotalLatency int64 `json:"total_latency_ns"`
+}
+
+// MetricsSnapshot es el estado persistido de las métricas, indexado por URL de servidor
+type MetricsSnapshot struct {
+	Servers map[string]ServerCounters `json:"servers"`
+	SavedAt time.Time                 `json:"saved_at"`
+}
+
+// MetricsStore guarda y recupera snapshots de métricas; Load devuelve nil sin
+// error si todavía no hay nada guardado
+type MetricsStore interface {
+	Load() (*MetricsSnapshot, error)
+	Save(snapshot *MetricsSnapshot) error
+}
+
+type TriggerMetricsProvider interface {
+	// GetTriggerMetrics devuelve una entrada por backend evaluado, ordenadas por nombre
+	GetTriggerMetrics() []TriggerMetrics
+	// GetTriggerHistory devuelve las últimas acciones, de la más antigua a la más reciente
+	GetTriggerHistory() []TriggerEvent
+}
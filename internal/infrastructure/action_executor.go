@@ -3,12 +3,77 @@ package infrastructure
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/juanbautista0/go-proxy/internal/domain"
 )
 
+// actionQueueSize limita las acciones exec y kubernetes pendientes de ejecutar
+const actionQueueSize = 64
+
+// TypedActionExecutor elige el ejecutor según ActionConfig.Type; sin tipo la
+// acción es una llamada HTTP. Los ejecutores exec y kubernetes son síncronos y
+// pueden tardar hasta su timeout, así que se ejecutan en segundo plano, en
+// orden y de uno en uno, para no bloquear al trigger que los dispara.
+type TypedActionExecutor struct {
+	executors map[string]domain.ActionExecutor
+	queue     chan queuedAction
+}
+
+type queuedAction struct {
+	executor domain.ActionExecutor
+	name     string
+	config   domain.ActionConfig
+}
+
+func NewTypedActionExecutor() *TypedActionExecutor {
+	e := &TypedActionExecutor{
+		executors: map[string]domain.ActionExecutor{
+			domain.ActionTypeHTTP:       NewHTTPActionExecutor(),
+			domain.ActionTypeExec:       NewExecActionExecutor(),
+			domain.ActionTypeKubernetes: NewKubernetesActionExecutor(),
+		},
+		queue: make(chan queuedAction, actionQueueSize),
+	}
+	go e.run()
+	return e
+}
+
+func (e *TypedActionExecutor) Execute(actionName string, config domain.ActionConfig) error {
+	actionType := config.Type
+	if actionType == "" {
+		actionType = domain.ActionTypeHTTP
+	}
+	executor, ok := e.executors[actionType]
+	if !ok {
+		return fmt.Errorf("action %s: unknown type %q", actionName, actionType)
+	}
+	if actionType == domain.ActionTypeHTTP {
+		return executor.Execute(actionName, config) // ya es asíncrono
+	}
+
+	select {
+	case e.queue <- queuedAction{executor: executor, name: actionName, config: config}:
+		return nil
+	default:
+		return fmt.Errorf("action %s: queue full (%d pending)", actionName, actionQueueSize)
+	}
+}
+
+// run ejecuta las acciones encoladas; los errores solo se registran porque
+// quien disparó la acción ya no está esperando
+func (e *TypedActionExecutor) run() {
+	for action := range e.queue {
+		if err := action.executor.Execute(action.name, action.config); err != nil {
+			slog.Error("Action failed", "action", action.name, "type", action.config.Type, "error", err)
+		}
+	}
+}
+
 type HTTPActionExecutor struct {
 	client *http.Client
 }
@@ -27,7 +92,14 @@ func (e *HTTPActionExecutor) Execute(actionName string, config domain.ActionConf
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 		defer cancel()
 		
-		req, err := http.NewRequestWithContext(ctx, config.Method, config.URL, bytes.NewBuffer([]byte{}))
+		body := []byte{}
+		if config.Payload != nil {
+			if encoded, err := json.Marshal(config.Payload); err == nil {
+				body = encoded
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, config.Method, config.URL, bytes.NewBuffer(body))
 		if err != nil {
 			return
 		}
@@ -1,9 +1,19 @@
 package infrastructure
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/juanbautista0/go-proxy/internal/domain"
 )
@@ -69,3 +79,179 @@ func TestHTTPActionExecutor_Execute_HTTPError(t *testing.T) {
 	}
 }
 
+
+func TestTypedActionExecutor_UnknownType(t *testing.T) {
+	executor := NewTypedActionExecutor()
+
+	err := executor.Execute("scale_up", domain.ActionConfig{Type: "ftp"})
+	if err == nil || !strings.Contains(err.Error(), "unknown type") {
+		t.Errorf("expected unknown type error, got %v", err)
+	}
+}
+
+func TestTypedActionExecutor_RunsExecInBackground(t *testing.T) {
+	outFile := filepath.Join(t.TempDir(), "out")
+	executor := NewTypedActionExecutor()
+
+	start := time.Now()
+	for _, step := range []string{"1", "2"} {
+		err := executor.Execute("scale_up", domain.ActionConfig{
+			Type:    domain.ActionTypeExec,
+			Command: "sh",
+			Args:    []string{"-c", `sleep 0.2; echo "$1" >> "$2"`, "sh", step, outFile},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("expected Execute to return without waiting for the command, took %v", elapsed)
+	}
+
+	// Las acciones se ejecutan de una en una y en orden
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(outFile)
+		if string(data) == "1\n2\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected both steps in order, got %q", data)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestExecActionExecutor_Execute(t *testing.T) {
+	dir := t.TempDir()
+	outFile := filepath.Join(dir, "out")
+	executor := NewExecActionExecutor()
+
+	config := domain.ActionConfig{
+		Type:    domain.ActionTypeExec,
+		Command: "sh",
+		Args:    []string{"-c", `echo "$GO_PROXY_ACTION $REGION $(cat)" > "$1"`, "sh", outFile},
+		Env:     map[string]string{"REGION": "eu"},
+		Payload: map[string]interface{}{"step": 1},
+	}
+	if err := executor.Execute("scale_up", config); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != `scale_up eu {"step":1}` {
+		t.Errorf("unexpected command output %q", got)
+	}
+}
+
+func TestExecActionExecutor_FailureAndTimeout(t *testing.T) {
+	executor := NewExecActionExecutor()
+
+	err := executor.Execute("fail", domain.ActionConfig{Command: "sh", Args: []string{"-c", "echo boom; exit 3"}})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected error with captured output, got %v", err)
+	}
+
+	start := time.Now()
+	err = executor.Execute("slow", domain.ActionConfig{Command: "sleep", Args: []string{"5"}, Timeout: 100 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected timeout to stop the command, took %v", elapsed)
+	}
+}
+
+func TestKubernetesActionExecutor_Execute(t *testing.T) {
+	replicas := 2
+	var patches []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/apps/v1/namespaces/shop/deployments/api/scale" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("expected bearer token, got %q", auth)
+		}
+		if r.Method == http.MethodPatch {
+			if ct := r.Header.Get("Content-Type"); ct != "application/merge-patch+json" {
+				t.Errorf("expected merge patch, got %s", ct)
+			}
+			body, _ := io.ReadAll(r.Body)
+			patches = append(patches, string(body))
+			var scale autoscalingv1.Scale
+			json.Unmarshal(body, &scale)
+			replicas = int(scale.Spec.Replicas)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(autoscalingv1.Scale{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "autoscaling/v1", Kind: "Scale"},
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop"},
+			Spec:       autoscalingv1.ScaleSpec{Replicas: int32(replicas)},
+		})
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("secret\n"), 0o600)
+	config := domain.ActionConfig{
+		Type: domain.ActionTypeKubernetes,
+		Kubernetes: &domain.KubernetesActionCfg{
+			Namespace:   "shop",
+			Deployment:  "api",
+			ScaleBy:     2,
+			MaxReplicas: 5,
+			APIServer:   server.URL,
+			TokenFile:   tokenFile,
+		},
+	}
+	executor := NewKubernetesActionExecutor()
+
+	// 2 -> 4, luego 4 -> 5 por max_replicas, y en el tope no se parchea
+	for i := 0; i < 3; i++ {
+		if err := executor.Execute("scale_up", config); err != nil {
+			t.Fatalf("execution %d: expected no error, got %v", i, err)
+		}
+	}
+	if replicas != 5 {
+		t.Errorf("expected 5 replicas, got %d", replicas)
+	}
+	if len(patches) != 2 || patches[0] != `{"spec":{"replicas":4}}` {
+		t.Errorf("unexpected patches %v", patches)
+	}
+}
+
+func TestKubernetesActionExecutor_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "deployments.apps \"api\" is forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	executor := NewKubernetesActionExecutor()
+	err := executor.Execute("scale_up", domain.ActionConfig{
+		Kubernetes: &domain.KubernetesActionCfg{Deployment: "api", ScaleBy: 1, APIServer: server.URL},
+	})
+	if !apierrors.IsForbidden(err) {
+		t.Errorf("expected forbidden error, got %v", err)
+	}
+}
+
+func TestKubernetesActionExecutor_ReusesClient(t *testing.T) {
+	executor := NewKubernetesActionExecutor()
+	k := &domain.KubernetesActionCfg{Deployment: "api", ScaleBy: 1, APIServer: "http://127.0.0.1:8001"}
+
+	first, err := executor.client(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := executor.client(&domain.KubernetesActionCfg{Deployment: "web", ScaleBy: -1, APIServer: k.APIServer})
+	if first != second {
+		t.Error("expected actions on the same API server to share the client")
+	}
+	other, _ := executor.client(&domain.KubernetesActionCfg{Deployment: "api", ScaleBy: 1, APIServer: "http://127.0.0.1:8002"})
+	if other == first {
+		t.Error("expected a different API server to get its own client")
+	}
+}
@@ -0,0 +1,201 @@
+package infrastructure
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveController atribuye el rendimiento medido en cada ventana de
+// evaluación al algoritmo que estuvo activo durante esa ventana.
+//
+// Score de una ventana (0.0 - 1.0):
+//   - 40% tasa de éxito (1 - error rate)
+//   - 40% latencia media (1.0 con 0ms, 0.0 con 1s o más)
+//   - 20% balance de carga entre servidores (1 - coeficiente de variación)
+//
+// El score de un algoritmo es la media de sus últimas ventanas. Los algoritmos
+// sin historial reciben explorationScore, por lo que solo se prueban cuando el
+// algoritmo activo rinde claramente por debajo de ese valor.
+type AdaptiveController struct {
+	mu                 sync.RWMutex
+	performanceHistory map[string]*PerformanceWindow
+	algorithmScores    map[string]float64
+	algorithmTotals    map[string]*algorithmTotals
+	switchThreshold    float64
+	evaluationWindow   time.Duration
+	explorationScore   float64
+	lastSwitch         time.Time
+	lastEvaluation     time.Time
+	lastSnapshot       map[string]serverSnapshot
+	clock              Clock
+}
+
+type PerformanceWindow struct {
+	samples    []float64
+	timestamps []time.Time
+	maxSize    int
+}
+
+// algorithmTotals acumula todo lo observado mientras un algoritmo estuvo
+// activo; a diferencia de PerformanceWindow no descarta ventanas antiguas
+type algorithmTotals struct {
+	windows    int64
+	completed  int64
+	failures   int64
+	latency    int64
+	balance    float64
+	lastWindow time.Time
+}
+
+// serverSnapshot guarda los contadores acumulados de un servidor al cierre de una ventana
+type serverSnapshot struct {
+	requests  int64
+	successes int64
+	failures  int64
+	latency   int64
+}
+
+func NewAdaptiveController() *AdaptiveController {
+	return &AdaptiveController{
+		performanceHistory: make(map[string]*PerformanceWindow),
+		algorithmScores:    make(map[string]float64),
+		algorithmTotals:    make(map[string]*algorithmTotals),
+		switchThreshold:    0.15,
+		evaluationWindow:   30 * time.Second,
+		explorationScore:   0.5,
+		lastSnapshot:       make(map[string]serverSnapshot),
+		clock:              SystemClock{},
+	}
+}
+
+func NewPerformanceWindow(maxSize int) *PerformanceWindow {
+	return &PerformanceWindow{maxSize: maxSize}
+}
+
+func (pw *PerformanceWindow) Add(sample float64, timestamp time.Time) {
+	pw.samples = append(pw.samples, sample)
+	pw.timestamps = append(pw.timestamps, timestamp)
+	if len(pw.samples) > pw.maxSize {
+		pw.samples = pw.samples[1:]
+		pw.timestamps = pw.timestamps[1:]
+	}
+}
+
+func (pw *PerformanceWindow) Average() float64 {
+	if len(pw.samples) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, sample := range pw.samples {
+		sum += sample
+	}
+	return sum / float64(len(pw.samples))
+}
+
+func (pw *PerformanceWindow) Len() int {
+	return len(pw.samples)
+}
+
+// algorithmScore requiere mu tomado
+func (ac *AdaptiveController) algorithmScore(algorithmName string) float64 {
+	window, exists := ac.performanceHistory[algorithmName]
+	if !exists || window.Len() == 0 {
+		return ac.explorationScore
+	}
+	return window.Average()
+}
+
+// recordWindow calcula el score de la ventana que termina a partir de la
+// diferencia de contadores y lo asigna al algoritmo activo. Requiere mu tomado.
+func (ac *AdaptiveController) recordWindow(algorithmName string, servers map[string]*ServerState, now time.Time) {
+	snapshot := make(map[string]serverSnapshot, len(servers))
+	var completed, failures, latency int64
+	loads := make([]float64, 0, len(servers))
+
+	for url, state := range servers {
+		current := serverSnapshot{
+			requests:  atomic.LoadInt64(&state.Metrics.RequestCount),
+			successes: atomic.LoadInt64(&state.Metrics.SuccessCount),
+			failures:  atomic.LoadInt64(&state.Metrics.FailureCount),
+			latency:   atomic.LoadInt64(&state.Metrics.TotalLatency),
+		}
+		snapshot[url] = current
+
+		// Servidores recreados reinician sus contadores
+		delta := current
+		if previous, exists := ac.lastSnapshot[url]; exists && current.requests >= previous.requests {
+			delta = serverSnapshot{
+				requests:  current.requests - previous.requests,
+				successes: current.successes - previous.successes,
+				failures:  current.failures - previous.failures,
+				latency:   current.latency - previous.latency,
+			}
+		}
+
+		completed += delta.successes + delta.failures
+		failures += delta.failures
+		latency += delta.latency
+		loads = append(loads, float64(delta.requests))
+	}
+	ac.lastSnapshot = snapshot
+
+	// Sin tráfico no hay nada que atribuir
+	if completed == 0 {
+		return
+	}
+
+	errorRate := float64(failures) / float64(completed)
+	avgLatency := float64(latency) / float64(completed)
+	balance := loadBalanceScore(loads)
+
+	score := (1.0-errorRate)*0.4 +
+		math.Max(0, 1.0-avgLatency/float64(time.Second))*0.4 +
+		balance*0.2
+
+	totals, exists := ac.algorithmTotals[algorithmName]
+	if !exists {
+		totals = &algorithmTotals{}
+		ac.algorithmTotals[algorithmName] = totals
+	}
+	totals.windows++
+	totals.completed += completed
+	totals.failures += failures
+	totals.latency += latency
+	totals.balance += balance
+	totals.lastWindow = now
+
+	window, exists := ac.performanceHistory[algorithmName]
+	if !exists {
+		window = NewPerformanceWindow(10)
+		ac.performanceHistory[algorithmName] = window
+	}
+	window.Add(score, now)
+}
+
+// loadBalanceScore devuelve 1 - coeficiente de variación de la carga (mínimo 0)
+func loadBalanceScore(loads []float64) float64 {
+	if len(loads) < 2 {
+		return 1.0
+	}
+
+	mean := 0.0
+	for _, load := range loads {
+		mean += load
+	}
+	mean /= float64(len(loads))
+
+	if mean == 0 {
+		return 1.0
+	}
+
+	variance := 0.0
+	for _, load := range loads {
+		variance += (load - mean) * (load - mean)
+	}
+	variance /= float64(len(loads))
+
+	cv := math.Sqrt(variance) / mean
+	return math.Max(0, 1.0-cv)
+}
@@ -0,0 +1,114 @@
+package infrastructure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestAdaptiveController_RecordWindowAttributesToActiveAlgorithm(t *testing.T) {
+	controller := NewAdaptiveController()
+	servers := map[string]*ServerState{}
+	for _, state := range newTestServerStates(2) {
+		servers[state.Server.URL] = state
+	}
+
+	// Ventana con 50% de errores y latencia alta
+	for _, state := range servers {
+		state.Metrics.RequestCount = 10
+		state.Metrics.SuccessCount = 5
+		state.Metrics.FailureCount = 5
+		state.Metrics.TotalLatency = int64(10 * 500 * time.Millisecond)
+	}
+	controller.recordWindow("least_connections", servers, time.Now())
+
+	badScore := controller.algorithmScore("least_connections")
+	if badScore >= 0.8 {
+		t.Errorf("expected degraded score below 0.8, got %.2f", badScore)
+	}
+
+	// La siguiente ventana solo cuenta el delta: 10 éxitos rápidos por servidor
+	for _, state := range servers {
+		state.Metrics.RequestCount += 10
+		state.Metrics.SuccessCount += 10
+		state.Metrics.TotalLatency += int64(10 * 10 * time.Millisecond)
+	}
+	controller.recordWindow("power_of_two", servers, time.Now())
+
+	goodScore := controller.algorithmScore("power_of_two")
+	if goodScore <= badScore {
+		t.Errorf("expected healthy window to score higher than %.2f, got %.2f", badScore, goodScore)
+	}
+	if controller.algorithmScore("least_connections") != badScore {
+		t.Error("expected previous algorithm history to be unaffected")
+	}
+}
+
+func TestAdaptiveController_RecordWindowWithoutTrafficIsIgnored(t *testing.T) {
+	controller := NewAdaptiveController()
+	servers := map[string]*ServerState{}
+	for _, state := range newTestServerStates(2) {
+		servers[state.Server.URL] = state
+	}
+
+	controller.recordWindow("adaptive_weighted", servers, time.Now())
+
+	if _, exists := controller.performanceHistory["adaptive_weighted"]; exists {
+		t.Error("expected no history without completed requests")
+	}
+}
+
+func TestEnterpriseBalancer_SwitchesAwayFromWorseAlgorithm(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	controller := balancer.adaptiveController
+	controller.performanceHistory["least_connections"] = NewPerformanceWindow(10)
+	controller.performanceHistory["least_connections"].Add(0.95, time.Now())
+
+	// El algoritmo activo sirve con errores constantes
+	for _, state := range balancer.servers {
+		state.Metrics.RequestCount = 100
+		state.Metrics.SuccessCount = 20
+		state.Metrics.FailureCount = 80
+		state.Metrics.TotalLatency = int64(100 * 900 * time.Millisecond)
+	}
+	controller.lastEvaluation = time.Now().Add(-time.Minute)
+
+	balancer.mu.RLock()
+	selected := balancer.reevaluateAlgorithm()
+	balancer.mu.RUnlock()
+
+	if selected != "least_connections" {
+		t.Errorf("expected switch to least_connections, got %s", selected)
+	}
+	if controller.algorithmScores["adaptive_weighted"] >= controller.algorithmScores["least_connections"] {
+		t.Error("expected algorithm scores to differ by measured performance")
+	}
+}
+
+func TestEnterpriseBalancer_KeepsAlgorithmWithinSwitchThreshold(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	controller := balancer.adaptiveController
+
+	controller.performanceHistory["adaptive_weighted"] = NewPerformanceWindow(10)
+	controller.performanceHistory["adaptive_weighted"].Add(0.85, time.Now())
+	controller.performanceHistory["least_connections"] = NewPerformanceWindow(10)
+	controller.performanceHistory["least_connections"].Add(0.9, time.Now())
+	controller.lastEvaluation = time.Now().Add(-time.Minute)
+
+	balancer.mu.RLock()
+	selected := balancer.reevaluateAlgorithm()
+	balancer.mu.RUnlock()
+
+	if selected != "adaptive_weighted" {
+		t.Errorf("expected to keep adaptive_weighted, got %s", selected)
+	}
+}
@@ -10,11 +10,21 @@ import (
 	"github.com/juanbautista0/go-proxy/internal/domain"
 )
 
+// HealthObserver recibe el estado de cada servidor tras aplicar los umbrales
+type HealthObserver interface {
+	ReportHealth(serverURL string, healthy bool)
+}
+
 type AdvancedHealthChecker struct {
-	backends map[string]*domain.Backend
-	stopChs  map[string]chan struct{}
-	client   *http.Client
-	mu       sync.RWMutex
+	backends    map[string]*domain.Backend
+	stopChs     map[string]chan struct{}
+	client      *http.Client
+	transitions *healthTransitions
+	observer    HealthObserver
+	mu          sync.RWMutex
+	// Un cliente por socket para los servidores unix://; protegido por socketMu
+	socketClients map[string]*http.Client
+	socketMu      sync.Mutex
 }
 
 type HealthCheckResult struct {
@@ -28,8 +38,9 @@ type HealthCheckResult struct {
 
 func NewAdvancedHealthChecker() *AdvancedHealthChecker {
 	return &AdvancedHealthChecker{
-		backends: make(map[string]*domain.Backend),
-		stopChs:  make(map[string]chan struct{}),
+		backends:    make(map[string]*domain.Backend),
+		stopChs:     make(map[string]chan struct{}),
+		transitions: newHealthTransitions(),
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 			Transport: &http.Transport{
@@ -45,6 +56,13 @@ func NewAdvancedHealthChecker() *AdvancedHealthChecker {
 	}
 }
 
+// SetObserver registra quién debe enterarse de los resultados (p.ej. el balanceador)
+func (hc *AdvancedHealthChecker) SetObserver(observer HealthObserver) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.observer = observer
+}
+
 func (hc *AdvancedHealthChecker) Start(backend *domain.Backend) error {
 	hc.mu.Lock()
 	defer hc.mu.Unlock()
@@ -61,10 +79,10 @@ func (hc *AdvancedHealthChecker) Start(backend *domain.Backend) error {
 
 	interval := backend.HealthInterval
 	if interval == 0 {
-		interval = 10 * time.Second
+		interval = domain.DefaultHealthInterval
 	}
 
-	go hc.healthCheckLoop(backend, stopCh, interval)
+	go hc.healthCheckLoop(backend, stopCh, interval, backend.HealthJitterFraction())
 	return nil
 }
 
@@ -79,49 +97,111 @@ func (hc *AdvancedHealthChecker) Stop() error {
 	return nil
 }
 
-func (hc *AdvancedHealthChecker) IsHealthy(serverURL string) bool {
-	// Esta función ahora es manejada por el IntelligentBalancer
-	return true
+// StopBackend detiene los checks de un backend y olvida sus servidores
+func (hc *AdvancedHealthChecker) StopBackend(name string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.stopBackendLocked(name)
 }
 
-func (hc *AdvancedHealthChecker) healthCheckLoop(backend *domain.Backend, stopCh chan struct{}, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// StopRemovedBackends detiene los checks de los backends que ya no están en
+// la configuración; sin esto sus goroutines siguen sondeando tras un reload
+func (hc *AdvancedHealthChecker) StopRemovedBackends(backends []domain.Backend) {
+	current := make(map[string]bool, len(backends))
+	for _, backend := range backends {
+		current[backend.Name] = true
+	}
 
-	// Health check inicial inmediato
-	hc.performHealthChecks(backend)
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	for name := range hc.backends {
+		if !current[name] {
+			hc.stopBackendLocked(name)
+		}
+	}
+}
+
+func (hc *AdvancedHealthChecker) stopBackendLocked(name string) {
+	if stopCh, exists := hc.stopChs[name]; exists {
+		close(stopCh)
+		delete(hc.stopChs, name)
+	}
+	delete(hc.backends, name)
+}
+
+func (hc *AdvancedHealthChecker) IsHealthy(serverURL string) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	for _, backend := range hc.backends {
+		for _, server := range backend.Servers {
+			if server.URL == serverURL {
+				return server.Healthy
+			}
+		}
+	}
+	return false
+}
+
+func (hc *AdvancedHealthChecker) healthCheckLoop(backend *domain.Backend, stopCh chan struct{}, interval time.Duration, jitter float64) {
+	// Health check inicial casi inmediato; el retraso y el intervalo aleatorios
+	// evitan que los probes de muchos backends e instancias lleguen a la vez
+	timer := time.NewTimer(domain.InitialJitter(interval, jitter))
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
 			hc.performHealthChecks(backend)
+			timer.Reset(domain.Jitter(interval, jitter))
 		case <-stopCh:
 			return
 		}
 	}
 }
 
-func (hc *AdvancedHealthChecker) performHealthChecks(backend *domain.Backend) {
-	var wg sync.WaitGroup
-	results := make(chan HealthCheckResult, len(backend.Servers))
+// maxConcurrentHealthChecks limita los probes simultáneos para no saturar la red
+const maxConcurrentHealthChecks = 10
 
+type healthCheckTarget struct {
+	url      string
+	endpoint string
+	method   string
+	headers  map[string]string
+}
+
+func (hc *AdvancedHealthChecker) performHealthChecks(backend *domain.Backend) {
+	// Tomar los objetivos con el lock y liberarlo durante los requests de red
+	hc.mu.RLock()
+	var targets []healthCheckTarget
 	for _, server := range backend.Servers {
-		if !server.Active {
-			continue
+		if server.Active {
+			targets = append(targets, healthCheckTarget{
+				url:      server.URL,
+				endpoint: healthEndpoint(&server, backend),
+				method:   healthMethod(&server, backend),
+				headers:  healthHeaders(&server, backend),
+			})
 		}
+	}
+	hc.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	results := make(chan HealthCheckResult, len(targets))
+	sem := make(chan struct{}, maxConcurrentHealthChecks)
 
+	for _, target := range targets {
 		wg.Add(1)
-		go func(srv domain.Server) {
+		sem <- struct{}{}
+		go func(target healthCheckTarget) {
 			defer wg.Done()
-			result := hc.checkServerHealth(srv, backend.HealthCheck)
-			results <- result
-		}(server)
+			defer func() { <-sem }()
+			results <- hc.checkServerHealth(target)
+		}(target)
 	}
 
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	wg.Wait()
+	close(results)
 
 	// Procesar resultados
 	for result := range results {
@@ -129,33 +209,104 @@ func (hc *AdvancedHealthChecker) performHealthChecks(backend *domain.Backend) {
 	}
 }
 
-func (hc *AdvancedHealthChecker) checkServerHealth(server domain.Server, healthPath string) HealthCheckResult {
+// healthEndpoint usa el endpoint individual del servidor o el del backend
+func healthEndpoint(server *domain.Server, backend *domain.Backend) string {
+	if server.HealthCheckEndpoint != "" {
+		return server.HealthCheckEndpoint
+	}
+	return backend.HealthCheck
+}
+
+// healthMethod usa el método del servidor, el del backend o GET
+func healthMethod(server *domain.Server, backend *domain.Backend) string {
+	if server.HealthMethod != "" {
+		return server.HealthMethod
+	}
+	if backend.HealthMethod != "" {
+		return backend.HealthMethod
+	}
+	return domain.DefaultHealthMethod
+}
+
+// healthHeaders combina las cabeceras del backend con las del servidor, que
+// tienen prioridad
+func healthHeaders(server *domain.Server, backend *domain.Backend) map[string]string {
+	if len(server.HealthHeaders) == 0 {
+		return backend.HealthHeaders
+	}
+	headers := make(map[string]string, len(backend.HealthHeaders)+len(server.HealthHeaders))
+	for name, value := range backend.HealthHeaders {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	for name, value := range server.HealthHeaders {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	return headers
+}
+
+// clientFor devuelve el cliente y la URL base para chequear un servidor. Un
+// servidor unix:// se chequea en http://localhost a través de su socket.
+func (hc *AdvancedHealthChecker) clientFor(serverURL string) (*http.Client, string) {
+	socket, ok := domain.UnixSocketPath(serverURL)
+	if !ok {
+		return hc.client, serverURL
+	}
+
+	hc.socketMu.Lock()
+	defer hc.socketMu.Unlock()
+	if hc.socketClients == nil {
+		hc.socketClients = make(map[string]*http.Client)
+	}
+	client, exists := hc.socketClients[socket]
+	if !exists {
+		transport := hc.client.Transport.(*http.Transport).Clone()
+		dial := transport.DialContext
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx, "unix", socket)
+		}
+		client = &http.Client{Timeout: hc.client.Timeout, Transport: transport}
+		hc.socketClients[socket] = client
+	}
+	return client, "http://" + domain.UnixSocketHost
+}
+
+func (hc *AdvancedHealthChecker) checkServerHealth(target healthCheckTarget) HealthCheckResult {
 	start := time.Now()
 	result := HealthCheckResult{
-		URL:       server.URL,
+		URL:       target.url,
 		Timestamp: start,
 	}
 
-	if healthPath == "" {
-		healthPath = "/health"
+	if target.endpoint == "" {
+		result.Healthy = true // Sin health check configurado
+		return result
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	url := server.URL + healthPath
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	client, baseURL := hc.clientFor(target.url)
+	url := baseURL + target.endpoint
+	req, err := http.NewRequestWithContext(ctx, target.method, url, nil)
 	if err != nil {
 		result.Error = err
 		result.Healthy = false
 		return result
 	}
 
-	// Headers para health check
+	// Headers para health check; las configuradas pueden sustituir a estas
 	req.Header.Set("User-Agent", "go-proxy-health-checker/1.0")
 	req.Header.Set("Accept", "*/*")
+	for name, value := range target.headers {
+		// Go ignora Host en req.Header: se fija en req.Host
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
 
-	resp, err := hc.client.Do(req)
+	resp, err := client.Do(req)
 	result.ResponseTime = time.Since(start)
 
 	if err != nil {
@@ -189,10 +340,13 @@ func (hc *AdvancedHealthChecker) processHealthCheckResult(result HealthCheckResu
 	// Encontrar el servidor y actualizar su estado
 	for i := range backend.Servers {
 		server := &backend.Servers[i]
-		if server.URL == result.URL {
-			server.Healthy = result.Healthy
+		if server.URL == result.URL && server.Active {
+			hc.transitions.apply(server, backend, result.Healthy)
 			server.LastHealthCheck = result.Timestamp
 			server.ResponseTime = result.ResponseTime
+			if hc.observer != nil {
+				hc.observer.ReportHealth(server.URL, server.Healthy)
+			}
 			
 			// Log de cambios de estado
 			if !result.Healthy && result.Error != nil {
@@ -228,7 +382,11 @@ func (hc *AdvancedHealthChecker) GetHealthMetrics() map[string]interface{} {
 		
 		backendMetrics["healthy_servers"] = healthyCount
 		backendMetrics["total_servers"] = totalCount
-		backendMetrics["health_ratio"] = float64(healthyCount) / float64(totalCount)
+		healthRatio := 0.0
+		if totalCount > 0 {
+			healthRatio = float64(healthyCount) / float64(totalCount)
+		}
+		backendMetrics["health_ratio"] = healthRatio
 		
 		metrics[backendName] = backendMetrics
 	}
@@ -0,0 +1,350 @@
+package infrastructure
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestAdvancedHealthChecker_SingleFailureDoesNotEject(t *testing.T) {
+	var failNext int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.CompareAndSwapInt32(&failNext, 1, 0) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hc := NewAdvancedHealthChecker()
+	backend := &domain.Backend{
+		Name:               "web",
+		HealthCheck:        "/health",
+		UnhealthyThreshold: 3,
+		HealthyThreshold:   2,
+		Servers:            []domain.Server{{URL: server.URL, Active: true}},
+	}
+	hc.backends[backend.Name] = backend
+
+	hc.performHealthChecks(backend)
+	if !hc.IsHealthy(server.URL) {
+		t.Fatal("expected server healthy after first successful check")
+	}
+
+	atomic.StoreInt32(&failNext, 1)
+	hc.performHealthChecks(backend)
+	if !hc.IsHealthy(server.URL) {
+		t.Error("expected a single failure not to eject the server")
+	}
+
+	hc.performHealthChecks(backend)
+	if !hc.IsHealthy(server.URL) {
+		t.Error("expected server to remain healthy")
+	}
+}
+
+func TestHealthTransitions_Thresholds(t *testing.T) {
+	transitions := newHealthTransitions()
+	backend := &domain.Backend{HealthyThreshold: 2, UnhealthyThreshold: 3}
+	server := &domain.Server{URL: "http://localhost:3001"}
+
+	steps := []struct {
+		healthy  bool
+		expected bool
+	}{
+		{healthy: true, expected: true},   // primer check: se aplica directamente
+		{healthy: false, expected: true},  // 1/3 fallos
+		{healthy: false, expected: true},  // 2/3 fallos
+		{healthy: true, expected: true},   // reinicia el conteo de fallos
+		{healthy: false, expected: true},  // 1/3
+		{healthy: false, expected: true},  // 2/3
+		{healthy: false, expected: false}, // 3/3: expulsado
+		{healthy: true, expected: false},  // 1/2 éxitos
+		{healthy: false, expected: false}, // reinicia el conteo de éxitos
+		{healthy: true, expected: false},  // 1/2
+		{healthy: true, expected: true},   // 2/2: readmitido
+	}
+
+	for i, step := range steps {
+		transitions.apply(server, backend, step.healthy)
+		if server.Healthy != step.expected {
+			t.Fatalf("step %d: expected healthy=%v, got %v", i, step.expected, server.Healthy)
+		}
+	}
+}
+
+func TestHealthTransitions_DefaultThresholds(t *testing.T) {
+	backend := &domain.Backend{}
+
+	if healthyThreshold(backend) != defaultHealthyThreshold {
+		t.Errorf("expected default healthy threshold %d, got %d", defaultHealthyThreshold, healthyThreshold(backend))
+	}
+	if unhealthyThreshold(backend) != defaultUnhealthyThreshold {
+		t.Errorf("expected default unhealthy threshold %d, got %d", defaultUnhealthyThreshold, unhealthyThreshold(backend))
+	}
+}
+
+func TestAdvancedHealthChecker_ChecksConcurrentlyWithoutHoldingLock(t *testing.T) {
+	var inFlight int32
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&inFlight, 1)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	servers := make([]domain.Server, 5)
+	for i := range servers {
+		servers[i] = domain.Server{URL: fmt.Sprintf("%s/s%d", slow.URL, i), Active: true}
+	}
+	hc := NewAdvancedHealthChecker()
+	backend := &domain.Backend{Name: "web", HealthCheck: "/health", Servers: servers}
+	hc.backends[backend.Name] = backend
+
+	done := make(chan struct{})
+	go func() {
+		hc.performHealthChecks(backend)
+		close(done)
+	}()
+
+	// Todos los probes deben estar en curso a la vez
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&inFlight) < int32(len(servers)) {
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("expected %d concurrent probes, got %d", len(servers), atomic.LoadInt32(&inFlight))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Con los probes bloqueados, las lecturas no deben esperar al lock
+	readDone := make(chan struct{})
+	go func() {
+		hc.IsHealthy(servers[0].URL)
+		close(readDone)
+	}()
+	select {
+	case <-readDone:
+	case <-time.After(time.Second):
+		t.Fatal("IsHealthy blocked while health checks were in flight")
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("performHealthChecks did not finish")
+	}
+	for _, server := range servers {
+		if !hc.IsHealthy(server.URL) {
+			t.Errorf("expected %s healthy after checks", server.URL)
+		}
+	}
+}
+
+func TestAdvancedHealthChecker_NoEndpointIsHealthy(t *testing.T) {
+	hc := NewAdvancedHealthChecker()
+	backend := &domain.Backend{
+		Name:    "web",
+		Servers: []domain.Server{{URL: "http://127.0.0.1:1", Active: true}},
+	}
+	hc.backends[backend.Name] = backend
+
+	// Sin health check configurado no se contacta al servidor
+	hc.performHealthChecks(backend)
+
+	if !hc.IsHealthy("http://127.0.0.1:1") {
+		t.Error("expected server without health endpoint to be healthy")
+	}
+	if hc.IsHealthy("http://unknown:9999") {
+		t.Error("expected unknown server to be unhealthy")
+	}
+}
+
+func TestAdvancedHealthChecker_GetHealthMetrics(t *testing.T) {
+	hc := NewAdvancedHealthChecker()
+	hc.backends["web"] = &domain.Backend{
+		Name: "web",
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Active: true, Healthy: true},
+			{URL: "http://localhost:3002", Active: true},
+		},
+	}
+	hc.backends["empty"] = &domain.Backend{Name: "empty"}
+
+	metrics := hc.GetHealthMetrics()
+
+	web := metrics["web"].(map[string]interface{})
+	if web["healthy_servers"] != 1 || web["total_servers"] != 2 || web["health_ratio"] != 0.5 {
+		t.Errorf("unexpected metrics for web backend: %v", web)
+	}
+	empty := metrics["empty"].(map[string]interface{})
+	if empty["health_ratio"] != 0.0 {
+		t.Errorf("expected zero health ratio without servers, got %v", empty["health_ratio"])
+	}
+}
+
+func TestAdvancedHealthChecker_ReportsToBalancer(t *testing.T) {
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+
+	backend := &domain.Backend{
+		Name:        "web",
+		HealthCheck: "/health",
+		Servers:     []domain.Server{{URL: unhealthy.URL, Weight: 1, Active: true}},
+	}
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateServers(backend.Servers, backend)
+
+	hc := NewAdvancedHealthChecker()
+	hc.SetObserver(balancer)
+	hc.backends[backend.Name] = backend
+
+	hc.performHealthChecks(backend)
+
+	if len(balancer.getAvailableServers(nil)) != 0 {
+		t.Error("expected health-check failure to remove the server from rotation")
+	}
+}
+
+func TestAdvancedHealthChecker_MethodAndHeaders(t *testing.T) {
+	var method, auth, region, host atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method.Store(r.Method)
+		auth.Store(r.Header.Get("Authorization"))
+		region.Store(r.Header.Get("X-Region"))
+		host.Store(r.Host)
+		if r.Header.Get("Authorization") != "Bearer server-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hc := NewAdvancedHealthChecker()
+	backend := &domain.Backend{
+		Name:          "web",
+		HealthCheck:   "/health",
+		HealthMethod:  "HEAD",
+		HealthHeaders: map[string]string{"Authorization": "Bearer backend-token", "X-Region": "eu", "Host": "health.internal"},
+		Servers: []domain.Server{{
+			URL:           server.URL,
+			Active:        true,
+			HealthHeaders: map[string]string{"authorization": "Bearer server-token"},
+		}},
+	}
+	hc.backends[backend.Name] = backend
+
+	hc.performHealthChecks(backend)
+
+	if !hc.IsHealthy(server.URL) {
+		t.Error("expected server healthy with the server's Authorization header")
+	}
+	if got := method.Load(); got != "HEAD" {
+		t.Errorf("expected HEAD health check, got %v", got)
+	}
+	if got := auth.Load(); got != "Bearer server-token" {
+		t.Errorf("expected server header to override the backend one, got %v", got)
+	}
+	if got := region.Load(); got != "eu" {
+		t.Errorf("expected backend header to be kept, got %v", got)
+	}
+	if got := host.Load(); got != "health.internal" {
+		t.Errorf("expected Host override, got %v", got)
+	}
+}
+
+func TestAdvancedHealthChecker_UnixSocketServer(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen on unix socket: %v", err)
+	}
+	var path atomic.Value
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path.Store(r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	serverURL := "unix://" + socket
+	hc := NewAdvancedHealthChecker()
+	backend := &domain.Backend{
+		Name:        "web",
+		HealthCheck: "/health",
+		Servers:     []domain.Server{{URL: serverURL, Active: true}},
+	}
+	hc.backends[backend.Name] = backend
+
+	hc.performHealthChecks(backend)
+
+	if !hc.IsHealthy(serverURL) {
+		t.Error("expected the socket server healthy")
+	}
+	if got := path.Load(); got != "/health" {
+		t.Errorf("expected check on /health, got %v", got)
+	}
+}
+
+func TestAdvancedHealthChecker_StopRemovedBackends(t *testing.T) {
+	var webChecks, apiChecks int32
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&webChecks, 1)
+	}))
+	defer web.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&apiChecks, 1)
+	}))
+	defer api.Close()
+
+	noJitter := 0.0
+	backends := []domain.Backend{
+		{Name: "web", HealthCheck: "/health", HealthInterval: 10 * time.Millisecond, HealthJitter: &noJitter, Servers: []domain.Server{{URL: web.URL, Active: true}}},
+		{Name: "api", HealthCheck: "/health", HealthInterval: 10 * time.Millisecond, HealthJitter: &noJitter, Servers: []domain.Server{{URL: api.URL, Active: true}}},
+	}
+
+	hc := NewAdvancedHealthChecker()
+	defer hc.Stop()
+	for i := range backends {
+		hc.Start(&backends[i])
+	}
+
+	// Cada backend tiene su propio loop: ambos reciben checks
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&webChecks) < 2 || atomic.LoadInt32(&apiChecks) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected both backends checked, got web=%d api=%d", atomic.LoadInt32(&webChecks), atomic.LoadInt32(&apiChecks))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	hc.StopRemovedBackends(backends[:1])
+	time.Sleep(30 * time.Millisecond)
+	stopped := atomic.LoadInt32(&apiChecks)
+	time.Sleep(50 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&apiChecks); got != stopped {
+		t.Errorf("expected removed backend to stop being checked, got %d more checks", got-stopped)
+	}
+	if hc.IsHealthy(api.URL) {
+		t.Error("expected removed backend's server to be forgotten")
+	}
+	before := atomic.LoadInt32(&webChecks)
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&webChecks) == before {
+		t.Error("expected remaining backend to keep being checked")
+	}
+}
@@ -0,0 +1,103 @@
+package infrastructure
+
+import (
+	"sort"
+	"time"
+)
+
+// AlgorithmReport resume la selección adaptativa: qué algoritmo está activo y
+// cómo rindió cada uno en las ventanas que se le atribuyeron
+type AlgorithmReport struct {
+	CurrentAlgorithm string             `json:"current_algorithm"`
+	PinnedAlgorithm  string             `json:"pinned_algorithm,omitempty"`
+	EvaluationWindow string             `json:"evaluation_window"`
+	SwitchThreshold  float64            `json:"switch_threshold"`
+	ExplorationScore float64            `json:"exploration_score"`
+	LastSwitch       *time.Time         `json:"last_switch,omitempty"`
+	Algorithms       []AlgorithmMetrics `json:"algorithms"`
+}
+
+// AlgorithmMetrics son las métricas de un algoritmo. Score y Samples salen de
+// la ventana deslizante que decide los cambios; el resto acumula todas las
+// ventanas en las que estuvo activo.
+type AlgorithmMetrics struct {
+	Name   string  `json:"name"`
+	Active bool    `json:"active"`
+	Score  float64 `json:"score"`
+	// Sin ventanas propias el score es exploration_score
+	Explored   bool              `json:"explored"`
+	Samples    []AlgorithmSample `json:"samples"`
+	Windows    int64             `json:"windows"`
+	Requests   int64             `json:"requests"`
+	Failures   int64             `json:"failures"`
+	ErrorRate  float64           `json:"error_rate"`
+	AvgLatency string            `json:"avg_latency"`
+	// Media de 1 - coeficiente de variación de la carga entre servidores
+	LoadBalance float64    `json:"load_balance"`
+	LastWindow  *time.Time `json:"last_window,omitempty"`
+}
+
+// AlgorithmSample es el score de una ventana de evaluación
+type AlgorithmSample struct {
+	Score     float64   `json:"score"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GetAlgorithmReport devuelve las métricas de todos los algoritmos ordenados
+// por nombre, incluidos los que aún no han estado activos
+func (eb *EnterpriseBalancer) GetAlgorithmReport() AlgorithmReport {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	ac := eb.adaptiveController
+	ac.mu.RLock()
+	defer ac.mu.RUnlock()
+
+	report := AlgorithmReport{
+		CurrentAlgorithm: eb.currentAlgorithm,
+		PinnedAlgorithm:  eb.pinnedAlgorithm,
+		EvaluationWindow: ac.evaluationWindow.String(),
+		SwitchThreshold:  ac.switchThreshold,
+		ExplorationScore: ac.explorationScore,
+	}
+	if report.PinnedAlgorithm != "" {
+		report.CurrentAlgorithm = report.PinnedAlgorithm
+	}
+	if !ac.lastSwitch.IsZero() {
+		lastSwitch := ac.lastSwitch
+		report.LastSwitch = &lastSwitch
+	}
+
+	names := make([]string, 0, len(eb.algorithms))
+	for name := range eb.algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		metrics := AlgorithmMetrics{
+			Name:       name,
+			Active:     name == report.CurrentAlgorithm,
+			Score:      ac.algorithmScore(name),
+			Samples:    []AlgorithmSample{},
+			AvgLatency: time.Duration(0).String(),
+		}
+		if window, exists := ac.performanceHistory[name]; exists && window.Len() > 0 {
+			metrics.Explored = true
+			for i, score := range window.samples {
+				metrics.Samples = append(metrics.Samples, AlgorithmSample{Score: score, Timestamp: window.timestamps[i]})
+			}
+		}
+		if totals, exists := ac.algorithmTotals[name]; exists && totals.windows > 0 {
+			metrics.Windows = totals.windows
+			metrics.Requests = totals.completed
+			metrics.Failures = totals.failures
+			metrics.ErrorRate = float64(totals.failures) / float64(totals.completed)
+			metrics.AvgLatency = time.Duration(totals.latency / totals.completed).String()
+			metrics.LoadBalance = totals.balance / float64(totals.windows)
+			lastWindow := totals.lastWindow
+			metrics.LastWindow = &lastWindow
+		}
+		report.Algorithms = append(report.Algorithms, metrics)
+	}
+	return report
+}
@@ -1,11 +1,12 @@
 package infrastructure
 
 import (
-	"crypto/md5"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -13,6 +14,18 @@ import (
 // Adaptive Weighted Round Robin con machine learning
 type AdaptiveWeightedRoundRobin struct {
 	lastUpdate time.Time
+	// Acumuladores del smooth WRR por URL; sobreviven a los subconjuntos de
+	// candidatos que cambian en cada request (header_match, reintentos,
+	// prioridades, saturación) y solo se borran al eliminar el servidor
+	current map[string]float64
+	mu      sync.Mutex
+	clock   Clock
+}
+
+func (a *AdaptiveWeightedRoundRobin) setClock(clock Clock) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.clock = clock
 }
 
 func (a *AdaptiveWeightedRoundRobin) SelectServer(servers []*ServerState, clientIP string) *ServerState {
@@ -20,30 +33,50 @@ func (a *AdaptiveWeightedRoundRobin) SelectServer(servers []*ServerState, client
 		return nil
 	}
 
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	a.UpdateWeights(servers)
-	
-	// Smooth weighted round robin (nginx algorithm)
-	var selected *ServerState
-	totalWeight := 0.0
+	if a.current == nil {
+		a.current = make(map[string]float64)
+	}
 
+	totalWeight := 0.0
 	for _, server := range servers {
-		server.CurrentWeight += server.EffectiveWeight
 		totalWeight += server.EffectiveWeight
-		
-		if selected == nil || server.CurrentWeight > selected.CurrentWeight {
-			selected = server
-		}
+	}
+	if totalWeight <= 0 {
+		return servers[0]
 	}
 
-	if selected != nil {
-		selected.CurrentWeight -= totalWeight
+	// Smooth weighted round robin (nginx algorithm) normalizado: cada
+	// candidato suma su fracción del peso de esta selección y el elegido resta
+	// 1. Así cada servidor recibe su parte de las selecciones en las que
+	// compite aunque los candidatos cambien entre llamadas. Los servidores
+	// nuevos empiezan en cero y los que no compiten conservan su acumulador.
+	var selected *ServerState
+	for _, server := range servers {
+		url := server.Server.URL
+		a.current[url] += server.EffectiveWeight / totalWeight
+
+		if selected == nil || a.current[url] > a.current[selected.Server.URL] {
+			selected = server
+		}
 	}
+	a.current[selected.Server.URL]--
 
 	return selected
 }
 
+// forgetServer descarta el acumulador de un servidor eliminado del balanceador
+func (a *AdaptiveWeightedRoundRobin) forgetServer(serverURL string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.current, serverURL)
+}
+
 func (a *AdaptiveWeightedRoundRobin) UpdateWeights(servers []*ServerState) {
-	now := time.Now()
+	now := clockNow(a.clock)
 	if now.Sub(a.lastUpdate) < 5*time.Second {
 		return
 	}
@@ -111,7 +144,7 @@ func (lc *LeastConnections) SelectServer(servers []*ServerState, clientIP string
 		activeConns := atomic.LoadInt64(&server.ConnectionPool.ActiveConns)
 		
 		// Score = conexiones_activas / peso_efectivo + factor_latencia
-		score := float64(activeConns) / server.EffectiveWeight
+		score := float64(activeConns) / selectionWeight(server.EffectiveWeight, server.Weight)
 		
 		// Penalizar por alta latencia
 		if server.Metrics.P95ResponseTime > 0 {
@@ -135,6 +168,88 @@ func (lc *LeastConnections) UpdateWeights(servers []*ServerState) {
 	// Weights updated by adaptive controller
 }
 
+// minSelectionWeight evita dividir por pesos nulos o casi nulos
+const minSelectionWeight = 0.1
+
+// selectionWeight usa el peso efectivo si el controlador ya lo calculó; justo
+// después del arranque puede valer 0, en cuyo caso se usa el peso estático
+func selectionWeight(effective, static float64) float64 {
+	weight := effective
+	if weight <= 0 {
+		weight = static
+	}
+	return math.Max(minSelectionWeight, weight)
+}
+
+// Weighted Least Connections con el peso estático configurado: no depende
+// del temporizador del controlador adaptativo
+type WeightedLeastConnections struct{}
+
+func (wlc *WeightedLeastConnections) SelectServer(servers []*ServerState, clientIP string) *ServerState {
+	var selected *ServerState
+	minScore := math.MaxFloat64
+	selectedWeight := 0.0
+
+	for _, server := range servers {
+		weight := server.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		score := float64(atomic.LoadInt64(&server.ConnectionPool.ActiveConns)) / weight
+
+		// En empate gana el de mayor peso
+		if score < minScore || (score == minScore && weight > selectedWeight) {
+			minScore = score
+			selectedWeight = weight
+			selected = server
+		}
+	}
+
+	return selected
+}
+
+func (wlc *WeightedLeastConnections) UpdateWeights(servers []*ServerState) {
+	// Usa el peso estático de la configuración
+}
+
+// Weighted Random: cada servidor se elige con probabilidad proporcional a su
+// peso efectivo. No guarda estado entre requests, por lo que es el más barato
+// para servicios stateless donde el orden estricto del round robin no importa.
+type WeightedRandom struct{}
+
+func (wr *WeightedRandom) SelectServer(servers []*ServerState, clientIP string) *ServerState {
+	candidates := make([]*ServerState, 0, len(servers))
+	cumulative := make([]float64, 0, len(servers))
+	total := 0.0
+
+	for _, server := range servers {
+		// La lista se filtró con el lock de lectura: otra request pudo ocupar
+		// la última conexión libre desde entonces
+		if atomic.LoadInt64(&server.ConnectionPool.ActiveConns) >= int64(server.ConnectionPool.MaxConnections) {
+			continue
+		}
+		total += selectionWeight(server.EffectiveWeight, server.Weight)
+		candidates = append(candidates, server)
+		cumulative = append(cumulative, total)
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Primer servidor cuyo peso acumulado supera el punto elegido
+	target := rand.Float64() * total
+	idx := sort.Search(len(cumulative), func(i int) bool {
+		return cumulative[i] > target
+	})
+	if idx == len(candidates) {
+		idx--
+	}
+	return candidates[idx]
+}
+
+func (wr *WeightedRandom) UpdateWeights(servers []*ServerState) {}
+
 // Least Response Time con predicción exponencial
 type LeastResponseTime struct{}
 
@@ -173,6 +288,62 @@ func (lrt *LeastResponseTime) SelectServer(servers []*ServerState, clientIP stri
 
 func (lrt *LeastResponseTime) UpdateWeights(servers []*ServerState) {}
 
+// peakEWMADecay es la constante de tiempo con la que el coste de peak_ewma
+// olvida un pico de latencia
+const peakEWMADecay = 10 * time.Second
+
+// PeakEWMA (Finagle, Envoy): el coste de cada servidor salta al instante a
+// cualquier pico de latencia y solo baja con el tiempo, de modo que un servidor
+// que acaba de tener un pico se evita aunque su media parezca buena. Se
+// multiplica por las requests en curso para repartir también por carga.
+type PeakEWMA struct {
+	clock Clock
+}
+
+func (pe *PeakEWMA) setClock(clock Clock) { pe.clock = clock }
+
+func (pe *PeakEWMA) SelectServer(servers []*ServerState, clientIP string) *ServerState {
+	now := clockNow(pe.clock)
+	var selected *ServerState
+	bestScore := math.MaxFloat64
+
+	for _, server := range servers {
+		activeConns := atomic.LoadInt64(&server.ConnectionPool.ActiveConns)
+		score := float64(server.Metrics.peakEWMACost(now)) * float64(activeConns+1)
+		if score < bestScore {
+			bestScore = score
+			selected = server
+		}
+	}
+
+	return selected
+}
+
+func (pe *PeakEWMA) UpdateWeights(servers []*ServerState) {}
+
+// observePeakEWMA incorpora una muestra: una latencia mayor que el coste lo
+// sustituye entera; una menor se promedia con más peso cuanto más tiempo ha
+// pasado desde la última
+func (m *ServerMetrics) observePeakEWMA(rtt time.Duration, now time.Time) {
+	if rtt > m.PeakEWMA || m.PeakEWMAStamp.IsZero() {
+		m.PeakEWMA = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(m.PeakEWMAStamp)) / float64(peakEWMADecay))
+		m.PeakEWMA = time.Duration(float64(m.PeakEWMA)*w + float64(rtt)*(1-w))
+	}
+	m.PeakEWMAStamp = now
+}
+
+// peakEWMACost devuelve el coste decaído hasta now; sin muestras usa el mismo
+// valor optimista que LeastResponseTime
+func (m *ServerMetrics) peakEWMACost(now time.Time) time.Duration {
+	if m.PeakEWMAStamp.IsZero() {
+		return 50 * time.Millisecond
+	}
+	w := math.Exp(-float64(now.Sub(m.PeakEWMAStamp)) / float64(peakEWMADecay))
+	return time.Duration(float64(m.PeakEWMA) * w)
+}
+
 // Consistent Hash con virtual nodes y failover
 type ConsistentHash struct {
 	ring *ConsistentHashRing
@@ -261,8 +432,11 @@ func (p2c *PowerOfTwoChoices) UpdateWeights(servers []*ServerState) {}
 type WeightedFairQueue struct {
 	virtualTime map[string]float64
 	lastUpdate  time.Time
+	clock       Clock
 }
 
+func (wfq *WeightedFairQueue) setClock(clock Clock) { wfq.clock = clock }
+
 func (wfq *WeightedFairQueue) SelectServer(servers []*ServerState, clientIP string) *ServerState {
 	if len(servers) == 0 {
 		return nil
@@ -272,7 +446,7 @@ func (wfq *WeightedFairQueue) SelectServer(servers []*ServerState, clientIP stri
 		wfq.virtualTime = make(map[string]float64)
 	}
 
-	now := time.Now()
+	now := clockNow(wfq.clock)
 	if now.Sub(wfq.lastUpdate) > time.Second {
 		wfq.updateVirtualTimes(servers)
 		wfq.lastUpdate = now
@@ -324,10 +498,12 @@ func (wfq *WeightedFairQueue) UpdateWeights(servers []*ServerState) {}
 
 // Consistent Hash Ring implementation
 type ConsistentHashRing struct {
+	mu           sync.RWMutex
 	ring         map[uint32]string
 	sortedHashes []uint32
 	virtualNodes int
 	servers      map[string]*ServerState
+	weights      map[string]int
 }
 
 func NewConsistentHashRing(virtualNodes int) *ConsistentHashRing {
@@ -335,20 +511,70 @@ func NewConsistentHashRing(virtualNodes int) *ConsistentHashRing {
 		ring:         make(map[uint32]string),
 		virtualNodes: virtualNodes,
 		servers:      make(map[string]*ServerState),
+		weights:      make(map[string]int),
 	}
 }
 
+// UpdateServers reconstruye el ring solo cuando cambia el conjunto de servidores
 func (chr *ConsistentHashRing) UpdateServers(servers []*ServerState) {
+	chr.mu.RLock()
+	changed := chr.hasChanged(servers)
+	chr.mu.RUnlock()
+
+	if !changed {
+		return
+	}
+
+	chr.mu.Lock()
+	defer chr.mu.Unlock()
+
+	// Otro goroutine pudo reconstruirlo mientras esperábamos el lock
+	if !chr.hasChanged(servers) {
+		return
+	}
+	chr.rebuild(servers)
+}
+
+func (chr *ConsistentHashRing) hasChanged(servers []*ServerState) bool {
+	if len(servers) != len(chr.servers) {
+		return true
+	}
+	for _, server := range servers {
+		if chr.servers[server.Server.URL] != server || chr.weights[server.Server.URL] != server.Server.Weight {
+			return true
+		}
+	}
+	return false
+}
+
+// nodesFor reparte el ring en proporción al peso; un peso menor que 1 cuenta
+// como 1 para que ningún servidor desaparezca del ring
+func (chr *ConsistentHashRing) nodesFor(server *ServerState) int {
+	weight := server.Server.Weight
+	if weight < 1 {
+		weight = 1
+	}
+	return chr.virtualNodes * weight
+}
+
+func (chr *ConsistentHashRing) rebuild(servers []*ServerState) {
+	totalNodes := 0
+	for _, server := range servers {
+		totalNodes += chr.nodesFor(server)
+	}
+
 	// Limpiar ring
-	chr.ring = make(map[uint32]string)
-	chr.servers = make(map[string]*ServerState)
-	chr.sortedHashes = nil
+	chr.ring = make(map[uint32]string, totalNodes)
+	chr.servers = make(map[string]*ServerState, len(servers))
+	chr.weights = make(map[string]int, len(servers))
+	chr.sortedHashes = make([]uint32, 0, totalNodes)
 
-	// Agregar servidores con virtual nodes
+	// Agregar servidores con virtual nodes proporcionales a su peso
 	for _, server := range servers {
 		chr.servers[server.Server.URL] = server
-		
-		for i := 0; i < chr.virtualNodes; i++ {
+		chr.weights[server.Server.URL] = server.Server.Weight
+
+		for i := 0; i < chr.nodesFor(server); i++ {
 			virtualKey := fmt.Sprintf("%s:%d", server.Server.URL, i)
 			hash := chr.hash(virtualKey)
 			chr.ring[hash] = server.Server.URL
@@ -362,6 +588,9 @@ func (chr *ConsistentHashRing) UpdateServers(servers []*ServerState) {
 }
 
 func (chr *ConsistentHashRing) GetServer(key string) *ServerState {
+	chr.mu.RLock()
+	defer chr.mu.RUnlock()
+
 	if len(chr.sortedHashes) == 0 {
 		return nil
 	}
@@ -382,9 +611,19 @@ func (chr *ConsistentHashRing) GetServer(key string) *ServerState {
 	return chr.servers[serverURL]
 }
 
+// hash usa FNV-1a sobre la clave completa con un finalizador de avalancha
+// (fmix32 de MurmurHash3) para repartir claves similares por todo el ring.
 func (chr *ConsistentHashRing) hash(key string) uint32 {
-	h := md5.Sum([]byte(key))
-	return uint32(h[0])<<24 | uint32(h[1])<<16 | uint32(h[2])<<8 | uint32(h[3])
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	sum := h.Sum32()
+
+	sum ^= sum >> 16
+	sum *= 0x85ebca6b
+	sum ^= sum >> 13
+	sum *= 0xc2b2ae35
+	sum ^= sum >> 16
+	return sum
 }
 
 // Ring Buffer para métricas de response time
@@ -406,10 +645,48 @@ func (rb *RingBuffer) Add(value time.Duration) {
 	}
 }
 
+// Reset descarta todas las muestras
+func (rb *RingBuffer) Reset() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.index = 0
+	rb.full = false
+}
+
 func (rb *RingBuffer) GetAll() []time.Duration {
 	rb.mu.RLock()
 	defer rb.mu.RUnlock()
-	
+	return rb.samples()
+}
+
+// Resize cambia la capacidad conservando las muestras más recientes
+func (rb *RingBuffer) Resize(size int) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if size == rb.size {
+		return
+	}
+
+	samples := rb.samples()
+	if len(samples) > size {
+		samples = samples[len(samples)-size:]
+	}
+	rb.buffer = make([]time.Duration, size)
+	copy(rb.buffer, samples)
+	rb.size = size
+	rb.index = len(samples) % size
+	rb.full = len(samples) == size
+}
+
+// MemoryBytes devuelve lo que ocupan las muestras (un int64 cada una)
+func (rb *RingBuffer) MemoryBytes() int64 {
+	rb.mu.RLock()
+	defer rb.mu.RUnlock()
+	return int64(cap(rb.buffer)) * 8
+}
+
+// samples devuelve las muestras de la más antigua a la más reciente; requiere rb.mu
+func (rb *RingBuffer) samples() []time.Duration {
 	if !rb.full {
 		result := make([]time.Duration, rb.index)
 		copy(result, rb.buffer[:rb.index])
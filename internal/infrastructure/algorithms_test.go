@@ -0,0 +1,392 @@
+package infrastructure
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func newTestServerStates(count int) []*ServerState {
+	states := make([]*ServerState, 0, count)
+	for i := 0; i < count; i++ {
+		states = append(states, &ServerState{
+			Server:          &domain.Server{URL: fmt.Sprintf("http://10.0.0.%d:8080", i+1), Weight: 1, Active: true},
+			Metrics:         &ServerMetrics{ResponseTimes: NewRingBuffer(10)},
+			CircuitBreaker:  &CircuitBreaker{State: CircuitClosed},
+			ConnectionPool:  &ConnectionPool{MaxConnections: 100},
+			Weight:          1,
+			EffectiveWeight: 1,
+		})
+	}
+	return states
+}
+
+func TestConsistentHashRing_Distribution(t *testing.T) {
+	ring := NewConsistentHashRing(150)
+	servers := newTestServerStates(5)
+	ring.UpdateServers(servers)
+
+	counts := make(map[string]int)
+	keys := 100000
+	for i := 0; i < keys; i++ {
+		server := ring.GetServer(fmt.Sprintf("192.168.%d.%d", i/256, i%256))
+		if server == nil {
+			t.Fatal("expected a server for every key")
+		}
+		counts[server.Server.URL]++
+	}
+
+	if len(counts) != len(servers) {
+		t.Fatalf("expected keys on %d servers, got %d", len(servers), len(counts))
+	}
+
+	mean := float64(keys) / float64(len(servers))
+	variance := 0.0
+	for _, count := range counts {
+		variance += (float64(count) - mean) * (float64(count) - mean)
+	}
+	variance /= float64(len(servers))
+	cv := math.Sqrt(variance) / mean
+
+	if cv > 0.1 {
+		t.Errorf("expected coefficient of variation below 0.1, got %.4f (%v)", cv, counts)
+	}
+}
+
+func TestConsistentHashRing_StableMapping(t *testing.T) {
+	ring := NewConsistentHashRing(150)
+	ring.UpdateServers(newTestServerStates(3))
+
+	first := ring.GetServer("192.168.1.10")
+	second := ring.GetServer("192.168.1.10")
+	if first == nil || first != second {
+		t.Error("expected the same key to map to the same server")
+	}
+}
+
+func TestConsistentHashRing_RebuildsOnlyOnChange(t *testing.T) {
+	ring := NewConsistentHashRing(10)
+	servers := newTestServerStates(3)
+
+	ring.UpdateServers(servers)
+	hashes := ring.sortedHashes
+
+	ring.UpdateServers(servers)
+	if &ring.sortedHashes[0] != &hashes[0] {
+		t.Error("expected ring to be reused when servers are unchanged")
+	}
+
+	ring.UpdateServers(servers[:2])
+	if len(ring.sortedHashes) != 20 {
+		t.Errorf("expected 20 virtual nodes after removal, got %d", len(ring.sortedHashes))
+	}
+	if _, exists := ring.servers[servers[2].Server.URL]; exists {
+		t.Error("expected removed server to leave the ring")
+	}
+}
+
+func TestConsistentHashRing_WeightedDistribution(t *testing.T) {
+	ring := NewConsistentHashRing(150)
+	servers := newTestServerStates(3)
+	servers[0].Server.Weight = 1
+	servers[1].Server.Weight = 2
+	servers[2].Server.Weight = 0 // Peso inválido: mantiene la presencia mínima
+	ring.UpdateServers(servers)
+
+	counts := make(map[string]int)
+	keys := 100000
+	for i := 0; i < keys; i++ {
+		counts[ring.GetServer(fmt.Sprintf("192.168.%d.%d", i/256, i%256)).Server.URL]++
+	}
+
+	// Pesos efectivos 1:2:1 -> 25%, 50%, 25%
+	expected := []float64{0.25, 0.5, 0.25}
+	for i, server := range servers {
+		share := float64(counts[server.Server.URL]) / float64(keys)
+		if math.Abs(share-expected[i]) > 0.05 {
+			t.Errorf("server %s: expected ~%.2f of keys, got %.3f (%v)", server.Server.URL, expected[i], share, counts)
+		}
+	}
+
+	// Un cambio de peso reconstruye el ring aunque el conjunto de servidores sea el mismo
+	servers[1].Server.Weight = 1
+	ring.UpdateServers(servers)
+	if len(ring.sortedHashes) != 3*150 {
+		t.Errorf("expected ring rebuilt with %d virtual nodes, got %d", 3*150, len(ring.sortedHashes))
+	}
+}
+
+func BenchmarkConsistentHash_SelectServer(b *testing.B) {
+	servers := newTestServerStates(10)
+	algorithm := &ConsistentHash{ring: NewConsistentHashRing(150)}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		algorithm.SelectServer(servers, "192.168.1.10")
+	}
+}
+
+func TestAdaptiveWeightedRoundRobin_Fairness(t *testing.T) {
+	wrr := &AdaptiveWeightedRoundRobin{}
+	servers := newTestServerStates(3)
+	for i, server := range servers {
+		server.Weight = float64(i + 1)
+	}
+
+	counts := make(map[*ServerState]int)
+	selections := 6000
+	for i := 0; i < selections; i++ {
+		counts[wrr.SelectServer(servers, "")]++
+	}
+
+	for _, server := range servers {
+		expected := float64(selections) * server.Weight / 6
+		if math.Abs(float64(counts[server])-expected) > expected*0.02 {
+			t.Errorf("%s: expected ~%.0f selections, got %d", server.Server.URL, expected, counts[server])
+		}
+	}
+}
+
+func TestAdaptiveWeightedRoundRobin_AlternatingSubsets(t *testing.T) {
+	wrr := &AdaptiveWeightedRoundRobin{}
+	servers := newTestServerStates(3)
+	for i, server := range servers {
+		server.Weight = float64(i + 1)
+	}
+	wrr.UpdateWeights(servers)
+
+	// Los candidatos cambian en cada request (reintentos, header_match...):
+	// cada servidor debe recibir su parte de las selecciones en que compite
+	subsets := [][]*ServerState{servers[:2], servers, servers[1:], {servers[0], servers[2]}}
+	expected := make(map[*ServerState]float64)
+	counts := make(map[*ServerState]int)
+	subsetCounts := make([]map[*ServerState]int, len(subsets))
+	for i := range subsetCounts {
+		subsetCounts[i] = make(map[*ServerState]int)
+	}
+	rounds := 3000
+	for i := 0; i < rounds; i++ {
+		for j, subset := range subsets {
+			total := 0.0
+			for _, server := range subset {
+				total += server.Weight
+			}
+			for _, server := range subset {
+				expected[server] += server.Weight / total
+			}
+			selected := wrr.SelectServer(subset, "")
+			counts[selected]++
+			subsetCounts[j][selected]++
+		}
+	}
+
+	for _, server := range servers {
+		if math.Abs(float64(counts[server])-expected[server]) > expected[server]*0.01 {
+			t.Errorf("%s: expected ~%.0f selections, got %d", server.Server.URL, expected[server], counts[server])
+		}
+	}
+	for j, subset := range subsets {
+		for _, server := range subset {
+			if subsetCounts[j][server] == 0 {
+				t.Errorf("subset %d: expected %s to be selected", j, server.Server.URL)
+			}
+		}
+	}
+}
+
+func TestAdaptiveWeightedRoundRobin_NewServerStartsFromZero(t *testing.T) {
+	wrr := &AdaptiveWeightedRoundRobin{}
+	servers := newTestServerStates(3)
+	for i, server := range servers {
+		server.Weight = float64(i + 1)
+	}
+
+	wrr.UpdateWeights(servers)
+
+	// Acumuladores a mitad de ciclo; añadir un servidor no reinicia al resto
+	for i := 0; i < 4; i++ {
+		wrr.SelectServer(servers[:2], "")
+	}
+	before := wrr.current[servers[0].Server.URL]
+	if before == 0 {
+		t.Fatal("expected a mid-cycle accumulator")
+	}
+	selected := wrr.SelectServer(servers, "")
+	after := before + servers[0].EffectiveWeight/6
+	if selected == servers[0] {
+		after--
+	}
+	if got := wrr.current[servers[0].Server.URL]; math.Abs(got-after) > 1e-9 {
+		t.Errorf("expected existing accumulator to carry over to %f, got %f", after, got)
+	}
+	if _, ok := wrr.current[servers[2].Server.URL]; !ok {
+		t.Fatal("expected new server to get an accumulator")
+	}
+
+	counts := make(map[*ServerState]int)
+	selections := 6000
+	for i := 0; i < selections; i++ {
+		counts[wrr.SelectServer(servers, "")]++
+	}
+	for _, server := range servers {
+		expected := float64(selections) * server.Weight / 6
+		if math.Abs(float64(counts[server])-expected) > expected*0.02 {
+			t.Errorf("%s: expected ~%.0f selections, got %d", server.Server.URL, expected, counts[server])
+		}
+	}
+
+	wrr.forgetServer(servers[2].Server.URL)
+	if _, ok := wrr.current[servers[2].Server.URL]; ok {
+		t.Error("expected removed server accumulator to be dropped")
+	}
+}
+
+func TestEnterpriseBalancer_DropsWRRStateOfRemovedServers(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{Name: "api", BalanceMode: "adaptive_weighted"}
+	servers := []domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true},
+		{URL: "http://localhost:3002", Weight: 1, Active: true},
+	}
+	balancer.UpdateServers(servers, backend)
+	for i := 0; i < 4; i++ {
+		balancer.SelectServer(backend, "10.0.0.1")
+	}
+
+	balancer.UpdateServers(servers[:1], backend)
+	wrr := balancer.algorithms["adaptive_weighted"].(*AdaptiveWeightedRoundRobin)
+	if _, ok := wrr.current["http://localhost:3002"]; ok {
+		t.Error("expected accumulator of removed server to be dropped")
+	}
+}
+
+func TestWeightedLeastConnections_ProportionalToWeight(t *testing.T) {
+	wlc := &WeightedLeastConnections{}
+	servers := newTestServerStates(3)
+	weights := []float64{1, 3, 6}
+	for i, server := range servers {
+		server.Weight = weights[i]
+		// El peso efectivo obsoleto no debe influir
+		server.EffectiveWeight = 0
+	}
+
+	// Las conexiones no se liberan: el reparto debe seguir los pesos estáticos
+	counts := make(map[*ServerState]int)
+	selections := 1000
+	for i := 0; i < selections; i++ {
+		selected := wlc.SelectServer(servers, "")
+		selected.ConnectionPool.ActiveConns++
+		counts[selected]++
+	}
+
+	for _, server := range servers {
+		expected := float64(selections) * server.Weight / 10
+		if math.Abs(float64(counts[server])-expected) > 1 {
+			t.Errorf("%s: expected ~%.0f selections, got %d", server.Server.URL, expected, counts[server])
+		}
+	}
+}
+
+func TestWeightedRandom_ProportionalToEffectiveWeight(t *testing.T) {
+	wr := &WeightedRandom{}
+	servers := newTestServerStates(3)
+	weights := []float64{1, 3, 6}
+	for i, server := range servers {
+		server.EffectiveWeight = weights[i]
+	}
+
+	counts := make(map[*ServerState]int)
+	selections := 20000
+	for i := 0; i < selections; i++ {
+		counts[wr.SelectServer(servers, "")]++
+	}
+
+	// Tolerancia amplia: la selección es aleatoria
+	for _, server := range servers {
+		expected := float64(selections) * server.EffectiveWeight / 10
+		if math.Abs(float64(counts[server])-expected) > expected*0.1 {
+			t.Errorf("%s: expected ~%.0f selections, got %d", server.Server.URL, expected, counts[server])
+		}
+	}
+}
+
+func TestWeightedRandom_SkipsSaturatedServers(t *testing.T) {
+	wr := &WeightedRandom{}
+	servers := newTestServerStates(2)
+	servers[0].EffectiveWeight = 100
+	servers[0].ConnectionPool.ActiveConns = int64(servers[0].ConnectionPool.MaxConnections)
+
+	for i := 0; i < 100; i++ {
+		if selected := wr.SelectServer(servers, ""); selected != servers[1] {
+			t.Fatalf("expected only the free server, got %v", selected.Server.URL)
+		}
+	}
+
+	servers[1].ConnectionPool.ActiveConns = int64(servers[1].ConnectionPool.MaxConnections)
+	if selected := wr.SelectServer(servers, ""); selected != nil {
+		t.Errorf("expected nil with every server saturated, got %v", selected.Server.URL)
+	}
+}
+
+func TestLeastConnections_ZeroEffectiveWeight(t *testing.T) {
+	lc := &LeastConnections{}
+	servers := newTestServerStates(2)
+	for _, server := range servers {
+		server.EffectiveWeight = 0
+	}
+	servers[0].ConnectionPool.ActiveConns = 5
+
+	selected := lc.SelectServer(servers, "")
+	if selected != servers[1] {
+		t.Fatalf("expected server with fewer connections, got %v", selected)
+	}
+
+	// 0/0 no debe dejar la selección sin servidor
+	servers[0].ConnectionPool.ActiveConns = 0
+	for _, server := range servers {
+		server.Weight = 0
+	}
+	if selected := lc.SelectServer(servers, ""); selected == nil {
+		t.Error("expected a server when every weight is zero")
+	}
+}
+
+func TestPeakEWMA_AvoidsRecentLatencySpike(t *testing.T) {
+	servers := newTestServerStates(2)
+	now := time.Now()
+
+	// El servidor 0 es más rápido de media pero acaba de tener un pico
+	for i := 0; i < 20; i++ {
+		servers[0].Metrics.observePeakEWMA(10*time.Millisecond, now.Add(time.Duration(i-20)*time.Second))
+		servers[1].Metrics.observePeakEWMA(40*time.Millisecond, now.Add(time.Duration(i-20)*time.Second))
+	}
+	servers[0].Metrics.observePeakEWMA(400*time.Millisecond, now)
+
+	algorithm := &PeakEWMA{}
+	if selected := algorithm.SelectServer(servers, "10.0.0.100"); selected != servers[1] {
+		t.Fatalf("expected the server without a spike, got %s", selected.Server.URL)
+	}
+
+	// Pasado el pico, el coste decae y el servidor vuelve a elegirse
+	servers[0].Metrics.PeakEWMAStamp = now.Add(-30 * time.Second)
+	servers[1].Metrics.PeakEWMAStamp = now
+	if selected := algorithm.SelectServer(servers, "10.0.0.100"); selected != servers[0] {
+		t.Fatalf("expected the spiked server once the peak decays, got %s", selected.Server.URL)
+	}
+}
+
+func TestPeakEWMA_PenalizesInFlightRequests(t *testing.T) {
+	servers := newTestServerStates(2)
+	now := time.Now()
+	servers[0].Metrics.observePeakEWMA(20*time.Millisecond, now)
+	servers[1].Metrics.observePeakEWMA(30*time.Millisecond, now)
+
+	// 20ms * 3 requests en curso pesa más que 30ms * 1
+	servers[0].ConnectionPool.ActiveConns = 2
+	if selected := (&PeakEWMA{}).SelectServer(servers, "10.0.0.100"); selected != servers[1] {
+		t.Errorf("expected the less loaded server, got %s", selected.Server.URL)
+	}
+}
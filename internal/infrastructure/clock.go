@@ -0,0 +1,44 @@
+package infrastructure
+
+import "time"
+
+// Clock abstrae la hora del balanceador y sus algoritmos para que los tests
+// avancen el tiempo (throttles, ventanas de evaluación, warmup) sin esperas
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock es el reloj real, el que se usa por defecto
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// clockAware lo implementan los algoritmos que miden tiempo
+type clockAware interface {
+	setClock(clock Clock)
+}
+
+// clockNow devuelve la hora de clock, o la del sistema si no se inyectó
+// ninguno (algoritmos creados directamente, p. ej. en tests)
+func clockNow(clock Clock) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return clock.Now()
+}
+
+// SetClock sustituye el reloj del balanceador, del controlador adaptativo y
+// de los algoritmos. Se llama antes de empezar a servir tráfico.
+func (eb *EnterpriseBalancer) SetClock(clock Clock) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.clock = clock
+	eb.adaptiveController.mu.Lock()
+	eb.adaptiveController.clock = clock
+	eb.adaptiveController.mu.Unlock()
+	for _, algorithm := range eb.algorithms {
+		if aware, ok := algorithm.(clockAware); ok {
+			aware.setClock(clock)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package infrastructure
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// fakeClock solo avanza cuando el test lo pide
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestAdaptiveWeightedRoundRobin_UpdateWeightsThrottle(t *testing.T) {
+	clock := newFakeClock()
+	awrr := &AdaptiveWeightedRoundRobin{}
+	awrr.setClock(clock)
+	servers := newTestServerStates(1)
+
+	awrr.UpdateWeights(servers)
+	initial := servers[0].EffectiveWeight
+
+	// Dentro de los 5s del throttle los cambios de métricas no se aplican
+	servers[0].Metrics.ErrorRate = 0.4
+	clock.Advance(4 * time.Second)
+	awrr.UpdateWeights(servers)
+	if servers[0].EffectiveWeight != initial {
+		t.Fatalf("expected weights throttled, got %v -> %v", initial, servers[0].EffectiveWeight)
+	}
+
+	clock.Advance(time.Second)
+	awrr.UpdateWeights(servers)
+	if servers[0].EffectiveWeight >= initial {
+		t.Errorf("expected error rate to lower the weight after 5s, got %v -> %v", initial, servers[0].EffectiveWeight)
+	}
+}
+
+func TestEnterpriseBalancer_EvaluationWindowUsesClock(t *testing.T) {
+	clock := newFakeClock()
+	balancer := NewEnterpriseBalancer()
+	balancer.SetClock(clock)
+	backend := &domain.Backend{
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	controller := balancer.adaptiveController
+	controller.performanceHistory["least_connections"] = NewPerformanceWindow(10)
+	controller.performanceHistory["least_connections"].Add(0.95, clock.Now())
+	controller.lastEvaluation = clock.Now()
+
+	// El algoritmo activo sirve con errores constantes
+	for _, state := range balancer.servers {
+		state.Metrics.RequestCount = 100
+		state.Metrics.SuccessCount = 20
+		state.Metrics.FailureCount = 80
+		state.Metrics.TotalLatency = int64(100 * 900 * time.Millisecond)
+	}
+
+	current := func() Algorithm {
+		balancer.mu.RLock()
+		defer balancer.mu.RUnlock()
+		return balancer.selectOptimalAlgorithm()
+	}
+
+	clock.Advance(29 * time.Second)
+	if _, ok := current().(*AdaptiveWeightedRoundRobin); !ok {
+		t.Fatal("expected no evaluation before the 30s window ends")
+	}
+
+	clock.Advance(2 * time.Second)
+	if _, ok := current().(*LeastConnections); !ok {
+		t.Errorf("expected switch to least_connections once the window ends, got %s", balancer.currentAlgorithm)
+	}
+	if !controller.lastEvaluation.Equal(clock.Now()) {
+		t.Errorf("expected evaluation stamped with the injected clock, got %v", controller.lastEvaluation)
+	}
+}
@@ -1,7 +1,7 @@
 package infrastructure
 
 import (
-	"fmt"
+	"log/slog"
 	"net/http"
 )
 
@@ -11,7 +11,7 @@ func (api *ConfigAPI) handleScaleUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// scale up logic here...
-	fmt.Println("handleScaleUp: scale up", r)
+	slog.Info("Scale up action received", "remote_addr", r.RemoteAddr)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"scaled_up"}`))
@@ -24,7 +24,7 @@ func (api *ConfigAPI) handleScaleDown(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// down scale logic here...
-	fmt.Println("handleScaleDown: scale down", r)
+	slog.Info("Scale down action received", "remote_addr", r.RemoteAddr)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"scaled_down"}`))
 }
@@ -35,7 +35,7 @@ func (api *ConfigAPI) handleMorningScale(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	// morning scale logic here...
-	fmt.Println("handleMorningScale: scale morning", r)
+	slog.Info("Morning scale action received", "remote_addr", r.RemoteAddr)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"morning_scaled"}`))
@@ -48,7 +48,7 @@ func (api *ConfigAPI) handleEveningScale(w http.ResponseWriter, r *http.Request)
 	}
 
 	// evening scaled logic here...
-	fmt.Println("handleEveningScale: scale evening", r)
+	slog.Info("Evening scale action received", "remote_addr", r.RemoteAddr)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"evening_scaled"}`))
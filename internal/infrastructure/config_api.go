@@ -2,7 +2,13 @@ package infrastructure
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"reflect"
+	"slices"
+	"time"
 
 	"github.com/juanbautista0/go-proxy/internal/domain"
 )
@@ -10,32 +16,51 @@ import (
 type ConfigAPI struct {
 	configManager   *ConfigManager
 	loadBalancer    *EnterpriseBalancer
+	healthChecker   *AdvancedHealthChecker
+	proxyMetrics    domain.MetricsResetter
+	// Coordina drenado y borrado de la config (POST /servers/decommission)
+	decommissioner *ServerDecommissioner
+	// Perfiles de /debug/pprof/, solo con debug.pprof_enabled y admin key
+	pprof http.Handler
+	// Métricas sintéticas del SmartTrigger, solo con debug.trigger_simulation
+	simulator domain.MetricsSimulator
 }
 
 func NewConfigAPI(configManager *ConfigManager) *ConfigAPI {
-	return &ConfigAPI{configManager: configManager}
+	return &ConfigAPI{configManager: configManager, pprof: newPprofHandler()}
 }
 
 func (api *ConfigAPI) SetLoadBalancer(lb *EnterpriseBalancer) {
 	api.loadBalancer = lb
 	
-	// Configurar callback para remover servidor de config cuando termine el drenado
-	gracefulManager := NewGracefulConfigManager(api.configManager)
-	lb.serverLifecycle.SetCallbacks(
-		func(serverURL string) {
-			// Remover de memoria (ya configurado)
-			lb.mu.Lock()
-			delete(lb.servers, serverURL)
-			lb.mu.Unlock()
-			
-			// Remover de configuración
-			gracefulManager.RemoveServerFromConfig(serverURL)
-		},
-		nil,
-	)
+	// Al terminar el drenado el servidor se quita también de la configuración
+	api.decommissioner = NewServerDecommissioner(lb, NewGracefulConfigManager(api.configManager))
+}
+
+func (api *ConfigAPI) SetHealthChecker(hc *AdvancedHealthChecker) {
+	api.healthChecker = hc
+}
+
+// SetProxyMetrics permite que POST /metrics/reset reinicie también las métricas globales
+func (api *ConfigAPI) SetProxyMetrics(metrics domain.MetricsResetter) {
+	api.proxyMetrics = metrics
 }
 
 func (api *ConfigAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Sin bloque cors configurado la API no expone headers CORS (es privilegiada)
+	if config := api.configManager.GetConfig(); config != nil && applyCORS(w, r, config.CORS) {
+		return
+	}
+
+	if isPprofPath(r.URL.Path) {
+		api.servePprof(w, r)
+		return
+	}
+	if r.URL.Path == triggerSimulationPath {
+		api.serveTriggerSimulation(w, r)
+		return
+	}
+
 	switch r.URL.Path {
 	case "/servers":
 		if !api.authenticate(r) {
@@ -43,6 +68,8 @@ func (api *ConfigAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		switch r.Method {
+		case http.MethodGet:
+			api.getServersStatus(w, r)
 		case http.MethodPost:
 			api.addServer(w, r)
 		case http.MethodPut:
@@ -65,6 +92,13 @@ func (api *ConfigAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
+	case "/config/effective":
+		switch r.Method {
+		case http.MethodGet:
+			api.getEffectiveConfig(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
 	case "/security":
 		switch r.Method {
 		case http.MethodGet:
@@ -82,6 +116,17 @@ func (api *ConfigAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
+	case "/maintenance":
+		if !api.authenticate(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			api.updateMaintenance(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
 	case "/actions/scale_up":
 		api.handleScaleUp(w, r)
 	case "/actions/scale_down":
@@ -96,10 +141,72 @@ func (api *ConfigAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case "/api-docs.yaml":
 		swaggerHandler := NewSwaggerHandler()
 		swaggerHandler.ServeHTTP(w, r)
+	case "/servers/drain":
+		if !api.authenticate(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			api.drainServer(w, r)
+		case http.MethodDelete:
+			api.cancelDrain(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/servers/decommission":
+		if !api.authenticate(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			api.decommissionServer(w, r)
+		case http.MethodGet:
+			api.getDecommission(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/servers/circuit":
+		if !api.authenticateAdmin(r) {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			api.forceCircuit(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
 	case "/servers/draining":
 		api.getDrainingServers(w, r)
 	case "/servers/status":
 		api.getServersStatus(w, r)
+	case "/metrics/snapshot", "/metrics/reset":
+		if !api.authenticateAdmin(r) {
+			http.Error(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.URL.Path == "/metrics/snapshot" && r.Method == http.MethodGet:
+			api.getMetricsSnapshot(w, r)
+		case r.URL.Path == "/metrics/reset" && r.Method == http.MethodPost:
+			api.resetMetrics(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case "/health/backends":
+		if !api.authenticate(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		api.getBackendHealth(w, r)
+	case "/balancer/algorithms":
+		if !api.authenticate(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		api.getAlgorithms(w, r)
 	default:
 		http.Error(w, "Not found", http.StatusNotFound)
 	}
@@ -144,20 +251,57 @@ func (api *ConfigAPI) authenticateAdmin(r *http.Request) bool {
 }
 
 func (api *ConfigAPI) getConfig(w http.ResponseWriter, r *http.Request) {
-	config := *api.configManager.GetConfig()
-	
-	// Ocultar API keys por seguridad
-	for i := range config.Security.APIKeys {
-		config.Security.APIKeys[i] = "***"
-	}
-	for i := range config.Security.AdminAPIKeys {
-		config.Security.AdminAPIKeys[i] = "***"
-	}
-	
+	config := maskSecrets(api.configManager.GetConfig())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(config)
+}
+
+// getEffectiveConfig devuelve la configuración con los valores por defecto
+// materializados, es decir, lo que el proxy está usando realmente
+func (api *ConfigAPI) getEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	config := maskSecrets(api.configManager.GetConfig().WithDefaults())
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(config)
 }
 
+// maskSecrets devuelve una copia con las API keys, la contraseña de Redis y
+// el entorno de las acciones ocultos; slices y mapas se copian para no tocar
+// la configuración en uso
+func maskSecrets(config *domain.Config) domain.Config {
+	masked := *config
+	masked.Security.APIKeys = maskAll(config.Security.APIKeys)
+	masked.Security.AdminAPIKeys = maskAll(config.Security.AdminAPIKeys)
+	if p := config.Metrics.Persistence; p != nil && p.Password != "" {
+		persistence := *p
+		persistence.Password = "***"
+		masked.Metrics.Persistence = &persistence
+	}
+	if config.Actions != nil {
+		masked.Actions = make(map[string]domain.ActionConfig, len(config.Actions))
+		for name, action := range config.Actions {
+			if action.Env != nil {
+				env := make(map[string]string, len(action.Env))
+				for key := range action.Env {
+					env[key] = "***"
+				}
+				action.Env = env
+			}
+			masked.Actions[name] = action
+		}
+	}
+	return masked
+}
+
+func maskAll(secrets []string) []string {
+	masked := make([]string, len(secrets))
+	for i := range masked {
+		masked[i] = "***"
+	}
+	return masked
+}
+
 func (api *ConfigAPI) getSecurity(w http.ResponseWriter, r *http.Request) {
 	config := api.configManager.GetConfig()
 	w.Header().Set("Content-Type", "application/json")
@@ -176,7 +320,7 @@ func (api *ConfigAPI) updateSecurity(w http.ResponseWriter, r *http.Request) {
 	config.Security = newSecurity
 
 	if err := api.configManager.Update(&config); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeUpdateError(w, err)
 		return
 	}
 
@@ -194,20 +338,69 @@ func (api *ConfigAPI) updateConfig(w http.ResponseWriter, r *http.Request) {
 	currentConfig := api.configManager.GetConfig()
 	newConfig.Proxy.Port = currentConfig.Proxy.Port
 
+	// Las acciones exec y kubernetes ejecutan comandos o escalan el clúster:
+	// solo un admin puede crearlas o modificarlas por la API
+	if privilegedActionsChanged(currentConfig.Actions, newConfig.Actions) && !api.authenticateAdmin(r) {
+		http.Error(w, "Admin access required to change exec or kubernetes actions", http.StatusForbidden)
+		return
+	}
+	// Igual que en /security: una key regular no puede cambiar las keys, p. ej.
+	// para darse permisos de admin
+	if securityChanged(currentConfig.Security, newConfig.Security) && !api.authenticateAdmin(r) {
+		http.Error(w, "Admin access required to change security", http.StatusForbidden)
+		return
+	}
+
 	if err := api.configManager.Update(&newConfig); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeUpdateError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// privilegedActionsChanged indica si se añade, modifica o elimina alguna
+// acción exec o kubernetes, o si una acción pasa a ser de uno de esos tipos
+func privilegedActionsChanged(current, updated map[string]domain.ActionConfig) bool {
+	for name, action := range updated {
+		old, exists := current[name]
+		if (isPrivilegedAction(action) || (exists && isPrivilegedAction(old))) && (!exists || !reflect.DeepEqual(old, action)) {
+			return true
+		}
+	}
+	for name, old := range current {
+		if _, exists := updated[name]; !exists && isPrivilegedAction(old) {
+			return true
+		}
+	}
+	return false
+}
+
+func securityChanged(current, updated domain.SecurityConfig) bool {
+	return !slices.Equal(current.APIKeys, updated.APIKeys) || !slices.Equal(current.AdminAPIKeys, updated.AdminAPIKeys)
+}
+
+func isPrivilegedAction(action domain.ActionConfig) bool {
+	return action.Type == domain.ActionTypeExec || action.Type == domain.ActionTypeKubernetes
+}
+
+// writeUpdateError responde 400 si la configuración no pasó la validación
+func writeUpdateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrInvalidConfig) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
 type AddServerRequest struct {
 	BackendName           string `json:"backend_name"`
 	URL                   string `json:"url"`
 	Weight                int    `json:"weight"`
 	MaxConnections        int    `json:"max_connections"`
 	HealthCheckEndpoint   string `json:"health_check_endpoint"`
+	Priority              int    `json:"priority"`
+	Labels                map[string]string `json:"labels,omitempty"`
 }
 
 func (api *ConfigAPI) addServer(w http.ResponseWriter, r *http.Request) {
@@ -234,12 +427,14 @@ func (api *ConfigAPI) addServer(w http.ResponseWriter, r *http.Request) {
 				Weight:              req.Weight,
 				MaxConnections:      req.MaxConnections,
 				HealthCheckEndpoint: req.HealthCheckEndpoint,
+				Priority:            req.Priority,
+				Labels:              req.Labels,
 				Active:              true,
 			}
 			config.Backends[i].Servers = append(config.Backends[i].Servers, server)
 			
 			if err := api.configManager.Update(&config); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeUpdateError(w, err)
 				return
 			}
 			
@@ -258,11 +453,18 @@ type UpdateServerRequest struct {
 	Weight                int    `json:"weight"`
 	MaxConnections        int    `json:"max_connections"`
 	HealthCheckEndpoint   string `json:"health_check_endpoint"`
+	Priority              int    `json:"priority"`
+	Labels                map[string]string `json:"labels,omitempty"`
 }
 
 type RemoveServerRequest struct {
 	BackendName string `json:"backend_name"`
 	ServerURL   string `json:"server_url"`
+	// by_url, least_connections, last_added, highest_connections o pattern;
+	// por defecto by_url con server_url y least_connections sin él
+	Strategy string `json:"strategy,omitempty"`
+	Pattern  string `json:"pattern,omitempty"` // glob sobre host:puerto para strategy pattern
+	Label    string `json:"label,omitempty"`   // "clave:valor": solo se eligen servidores con esa label
 }
 
 func (api *ConfigAPI) removeServer(w http.ResponseWriter, r *http.Request) {
@@ -273,48 +475,75 @@ func (api *ConfigAPI) removeServer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	config := *api.configManager.GetConfig()
-	
-	// Buscar backend y validar servidor
+
+	// Buscar backend y elegir los servidores a quitar
 	for i := range config.Backends {
-		if config.Backends[i].Name == req.BackendName {
-			// Validar límite mínimo
-			currentCount := len(config.Backends[i].Servers)
-			if config.Backends[i].MinServers > 0 && currentCount <= config.Backends[i].MinServers {
-				http.Error(w, "Minimum servers limit reached", http.StatusBadRequest)
-				return
-			}
-			
-			for j, server := range config.Backends[i].Servers {
-				if server.URL == req.ServerURL {
-					// Iniciar drenado graceful si hay load balancer
-					if api.loadBalancer != nil {
-						if !api.loadBalancer.GracefulRemoveServer(req.ServerURL) {
-							http.Error(w, "Server not found in load balancer", http.StatusNotFound)
-							return
-						}
-						w.WriteHeader(http.StatusAccepted)
-						w.Write([]byte(`{"status":"draining","message":"Server removal initiated, draining connections"}`))
-						return
-					}
-					
-					// Fallback: remoción inmediata si no hay load balancer
-					config.Backends[i].Servers = append(
-						config.Backends[i].Servers[:j],
-						config.Backends[i].Servers[j+1:]...,
-					)
-					
-					if err := api.configManager.Update(&config); err != nil {
-						http.Error(w, err.Error(), http.StatusInternalServerError)
-						return
-					}
-					
-					w.WriteHeader(http.StatusOK)
+		if config.Backends[i].Name != req.BackendName {
+			continue
+		}
+
+		victims, err := api.pickRemovalVictims(&config.Backends[i], req)
+		switch {
+		case errors.Is(err, errNoServerToRemove):
+			http.Error(w, "Server not found", http.StatusNotFound)
+			return
+		case errors.Is(err, errBelowMinServers):
+			http.Error(w, "Minimum servers limit reached", http.StatusBadRequest)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Iniciar drenado graceful si hay load balancer
+		if api.loadBalancer != nil {
+			for _, victim := range victims {
+				if !api.loadBalancer.HasServer(victim) {
+					http.Error(w, "Server not found in load balancer", http.StatusNotFound)
 					return
 				}
 			}
+			for _, victim := range victims {
+				api.loadBalancer.GracefulRemoveServer(victim)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":  "draining",
+				"message": "Server removal initiated, draining connections",
+				"servers": victims,
+			})
+			return
+		}
+
+		// Fallback: remoción inmediata si no hay load balancer. Se copia la
+		// lista: la config en memoria la comparten los lectores
+		removed := make(map[string]bool, len(victims))
+		for _, victim := range victims {
+			removed[victim] = true
+		}
+		servers := make([]domain.Server, 0, len(config.Backends[i].Servers))
+		for _, server := range config.Backends[i].Servers {
+			if !removed[server.URL] {
+				servers = append(servers, server)
+			}
+		}
+		config.Backends = append([]domain.Backend(nil), config.Backends...)
+		config.Backends[i].Servers = servers
+
+		if err := api.configManager.Update(&config); err != nil {
+			writeUpdateError(w, err)
+			return
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "removed",
+			"servers": victims,
+		})
+		return
 	}
-	
+
 	http.Error(w, "Server not found", http.StatusNotFound)
 }
 
@@ -337,11 +566,13 @@ func (api *ConfigAPI) updateServer(w http.ResponseWriter, r *http.Request) {
 						Weight:              req.Weight,
 						MaxConnections:      req.MaxConnections,
 						HealthCheckEndpoint: req.HealthCheckEndpoint,
+						Priority:            req.Priority,
+						Labels:              req.Labels,
 						Active:              true,
 					}
 					
 					if err := api.configManager.Update(&config); err != nil {
-						http.Error(w, err.Error(), http.StatusInternalServerError)
+						writeUpdateError(w, err)
 						return
 					}
 					
@@ -355,6 +586,47 @@ func (api *ConfigAPI) updateServer(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Server not found", http.StatusNotFound)
 }
 
+type MaintenanceRequest struct {
+	BackendName string `json:"backend_name"`
+	Enabled     bool   `json:"enabled"`
+	StatusCode  int    `json:"status_code"`
+	Body        string `json:"body"`
+	ContentType string `json:"content_type"`
+}
+
+func (api *ConfigAPI) updateMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config := *api.configManager.GetConfig()
+	// Copiar backends para no modificar la configuración en uso
+	config.Backends = append([]domain.Backend(nil), config.Backends...)
+
+	for i := range config.Backends {
+		if config.Backends[i].Name == req.BackendName {
+			config.Backends[i].Maintenance = domain.MaintenanceCfg{
+				Enabled:     req.Enabled,
+				StatusCode:  req.StatusCode,
+				Body:        req.Body,
+				ContentType: req.ContentType,
+			}
+
+			if err := api.configManager.Update(&config); err != nil {
+				writeUpdateError(w, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	http.Error(w, "Backend not found", http.StatusNotFound)
+}
+
 func (api *ConfigAPI) getDrainingServers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -369,7 +641,184 @@ func (api *ConfigAPI) getDrainingServers(w http.ResponseWriter, r *http.Request)
 	
 	drainingServers := api.loadBalancer.GetDrainingServers()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string][]string{"draining_servers": drainingServers})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"draining_servers": drainingServers,
+		"servers":          api.loadBalancer.GetDrainStatus(),
+	})
+}
+
+type DrainRequest struct {
+	ServerURL string `json:"server_url"`
+}
+
+// drainServer saca un servidor de rotación esperando a que terminen sus conexiones
+func (api *ConfigAPI) drainServer(w http.ResponseWriter, r *http.Request) {
+	var req DrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ServerURL == "" {
+		http.Error(w, "server_url is required", http.StatusBadRequest)
+		return
+	}
+	if api.loadBalancer == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+	if api.loadBalancer.IsServerDraining(req.ServerURL) {
+		http.Error(w, "Server is already draining", http.StatusConflict)
+		return
+	}
+	if !api.loadBalancer.GracefulRemoveServer(req.ServerURL) {
+		http.Error(w, "Server not found in load balancer", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "draining",
+		"server_url": req.ServerURL,
+	})
+}
+
+// cancelDrain aborta el drenado y devuelve el servidor a rotación
+func (api *ConfigAPI) cancelDrain(w http.ResponseWriter, r *http.Request) {
+	var req DrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ServerURL == "" {
+		http.Error(w, "server_url is required", http.StatusBadRequest)
+		return
+	}
+	if api.loadBalancer == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+	if !api.loadBalancer.CancelDrain(req.ServerURL) {
+		http.Error(w, "Server is not draining", http.StatusNotFound)
+		return
+	}
+	if api.decommissioner != nil {
+		api.decommissioner.Cancel(req.ServerURL)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "active",
+		"server_url": req.ServerURL,
+	})
+}
+
+// decommissionServer drena el servidor, lo quita de la config y transmite el
+// progreso como server-sent events hasta que termina. Si el cliente se
+// desconecta la operación sigue; GET /servers/decommission permite consultarla
+func (api *ConfigAPI) decommissionServer(w http.ResponseWriter, r *http.Request) {
+	var req DrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ServerURL == "" {
+		http.Error(w, "server_url is required", http.StatusBadRequest)
+		return
+	}
+	if api.decommissioner == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	done, err := api.decommissioner.Start(req.ServerURL)
+	switch {
+	case errors.Is(err, ErrServerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, ErrServerDraining):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		status, _ := api.decommissioner.Status(req.ServerURL)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		status, _ := api.decommissioner.Status(req.ServerURL)
+		data, _ := json.Marshal(status)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+		if status.Finished() {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// getDecommission devuelve el estado del último decommission de un servidor
+func (api *ConfigAPI) getDecommission(w http.ResponseWriter, r *http.Request) {
+	serverURL := r.URL.Query().Get("server_url")
+	if serverURL == "" {
+		http.Error(w, "server_url is required", http.StatusBadRequest)
+		return
+	}
+	if api.decommissioner == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+	status, ok := api.decommissioner.Status(serverURL)
+	if !ok {
+		http.Error(w, "No decommission for server", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+type CircuitRequest struct {
+	ServerURL string `json:"server_url"`
+	State     string `json:"state"`
+}
+
+// forceCircuit abre, cierra o pasa a half-open el circuito de un servidor
+// durante un incidente, sin tocar la configuración
+func (api *ConfigAPI) forceCircuit(w http.ResponseWriter, r *http.Request) {
+	var req CircuitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ServerURL == "" {
+		http.Error(w, "server_url is required", http.StatusBadRequest)
+		return
+	}
+	circuit, ok := ParseCircuitState(req.State)
+	if !ok {
+		http.Error(w, "state must be open, close or half_open", http.StatusBadRequest)
+		return
+	}
+	if api.loadBalancer == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+	if !api.loadBalancer.ForceCircuitState(req.ServerURL, circuit) {
+		http.Error(w, "Server not found in load balancer", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"circuit_state": circuit.String(),
+		"server_url":    req.ServerURL,
+	})
 }
 
 func (api *ConfigAPI) getServersStatus(w http.ResponseWriter, r *http.Request) {
@@ -378,6 +827,17 @@ func (api *ConfigAPI) getServersStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// ?label=class:spot; con varios filtros el servidor debe cumplirlos todos
+	selector := make(map[string]string)
+	for _, filter := range r.URL.Query()["label"] {
+		name, value, err := domain.ParseLabelSelector(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		selector[name] = value
+	}
+
 	if api.loadBalancer == nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{"servers": map[string]interface{}{}})
@@ -389,9 +849,15 @@ func (api *ConfigAPI) getServersStatus(w http.ResponseWriter, r *http.Request) {
 	
 	status := make(map[string]interface{})
 	for url, server := range serverMetrics {
+		if !server.MatchesLabels(selector) {
+			continue
+		}
 		serverStatus := map[string]interface{}{
 			"active":         server.Active,
 			"healthy":        server.Healthy,
+			"weight":         server.Weight,
+			"effective_weight": server.EffectiveWeight,
+			"max_connections": server.MaxConnections,
 			"connections":    server.CurrentConns,
 			"total_requests": server.TotalRequests,
 			"failed_requests": server.FailedRequests,
@@ -399,6 +865,9 @@ func (api *ConfigAPI) getServersStatus(w http.ResponseWriter, r *http.Request) {
 			"circuit_open":   server.CircuitOpen,
 			"draining":       false,
 		}
+		if len(server.Labels) > 0 {
+			serverStatus["labels"] = server.Labels
+		}
 		
 		for _, drainingURL := range drainingServers {
 			if drainingURL == url {
@@ -412,4 +881,109 @@ func (api *ConfigAPI) getServersStatus(w http.ResponseWriter, r *http.Request) {
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"servers": status})
-}
\ No newline at end of file
+}
+// getBackendHealth devuelve servidores sanos, totales y ratio por backend para
+// alertar cuando un backend pierde capacidad
+func (api *ConfigAPI) getBackendHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var backends map[string]interface{}
+	switch {
+	case api.healthChecker != nil:
+		backends = api.healthChecker.GetHealthMetrics()
+	case api.loadBalancer != nil:
+		backends = api.backendHealthFromStats()
+	default:
+		http.Error(w, "Health information not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timestamp": time.Now(),
+		"backends":  backends,
+	})
+}
+
+// getAlgorithms expone el score y las métricas acumuladas de cada algoritmo
+// para comparar su rendimiento y decidir si fijar uno con balance_mode
+func (api *ConfigAPI) getAlgorithms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if api.loadBalancer == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(api.loadBalancer.GetAlgorithmReport())
+}
+
+// backendHealthFromStats calcula lo mismo que AdvancedHealthChecker.GetHealthMetrics
+// a partir del estado del balanceador
+func (api *ConfigAPI) backendHealthFromStats() map[string]interface{} {
+	serverStats := api.loadBalancer.GetServerMetrics()
+	metrics := make(map[string]interface{})
+
+	for _, backend := range api.configManager.GetConfig().Backends {
+		healthyCount := 0
+		totalCount := 0
+		for _, server := range backend.Servers {
+			stats, exists := serverStats[server.URL]
+			if !exists || !stats.Active {
+				continue
+			}
+			totalCount++
+			if stats.Healthy {
+				healthyCount++
+			}
+		}
+
+		healthRatio := 0.0
+		if totalCount > 0 {
+			healthRatio = float64(healthyCount) / float64(totalCount)
+		}
+		metrics[backend.Name] = map[string]interface{}{
+			"healthy_servers": healthyCount,
+			"total_servers":   totalCount,
+			"health_ratio":    healthRatio,
+		}
+	}
+	return metrics
+}
+
+// getMetricsSnapshot devuelve los contadores acumulados de cada servidor
+func (api *ConfigAPI) getMetricsSnapshot(w http.ResponseWriter, r *http.Request) {
+	if api.loadBalancer == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.loadBalancer.MetricsSnapshot())
+}
+
+// resetMetrics pone a cero los contadores y devuelve sus valores previos, para
+// capturar una prueba de carga sin perder las requests que llegan mientras tanto
+func (api *ConfigAPI) resetMetrics(w http.ResponseWriter, r *http.Request) {
+	if api.loadBalancer == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	snapshot := api.loadBalancer.ResetMetrics()
+	if api.proxyMetrics != nil {
+		api.proxyMetrics.ResetMetrics()
+	}
+	slog.Info("Metrics reset", "servers", len(snapshot.Servers), "remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
@@ -6,7 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/juanbautista0/go-proxy/internal/domain"
 )
@@ -217,6 +220,215 @@ func TestConfigAPI_RemoveServer(t *testing.T) {
 	}
 }
 
+func TestConfigAPI_RemoveServerStrategies(t *testing.T) {
+	servers := []domain.Server{
+		{URL: "http://app-1.internal:3001", Weight: 1, Active: true},
+		{URL: "http://app-2.internal:3001", Weight: 1, Active: true},
+		{URL: "http://10.0.1.5:3001", Weight: 1, Active: true},
+		{URL: "http://10.0.1.6:3001", Weight: 1, Active: true},
+	}
+	conns := map[string]int64{
+		"http://app-1.internal:3001": 5,
+		"http://app-2.internal:3001": 9,
+		"http://10.0.1.5:3001":       1,
+		"http://10.0.1.6:3001":       3,
+	}
+
+	tests := []struct {
+		name     string
+		req      RemoveServerRequest
+		status   int
+		expected []string
+	}{
+		{"default is least loaded", RemoveServerRequest{}, http.StatusAccepted, []string{"http://10.0.1.5:3001"}},
+		{"highest connections", RemoveServerRequest{Strategy: RemoveHighestConnections}, http.StatusAccepted, []string{"http://app-2.internal:3001"}},
+		{"last added", RemoveServerRequest{Strategy: RemoveLastAdded}, http.StatusAccepted, []string{"http://10.0.1.6:3001"}},
+		{"by url", RemoveServerRequest{ServerURL: "http://app-1.internal:3001"}, http.StatusAccepted, []string{"http://app-1.internal:3001"}},
+		{"pattern", RemoveServerRequest{Strategy: RemoveByPattern, Pattern: "10.0.1.*"}, http.StatusAccepted, []string{"http://10.0.1.5:3001", "http://10.0.1.6:3001"}},
+		{"pattern below min_servers", RemoveServerRequest{Strategy: RemoveByPattern, Pattern: "*"}, http.StatusBadRequest, nil},
+		{"pattern without match", RemoveServerRequest{Strategy: RemoveByPattern, Pattern: "*.old.internal"}, http.StatusNotFound, nil},
+		{"pattern missing", RemoveServerRequest{Strategy: RemoveByPattern}, http.StatusBadRequest, nil},
+		{"unknown strategy", RemoveServerRequest{Strategy: "random"}, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, tempFile := setupTestConfigAPI(t)
+			defer os.Remove(tempFile)
+
+			config := *api.configManager.GetConfig()
+			config.Security.APIKeys = []string{"test-key"}
+			config.Backends = []domain.Backend{{Name: "web-servers", MinServers: 2, Servers: servers}}
+			api.configManager.Update(&config)
+
+			balancer := NewEnterpriseBalancer()
+			balancer.UpdateBackends(config.Backends)
+			for url, n := range conns {
+				atomic.StoreInt64(&balancer.servers[url].ConnectionPool.ActiveConns, n)
+			}
+			api.SetLoadBalancer(balancer)
+
+			tt.req.BackendName = "web-servers"
+			body, _ := json.Marshal(tt.req)
+			req := httptest.NewRequest("DELETE", "/servers", bytes.NewBuffer(body))
+			req.Header.Set("X-API-KEY", "test-key")
+			w := httptest.NewRecorder()
+			api.ConfigAPI.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.expected == nil {
+				if draining := balancer.GetDrainingServers(); len(draining) != 0 {
+					t.Errorf("expected no server draining, got %v", draining)
+				}
+				return
+			}
+
+			var resp struct {
+				Servers []string `json:"servers"`
+			}
+			json.NewDecoder(w.Body).Decode(&resp)
+			if strings.Join(resp.Servers, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v removed, got %v", tt.expected, resp.Servers)
+			}
+			for _, url := range tt.expected {
+				if !balancer.IsServerDraining(url) {
+					t.Errorf("expected %s draining", url)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigAPI_ServersByLabel(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Backends = []domain.Backend{{Name: "web-servers", MinServers: 1, Servers: []domain.Server{
+		{URL: "http://10.0.1.5:3001", Weight: 1, Active: true, Labels: map[string]string{"class": "on_demand"}},
+		{URL: "http://10.0.1.6:3001", Weight: 1, Active: true, Labels: map[string]string{"class": "spot", "zone": "a"}},
+		{URL: "http://10.0.1.7:3001", Weight: 1, Active: true, Labels: map[string]string{"class": "spot", "zone": "b"}},
+	}}}
+	api.configManager.Update(&config)
+
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateBackends(config.Backends)
+	api.SetLoadBalancer(balancer)
+
+	list := func(query string) (int, map[string]map[string]interface{}) {
+		req := httptest.NewRequest("GET", "/servers"+query, nil)
+		req.Header.Set("X-API-KEY", "test-key")
+		w := httptest.NewRecorder()
+		api.ConfigAPI.ServeHTTP(w, req)
+
+		var resp struct {
+			Servers map[string]map[string]interface{} `json:"servers"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp.Servers
+	}
+
+	if _, servers := list(""); len(servers) != 3 {
+		t.Errorf("expected 3 servers without filter, got %d", len(servers))
+	}
+	_, servers := list("?label=class:spot")
+	if len(servers) != 2 || servers["http://10.0.1.5:3001"] != nil {
+		t.Errorf("expected the 2 spot servers, got %v", servers)
+	}
+	if labels, _ := servers["http://10.0.1.6:3001"]["labels"].(map[string]interface{}); labels["zone"] != "a" {
+		t.Errorf("expected labels in the response, got %v", servers["http://10.0.1.6:3001"])
+	}
+	if _, servers := list("?label=class:spot&label=zone:b"); len(servers) != 1 || servers["http://10.0.1.7:3001"] == nil {
+		t.Errorf("expected only the spot server in zone b, got %v", servers)
+	}
+	if code, _ := list("?label=class"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a selector without value, got %d", code)
+	}
+
+	// Scale-down dirigido: el menos cargado entre los spot
+	atomic.StoreInt64(&balancer.servers["http://10.0.1.6:3001"].ConnectionPool.ActiveConns, 4)
+	body, _ := json.Marshal(RemoveServerRequest{BackendName: "web-servers", Label: "class:spot"})
+	req := httptest.NewRequest("DELETE", "/servers", bytes.NewBuffer(body))
+	req.Header.Set("X-API-KEY", "test-key")
+	w := httptest.NewRecorder()
+	api.ConfigAPI.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if !balancer.IsServerDraining("http://10.0.1.7:3001") {
+		t.Errorf("expected the idle spot server to drain, draining %v", balancer.GetDrainingServers())
+	}
+}
+
+func TestConfigAPI_UpdateMaintenance(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	api.configManager.Update(&config)
+
+	maintenanceReq := MaintenanceRequest{
+		BackendName: "web-servers",
+		Enabled:     true,
+		StatusCode:  http.StatusServiceUnavailable,
+		Body:        "Back soon",
+	}
+
+	body, _ := json.Marshal(maintenanceReq)
+	req := httptest.NewRequest("PUT", "/maintenance", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-KEY", "test-key")
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	maintenance := api.configManager.GetConfig().Backends[0].Maintenance
+	if !maintenance.Enabled {
+		t.Error("expected maintenance to be enabled")
+	}
+	if maintenance.Body != "Back soon" {
+		t.Errorf("expected body 'Back soon', got %s", maintenance.Body)
+	}
+
+	// Un código fuera de 200-599 haría entrar en pánico a WriteHeader
+	maintenanceReq.StatusCode = 1200
+	body, _ = json.Marshal(maintenanceReq)
+	req = httptest.NewRequest("PUT", "/maintenance", bytes.NewBuffer(body))
+	req.Header.Set("X-API-KEY", "test-key")
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for status code 1200, got %d", w.Code)
+	}
+	if code := api.configManager.GetConfig().Backends[0].Maintenance.StatusCode; code != http.StatusServiceUnavailable {
+		t.Errorf("expected previous status code kept, got %d", code)
+	}
+
+	// Backend inexistente
+	maintenanceReq.StatusCode = http.StatusServiceUnavailable
+	maintenanceReq.BackendName = "nonexistent"
+	body, _ = json.Marshal(maintenanceReq)
+	req = httptest.NewRequest("PUT", "/maintenance", bytes.NewBuffer(body))
+	req.Header.Set("X-API-KEY", "test-key")
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
 func TestConfigAPI_InvalidRequests(t *testing.T) {
 	api, tempFile := setupTestConfigAPI(t)
 	defer os.Remove(tempFile)
@@ -271,4 +483,758 @@ func TestConfigAPI_InvalidRequests(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestConfigAPI_DrainServer(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	api.configManager.Update(&config)
+
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateServers([]domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true},
+	}, &domain.Backend{})
+	atomic.StoreInt64(&balancer.servers["http://localhost:3001"].ConnectionPool.ActiveConns, 3)
+	api.SetLoadBalancer(balancer)
+
+	drain := func(method, key string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(DrainRequest{ServerURL: "http://localhost:3001"})
+		req := httptest.NewRequest(method, "/servers/drain", bytes.NewBuffer(body))
+		if key != "" {
+			req.Header.Set("X-API-KEY", key)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := drain("POST", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without API key, got %d", w.Code)
+	}
+	if w := drain("POST", "test-key"); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 when starting drain, got %d", w.Code)
+	}
+	if w := drain("POST", "test-key"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 when already draining, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/servers/draining", nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+
+	var status struct {
+		Servers []DrainStatus `json:"servers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("failed to decode draining status: %v", err)
+	}
+	if len(status.Servers) != 1 || status.Servers[0].RemainingConnections != 3 {
+		t.Fatalf("expected one draining server with 3 connections, got %+v", status.Servers)
+	}
+	if status.Servers[0].TimeToDeadline == "" || status.Servers[0].Deadline.IsZero() {
+		t.Errorf("expected deadline information, got %+v", status.Servers[0])
+	}
+
+	if w := drain("DELETE", "test-key"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 when cancelling drain, got %d", w.Code)
+	}
+	if balancer.IsServerDraining("http://localhost:3001") {
+		t.Error("expected server back in rotation after cancel")
+	}
+	if w := drain("DELETE", "test-key"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when server is not draining, got %d", w.Code)
+	}
+}
+
+func TestConfigAPI_DecommissionServer(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Backends = []domain.Backend{{
+		Name: "web-servers",
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+	}}
+	api.configManager.Update(&config)
+
+	balancer := NewEnterpriseBalancer()
+	balancer.serverLifecycle.checkInterval = 10 * time.Millisecond
+	balancer.UpdateBackends(config.Backends)
+	api.configManager.AddCallback(func(c *domain.Config) { balancer.UpdateBackends(c.Backends) })
+	atomic.StoreInt64(&balancer.servers["http://localhost:3002"].ConnectionPool.ActiveConns, 1)
+	api.SetLoadBalancer(balancer)
+
+	request := func(method, target string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, target, bytes.NewBuffer(data))
+		req.Header.Set("X-API-KEY", "test-key")
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w
+	}
+	status := func() DecommissionStatus {
+		var status DecommissionStatus
+		json.NewDecoder(request("GET", "/servers/decommission?server_url=http://localhost:3002", nil).Body).Decode(&status)
+		return status
+	}
+
+	stream := make(chan *httptest.ResponseRecorder)
+	go func() {
+		stream <- request("POST", "/servers/decommission", DrainRequest{ServerURL: "http://localhost:3002"})
+	}()
+
+	// Mientras quede una conexión el servidor sigue drenando
+	deadline := time.Now().Add(2 * time.Second)
+	for status().Phase != DecommissionDraining {
+		if time.Now().After(deadline) {
+			t.Fatal("decommission did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := status(); got.RemainingConnections != 1 {
+		t.Errorf("expected 1 remaining connection, got %+v", got)
+	}
+	if w := request("POST", "/servers/decommission", DrainRequest{ServerURL: "http://localhost:3002"}); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while decommissioning, got %d", w.Code)
+	}
+
+	atomic.StoreInt64(&balancer.servers["http://localhost:3002"].ConnectionPool.ActiveConns, 0)
+
+	var w *httptest.ResponseRecorder
+	select {
+	case w = <-stream:
+	case <-time.After(5 * time.Second):
+		t.Fatal("decommission stream did not finish")
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected event stream, got %q", ct)
+	}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	var last DecommissionStatus
+	json.Unmarshal([]byte(strings.TrimPrefix(events[len(events)-1], "data: ")), &last)
+	if last.Phase != DecommissionCompleted || last.FinishedAt == nil {
+		t.Fatalf("expected last event to be completed, got %s", events[len(events)-1])
+	}
+
+	if balancer.HasServer("http://localhost:3002") {
+		t.Error("expected balancer to drop the decommissioned server")
+	}
+	servers := api.configManager.GetConfig().Backends[0].Servers
+	if len(servers) != 1 || servers[0].URL != "http://localhost:3001" {
+		t.Errorf("expected server removed from config, got %+v", servers)
+	}
+	if w := request("POST", "/servers/decommission", DrainRequest{ServerURL: "http://localhost:3002"}); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown server, got %d", w.Code)
+	}
+}
+
+func TestConfigAPI_ForceCircuit(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Security.AdminAPIKeys = []string{"admin-key"}
+	api.configManager.Update(&config)
+
+	// Con un recovery_timeout mínimo el breaker automático ya habría pasado a half-open
+	backend := &domain.Backend{
+		Name: "web-servers",
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+		CircuitBreaker: domain.CircuitBreakerCfg{FailureThreshold: 5, RecoveryTimeout: time.Nanosecond},
+	}
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateServers(backend.Servers, backend)
+	api.SetLoadBalancer(balancer)
+
+	force := func(key, state string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(CircuitRequest{ServerURL: "http://localhost:3001", State: state})
+		req := httptest.NewRequest("POST", "/servers/circuit", bytes.NewBuffer(body))
+		if key != "" {
+			req.Header.Set("X-API-KEY", key)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := force("test-key", "open"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin key, got %d", w.Code)
+	}
+	if w := force("admin-key", "broken"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown state, got %d", w.Code)
+	}
+	if w := force("admin-key", "open"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 when opening circuit, got %d", w.Code)
+	}
+
+	for i := 0; i < 20; i++ {
+		server := balancer.SelectServer(backend, "127.0.0.1")
+		if server.URL == "http://localhost:3001" {
+			t.Fatal("expected manually opened server out of rotation")
+		}
+		balancer.UpdateStats(server, time.Millisecond, true)
+	}
+	if stats := balancer.GetServerMetrics()["http://localhost:3001"]; !stats.CircuitOpen || !stats.CircuitOverride {
+		t.Errorf("expected open circuit flagged as manual override, got %+v", stats)
+	}
+
+	if w := force("admin-key", "close"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 when closing circuit, got %d", w.Code)
+	}
+	selected := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		server := balancer.SelectServer(backend, "127.0.0.1")
+		selected[server.URL] = true
+		balancer.UpdateStats(server, time.Millisecond, true)
+	}
+	if !selected["http://localhost:3001"] {
+		t.Error("expected server back in rotation after closing its circuit")
+	}
+}
+
+func TestConfigAPI_BackendHealthFromStats(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Backends = []domain.Backend{{
+		Name: "web-servers",
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+	}}
+	api.configManager.Update(&config)
+
+	// Sin health checker avanzado los datos salen del balanceador
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateServers(config.Backends[0].Servers, &config.Backends[0])
+	balancer.servers["http://localhost:3002"].HealthState = Unhealthy
+	api.SetLoadBalancer(balancer)
+
+	req := httptest.NewRequest("GET", "/health/backends", nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without API key, got %d", w.Code)
+	}
+
+	req.Header.Set("X-API-KEY", "test-key")
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Backends map[string]struct {
+			HealthyServers int     `json:"healthy_servers"`
+			TotalServers   int     `json:"total_servers"`
+			HealthRatio    float64 `json:"health_ratio"`
+		} `json:"backends"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	web := response.Backends["web-servers"]
+	if web.HealthyServers != 1 || web.TotalServers != 2 || web.HealthRatio != 0.5 {
+		t.Errorf("expected 1/2 healthy, got %+v", web)
+	}
+}
+
+func TestConfigAPI_GetEffectiveConfig(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	original := api.configManager.config
+	original.Security.APIKeys = []string{"secret-key"}
+	original.Metrics.Persistence = &domain.MetricsPersistenceCfg{Store: domain.MetricsStoreRedis, Address: "localhost:6379", Password: "redis-pass"}
+
+	req := httptest.NewRequest("GET", "/config/effective", nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var config domain.Config
+	if err := json.Unmarshal(w.Body.Bytes(), &config); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	backend := config.Backends[0]
+	if backend.Retries != domain.DefaultRetries {
+		t.Errorf("expected retries %d, got %d", domain.DefaultRetries, backend.Retries)
+	}
+	if backend.HealthInterval != domain.DefaultHealthInterval {
+		t.Errorf("expected health interval %v, got %v", domain.DefaultHealthInterval, backend.HealthInterval)
+	}
+	if backend.Transport.MaxIdleConnsPerHost != domain.DefaultMaxIdleConnsPerHost {
+		t.Errorf("expected max idle conns per host %d, got %d", domain.DefaultMaxIdleConnsPerHost, backend.Transport.MaxIdleConnsPerHost)
+	}
+	if config.Metrics.Persistence.Key != domain.DefaultRedisMetricsKey {
+		t.Errorf("expected redis key %q, got %q", domain.DefaultRedisMetricsKey, config.Metrics.Persistence.Key)
+	}
+
+	if config.Security.APIKeys[0] != "***" || config.Metrics.Persistence.Password != "***" {
+		t.Errorf("expected secrets to be masked, got %v / %q", config.Security.APIKeys, config.Metrics.Persistence.Password)
+	}
+
+	// Ni los valores por defecto ni el enmascarado tocan la configuración en uso
+	if original.Security.APIKeys[0] != "secret-key" || original.Metrics.Persistence.Password != "redis-pass" {
+		t.Error("expected live config secrets to be untouched")
+	}
+	if original.Backends[0].Retries != 0 {
+		t.Errorf("expected live config retries to stay 0, got %d", original.Backends[0].Retries)
+	}
+}
+
+func TestConfigAPI_GetConfig_DoesNotMaskLiveConfig(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	original := api.configManager.config
+	original.Security.AdminAPIKeys = []string{"admin-key"}
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", "/config", nil))
+
+	if original.Security.AdminAPIKeys[0] != "admin-key" {
+		t.Errorf("expected live admin key to be untouched, got %q", original.Security.AdminAPIKeys[0])
+	}
+}
+
+func TestConfigAPI_GetConfig_MasksActionEnv(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	original := api.configManager.config
+	original.Actions = map[string]domain.ActionConfig{
+		"deploy": {Type: domain.ActionTypeExec, Command: "/usr/bin/deploy", Env: map[string]string{"TOKEN": "s3cr3t"}},
+	}
+
+	for _, path := range []string{"/config", "/config/effective"} {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		var config domain.Config
+		if err := json.NewDecoder(w.Body).Decode(&config); err != nil {
+			t.Fatal(err)
+		}
+		if env := config.Actions["deploy"].Env; env["TOKEN"] != "***" {
+			t.Errorf("%s: expected action env to be masked, got %v", path, env)
+		}
+	}
+	if original.Actions["deploy"].Env["TOKEN"] != "s3cr3t" {
+		t.Error("expected live action env to be untouched")
+	}
+}
+
+func TestConfigAPI_UpdateConfig_PrivilegedActionsRequireAdmin(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Security.AdminAPIKeys = []string{"admin-key"}
+	config.Actions = map[string]domain.ActionConfig{
+		"notify": {URL: "http://localhost:9000/hook", Method: "POST"},
+		"deploy": {Type: domain.ActionTypeExec, Command: "/usr/bin/deploy"},
+	}
+	if err := api.configManager.Update(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key string, actions map[string]domain.ActionConfig) int {
+		update := *api.configManager.GetConfig()
+		update.Actions = actions
+		body, _ := json.Marshal(update)
+		req := httptest.NewRequest("PUT", "/config", bytes.NewReader(body))
+		req.Header.Set("X-API-KEY", key)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w.Code
+	}
+	with := func(name string, action domain.ActionConfig) map[string]domain.ActionConfig {
+		actions := map[string]domain.ActionConfig{}
+		for n, a := range api.configManager.GetConfig().Actions {
+			actions[n] = a
+		}
+		if action.Type == "" && action.URL == "" {
+			delete(actions, name)
+		} else {
+			actions[name] = action
+		}
+		return actions
+	}
+
+	tests := []struct {
+		name    string
+		actions map[string]domain.ActionConfig
+	}{
+		{"add exec", with("shell", domain.ActionConfig{Type: domain.ActionTypeExec, Command: "/bin/sh", Args: []string{"-c", "id"}})},
+		{"change exec args", with("deploy", domain.ActionConfig{Type: domain.ActionTypeExec, Command: "/usr/bin/deploy", Args: []string{"--force"}})},
+		{"add env", with("deploy", domain.ActionConfig{Type: domain.ActionTypeExec, Command: "/usr/bin/deploy", Env: map[string]string{"LD_PRELOAD": "/tmp/x.so"}})},
+		{"http to exec", with("notify", domain.ActionConfig{Type: domain.ActionTypeExec, Command: "/bin/sh"})},
+		{"add kubernetes", with("k8s", domain.ActionConfig{Type: domain.ActionTypeKubernetes, Kubernetes: &domain.KubernetesActionCfg{Deployment: "web", ScaleBy: 1}})},
+		{"remove exec", with("deploy", domain.ActionConfig{})},
+	}
+	for _, tt := range tests {
+		if code := put("test-key", tt.actions); code != http.StatusForbidden {
+			t.Errorf("%s: expected 403 for regular key, got %d", tt.name, code)
+		}
+	}
+	if _, exists := api.configManager.GetConfig().Actions["shell"]; exists {
+		t.Fatal("expected exec action from regular key to be rejected")
+	}
+
+	// Las acciones http y el resto de la configuración siguen abiertas a las keys regulares
+	if code := put("test-key", with("notify", domain.ActionConfig{URL: "http://localhost:9000/other", Method: "POST"})); code != http.StatusOK {
+		t.Errorf("expected http action change with regular key to succeed, got %d", code)
+	}
+	if code := put("test-key", api.configManager.GetConfig().Actions); code != http.StatusOK {
+		t.Errorf("expected unchanged exec action with regular key to succeed, got %d", code)
+	}
+	if code := put("admin-key", tests[0].actions); code != http.StatusOK {
+		t.Errorf("expected exec action with admin key to succeed, got %d", code)
+	}
+	if api.configManager.GetConfig().Actions["shell"].Command != "/bin/sh" {
+		t.Error("expected admin exec action to be applied")
+	}
+}
+
+func TestConfigAPI_UpdateConfig_SecurityRequiresAdmin(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Security.AdminAPIKeys = []string{"admin-key"}
+	if err := api.configManager.Update(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key string, update domain.Config) int {
+		body, _ := json.Marshal(update)
+		req := httptest.NewRequest("PUT", "/config", bytes.NewReader(body))
+		req.Header.Set("X-API-KEY", key)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Paso 1: la key regular intenta hacerse admin por /config
+	escalate := *api.configManager.GetConfig()
+	escalate.Security.AdminAPIKeys = []string{"admin-key", "test-key"}
+	if code := put("test-key", escalate); code != http.StatusForbidden {
+		t.Errorf("expected 403 when a regular key changes security, got %d", code)
+	}
+	if admins := api.configManager.GetConfig().Security.AdminAPIKeys; len(admins) != 1 {
+		t.Fatalf("expected admin keys to be untouched, got %v", admins)
+	}
+
+	// Paso 2: sigue sin poder crear una acción exec
+	shell := *api.configManager.GetConfig()
+	shell.Actions = map[string]domain.ActionConfig{"shell": {Type: domain.ActionTypeExec, Command: "/bin/sh"}}
+	if code := put("test-key", shell); code != http.StatusForbidden {
+		t.Errorf("expected 403 for exec action from regular key, got %d", code)
+	}
+
+	// Sin cambios en security la key regular puede actualizar el resto
+	if code := put("test-key", *api.configManager.GetConfig()); code != http.StatusOK {
+		t.Errorf("expected unchanged security with regular key to succeed, got %d", code)
+	}
+	if code := put("admin-key", escalate); code != http.StatusOK {
+		t.Errorf("expected security change with admin key to succeed, got %d", code)
+	}
+}
+
+type countingResetter struct{ resets int }
+
+func (c *countingResetter) ResetMetrics() { c.resets++ }
+
+func TestConfigAPI_MetricsSnapshotAndReset(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Security.AdminAPIKeys = []string{"admin-key"}
+	api.configManager.Update(&config)
+
+	backend := &domain.Backend{
+		Name:    "web-servers",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}},
+	}
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateServers(backend.Servers, backend)
+	api.SetLoadBalancer(balancer)
+	proxyMetrics := &countingResetter{}
+	api.SetProxyMetrics(proxyMetrics)
+
+	for _, success := range []bool{true, true, false} {
+		server := balancer.SelectServer(backend, "192.168.1.1")
+		balancer.UpdateStats(server, 10*time.Millisecond, success)
+	}
+
+	call := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-KEY", key)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) domain.ServerCounters {
+		var snapshot domain.MetricsSnapshot
+		if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+			t.Fatalf("failed to unmarshal snapshot: %v", err)
+		}
+		return snapshot.Servers["http://localhost:3001"]
+	}
+
+	if w := call("POST", "/metrics/reset", "test-key"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin key, got %d", w.Code)
+	}
+	if w := call("GET", "/metrics/reset", "admin-key"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET /metrics/reset, got %d", w.Code)
+	}
+
+	w := call("GET", "/metrics/snapshot", "admin-key")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for snapshot, got %d", w.Code)
+	}
+	if counters := decode(w); counters.RequestCount != 3 || counters.SuccessCount != 2 || counters.FailureCount != 1 {
+		t.Errorf("unexpected snapshot counters: %+v", counters)
+	}
+
+	// El reset devuelve los valores previos y deja los contadores a cero
+	w = call("POST", "/metrics/reset", "admin-key")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for reset, got %d", w.Code)
+	}
+	if counters := decode(w); counters.RequestCount != 3 || counters.TotalLatency != int64(30*time.Millisecond) {
+		t.Errorf("expected pre-reset counters in the response, got %+v", counters)
+	}
+	if proxyMetrics.resets != 1 {
+		t.Errorf("expected proxy metrics to be reset once, got %d", proxyMetrics.resets)
+	}
+	if counters := decode(call("GET", "/metrics/snapshot", "admin-key")); counters != (domain.ServerCounters{}) {
+		t.Errorf("expected zeroed counters after reset, got %+v", counters)
+	}
+	if samples := balancer.servers["http://localhost:3001"].Metrics.ResponseTimes.GetAll(); len(samples) != 0 {
+		t.Errorf("expected latency samples to be cleared, got %v", samples)
+	}
+}
+
+func TestConfigAPI_BalancerAlgorithms(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	api.configManager.Update(&config)
+
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{Servers: []domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true},
+		{URL: "http://localhost:3002", Weight: 1, Active: true},
+	}}
+	balancer.UpdateServers(backend.Servers, backend)
+	api.SetLoadBalancer(balancer)
+
+	// Una ventana de least_connections con 50% de errores y 200ms de media
+	for _, state := range balancer.servers {
+		state.Metrics.RequestCount = 10
+		state.Metrics.SuccessCount = 5
+		state.Metrics.FailureCount = 5
+		state.Metrics.TotalLatency = int64(10 * 200 * time.Millisecond)
+	}
+	balancer.adaptiveController.recordWindow("least_connections", balancer.servers, time.Now())
+
+	req := httptest.NewRequest("GET", "/balancer/algorithms", nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without API key, got %d", w.Code)
+	}
+
+	req.Header.Set("X-API-KEY", "test-key")
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var report AlgorithmReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if report.CurrentAlgorithm != "adaptive_weighted" {
+		t.Errorf("expected adaptive_weighted active, got %q", report.CurrentAlgorithm)
+	}
+	if len(report.Algorithms) != len(balancer.algorithms) {
+		t.Fatalf("expected every algorithm listed, got %d", len(report.Algorithms))
+	}
+
+	byName := make(map[string]AlgorithmMetrics)
+	for _, metrics := range report.Algorithms {
+		byName[metrics.Name] = metrics
+	}
+	measured := byName["least_connections"]
+	if !measured.Explored || measured.Windows != 1 || len(measured.Samples) != 1 {
+		t.Errorf("expected one window for least_connections, got %+v", measured)
+	}
+	if measured.Requests != 20 || measured.Failures != 10 || measured.ErrorRate != 0.5 {
+		t.Errorf("expected 20 requests and 50%% errors, got %d / %d / %g", measured.Requests, measured.Failures, measured.ErrorRate)
+	}
+	if measured.AvgLatency != "200ms" || measured.Score != measured.Samples[0].Score {
+		t.Errorf("expected 200ms average and score from its window, got %s / %g", measured.AvgLatency, measured.Score)
+	}
+
+	unexplored := byName["power_of_two"]
+	if unexplored.Explored || unexplored.Windows != 0 || unexplored.Score != report.ExplorationScore {
+		t.Errorf("expected power_of_two unexplored at the exploration score, got %+v", unexplored)
+	}
+	if !byName["adaptive_weighted"].Active {
+		t.Error("expected adaptive_weighted marked active")
+	}
+}
+
+func TestConfigAPI_Pprof(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Security.AdminAPIKeys = []string{"admin-key"}
+	api.configManager.Update(&config)
+
+	get := func(key, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if key != "" {
+			req.Header.Set("X-API-KEY", key)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w
+	}
+
+	// Sin admin key no se revela si el profiling está activo
+	for _, key := range []string{"", "test-key"} {
+		if w := get(key, "/debug/pprof/"); w.Code != http.StatusForbidden {
+			t.Errorf("expected 403 with key %q, got %d", key, w.Code)
+		}
+	}
+	if w := get("admin-key", "/debug/pprof/"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 while pprof is disabled, got %d", w.Code)
+	}
+
+	config.Debug.PprofEnabled = true
+	api.configManager.Update(&config)
+
+	if w := get("test-key", "/debug/pprof/goroutine"); w.Code != http.StatusForbidden {
+		t.Errorf("expected regular keys to stay forbidden, got %d", w.Code)
+	}
+	w := get("admin-key", "/debug/pprof/goroutine?debug=1")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expected goroutine profile, got %d", w.Code)
+	}
+	if w := get("admin-key", "/debug/pprof/"); w.Code != http.StatusOK {
+		t.Errorf("expected profile index, got %d", w.Code)
+	}
+}
+
+type fakeMetricsSimulator struct {
+	simulation *domain.SimulatedMetrics
+}
+
+func (f *fakeMetricsSimulator) SimulateMetrics(simulation *domain.SimulatedMetrics) {
+	f.simulation = simulation
+}
+
+func (f *fakeMetricsSimulator) SimulatedMetrics(now time.Time) *domain.SimulatedMetrics {
+	return f.simulation
+}
+
+func TestConfigAPI_TriggerSimulation(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+	simulator := &fakeMetricsSimulator{}
+	api.SetMetricsSimulator(simulator)
+
+	config := *api.configManager.GetConfig()
+	config.Security.APIKeys = []string{"test-key"}
+	config.Security.AdminAPIKeys = []string{"admin-key"}
+	api.configManager.Update(&config)
+
+	do := func(method, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/debug/trigger-simulation", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-KEY", key)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		return w
+	}
+
+	// Sin admin key no se revela si la simulación está activa
+	for _, key := range []string{"", "test-key"} {
+		if w := do("GET", key, ""); w.Code != http.StatusForbidden {
+			t.Errorf("expected 403 with key %q, got %d", key, w.Code)
+		}
+	}
+	if w := do("PUT", "admin-key", `{"rps": 2000}`); w.Code != http.StatusNotFound || simulator.simulation != nil {
+		t.Errorf("expected 404 while trigger simulation is disabled, got %d", w.Code)
+	}
+
+	config.Debug.TriggerSimulation = true
+	api.configManager.Update(&config)
+
+	for _, body := range []string{
+		`{}`,
+		`{"rps": -1}`,
+		`{"error_rate": 1.5}`,
+		`{"latency": "slow"}`,
+		`{"rps": 100, "duration": "2h"}`,
+		`{"rps": 100, "backend": "missing"}`,
+	} {
+		if w := do("PUT", "admin-key", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+
+	before := time.Now()
+	w := do("PUT", "admin-key", `{"rps": 2000, "latency": "800ms", "duration": "30s"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	simulation := simulator.simulation
+	if simulation == nil || *simulation.RPS != 2000 || *simulation.Latency != 800*time.Millisecond ||
+		simulation.ErrorRate != nil || simulation.ExpiresAt.Before(before.Add(30*time.Second)) {
+		t.Fatalf("unexpected simulation %+v", simulation)
+	}
+
+	var response TriggerSimulationResponse
+	json.NewDecoder(do("GET", "admin-key", "").Body).Decode(&response)
+	if !response.Active || response.Latency != "800ms" {
+		t.Errorf("expected active simulation, got %+v", response)
+	}
+
+	if w := do("DELETE", "admin-key", ""); w.Code != http.StatusOK || simulator.simulation != nil {
+		t.Errorf("expected simulation stopped, got %d", w.Code)
+	}
+}
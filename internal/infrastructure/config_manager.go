@@ -1,18 +1,32 @@
 package infrastructure
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/juanbautista0/go-proxy/internal/domain"
 	"gopkg.in/yaml.v3"
 )
 
+// ErrConfigReadOnly indica que el origen de la configuración no admite escrituras
+var ErrConfigReadOnly = errors.New("config source is read-only")
+
+// configSaver es un origen de configuración que además permite guardarla
+type configSaver interface {
+	Save(config *domain.Config) error
+}
+
 type ConfigManager struct {
 	configPath string
-	mu         sync.RWMutex
-	config     *domain.Config
-	callbacks  []func(*domain.Config)
+	// repo sustituye al archivo cuando la configuración vive en otro origen
+	repo      domain.ConfigRepository
+	mu        sync.RWMutex
+	config    *domain.Config
+	callbacks []func(*domain.Config)
 }
 
 func NewConfigManager(configPath string) *ConfigManager {
@@ -22,43 +36,113 @@ func NewConfigManager(configPath string) *ConfigManager {
 	}
 }
 
-func (cm *ConfigManager) Load() (*domain.Config, error) {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+// NewConfigManagerWithRepository lee la configuración de repo en lugar de un archivo
+func NewConfigManagerWithRepository(repo domain.ConfigRepository) *ConfigManager {
+	return &ConfigManager{
+		repo:      repo,
+		callbacks: make([]func(*domain.Config), 0),
+	}
+}
 
-	data, err := os.ReadFile(cm.configPath)
+// NewConfigManagerFromSource elige el origen según el esquema: consul://host:port/key
+// lee de Consul KV; una ruta sin esquema o file:// usa el archivo local
+func NewConfigManagerFromSource(source string) (*ConfigManager, error) {
+	if !strings.Contains(source, "://") {
+		return newFileConfigManager(source)
+	}
+
+	u, err := url.Parse(source)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid config source %q: %w", source, err)
+	}
+	switch u.Scheme {
+	case "file":
+		return newFileConfigManager(u.Host + u.Path)
+	case "consul":
+		repo, err := NewConsulConfigRepositoryFromURL(u)
+		if err != nil {
+			return nil, err
+		}
+		return NewConfigManagerWithRepository(repo), nil
+	default:
+		return nil, fmt.Errorf("unknown config source scheme %q", u.Scheme)
 	}
+}
 
-	var config domain.Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
+func newFileConfigManager(configPath string) (*ConfigManager, error) {
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("config file not found: %s", configPath)
 	}
+	return NewConfigManager(configPath), nil
+}
+
+func (cm *ConfigManager) Load() (*domain.Config, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
 
-	// Activar servidores por defecto
-	for i := range config.Backends {
-		for j := range config.Backends[i].Servers {
-			config.Backends[i].Servers[j].Active = true
+	var config *domain.Config
+	if cm.repo != nil {
+		loaded, err := cm.repo.Load()
+		if err != nil {
+			return nil, err
+		}
+		config = loaded
+	} else {
+		data, err := os.ReadFile(cm.configPath)
+		if err != nil {
+			return nil, err
+		}
+		if config, err = decodeConfig(data); err != nil {
+			return nil, err
 		}
 	}
 
-	cm.config = &config
-	return &config, nil
+	cm.config = config
+	return config, nil
+}
+
+// Watch aplica los cambios que publica el repositorio. Con archivo local no hace
+// nada: los cambios llegan por la API de configuración.
+func (cm *ConfigManager) Watch() error {
+	if cm.repo == nil {
+		return nil
+	}
+	return cm.repo.Watch(func(config *domain.Config) {
+		cm.mu.Lock()
+		defer cm.mu.Unlock()
+		cm.config = config
+		for _, callback := range cm.callbacks {
+			callback(config)
+		}
+	})
 }
 
 func (cm *ConfigManager) Update(config *domain.Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	// Escribir archivo primero
-	data, err := yaml.Marshal(config)
-	if err != nil {
-		return err
-	}
+	// Escribir en el origen primero
+	if cm.repo != nil {
+		saver, ok := cm.repo.(configSaver)
+		if !ok {
+			return ErrConfigReadOnly
+		}
+		if err := saver.Save(config); err != nil {
+			return err
+		}
+	} else {
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return err
+		}
 
-	if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
-		return err
+		if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
+			return err
+		}
 	}
 
 	// Actualizar memoria con copia
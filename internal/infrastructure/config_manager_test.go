@@ -1,6 +1,7 @@
 package infrastructure
 
 import (
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -55,6 +56,75 @@ backends:
 	}
 }
 
+func TestConfigManager_LoadRejectsInvalidServerURL(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "config_test_*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	tempFile.WriteString(`
+backends:
+  - name: "test-backend"
+    servers:
+      - url: "localhost:3001"
+        weight: 1
+`)
+	tempFile.Close()
+
+	manager := NewConfigManager(tempFile.Name())
+	if _, err := manager.Load(); !errors.Is(err, domain.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+	if manager.GetConfig() != nil {
+		t.Error("expected invalid config not to be stored")
+	}
+}
+
+func TestConfigManager_LoadFlushInterval(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "config_test_*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	tempFile.WriteString(`
+backends:
+  - name: "events"
+    flush_interval: -1
+    servers:
+      - url: "http://localhost:3001"
+        weight: 1
+  - name: "downloads"
+    flush_interval: "100ms"
+    servers:
+      - url: "http://localhost:3002"
+        weight: 1
+`)
+	tempFile.Close()
+
+	manager := NewConfigManager(tempFile.Name())
+	config, err := manager.Load()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	// Guardar y recargar conserva el formato aceptado
+	if err := manager.Update(config); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if config, err = manager.Load(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+
+	if config.Backends[0].FlushInterval != -1 {
+		t.Errorf("expected immediate flush (-1), got %v", config.Backends[0].FlushInterval)
+	}
+	if config.Backends[1].FlushInterval != domain.FlushInterval(100*time.Millisecond) {
+		t.Errorf("expected 100ms flush interval, got %v", config.Backends[1].FlushInterval)
+	}
+}
+
 func TestConfigManager_Update(t *testing.T) {
 	tempFile, err := os.CreateTemp("", "config_test_*.yaml")
 	if err != nil {
@@ -21,11 +21,18 @@ func (r *FileConfigRepository) Load() (*domain.Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	return decodeConfig(data)
+}
 
+// decodeConfig interpreta y valida el YAML de configuración, sea cual sea su origen
+func decodeConfig(data []byte) (*domain.Config, error) {
 	var config domain.Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, err
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	// Activar todos los servidores por defecto
 	for i := range config.Backends {
@@ -0,0 +1,258 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConsulAddress = "127.0.0.1:8500"
+	consulWatchWait      = 5 * time.Minute
+	consulRetryInterval  = 5 * time.Second
+)
+
+// ConsulConfigRepository lee la configuración YAML de una clave de Consul KV y
+// detecta cambios con blocking queries
+type ConsulConfigRepository struct {
+	baseURL string
+	key     string
+	token   string
+	client  *http.Client
+
+	mu        sync.Mutex
+	lastIndex uint64
+	lastData  []byte
+	stop      chan struct{}
+}
+
+// NewConsulConfigRepository crea el repositorio para la clave key del agente en
+// baseURL (p. ej. http://127.0.0.1:8500)
+func NewConsulConfigRepository(baseURL, key, token string) *ConsulConfigRepository {
+	return &ConsulConfigRepository{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		key:     strings.Trim(key, "/"),
+		token:   token,
+		// Sin timeout global: las blocking queries se limitan con wait
+		client: &http.Client{},
+	}
+}
+
+// NewConsulConfigRepositoryFromURL interpreta consul://host:port/key. El token se
+// toma de ?token= o de CONSUL_HTTP_TOKEN y ?scheme=https activa TLS.
+func NewConsulConfigRepositoryFromURL(u *url.URL) (*ConsulConfigRepository, error) {
+	key := strings.Trim(u.Path, "/")
+	if key == "" {
+		return nil, fmt.Errorf("consul config source requires a key: consul://host:port/<key>")
+	}
+
+	host := u.Host
+	if host == "" {
+		host = defaultConsulAddress
+	}
+	scheme := u.Query().Get("scheme")
+	if scheme == "" {
+		scheme = "http"
+	}
+	token := u.Query().Get("token")
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+
+	return NewConsulConfigRepository(scheme+"://"+host, key, token), nil
+}
+
+func (r *ConsulConfigRepository) Load() (*domain.Config, error) {
+	data, index, err := r.fetch(context.Background(), 0)
+	if err != nil {
+		return nil, err
+	}
+	config, err := decodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.lastIndex, r.lastData = index, data
+	r.mu.Unlock()
+	return config, nil
+}
+
+// Watch consulta la clave en bucle con el último índice conocido; Consul
+// mantiene la request abierta hasta que la clave cambia o vence wait
+func (r *ConsulConfigRepository) Watch(callback func(*domain.Config)) error {
+	r.mu.Lock()
+	if r.stop != nil {
+		r.mu.Unlock()
+		return fmt.Errorf("consul watch already running")
+	}
+	stop := make(chan struct{})
+	r.stop = stop
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	go func() {
+		for {
+			r.mu.Lock()
+			index := r.lastIndex
+			r.mu.Unlock()
+
+			data, newIndex, err := r.fetch(ctx, index)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Warn("Consul watch failed", "key", r.key, "error", err)
+				select {
+				case <-time.After(consulRetryInterval):
+					continue
+				case <-stop:
+					return
+				}
+			}
+
+			r.mu.Lock()
+			// Un índice que retrocede (p. ej. tras restaurar un snapshot) obliga a
+			// empezar de nuevo, según la documentación de Consul
+			if newIndex < r.lastIndex {
+				newIndex = 0
+			}
+			r.lastIndex = newIndex
+			changed := !bytes.Equal(data, r.lastData)
+			if changed {
+				r.lastData = data
+			}
+			r.mu.Unlock()
+
+			// La query también vuelve al vencer wait o con cambios ajenos a la
+			// clave; además Save ya registró lo que escribió
+			if !changed {
+				continue
+			}
+			config, err := decodeConfig(data)
+			if err != nil {
+				slog.Warn("Ignoring invalid config from Consul", "key", r.key, "error", err)
+				continue
+			}
+			callback(config)
+		}
+	}()
+
+	return nil
+}
+
+// Stop detiene el watch iniciado con Watch
+func (r *ConsulConfigRepository) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+// Save escribe la configuración en la clave, para los cambios hechos por la API
+func (r *ConsulConfigRepository) Save(config *domain.Config) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	// Se registra antes del PUT: el watch puede despertar antes de que
+	// vuelva la respuesta y no debe notificar lo que escribimos nosotros
+	r.mu.Lock()
+	previous := r.lastData
+	r.lastData = data
+	r.mu.Unlock()
+
+	if err := r.put(data); err != nil {
+		r.mu.Lock()
+		if bytes.Equal(r.lastData, data) {
+			r.lastData = previous
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *ConsulConfigRepository) put(data []byte) error {
+	req, err := http.NewRequest(http.MethodPut, r.baseURL+"/v1/kv/"+r.key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	r.authorize(req)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "true" {
+		return fmt.Errorf("consul PUT %s failed: %d %s", r.key, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// fetch lee el valor crudo de la clave; con index > 0 es una blocking query
+func (r *ConsulConfigRepository) fetch(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	query := url.Values{"raw": {""}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", consulWatchWait.String())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/v1/kv/"+r.key+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	r.authorize(req)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, 0, fmt.Errorf("consul key %s not found", r.key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("consul GET %s failed: %d", r.key, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Sin índice válido el watch no podría bloquear y consultaría sin pausa
+	newIndex, err := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil || newIndex == 0 {
+		return nil, 0, fmt.Errorf("consul GET %s returned no X-Consul-Index", r.key)
+	}
+	return data, newIndex, nil
+}
+
+func (r *ConsulConfigRepository) authorize(req *http.Request) {
+	if r.token != "" {
+		req.Header.Set("X-Consul-Token", r.token)
+	}
+}
@@ -0,0 +1,148 @@
+package infrastructure
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+const consulTestConfig = `
+proxy:
+  port: 8080
+backends:
+  - name: "test-backend"
+    servers:
+      - url: "http://localhost:3001"
+        weight: 1
+`
+
+// fakeConsulKV atiende GET (con blocking queries) y PUT de una única clave
+type fakeConsulKV struct {
+	mu      sync.Mutex
+	value   []byte
+	index   uint64
+	changed chan struct{}
+	token   string
+}
+
+func newFakeConsulKV(t *testing.T, value, token string) (*fakeConsulKV, *httptest.Server) {
+	kv := &fakeConsulKV{value: []byte(value), index: 1, changed: make(chan struct{}), token: token}
+	server := httptest.NewServer(http.HandlerFunc(kv.serveHTTP))
+	t.Cleanup(server.Close)
+	return kv, server
+}
+
+func (kv *fakeConsulKV) set(value []byte) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.value = value
+	kv.index++
+	close(kv.changed)
+	kv.changed = make(chan struct{})
+}
+
+func (kv *fakeConsulKV) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != kv.token {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+		return
+	}
+	if r.Method == http.MethodPut {
+		data, _ := io.ReadAll(r.Body)
+		kv.set(data)
+		io.WriteString(w, "true")
+		return
+	}
+
+	kv.mu.Lock()
+	changed := kv.changed
+	wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+	blocked := wait >= kv.index
+	kv.mu.Unlock()
+
+	if blocked {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(kv.index, 10))
+	w.Write(kv.value)
+}
+
+func TestConsulConfigRepository_LoadAndWatch(t *testing.T) {
+	kv, server := newFakeConsulKV(t, consulTestConfig, "secret")
+	repo := NewConsulConfigRepository(server.URL, "go-proxy/config", "secret")
+	defer repo.Stop()
+
+	config, err := repo.Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if config.Proxy.Port != 8080 || !config.Backends[0].Servers[0].Active {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+
+	updates := make(chan *domain.Config, 1)
+	if err := repo.Watch(func(c *domain.Config) { updates <- c }); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+
+	kv.set([]byte(consulTestConfig + "  - name: \"second\"\n    servers:\n      - url: \"http://localhost:3002\"\n"))
+	select {
+	case updated := <-updates:
+		if len(updated.Backends) != 2 {
+			t.Errorf("expected updated config with 2 backends, got %d", len(updated.Backends))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected callback after the key changed")
+	}
+
+	// Lo escrito por Save no vuelve a notificarse
+	config.Proxy.Port = 9090
+	if err := repo.Save(config); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	select {
+	case c := <-updates:
+		t.Errorf("unexpected callback for own write: port %d", c.Proxy.Port)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestConsulConfigRepository_RejectsWrongToken(t *testing.T) {
+	_, server := newFakeConsulKV(t, consulTestConfig, "secret")
+	if _, err := NewConsulConfigRepository(server.URL, "go-proxy/config", "wrong").Load(); err == nil {
+		t.Error("expected error with a wrong ACL token")
+	}
+}
+
+func TestNewConfigManagerFromSource(t *testing.T) {
+	_, server := newFakeConsulKV(t, consulTestConfig, "")
+	manager, err := NewConfigManagerFromSource("consul://" + server.Listener.Addr().String() + "/go-proxy/config")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := manager.Load(); err != nil {
+		t.Fatalf("load from consul failed: %v", err)
+	}
+
+	if _, err := NewConfigManagerFromSource("consul://127.0.0.1:8500"); err == nil {
+		t.Error("expected error for consul source without key")
+	}
+	if _, err := NewConfigManagerFromSource("etcd://127.0.0.1:2379/config"); err == nil {
+		t.Error("expected error for unknown scheme")
+	}
+	if _, err := NewConfigManagerFromSource("/does/not/exist.yaml"); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
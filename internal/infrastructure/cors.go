@@ -0,0 +1,97 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+var (
+	defaultCORSMethods = domain.DefaultCORSMethods
+	defaultCORSHeaders = domain.DefaultCORSHeaders
+)
+
+// publicCORS replica el comportamiento histórico del servidor de métricas
+// cuando no hay bloque cors configurado: lectura desde cualquier origen.
+var publicCORS = domain.CORSConfig{
+	AllowedOrigins: []string{"*"},
+	AllowedMethods: []string{http.MethodGet, http.MethodOptions},
+}
+
+// applyCORS agrega los headers CORS para el origen de la request y responde
+// los preflight. Devuelve true si la request ya fue respondida.
+func applyCORS(w http.ResponseWriter, r *http.Request, cfg domain.CORSConfig) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(cfg.AllowedOrigins) == 0 {
+		return false
+	}
+
+	allowOrigin, ok := matchOrigin(origin, cfg)
+	if !ok {
+		// Origen no permitido: sin headers, el navegador bloquea la respuesta
+		if isPreflight(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", allowOrigin)
+	if allowOrigin != "*" {
+		h.Add("Vary", "Origin")
+	}
+	if cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !isPreflight(r) {
+		return false
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if cfg.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// matchOrigin devuelve el valor de Access-Control-Allow-Origin para el origen.
+// Con credenciales el comodín no es válido, así que se refleja el origen.
+func matchOrigin(origin string, cfg domain.CORSConfig) (string, bool) {
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" {
+			if cfg.AllowCredentials {
+				return origin, true
+			}
+			return "*", true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// sameOrigin indica si el Origin apunta al mismo host:puerto que la request
+func sameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, host)
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
@@ -0,0 +1,161 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestApplyCORS(t *testing.T) {
+	cfg := domain.CORSConfig{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"X-API-KEY", "Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		origin        string
+		preflight     bool
+		handled       bool
+		status        int
+		expectedAllow string
+	}{
+		{name: "allowed origin", method: "GET", origin: "https://admin.example.com", expectedAllow: "https://admin.example.com"},
+		{name: "disallowed origin", method: "GET", origin: "https://evil.example.com"},
+		{name: "no origin", method: "GET"},
+		{name: "allowed preflight", method: "OPTIONS", origin: "https://admin.example.com", preflight: true, handled: true, status: http.StatusNoContent, expectedAllow: "https://admin.example.com"},
+		{name: "disallowed preflight", method: "OPTIONS", origin: "https://evil.example.com", preflight: true, handled: true, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/config", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "PUT")
+			}
+			w := httptest.NewRecorder()
+
+			handled := applyCORS(w, req, cfg)
+
+			if handled != tt.handled {
+				t.Errorf("expected handled=%v, got %v", tt.handled, handled)
+			}
+			if tt.handled && w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.expectedAllow {
+				t.Errorf("expected Allow-Origin %q, got %q", tt.expectedAllow, got)
+			}
+			if tt.status == http.StatusNoContent {
+				if w.Header().Get("Access-Control-Allow-Methods") != "GET, PUT" {
+					t.Errorf("unexpected Allow-Methods: %q", w.Header().Get("Access-Control-Allow-Methods"))
+				}
+				if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+					t.Error("expected Allow-Credentials header")
+				}
+				if w.Header().Get("Access-Control-Max-Age") != "600" {
+					t.Errorf("expected Max-Age 600, got %q", w.Header().Get("Access-Control-Max-Age"))
+				}
+			}
+		})
+	}
+}
+
+func TestConfigAPI_CORS(t *testing.T) {
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	// Sin configuración la API privilegiada no expone CORS
+	req := httptest.NewRequest("GET", "/config", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS headers by default, got %q", got)
+	}
+
+	config := *api.configManager.GetConfig()
+	config.CORS = domain.CORSConfig{AllowedOrigins: []string{"https://admin.example.com"}}
+	api.configManager.Update(&config)
+
+	req = httptest.NewRequest("OPTIONS", "/servers", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w = httptest.NewRecorder()
+	api.ConfigAPI.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected preflight status 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+		t.Errorf("expected Allow-Origin for configured origin, got %q", got)
+	}
+}
+
+func TestMetricsServer_CORS(t *testing.T) {
+	ms := NewMetricsServer(nil)
+	handler := ms.withCORS(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Origin", "https://anywhere.example.com")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected public read access by default, got %q", got)
+	}
+
+	ms.SetCORS(&domain.CORSConfig{AllowedOrigins: []string{"https://admin.example.com"}})
+	w = httptest.NewRecorder()
+	handler(w, req)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected configured policy to reject other origins, got %q", got)
+	}
+}
+
+func TestMetricsServer_WebSocketOrigin(t *testing.T) {
+	ms := NewMetricsServer(&stubProxyService{})
+	server := httptest.NewServer(ms.withCORS(ms.webSocketMetrics.HandleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	dial := func(origin string) error {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	// Sin bloque cors /ws sigue siendo público como /metrics
+	if err := dial("https://anywhere.example.com"); err != nil {
+		t.Errorf("expected public access by default, got %v", err)
+	}
+
+	ms.SetCORS(&domain.CORSConfig{AllowedOrigins: []string{"https://admin.example.com"}})
+	if err := dial("https://anywhere.example.com"); err == nil {
+		t.Error("expected configured policy to reject other origins")
+	}
+	for _, origin := range []string{"https://admin.example.com", server.URL, ""} {
+		if err := dial(origin); err != nil {
+			t.Errorf("expected origin %q allowed, got %v", origin, err)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package infrastructure
 
 import (
+	"log/slog"
 	"math"
+	"net/http"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -15,24 +17,79 @@ type EnterpriseBalancer struct {
 	servers               map[string]*ServerState
 	algorithms            map[string]Algorithm
 	currentAlgorithm      string
+	pinnedAlgorithm       string
 	adaptiveController    *AdaptiveController
 	consistentHashRing    *ConsistentHashRing
 	requestCounter        int64
 	performanceMonitor    *PerformanceMonitor
 	serverLifecycle       *ServerLifecycle
+	syncedServers         *domain.Server
+	syncedCount           int
+	// Con varios backends: slices sincronizados por UpdateBackends y servidores
+	// de cada backend, para no mezclar sus pools al seleccionar
+	syncedBackends        map[*domain.Server]int
+	backendMembers        map[string]map[string]bool
+	// Contadores restaurados de servidores que aún no se han sincronizado
+	restoredCounters      map[string]domain.ServerCounters
+	// Cola de espera cuando todos los servidores están al límite de conexiones
+	queued                int64
+	slots                 slotNotifier
+	// Percentiles de latencia por servidor (metrics.percentiles)
+	percentiles []float64
+	// Tamaño del buffer de latencias de cada servidor (metrics.latency_samples)
+	latencySamples int
+	// Límites del histograma de latencias (metrics.latency_buckets), ordenados
+	latencyBuckets []time.Duration
+	// Reloj inyectable para los tests; SystemClock por defecto
+	clock Clock
+	// Ya hubo una primera sincronización: los servidores nuevos entran como Pending
+	seeded bool
+}
+
+// slotNotifier despierta a las requests en cola cuando se libera una conexión
+type slotNotifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// wait devuelve un canal que se cierra en la próxima liberación; hay que
+// obtenerlo antes de comprobar los servidores para no perder el aviso
+func (n *slotNotifier) wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ch == nil {
+		n.ch = make(chan struct{})
+	}
+	return n.ch
+}
+
+func (n *slotNotifier) notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.ch != nil {
+		close(n.ch)
+		n.ch = nil
+	}
 }
 
 type ServerState struct {
-	Server           *domain.Server
-	Metrics          *ServerMetrics
-	HealthState      HealthState
-	CircuitBreaker   *CircuitBreaker
-	ConnectionPool   *ConnectionPool
-	LastHealthCheck  time.Time
-	ConsecutiveFails int
-	Weight           float64
-	EffectiveWeight  float64
-	CurrentWeight    float64
+	Server            *domain.Server
+	Metrics           *ServerMetrics
+	HealthState       HealthState
+	CircuitBreaker    *CircuitBreaker
+	ConnectionPool    *ConnectionPool
+	LastHealthCheck   time.Time
+	ConsecutiveFails  int
+	// HealthCheckFailed excluye el servidor hasta que el health checker lo vea sano
+	HealthCheckFailed bool
+	Weight            float64
+	EffectiveWeight   float64
+	Transport         http.RoundTripper
+	TransportConfig   domain.TransportCfg
+	Protocol          string
+	// Cuándo se añadió y server_warmup del backend, para salir de Pending
+	AddedAt time.Time
+	Warmup  time.Duration
 }
 
 type ServerMetrics struct {
@@ -44,9 +101,20 @@ type ServerMetrics struct {
 	TotalLatency     int64
 	P95ResponseTime  time.Duration
 	P99ResponseTime  time.Duration
+	EWMAResponseTime time.Duration
 	ThroughputRPS    float64
 	ErrorRate        float64
 	LastUpdate       time.Time
+	// Percentiles configurados por domain.PercentileLabel; se sustituye entero en cada actualización
+	Percentiles map[string]time.Duration
+	// Coste de peak_ewma y cuándo se actualizó; decae con el tiempo
+	PeakEWMA      time.Duration
+	PeakEWMAStamp time.Time
+	// Duración de las conexiones actualizadas ya cerradas
+	Upgrades upgradeStats
+	// Histograma acumulado de tiempos de respuesta desde el alta del servidor
+	// o el último reset; nil hasta la primera respuesta
+	Latencies *LatencyHistogram
 }
 
 type HealthState int
@@ -56,6 +124,9 @@ const (
 	Degraded
 	Unhealthy
 	Recovering
+	// Pending es un servidor añadido en caliente que aún no ha pasado su
+	// primer health check; no recibe tráfico
+	Pending
 )
 
 type CircuitState int
@@ -75,12 +146,28 @@ type CircuitBreaker struct {
 	FailureThreshold int
 	RecoveryTimeout  time.Duration
 	HalfOpenRequests int
+	LastResort       bool
+	ProbeInFlight    bool
+	// HalfOpenSuccesses son los éxitos seguidos en half-open que cierran el
+	// circuito; 0 usa domain.DefaultHalfOpenSuccesses
+	HalfOpenSuccesses int
+	// ManualOverride indica que el estado lo fijó un administrador. Un circuito
+	// abierto a mano no pasa a half-open por sí solo; cualquier transición
+	// automática posterior devuelve el control al breaker.
+	ManualOverride bool
 }
 
 type ConnectionPool struct {
 	MaxConnections int
 	ActiveConns    int64
 	WaitingConns   int64
+	// Conexiones TCP del transporte: abiertas ahora y, por request, si se
+	// abrió una nueva o se reutilizó una keep-alive
+	OpenConns   int64
+	NewConns    int64
+	ReusedConns int64
+	// Conexiones actualizadas (101) en curso: no ocupan ActiveConns
+	UpgradedConns int64
 }
 
 type Algorithm interface {
@@ -88,23 +175,6 @@ type Algorithm interface {
 	UpdateWeights(servers []*ServerState)
 }
 
-type AdaptiveController struct {
-	mu                 sync.RWMutex
-	performanceHistory map[string]*PerformanceWindow
-	algorithmScores    map[string]float64
-	switchThreshold    float64
-	evaluationWindow   time.Duration
-	lastSwitch         time.Time
-}
-
-type PerformanceWindow struct {
-	samples    []float64
-	timestamps []time.Time
-	maxSize    int
-}
-
-
-
 type PerformanceMonitor struct {
 	globalMetrics *GlobalMetrics
 	alertThresholds *AlertThresholds
@@ -142,6 +212,8 @@ func NewEnterpriseBalancer() *EnterpriseBalancer {
 		currentAlgorithm:   "adaptive_weighted",
 		consistentHashRing: NewConsistentHashRing(150),
 		serverLifecycle:    NewServerLifecycle(),
+		latencySamples:     domain.DefaultLatencySamples,
+		clock:              SystemClock{},
 		performanceMonitor: &PerformanceMonitor{
 			globalMetrics: &GlobalMetrics{},
 			alertThresholds: &AlertThresholds{
@@ -150,12 +222,7 @@ func NewEnterpriseBalancer() *EnterpriseBalancer {
 				MinThroughput:   100,
 			},
 		},
-		adaptiveController: &AdaptiveController{
-			performanceHistory: make(map[string]*PerformanceWindow),
-			algorithmScores:    make(map[string]float64),
-			switchThreshold:    0.15,
-			evaluationWindow:   30 * time.Second,
-		},
+		adaptiveController: NewAdaptiveController(),
 	}
 
 	// Registrar algoritmos avanzados
@@ -165,108 +232,369 @@ func NewEnterpriseBalancer() *EnterpriseBalancer {
 	eb.algorithms["consistent_hash"] = &ConsistentHash{ring: eb.consistentHashRing}
 	eb.algorithms["power_of_two"] = &PowerOfTwoChoices{}
 	eb.algorithms["weighted_fair_queue"] = &WeightedFairQueue{}
+	eb.algorithms["weighted_least_connections"] = &WeightedLeastConnections{}
+	eb.algorithms["weighted_random"] = &WeightedRandom{}
+	eb.algorithms["peak_ewma"] = &PeakEWMA{}
 
 	// Configurar callbacks del lifecycle
-	eb.serverLifecycle.SetCallbacks(
-		func(serverURL string) {
-			eb.mu.Lock()
-			delete(eb.servers, serverURL)
-			eb.mu.Unlock()
-		},
-		nil,
-	)
+	eb.serverLifecycle.SetCallbacks(eb.forgetServer, nil)
 
 	return eb
 }
 
 func (eb *EnterpriseBalancer) SelectServer(backend *domain.Backend, clientIP string) *domain.Server {
-	// Inicializar servidores si es necesario (con write lock)
-	eb.mu.Lock()
-	eb.initializeServers(backend.Servers, backend)
-	eb.mu.Unlock()
+	server, _ := eb.selectServer(backend, clientIP, nil)
+	return server
+}
+
+// SelectServerFrom selecciona igual que SelectServer pero solo entre los
+// servidores aceptados por allowed (p.ej. el subconjunto de una regla de cabecera)
+func (eb *EnterpriseBalancer) SelectServerFrom(backend *domain.Backend, clientIP string, allowed func(*domain.Server) bool) *domain.Server {
+	server, _ := eb.selectServer(backend, clientIP, allowed)
+	return server
+}
+
+// SelectServerWithReason selecciona como SelectServerFrom y, si no hay
+// servidor, indica el motivo: domain.RejectCapacity si algún candidato solo
+// quedó fuera por max_connections (o la cola se llenó o agotó max_wait) y
+// domain.RejectUnhealthy en otro caso
+func (eb *EnterpriseBalancer) SelectServerWithReason(backend *domain.Backend, clientIP string, allowed func(*domain.Server) bool) (*domain.Server, string) {
+	return eb.selectServer(backend, clientIP, allowed)
+}
+
+// SelectRetryServer elige un servidor para reintentar una request fallida. Solo
+// acepta servidores sanos con el circuito cerrado, de modo que el reintento
+// nunca cae en un circuito abierto o en recuperación ni en la prueba last_resort.
+func (eb *EnterpriseBalancer) SelectRetryServer(backend *domain.Backend, clientIP string, allowed func(*domain.Server) bool) *domain.Server {
+	// allowed se evalúa con eb.mu ya tomado: el estado se lee sin volver a bloquear
+	healthy := func(server *domain.Server) bool {
+		if allowed != nil && !allowed(server) {
+			return false
+		}
+		state, exists := eb.servers[server.URL]
+		return exists &&
+			state.CircuitBreaker.State == CircuitClosed &&
+			state.HealthState != Unhealthy &&
+			!state.HealthCheckFailed
+	}
+	server, _ := eb.selectServer(backend, clientIP, healthy)
+	return server
+}
 
+func (eb *EnterpriseBalancer) selectServer(backend *domain.Backend, clientIP string, allowed func(*domain.Server) bool) (*domain.Server, string) {
+	// Sincronizar servidores solo si el backend cambió desde la última actualización
 	eb.mu.RLock()
-	defer eb.mu.RUnlock()
+	stale := eb.serversStale(backend.Servers)
+	eb.mu.RUnlock()
 
-	// Obtener servidores disponibles (excluyendo los que están drenando)
-	availableServers := eb.getAvailableServers()
-	if len(availableServers) == 0 {
-		return nil
+	if stale {
+		eb.mu.Lock()
+		if eb.serversStale(backend.Servers) {
+			eb.updateServers(backend.Servers, backend)
+		}
+		eb.mu.Unlock()
+	}
+
+	allowed = eb.restrictToBackend(backend, allowed)
+	selectedState, saturated := eb.pickServer(clientIP, allowed)
+
+	// Todos los candidatos están en su límite de conexiones: esperar un hueco
+	if selectedState == nil && len(saturated) > 0 && backend.Queue.IsEnabled() {
+		selectedState = eb.waitForSlot(backend.Queue, clientIP, allowed, saturated)
 	}
 
-	// Seleccionar algoritmo adaptativo
-	algorithm := eb.selectOptimalAlgorithm()
-	
-	// Seleccionar servidor usando el algoritmo
-	selectedState := algorithm.SelectServer(availableServers, clientIP)
 	if selectedState == nil {
-		return nil
+		if len(saturated) > 0 {
+			return nil, domain.RejectCapacity
+		}
+		return nil, domain.RejectUnhealthy
 	}
 
-	// Actualizar métricas de selección
+	// Actualizar métricas de selección fuera del lock
 	atomic.AddInt64(&selectedState.Metrics.RequestCount, 1)
 	atomic.AddInt64(&selectedState.ConnectionPool.ActiveConns, 1)
 
-	return selectedState.Server
+	return selectedState.Server, ""
+}
+
+// restrictToBackend limita la selección a los servidores del backend cuando
+// UpdateBackends sincronizó varios; el mapa se reemplaza entero en cada
+// actualización, por lo que puede leerse sin lock dentro del filtro
+func (eb *EnterpriseBalancer) restrictToBackend(backend *domain.Backend, allowed func(*domain.Server) bool) func(*domain.Server) bool {
+	eb.mu.RLock()
+	members, restricted := eb.backendMembers[backend.Name]
+	eb.mu.RUnlock()
+
+	if !restricted {
+		return allowed
+	}
+	return func(server *domain.Server) bool {
+		return members[server.URL] && (allowed == nil || allowed(server))
+	}
+}
+
+// pickServer aplica el algoritmo sobre los servidores disponibles y devuelve
+// también los descartados únicamente por estar en su límite de conexiones
+func (eb *EnterpriseBalancer) pickServer(clientIP string, allowed func(*domain.Server) bool) (*ServerState, []*ServerState) {
+	var selectedState *ServerState
+
+	eb.mu.RLock()
+	// Obtener servidores disponibles (excluyendo los que están drenando)
+	availableServers, saturated := eb.collectServers(allowed)
+	if len(availableServers) > 0 {
+		// Seleccionar servidor usando el algoritmo adaptativo
+		selectedState = eb.selectOptimalAlgorithm().SelectServer(availableServers, clientIP)
+	}
+	eb.mu.RUnlock()
+
+	// Último recurso: todos los circuitos abiertos, enviar una única prueba
+	if selectedState == nil && len(availableServers) == 0 {
+		selectedState = eb.selectLastResortServer(allowed)
+	}
+
+	return selectedState, saturated
+}
+
+// waitForSlot encola la request hasta que un servidor libere una conexión o
+// venza max_wait. Con la cola llena se rechaza al momento para que no crezca
+// sin límite bajo sobrecarga sostenida.
+func (eb *EnterpriseBalancer) waitForSlot(queue domain.QueueCfg, clientIP string, allowed func(*domain.Server) bool, saturated []*ServerState) *ServerState {
+	if atomic.AddInt64(&eb.queued, 1) > int64(queue.MaxDepth) {
+		atomic.AddInt64(&eb.queued, -1)
+		return nil
+	}
+	defer atomic.AddInt64(&eb.queued, -1)
+
+	// WaitingConns refleja las requests en cola a la espera de cada servidor
+	for _, state := range saturated {
+		atomic.AddInt64(&state.ConnectionPool.WaitingConns, 1)
+	}
+	defer func() {
+		for _, state := range saturated {
+			atomic.AddInt64(&state.ConnectionPool.WaitingConns, -1)
+		}
+	}()
+
+	timer := time.NewTimer(queue.MaxWait)
+	defer timer.Stop()
+
+	for {
+		freed := eb.slots.wait()
+		selectedState, stillSaturated := eb.pickServer(clientIP, allowed)
+		if selectedState != nil {
+			return selectedState
+		}
+		// Ya no falta capacidad sino servidores: esperar no sirve
+		if len(stillSaturated) == 0 {
+			return nil
+		}
+
+		select {
+		case <-freed:
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// QueueDepth devuelve las requests que esperan un hueco de conexión
+func (eb *EnterpriseBalancer) QueueDepth() int64 {
+	return atomic.LoadInt64(&eb.queued)
+}
+
+// serversStale indica si el slice recibido no es el último sincronizado con
+// UpdateServers ni uno de los backends sincronizados con UpdateBackends
+func (eb *EnterpriseBalancer) serversStale(servers []domain.Server) bool {
+	if len(servers) > 0 && eb.syncedBackends[&servers[0]] == len(servers) {
+		return false
+	}
+	if len(servers) != eb.syncedCount {
+		return true
+	}
+	return len(servers) > 0 && &servers[0] != eb.syncedServers
 }
 
 func (eb *EnterpriseBalancer) UpdateServers(servers []domain.Server, backend *domain.Backend) {
-	// Crear mapa de servidores actuales
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.updateServers(servers, backend)
+}
+
+// UpdateBackends sincroniza los servidores de todos los backends de una vez.
+// Los que ya existían conservan métricas, circuito y salud; solo los nuevos
+// empiezan de cero y se eliminan los que ya no aparecen en ningún backend.
+func (eb *EnterpriseBalancer) UpdateBackends(backends []domain.Backend) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	currentServers := make(map[string]bool)
+	members := make(map[string]map[string]bool, len(backends))
+	eb.syncedBackends = make(map[*domain.Server]int, len(backends))
+	for i := range backends {
+		backend := &backends[i]
+		urls := eb.upsertServers(backend.Servers, backend)
+		members[backend.Name] = urls
+		for url := range urls {
+			currentServers[url] = true
+		}
+		if len(backend.Servers) > 0 {
+			eb.syncedBackends[&backend.Servers[0]] = len(backend.Servers)
+		}
+	}
+	eb.removeServersExcept(currentServers)
+
+	// Con un único backend todos los servidores son suyos: no hace falta filtrar
+	eb.backendMembers = nil
+	if len(backends) > 1 {
+		eb.backendMembers = members
+	}
+	if len(backends) > 0 {
+		eb.applyBalanceMode(&backends[0])
+		eb.markSynced(backends[0].Servers)
+	}
+	eb.seeded = true
+}
+
+func (eb *EnterpriseBalancer) updateServers(servers []domain.Server, backend *domain.Backend) {
+	eb.removeServersExcept(eb.upsertServers(servers, backend))
+	eb.syncedBackends = nil
+	eb.backendMembers = nil
+	eb.applyBalanceMode(backend)
+	eb.markSynced(servers)
+	eb.seeded = true
+}
+
+// upsertServers crea el estado de los servidores nuevos y actualiza la
+// configuración de los existentes sin tocar lo aprendido del tráfico.
+// Devuelve las URLs válidas del backend.
+func (eb *EnterpriseBalancer) upsertServers(servers []domain.Server, backend *domain.Backend) map[string]bool {
 	currentServers := make(map[string]bool)
 	for i := range servers {
 		server := &servers[i]
+		// Un servidor con URL inválida nunca es seleccionable
+		if _, err := domain.ParseBackendServerURL(server.URL); err != nil {
+			slog.Warn("Skipping server", "backend", backend.Name, "error", err)
+			continue
+		}
 		currentServers[server.URL] = true
 		
 		if _, exists := eb.servers[server.URL]; !exists {
 			// Agregar servidor nuevo usando valores del YAML
+			pool := &ConnectionPool{MaxConnections: eb.maxConnectionsFor(servers, server)}
 			eb.servers[server.URL] = &ServerState{
 				Server: server,
 				Metrics: &ServerMetrics{
-					ResponseTimes: NewRingBuffer(1000),
-					LastUpdate:    time.Now(),
+					ResponseTimes: NewRingBuffer(eb.latencySamples),
+					LastUpdate:    eb.clock.Now(),
 				},
 				HealthState: Healthy,
 				CircuitBreaker: &CircuitBreaker{
-					State:            CircuitClosed,
-					FailureThreshold: backend.CircuitBreaker.FailureThreshold,
-					RecoveryTimeout:  backend.CircuitBreaker.RecoveryTimeout,
-				},
-				ConnectionPool: &ConnectionPool{
-					MaxConnections: eb.calculateDynamicMaxConnections(servers, server),
+					State:             CircuitClosed,
+					FailureThreshold:  backend.CircuitBreaker.FailureThreshold,
+					RecoveryTimeout:   backend.CircuitBreaker.RecoveryTimeout,
+					LastResort:        backend.CircuitBreaker.LastResort,
+					HalfOpenSuccesses: backend.CircuitBreaker.HalfOpenSuccesses,
 				},
+				ConnectionPool: pool,
 				Weight:          float64(server.Weight),
 				EffectiveWeight: float64(server.Weight),
-				CurrentWeight:   0,
+				Transport:       newBackendTransport(backend, pool, server.URL),
+				TransportConfig: backend.Transport,
+				Protocol:        backend.Protocol,
+				AddedAt:         eb.clock.Now(),
+				Warmup:          backend.ServerWarmup,
+			}
+			// Los servidores de la configuración inicial entran en rotación
+			// directamente; los añadidos después esperan a su health check
+			if eb.seeded {
+				eb.servers[server.URL].HealthState = Pending
+			}
+			if counters, ok := eb.restoredCounters[server.URL]; ok {
+				addServerCounters(eb.servers[server.URL].Metrics, counters)
+				delete(eb.restoredCounters, server.URL)
 			}
 		} else {
-			// Actualizar servidor existente
+			// Actualizar servidor existente; el peso adaptativo solo se reinicia
+			// si cambió el peso configurado
+			if eb.servers[server.URL].Weight != float64(server.Weight) {
+				eb.servers[server.URL].Weight = float64(server.Weight)
+				eb.servers[server.URL].EffectiveWeight = float64(server.Weight)
+			}
 			eb.servers[server.URL].Server = server
-			eb.servers[server.URL].Weight = float64(server.Weight)
-			eb.servers[server.URL].EffectiveWeight = float64(server.Weight)
 			// Actualizar configuración del circuit breaker y conexiones
 			eb.servers[server.URL].CircuitBreaker.FailureThreshold = backend.CircuitBreaker.FailureThreshold
 			eb.servers[server.URL].CircuitBreaker.RecoveryTimeout = backend.CircuitBreaker.RecoveryTimeout
-			eb.servers[server.URL].ConnectionPool.MaxConnections = eb.calculateDynamicMaxConnections(servers, server)
+			eb.servers[server.URL].CircuitBreaker.LastResort = backend.CircuitBreaker.LastResort
+			eb.servers[server.URL].CircuitBreaker.HalfOpenSuccesses = backend.CircuitBreaker.HalfOpenSuccesses
+			eb.servers[server.URL].ConnectionPool.MaxConnections = eb.maxConnectionsFor(servers, server)
+			eb.servers[server.URL].Warmup = backend.ServerWarmup
+			// Recrear el transporte solo si cambió su configuración para conservar las conexiones
+			state := eb.servers[server.URL]
+			if state.Transport == nil || state.TransportConfig != backend.Transport || state.Protocol != backend.Protocol {
+				if state.Transport != nil {
+					closeIdleConnections(state.Transport)
+				}
+				state.Transport = newBackendTransport(backend, state.ConnectionPool, server.URL)
+				state.TransportConfig = backend.Transport
+				state.Protocol = backend.Protocol
+			}
 		}
 	}
 	
-	// Eliminar servidores que ya no existen
+	return currentServers
+}
+
+// removeServersExcept elimina los servidores que ya no existen en la configuración
+func (eb *EnterpriseBalancer) removeServersExcept(currentServers map[string]bool) {
 	for url := range eb.servers {
 		if !currentServers[url] {
+			if eb.servers[url].Transport != nil {
+				closeIdleConnections(eb.servers[url].Transport)
+			}
 			delete(eb.servers, url)
+			eb.forgetAlgorithmState(url)
 		}
 	}
 }
 
-func (eb *EnterpriseBalancer) initializeServers(servers []domain.Server, backend *domain.Backend) {
-	eb.UpdateServers(servers, backend)
+func (eb *EnterpriseBalancer) applyBalanceMode(backend *domain.Backend) {
+	// balance_mode fija el algoritmo; vacío o adaptive_weighted mantiene la
+	// selección adaptativa salvo con adaptive_balancing: false, que fija
+	// balance_mode o, si no es un algoritmo conocido, adaptive_weighted
+	eb.pinnedAlgorithm = ""
+	_, known := eb.algorithms[backend.BalanceMode]
+	switch {
+	case known && backend.BalanceMode != "adaptive_weighted":
+		eb.pinnedAlgorithm = backend.BalanceMode
+	case !backend.AdaptiveBalancingEnabled():
+		eb.pinnedAlgorithm = "adaptive_weighted"
+	}
+}
+
+func (eb *EnterpriseBalancer) markSynced(servers []domain.Server) {
+	eb.syncedCount = len(servers)
+	eb.syncedServers = nil
+	if len(servers) > 0 {
+		eb.syncedServers = &servers[0]
+	}
+}
+
+func (eb *EnterpriseBalancer) getAvailableServers(allowed func(*domain.Server) bool) []*ServerState {
+	available, _ := eb.collectServers(allowed)
+	return available
 }
 
-func (eb *EnterpriseBalancer) getAvailableServers() []*ServerState {
-	var available []*ServerState
-	now := time.Now()
+// collectServers devuelve los servidores seleccionables y, aparte, los que solo
+// quedan fuera por haber alcanzado max_connections
+func (eb *EnterpriseBalancer) collectServers(allowed func(*domain.Server) bool) ([]*ServerState, []*ServerState) {
+	var available, saturated []*ServerState
+	now := eb.clock.Now()
 
 	for _, state := range eb.servers {
+		if allowed != nil && !allowed(state.Server) {
+			continue
+		}
+
 		// Excluir servidores que están drenando
 		if eb.serverLifecycle.IsServerDraining(state.Server.URL) {
 			continue
@@ -274,7 +602,7 @@ func (eb *EnterpriseBalancer) getAvailableServers() []*ServerState {
 
 		// Circuit breaker logic
 		if state.CircuitBreaker.State == CircuitOpen {
-			if now.After(state.CircuitBreaker.NextRetryTime) {
+			if !state.CircuitBreaker.ManualOverride && now.After(state.CircuitBreaker.NextRetryTime) {
 				state.CircuitBreaker.State = CircuitHalfOpen
 				state.CircuitBreaker.HalfOpenRequests = 0
 			} else {
@@ -283,113 +611,155 @@ func (eb *EnterpriseBalancer) getAvailableServers() []*ServerState {
 		}
 
 		// Health check
+		if state.HealthCheckFailed || state.HealthState == Pending {
+			continue
+		}
 		if state.HealthState == Unhealthy && now.Sub(state.LastHealthCheck) < 10*time.Second {
 			continue
 		}
 
 		// Connection limit
-		if state.ConnectionPool.ActiveConns >= int64(state.ConnectionPool.MaxConnections) {
+		if atomic.LoadInt64(&state.ConnectionPool.ActiveConns) >= int64(state.ConnectionPool.MaxConnections) {
+			saturated = append(saturated, state)
 			continue
 		}
 
 		available = append(available, state)
 	}
 
-	return available
+	return filterByPriority(available), saturated
 }
 
-func (eb *EnterpriseBalancer) selectOptimalAlgorithm() Algorithm {
-	// Evaluación adaptativa de algoritmos
-	eb.adaptiveController.mu.RLock()
-	lastSwitch := eb.adaptiveController.lastSwitch
-	eb.adaptiveController.mu.RUnlock()
-	
-	if time.Since(lastSwitch) > eb.adaptiveController.evaluationWindow {
-		bestAlgorithm := eb.evaluateAlgorithms()
-		if bestAlgorithm != eb.currentAlgorithm {
-			eb.adaptiveController.mu.RLock()
-			currentScore := eb.adaptiveController.algorithmScores[eb.currentAlgorithm]
-			bestScore := eb.adaptiveController.algorithmScores[bestAlgorithm]
-			eb.adaptiveController.mu.RUnlock()
-			
-			if bestScore-currentScore > eb.adaptiveController.switchThreshold {
-				eb.currentAlgorithm = bestAlgorithm
-				eb.adaptiveController.mu.Lock()
-				eb.adaptiveController.lastSwitch = time.Now()
-				eb.adaptiveController.mu.Unlock()
+// filterByPriority conserva solo el nivel de prioridad más alto disponible
+// (menor valor): los backups reciben tráfico únicamente cuando no queda ningún
+// primario y vuelven a quedar fuera en cuanto un primario se recupera.
+func filterByPriority(servers []*ServerState) []*ServerState {
+	if len(servers) == 0 {
+		return servers
+	}
+
+	best := servers[0].Server.Priority
+	mixed := false
+	for _, state := range servers[1:] {
+		if state.Server.Priority != best {
+			mixed = true
+			if state.Server.Priority < best {
+				best = state.Server.Priority
 			}
 		}
 	}
+	if !mixed {
+		return servers
+	}
 
-	return eb.algorithms[eb.currentAlgorithm]
+	filtered := servers[:0]
+	for _, state := range servers {
+		if state.Server.Priority == best {
+			filtered = append(filtered, state)
+		}
+	}
+	return filtered
 }
 
-func (eb *EnterpriseBalancer) evaluateAlgorithms() string {
-	bestAlgorithm := eb.currentAlgorithm
-	bestScore := 0.0
+// selectLastResortServer elige, entre los servidores con circuito abierto y
+// last_resort habilitado, el más cercano a su NextRetryTime. Solo se permite
+// una prueba en vuelo a la vez.
+func (eb *EnterpriseBalancer) selectLastResortServer(allowed func(*domain.Server) bool) *ServerState {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
 
-	eb.adaptiveController.mu.Lock()
-	defer eb.adaptiveController.mu.Unlock()
-	
-	for name, _ := range eb.algorithms {
-		score := eb.calculateAlgorithmScore(name)
-		eb.adaptiveController.algorithmScores[name] = score
-		
-		if score > bestScore {
-			bestScore = score
-			bestAlgorithm = name
+	var candidate *ServerState
+	for _, state := range eb.servers {
+		if allowed != nil && !allowed(state.Server) {
+			continue
+		}
+		cb := state.CircuitBreaker
+		if !cb.LastResort || cb.State != CircuitOpen || cb.ManualOverride || eb.serverLifecycle.IsServerDraining(state.Server.URL) {
+			continue
+		}
+		// Ya hay una prueba en curso: no saturar servidores caídos
+		if cb.ProbeInFlight {
+			return nil
+		}
+		if candidate == nil || cb.NextRetryTime.Before(candidate.CircuitBreaker.NextRetryTime) {
+			candidate = state
 		}
 	}
 
-	return bestAlgorithm
+	if candidate != nil {
+		candidate.CircuitBreaker.ProbeInFlight = true
+	}
+	return candidate
 }
 
-func (eb *EnterpriseBalancer) calculateAlgorithmScore(algorithmName string) float64 {
-	// Score basado en múltiples métricas
-	errorRateScore := (1.0 - eb.performanceMonitor.globalMetrics.ErrorRate) * 0.3
-	
-	responseTimeScore := 0.0
-	if eb.performanceMonitor.globalMetrics.AvgResponseTime > 0 {
-		responseTimeScore = math.Max(0, 1.0-float64(eb.performanceMonitor.globalMetrics.AvgResponseTime)/float64(time.Second)) * 0.3
+func (eb *EnterpriseBalancer) selectOptimalAlgorithm() Algorithm {
+	if eb.pinnedAlgorithm != "" {
+		return eb.algorithms[eb.pinnedAlgorithm]
 	}
-	
-	throughputScore := math.Min(1.0, eb.performanceMonitor.globalMetrics.ThroughputRPS/1000.0) * 0.2
-	
-	balanceScore := eb.calculateLoadBalanceScore() * 0.2
-	
-	return errorRateScore + responseTimeScore + throughputScore + balanceScore
-}
 
-func (eb *EnterpriseBalancer) calculateLoadBalanceScore() float64 {
-	if len(eb.servers) < 2 {
-		return 1.0
+	eb.adaptiveController.mu.RLock()
+	current := eb.currentAlgorithm
+	due := eb.adaptiveController.clock.Now().Sub(eb.adaptiveController.lastEvaluation) > eb.adaptiveController.evaluationWindow
+	eb.adaptiveController.mu.RUnlock()
+
+	// Evaluación adaptativa de algoritmos, como máximo una vez por ventana
+	if due {
+		current = eb.reevaluateAlgorithm()
 	}
 
-	var loads []float64
-	for _, state := range eb.servers {
-		load := float64(atomic.LoadInt64(&state.Metrics.RequestCount))
-		loads = append(loads, load)
+	return eb.algorithms[current]
+}
+
+func (eb *EnterpriseBalancer) reevaluateAlgorithm() string {
+	eb.adaptiveController.mu.Lock()
+	defer eb.adaptiveController.mu.Unlock()
+
+	// Otra goroutine pudo evaluar mientras esperábamos el lock
+	now := eb.adaptiveController.clock.Now()
+	if now.Sub(eb.adaptiveController.lastEvaluation) <= eb.adaptiveController.evaluationWindow {
+		return eb.currentAlgorithm
 	}
+	eb.adaptiveController.lastEvaluation = now
+
+	// Atribuir la ventana que termina al algoritmo que estuvo activo
+	eb.adaptiveController.recordWindow(eb.currentAlgorithm, eb.servers, now)
+
+	bestAlgorithm := eb.evaluateAlgorithms()
+	if bestAlgorithm != eb.currentAlgorithm {
+		currentScore := eb.adaptiveController.algorithmScores[eb.currentAlgorithm]
+		bestScore := eb.adaptiveController.algorithmScores[bestAlgorithm]
 
-	// Calcular coeficiente de variación
-	mean := 0.0
-	for _, load := range loads {
-		mean += load
+		if bestScore-currentScore > eb.adaptiveController.switchThreshold {
+			eb.currentAlgorithm = bestAlgorithm
+			eb.adaptiveController.lastSwitch = now
+		}
 	}
-	mean /= float64(len(loads))
 
-	if mean == 0 {
-		return 1.0
+	return eb.currentAlgorithm
+}
+
+// evaluateAlgorithms requiere adaptiveController.mu tomado
+func (eb *EnterpriseBalancer) evaluateAlgorithms() string {
+	names := make([]string, 0, len(eb.algorithms))
+	for name := range eb.algorithms {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	variance := 0.0
-	for _, load := range loads {
-		variance += (load - mean) * (load - mean)
+	bestAlgorithm := eb.currentAlgorithm
+	bestScore := 0.0
+
+	for _, name := range names {
+		score := eb.adaptiveController.algorithmScore(name)
+		eb.adaptiveController.algorithmScores[name] = score
+		
+		if score > bestScore {
+			bestScore = score
+			bestAlgorithm = name
+		}
 	}
-	variance /= float64(len(loads))
 
-	cv := math.Sqrt(variance) / mean
-	return math.Max(0, 1.0-cv)
+	return bestAlgorithm
 }
 
 func (eb *EnterpriseBalancer) UpdateStats(server *domain.Server, responseTime time.Duration, success bool) {
@@ -403,19 +773,38 @@ func (eb *EnterpriseBalancer) UpdateStats(server *domain.Server, responseTime ti
 
 	// Actualizar métricas del servidor
 	state.Metrics.ResponseTimes.Add(responseTime)
+	eb.observeLatency(state, responseTime)
 	atomic.AddInt64(&state.Metrics.TotalLatency, int64(responseTime))
 	atomic.AddInt64(&state.ConnectionPool.ActiveConns, -1)
+	eb.slots.notify()
+	if state.Metrics.EWMAResponseTime == 0 {
+		state.Metrics.EWMAResponseTime = responseTime
+	} else {
+		state.Metrics.EWMAResponseTime = time.Duration(ewmaAlpha*float64(responseTime) + (1-ewmaAlpha)*float64(state.Metrics.EWMAResponseTime))
+	}
+	state.Metrics.observePeakEWMA(responseTime, eb.clock.Now())
+
+	probe := state.CircuitBreaker.ProbeInFlight
+	state.CircuitBreaker.ProbeInFlight = false
 
 	if success {
 		atomic.AddInt64(&state.Metrics.SuccessCount, 1)
 		state.CircuitBreaker.SuccessCount++
+
+		// Prueba de último recurso exitosa: pasar a half-open para recuperar
+		if probe && state.CircuitBreaker.State == CircuitOpen {
+			state.CircuitBreaker.State = CircuitHalfOpen
+			state.CircuitBreaker.HalfOpenRequests = 0
+			state.CircuitBreaker.ManualOverride = false
+		}
 		
 		// Reset circuit breaker si está en half-open
 		if state.CircuitBreaker.State == CircuitHalfOpen {
 			state.CircuitBreaker.HalfOpenRequests++
-			if state.CircuitBreaker.HalfOpenRequests >= 5 {
+			if state.CircuitBreaker.HalfOpenRequests >= state.CircuitBreaker.halfOpenSuccesses() {
 				state.CircuitBreaker.State = CircuitClosed
 				state.CircuitBreaker.FailureCount = 0
+				state.CircuitBreaker.ManualOverride = false
 			}
 		}
 		
@@ -426,13 +815,17 @@ func (eb *EnterpriseBalancer) UpdateStats(server *domain.Server, responseTime ti
 	} else {
 		atomic.AddInt64(&state.Metrics.FailureCount, 1)
 		state.CircuitBreaker.FailureCount++
-		state.CircuitBreaker.LastFailureTime = time.Now()
+		state.CircuitBreaker.LastFailureTime = eb.clock.Now()
 		state.ConsecutiveFails++
 
-		// Circuit breaker logic
-		if state.CircuitBreaker.FailureCount >= int64(state.CircuitBreaker.FailureThreshold) {
+		// Circuit breaker logic (una apertura manual se respeta hasta que se revierta).
+		// Un fallo en half-open reabre el circuito sin esperar al umbral.
+		manuallyOpen := state.CircuitBreaker.ManualOverride && state.CircuitBreaker.State == CircuitOpen
+		halfOpen := state.CircuitBreaker.State == CircuitHalfOpen
+		if !manuallyOpen && (halfOpen || state.CircuitBreaker.FailureCount >= int64(state.CircuitBreaker.FailureThreshold)) {
 			state.CircuitBreaker.State = CircuitOpen
-			state.CircuitBreaker.NextRetryTime = time.Now().Add(state.CircuitBreaker.RecoveryTimeout)
+			state.CircuitBreaker.NextRetryTime = eb.clock.Now().Add(state.CircuitBreaker.RecoveryTimeout)
+			state.CircuitBreaker.ManualOverride = false
 		}
 
 		// Health state degradation
@@ -453,26 +846,22 @@ func (eb *EnterpriseBalancer) updateCalculatedMetrics(state *ServerState) {
 	totalReqs := atomic.LoadInt64(&state.Metrics.RequestCount)
 	if totalReqs > 0 {
 		successReqs := atomic.LoadInt64(&state.Metrics.SuccessCount)
-		state.Metrics.ErrorRate = 1.0 - (float64(successReqs) / float64(totalReqs))
+		// Tras un reset, las requests que ya estaban en curso suman éxitos sin request
+		state.Metrics.ErrorRate = math.Max(0, 1.0-(float64(successReqs)/float64(totalReqs)))
 		
 		// Calcular percentiles
 		times := state.Metrics.ResponseTimes.GetAll()
 		if len(times) > 0 {
 			sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
-			
-			p95Index := int(float64(len(times)) * 0.95)
-			p99Index := int(float64(len(times)) * 0.99)
-			
-			if p95Index < len(times) {
-				state.Metrics.P95ResponseTime = times[p95Index]
-			}
-			if p99Index < len(times) {
-				state.Metrics.P99ResponseTime = times[p99Index]
-			}
+
+			// P95 y P99 siempre: los algoritmos de balanceo usan P95
+			state.Metrics.P95ResponseTime = percentile(times, 95)
+			state.Metrics.P99ResponseTime = percentile(times, 99)
+			state.Metrics.Percentiles = latencyPercentiles(times, eb.percentiles)
 		}
 	}
 	
-	state.Metrics.LastUpdate = time.Now()
+	state.Metrics.LastUpdate = eb.clock.Now()
 }
 
 func (eb *EnterpriseBalancer) updateGlobalMetrics() {
@@ -496,6 +885,97 @@ func (eb *EnterpriseBalancer) updateGlobalMetrics() {
 	}
 }
 
+// RestoreMetrics suma los contadores de una ejecución anterior. Los servidores
+// que aún no existen los reciben cuando updateServers los crea.
+func (eb *EnterpriseBalancer) RestoreMetrics(snapshot *domain.MetricsSnapshot) {
+	if snapshot == nil {
+		return
+	}
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.restoredCounters = make(map[string]domain.ServerCounters)
+	for url, counters := range snapshot.Servers {
+		if state, exists := eb.servers[url]; exists {
+			addServerCounters(state.Metrics, counters)
+			continue
+		}
+		eb.restoredCounters[url] = counters
+	}
+	eb.updateGlobalMetrics()
+}
+
+// MetricsSnapshot copia los contadores acumulados para persistirlos
+func (eb *EnterpriseBalancer) MetricsSnapshot() *domain.MetricsSnapshot {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	snapshot := &domain.MetricsSnapshot{
+		Servers: make(map[string]domain.ServerCounters, len(eb.servers)+len(eb.restoredCounters)),
+		SavedAt: time.Now(),
+	}
+	// Conservar lo restaurado de servidores sin tráfico todavía en esta ejecución
+	for url, counters := range eb.restoredCounters {
+		snapshot.Servers[url] = counters
+	}
+	for url, state := range eb.servers {
+		snapshot.Servers[url] = domain.ServerCounters{
+			RequestCount: atomic.LoadInt64(&state.Metrics.RequestCount),
+			SuccessCount: atomic.LoadInt64(&state.Metrics.SuccessCount),
+			FailureCount: atomic.LoadInt64(&state.Metrics.FailureCount),
+			TotalLatency: atomic.LoadInt64(&state.Metrics.TotalLatency),
+		}
+	}
+	return snapshot
+}
+
+// ResetMetrics pone a cero los contadores y las muestras de latencia de todos
+// los servidores y devuelve sus valores justo antes. Cada contador se
+// intercambia de forma atómica: una request concurrente cuenta en el snapshot
+// o en el periodo nuevo, nunca se pierde ni se cuenta dos veces.
+func (eb *EnterpriseBalancer) ResetMetrics() *domain.MetricsSnapshot {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	snapshot := &domain.MetricsSnapshot{
+		Servers: make(map[string]domain.ServerCounters, len(eb.servers)+len(eb.restoredCounters)),
+		SavedAt: time.Now(),
+	}
+	for url, counters := range eb.restoredCounters {
+		snapshot.Servers[url] = counters
+	}
+	eb.restoredCounters = nil
+
+	for url, state := range eb.servers {
+		snapshot.Servers[url] = domain.ServerCounters{
+			RequestCount: atomic.SwapInt64(&state.Metrics.RequestCount, 0),
+			SuccessCount: atomic.SwapInt64(&state.Metrics.SuccessCount, 0),
+			FailureCount: atomic.SwapInt64(&state.Metrics.FailureCount, 0),
+			TotalLatency: atomic.SwapInt64(&state.Metrics.TotalLatency, 0),
+		}
+		state.Metrics.ResponseTimes.Reset()
+		state.Metrics.Upgrades.reset()
+		state.Metrics.Latencies = nil
+		state.Metrics.P95ResponseTime = 0
+		state.Metrics.P99ResponseTime = 0
+		state.Metrics.Percentiles = nil
+		state.Metrics.ErrorRate = 0
+		state.Metrics.LastUpdate = snapshot.SavedAt
+	}
+
+	*eb.performanceMonitor.globalMetrics = GlobalMetrics{}
+	eb.updateGlobalMetrics()
+	return snapshot
+}
+
+func addServerCounters(metrics *ServerMetrics, counters domain.ServerCounters) {
+	atomic.AddInt64(&metrics.RequestCount, counters.RequestCount)
+	atomic.AddInt64(&metrics.SuccessCount, counters.SuccessCount)
+	atomic.AddInt64(&metrics.FailureCount, counters.FailureCount)
+	atomic.AddInt64(&metrics.TotalLatency, counters.TotalLatency)
+}
+
 func (eb *EnterpriseBalancer) GetServerMetrics() map[string]*domain.Server {
 	eb.mu.RLock()
 	defer eb.mu.RUnlock()
@@ -504,22 +984,70 @@ func (eb *EnterpriseBalancer) GetServerMetrics() map[string]*domain.Server {
 	for url, state := range eb.servers {
 		// Crear una copia del servidor con métricas actualizadas
 		server := &domain.Server{
-			URL:            state.Server.URL,
-			Weight:         state.Server.Weight,
-			MaxConnections: state.Server.MaxConnections,
-			Active:         state.Server.Active,
-			Healthy:        state.HealthState == Healthy,
-			CircuitOpen:    state.CircuitBreaker.State == CircuitOpen,
-			TotalRequests:  atomic.LoadInt64(&state.Metrics.RequestCount),
-			FailedRequests: atomic.LoadInt64(&state.Metrics.FailureCount),
-			CurrentConns:   atomic.LoadInt64(&state.ConnectionPool.ActiveConns),
-			ResponseTime:   state.Metrics.P95ResponseTime,
+			URL:             state.Server.URL,
+			Weight:          state.Server.Weight,
+			EffectiveWeight: state.EffectiveWeight,
+			MaxConnections:  state.ConnectionPool.MaxConnections,
+			Priority:        state.Server.Priority,
+			Labels:          state.Server.Labels,
+			Active:          state.Server.Active,
+			Healthy:         state.HealthState == Healthy,
+			CircuitOpen:     state.CircuitBreaker.State == CircuitOpen,
+			CircuitOverride: state.CircuitBreaker.ManualOverride,
+			OpenConns:       atomic.LoadInt64(&state.ConnectionPool.OpenConns),
+			IdleConns:       state.ConnectionPool.IdleConns(),
+			NewConns:        atomic.LoadInt64(&state.ConnectionPool.NewConns),
+			ReusedConns:     atomic.LoadInt64(&state.ConnectionPool.ReusedConns),
+			TotalRequests:   atomic.LoadInt64(&state.Metrics.RequestCount),
+			FailedRequests:  atomic.LoadInt64(&state.Metrics.FailureCount),
+			CurrentConns:    atomic.LoadInt64(&state.ConnectionPool.ActiveConns),
+			ResponseTime:    state.Metrics.P95ResponseTime,
+			// El mapa no se modifica después de calcularse: se puede compartir
+			LatencyPercentiles: state.Metrics.Percentiles,
+			UpgradedConns:      atomic.LoadInt64(&state.ConnectionPool.UpgradedConns),
+			UpgradedClosed:     state.Metrics.Upgrades.closed(),
 		}
 		metrics[url] = server
 	}
 	return metrics
 }
 
+// ReportHealth recibe el resultado del health checker. Un check fallido saca al
+// servidor de rotación aunque no reciba tráfico; uno exitoso lo reincorpora y
+// pasa a Recovering si el tráfico lo había marcado Unhealthy.
+func (eb *EnterpriseBalancer) ReportHealth(serverURL string, healthy bool) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	state, exists := eb.servers[serverURL]
+	if !exists {
+		return
+	}
+
+	state.LastHealthCheck = eb.clock.Now()
+	if !healthy {
+		state.HealthCheckFailed = true
+		// Un servidor pendiente sigue pendiente: Recovering lo metería en rotación
+		if state.HealthState != Pending {
+			state.HealthState = Unhealthy
+		}
+		return
+	}
+
+	state.HealthCheckFailed = false
+	if state.HealthState == Pending {
+		if eb.clock.Now().Sub(state.AddedAt) >= state.Warmup {
+			state.HealthState = Healthy
+			slog.Info("Server ready", "server", serverURL, "warmup", state.Warmup)
+		}
+		return
+	}
+	if state.HealthState == Unhealthy {
+		state.HealthState = Recovering
+		state.ConsecutiveFails = 0
+	}
+}
+
 func (eb *EnterpriseBalancer) GracefulRemoveServer(serverURL string) bool {
 	eb.mu.RLock()
 	state, exists := eb.servers[serverURL]
@@ -533,6 +1061,40 @@ func (eb *EnterpriseBalancer) GracefulRemoveServer(serverURL string) bool {
 	return true
 }
 
+// forgetServer suelta un servidor ya drenado y cierra sus conexiones inactivas
+func (eb *EnterpriseBalancer) forgetServer(serverURL string) {
+	eb.mu.Lock()
+	if state, exists := eb.servers[serverURL]; exists && state.Transport != nil {
+		closeIdleConnections(state.Transport)
+	}
+	delete(eb.servers, serverURL)
+	eb.forgetAlgorithmState(serverURL)
+	eb.mu.Unlock()
+}
+
+// serverForgetter lo implementan los algoritmos que guardan estado por servidor
+type serverForgetter interface {
+	forgetServer(serverURL string)
+}
+
+// forgetAlgorithmState descarta el estado que los algoritmos guardan del
+// servidor eliminado. Requiere eb.mu tomado.
+func (eb *EnterpriseBalancer) forgetAlgorithmState(serverURL string) {
+	for _, algorithm := range eb.algorithms {
+		if forgetter, ok := algorithm.(serverForgetter); ok {
+			forgetter.forgetServer(serverURL)
+		}
+	}
+}
+
+// HasServer indica si el balanceador todavía conoce a serverURL
+func (eb *EnterpriseBalancer) HasServer(serverURL string) bool {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	_, exists := eb.servers[serverURL]
+	return exists
+}
+
 func (eb *EnterpriseBalancer) IsServerDraining(serverURL string) bool {
 	return eb.serverLifecycle.IsServerDraining(serverURL)
 }
@@ -541,6 +1103,54 @@ func (eb *EnterpriseBalancer) GetDrainingServers() []string {
 	return eb.serverLifecycle.GetDrainingServers()
 }
 
+func (eb *EnterpriseBalancer) GetDrainStatus() []DrainStatus {
+	return eb.serverLifecycle.GetDrainStatus()
+}
+
+// CancelDrain devuelve el servidor a rotación si todavía está drenando
+func (eb *EnterpriseBalancer) CancelDrain(serverURL string) bool {
+	return eb.serverLifecycle.CancelRemoval(serverURL)
+}
+
+func (cb *CircuitBreaker) halfOpenSuccesses() int {
+	if cb.HalfOpenSuccesses > 0 {
+		return cb.HalfOpenSuccesses
+	}
+	return domain.DefaultHalfOpenSuccesses
+}
+
+// ForceCircuitState fija a mano el circuito de un servidor; false si no existe.
+// Abrirlo lo saca de rotación hasta que se cierre o pase a half-open; cerrarlo
+// reinicia los contadores y devuelve el control al breaker automático.
+func (eb *EnterpriseBalancer) ForceCircuitState(serverURL string, circuit CircuitState) bool {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	state, exists := eb.servers[serverURL]
+	if !exists {
+		return false
+	}
+
+	cb := state.CircuitBreaker
+	cb.State = circuit
+	cb.HalfOpenRequests = 0
+	cb.ProbeInFlight = false
+	cb.ManualOverride = true
+	if circuit == CircuitClosed {
+		cb.FailureCount = 0
+	}
+	return true
+}
+
+// maxConnectionsFor usa max_connections del servidor si está configurado y,
+// si no, la capacidad dinámica calculada por peso.
+func (eb *EnterpriseBalancer) maxConnectionsFor(servers []domain.Server, server *domain.Server) int {
+	if server.MaxConnections > 0 {
+		return server.MaxConnections
+	}
+	return eb.calculateDynamicMaxConnections(servers, server)
+}
+
 func (eb *EnterpriseBalancer) calculateDynamicMaxConnections(servers []domain.Server, currentServer *domain.Server) int {
 	// Capacidad base por servidor (configurable)
 	baseCapacity := 1000
@@ -1,6 +1,7 @@
 package infrastructure
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -102,6 +103,29 @@ func TestEnterpriseBalancer_UpdateServers(t *testing.T) {
 	}
 }
 
+func TestEnterpriseBalancer_UpdateBackendsKeepsAdaptiveWeight(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateBackends([]domain.Backend{{
+		Name:    "web",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 2, Active: true}, {URL: "http://localhost:3002", Weight: 1, Active: true}},
+	}})
+	balancer.servers["http://localhost:3001"].EffectiveWeight = 0.5
+	balancer.servers["http://localhost:3002"].EffectiveWeight = 0.5
+
+	// Misma configuración para 3001; 3002 cambia de peso
+	balancer.UpdateBackends([]domain.Backend{{
+		Name:    "web",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 2, Active: true}, {URL: "http://localhost:3002", Weight: 4, Active: true}},
+	}})
+
+	if got := balancer.servers["http://localhost:3001"].EffectiveWeight; got != 0.5 {
+		t.Errorf("expected unchanged server to keep its adaptive weight, got %v", got)
+	}
+	if got := balancer.servers["http://localhost:3002"].EffectiveWeight; got != 4 {
+		t.Errorf("expected reweighted server to restart from its new weight, got %v", got)
+	}
+}
+
 func TestEnterpriseBalancer_UpdateStats(t *testing.T) {
 	balancer := NewEnterpriseBalancer()
 	
@@ -184,6 +208,186 @@ func TestEnterpriseBalancer_CircuitBreaker(t *testing.T) {
 	}
 }
 
+func TestEnterpriseBalancer_CircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		successes int
+		want      int
+	}{
+		{"default threshold", 0, domain.DefaultHalfOpenSuccesses},
+		{"fewer probes", 2, 2},
+		{"more probes", 8, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			balancer := NewEnterpriseBalancer()
+			balancer.SetClock(clock)
+			server := &domain.Server{URL: "http://localhost:3001", Weight: 1, Active: true}
+			balancer.UpdateServers([]domain.Server{*server}, &domain.Backend{
+				CircuitBreaker: domain.CircuitBreakerCfg{
+					FailureThreshold:  3,
+					RecoveryTimeout:   30 * time.Second,
+					HalfOpenSuccesses: tt.successes,
+				},
+			})
+			cb := balancer.servers[server.URL].CircuitBreaker
+
+			for i := 0; i < 3; i++ {
+				balancer.UpdateStats(server, time.Millisecond, false)
+			}
+			clock.Advance(31 * time.Second)
+			balancer.getAvailableServers(nil)
+			if cb.State != CircuitHalfOpen {
+				t.Fatalf("expected half-open after recovery timeout, got %v", cb.State)
+			}
+
+			for i := 1; i < tt.want; i++ {
+				balancer.UpdateStats(server, time.Millisecond, true)
+			}
+			if cb.State != CircuitHalfOpen {
+				t.Fatalf("expected half-open after %d successes, got %v", tt.want-1, cb.State)
+			}
+			balancer.UpdateStats(server, time.Millisecond, true)
+			if cb.State != CircuitClosed {
+				t.Errorf("expected closed after %d successes, got %v", tt.want, cb.State)
+			}
+		})
+	}
+}
+
+func TestEnterpriseBalancer_CircuitBreakerHalfOpenFailureReopens(t *testing.T) {
+	clock := newFakeClock()
+	balancer := NewEnterpriseBalancer()
+	balancer.SetClock(clock)
+	server := &domain.Server{URL: "http://localhost:3001", Weight: 1, Active: true}
+	balancer.UpdateServers([]domain.Server{*server}, &domain.Backend{
+		CircuitBreaker: domain.CircuitBreakerCfg{FailureThreshold: 3, RecoveryTimeout: 30 * time.Second},
+	})
+	state := balancer.servers[server.URL]
+
+	for i := 0; i < 3; i++ {
+		balancer.UpdateStats(server, time.Millisecond, false)
+	}
+	clock.Advance(31 * time.Second)
+	balancer.getAvailableServers(nil)
+	balancer.UpdateStats(server, time.Millisecond, true)
+
+	// Aunque el contador se hubiera reiniciado, un fallo en half-open reabre
+	state.CircuitBreaker.FailureCount = 0
+	balancer.UpdateStats(server, time.Millisecond, false)
+
+	if state.CircuitBreaker.State != CircuitOpen {
+		t.Fatalf("expected a half-open failure to reopen the circuit, got %v", state.CircuitBreaker.State)
+	}
+	if want := clock.Now().Add(30 * time.Second); !state.CircuitBreaker.NextRetryTime.Equal(want) {
+		t.Errorf("expected next retry at %v, got %v", want, state.CircuitBreaker.NextRetryTime)
+	}
+	if len(balancer.getAvailableServers(nil)) != 0 {
+		t.Error("expected the reopened server out of rotation")
+	}
+}
+
+func TestEnterpriseBalancer_MaxConnectionsFromConfig(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+
+	backend := &domain.Backend{
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true, MaxConnections: 2},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	limited := balancer.servers["http://localhost:3001"]
+	if limited.ConnectionPool.MaxConnections != 2 {
+		t.Fatalf("expected max connections 2, got %d", limited.ConnectionPool.MaxConnections)
+	}
+	if balancer.servers["http://localhost:3002"].ConnectionPool.MaxConnections <= 0 {
+		t.Error("expected dynamic max connections when not configured")
+	}
+
+	// Servidor en su límite no debe ser seleccionado
+	limited.ConnectionPool.ActiveConns = 2
+	for i := 0; i < 10; i++ {
+		server := balancer.SelectServer(backend, "192.168.1.1")
+		if server == nil || server.URL != "http://localhost:3002" {
+			t.Fatalf("expected only http://localhost:3002 to be selected, got %v", server)
+		}
+	}
+
+	// Cambios de configuración actualizan el límite
+	updated := []domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true, MaxConnections: 5},
+		{URL: "http://localhost:3002", Weight: 1, Active: true},
+	}
+	balancer.UpdateServers(updated, backend)
+	if limited.ConnectionPool.MaxConnections != 5 {
+		t.Errorf("expected max connections 5 after update, got %d", limited.ConnectionPool.MaxConnections)
+	}
+}
+
+func TestEnterpriseBalancer_LastResortProbe(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+
+	backend := &domain.Backend{
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+		CircuitBreaker: domain.CircuitBreakerCfg{
+			FailureThreshold: 1,
+			RecoveryTimeout:  30 * time.Second,
+			LastResort:       true,
+		},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	balancer.UpdateStats(&backend.Servers[0], time.Second, false)
+	balancer.UpdateStats(&backend.Servers[1], time.Second, false)
+
+	// El primero en reintentar es el candidato
+	balancer.servers["http://localhost:3001"].CircuitBreaker.NextRetryTime = time.Now().Add(time.Minute)
+	balancer.servers["http://localhost:3002"].CircuitBreaker.NextRetryTime = time.Now().Add(5 * time.Second)
+
+	probe := balancer.SelectServer(backend, "192.168.1.1")
+	if probe == nil || probe.URL != "http://localhost:3002" {
+		t.Fatalf("expected last-resort probe to http://localhost:3002, got %v", probe)
+	}
+
+	// Solo una prueba en vuelo
+	if second := balancer.SelectServer(backend, "192.168.1.1"); second != nil {
+		t.Errorf("expected no second probe while one is in flight, got %s", second.URL)
+	}
+
+	balancer.UpdateStats(probe, 50*time.Millisecond, true)
+
+	if state := balancer.servers["http://localhost:3002"].CircuitBreaker.State; state != CircuitHalfOpen {
+		t.Errorf("expected circuit half-open after successful probe, got %v", state)
+	}
+}
+
+func TestEnterpriseBalancer_LastResortDisabled(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+
+	backend := &domain.Backend{
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+		},
+		CircuitBreaker: domain.CircuitBreakerCfg{
+			FailureThreshold: 1,
+			RecoveryTimeout:  30 * time.Second,
+		},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+	balancer.UpdateStats(&backend.Servers[0], time.Second, false)
+
+	if server := balancer.SelectServer(backend, "192.168.1.1"); server != nil {
+		t.Errorf("expected no server without last_resort, got %s", server.URL)
+	}
+}
+
 func TestEnterpriseBalancer_GetServerMetrics(t *testing.T) {
 	balancer := NewEnterpriseBalancer()
 	
@@ -224,6 +428,15 @@ func TestEnterpriseBalancer_GetServerMetrics(t *testing.T) {
 	if server2Metrics.FailedRequests != 1 {
 		t.Errorf("expected 1 failed request for server2, got %d", server2Metrics.FailedRequests)
 	}
+
+	// Peso efectivo y límite de conexiones reflejan el estado del balanceador
+	state := balancer.servers["http://localhost:3002"]
+	if server2Metrics.EffectiveWeight != state.EffectiveWeight {
+		t.Errorf("expected effective weight %f, got %f", state.EffectiveWeight, server2Metrics.EffectiveWeight)
+	}
+	if server2Metrics.MaxConnections != state.ConnectionPool.MaxConnections {
+		t.Errorf("expected max connections %d, got %d", state.ConnectionPool.MaxConnections, server2Metrics.MaxConnections)
+	}
 }
 
 func TestEnterpriseBalancer_HealthStateTransitions(t *testing.T) {
@@ -276,4 +489,404 @@ func TestEnterpriseBalancer_HealthStateTransitions(t *testing.T) {
 	if state.ConsecutiveFails != 0 {
 		t.Errorf("expected consecutive fails to be reset, got %d", state.ConsecutiveFails)
 	}
-}
\ No newline at end of file
+}
+func BenchmarkEnterpriseBalancer_SelectServer(b *testing.B) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name: "bench-backend",
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 2, Active: true},
+			{URL: "http://localhost:3003", Weight: 3, Active: true},
+		},
+	}
+
+	balancer.UpdateServers(backend.Servers, backend)
+	pools := make(map[string]*ConnectionPool)
+	for url, state := range balancer.servers {
+		pools[url] = state.ConnectionPool
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if server := balancer.SelectServer(backend, "192.168.1.1"); server != nil {
+				atomic.AddInt64(&pools[server.URL].ActiveConns, -1)
+			}
+		}
+	})
+}
+
+func TestEnterpriseBalancer_ReportHealth(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	servers := []domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true},
+		{URL: "http://localhost:3002", Weight: 1, Active: true},
+	}
+	balancer.UpdateServers(servers, &domain.Backend{})
+
+	// Un check fallido expulsa al servidor aunque no haya recibido tráfico
+	balancer.ReportHealth("http://localhost:3001", false)
+	for _, state := range balancer.getAvailableServers(nil) {
+		if state.Server.URL == "http://localhost:3001" {
+			t.Fatal("expected server failing health checks to be excluded")
+		}
+	}
+	if balancer.servers["http://localhost:3001"].HealthState != Unhealthy {
+		t.Errorf("expected Unhealthy state, got %v", balancer.servers["http://localhost:3001"].HealthState)
+	}
+
+	// La exclusión no caduca por tiempo: solo un check exitoso la levanta
+	balancer.servers["http://localhost:3001"].LastHealthCheck = time.Now().Add(-time.Minute)
+	if len(balancer.getAvailableServers(nil)) != 1 {
+		t.Error("expected server to stay excluded until a passing check")
+	}
+
+	balancer.ReportHealth("http://localhost:3001", true)
+	if len(balancer.getAvailableServers(nil)) != 2 {
+		t.Error("expected server back in rotation after a passing check")
+	}
+	if balancer.servers["http://localhost:3001"].HealthState != Recovering {
+		t.Errorf("expected Recovering state, got %v", balancer.servers["http://localhost:3001"].HealthState)
+	}
+}
+
+func TestEnterpriseBalancer_NewServerWaitsForHealthCheck(t *testing.T) {
+	clock := newFakeClock()
+	balancer := NewEnterpriseBalancer()
+	balancer.SetClock(clock)
+	initial := []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}}
+	balancer.UpdateBackends([]domain.Backend{{Name: "web", Servers: initial}})
+
+	// Los servidores de la configuración inicial entran en rotación directamente
+	if len(balancer.getAvailableServers(nil)) != 1 {
+		t.Fatal("expected initial server in rotation")
+	}
+
+	scaled := []domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true},
+		{URL: "http://localhost:3002", Weight: 1, Active: true},
+	}
+	backend := domain.Backend{Name: "web", Servers: scaled, ServerWarmup: time.Minute}
+	balancer.UpdateBackends([]domain.Backend{backend})
+
+	selected := func() map[string]bool {
+		urls := make(map[string]bool)
+		for i := 0; i < 20; i++ {
+			if server := balancer.SelectServer(&backend, "10.0.0.1"); server != nil {
+				urls[server.URL] = true
+				balancer.UpdateStats(server, time.Millisecond, true)
+			}
+		}
+		return urls
+	}
+
+	if selected()["http://localhost:3002"] {
+		t.Fatal("expected new server excluded before its first health check")
+	}
+	if state := balancer.servers["http://localhost:3002"].HealthState; state != Pending {
+		t.Errorf("expected Pending state, got %v", state)
+	}
+
+	// Un check fallido no lo saca de Pending
+	balancer.ReportHealth("http://localhost:3002", false)
+	if state := balancer.servers["http://localhost:3002"].HealthState; state != Pending {
+		t.Errorf("expected failing check to keep the server Pending, got %v", state)
+	}
+
+	// Un check correcto dentro del warmup tampoco
+	balancer.ReportHealth("http://localhost:3002", true)
+	if selected()["http://localhost:3002"] {
+		t.Error("expected new server excluded during server_warmup")
+	}
+
+	clock.Advance(2 * time.Minute)
+	balancer.ReportHealth("http://localhost:3002", true)
+	if !selected()["http://localhost:3002"] {
+		t.Error("expected new server in rotation after a passing check past server_warmup")
+	}
+}
+
+func TestEnterpriseBalancer_PriorityFailover(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	servers := []domain.Server{
+		{URL: "http://primary-1:3001", Weight: 1, Active: true},
+		{URL: "http://primary-2:3002", Weight: 1, Active: true},
+		{URL: "http://backup-1:3003", Weight: 1, Active: true, Priority: 1},
+	}
+	backend := &domain.Backend{Servers: servers}
+	balancer.UpdateServers(servers, backend)
+
+	selectURLs := func() map[string]bool {
+		selected := make(map[string]bool)
+		for i := 0; i < 50; i++ {
+			if server := balancer.SelectServer(backend, "10.0.0.1"); server != nil {
+				selected[server.URL] = true
+				balancer.UpdateStats(server, time.Millisecond, true)
+			}
+		}
+		return selected
+	}
+
+	// Con primarios disponibles el backup no recibe tráfico
+	if selected := selectURLs(); selected["http://backup-1:3003"] || len(selected) == 0 {
+		t.Fatalf("expected only primaries while they are available, got %v", selected)
+	}
+
+	// Un primario caído: el otro absorbe el tráfico, el backup sigue fuera
+	balancer.ReportHealth("http://primary-1:3001", false)
+	if selected := selectURLs(); len(selected) != 1 || !selected["http://primary-2:3002"] {
+		t.Fatalf("expected remaining primary only, got %v", selected)
+	}
+
+	// Failover: todos los primarios caídos
+	balancer.ReportHealth("http://primary-2:3002", false)
+	if selected := selectURLs(); len(selected) != 1 || !selected["http://backup-1:3003"] {
+		t.Fatalf("expected failover to backup, got %v", selected)
+	}
+
+	// Failback automático al recuperarse un primario
+	balancer.ReportHealth("http://primary-1:3001", true)
+	if selected := selectURLs(); len(selected) != 1 || !selected["http://primary-1:3001"] {
+		t.Fatalf("expected failback to recovered primary, got %v", selected)
+	}
+}
+
+func TestEnterpriseBalancer_BalanceModePinsAlgorithm(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name:        "test-backend",
+		BalanceMode: "weighted_least_connections",
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true, MaxConnections: 1000},
+			{URL: "http://localhost:3002", Weight: 3, Active: true, MaxConnections: 1000},
+		},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	// Forzar una reevaluación: con el algoritmo fijado no debe cambiar
+	balancer.adaptiveController.lastEvaluation = time.Time{}
+
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		counts[balancer.SelectServer(backend, "192.168.1.1").URL]++
+	}
+
+	if counts["http://localhost:3001"] != 100 || counts["http://localhost:3002"] != 300 {
+		t.Errorf("expected 100/300 split, got %v", counts)
+	}
+	if _, ok := balancer.selectOptimalAlgorithm().(*WeightedLeastConnections); !ok {
+		t.Error("expected balance_mode to pin weighted_least_connections")
+	}
+}
+
+func TestEnterpriseBalancer_SkipsInvalidServerURL(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name: "test-backend",
+		Servers: []domain.Server{
+			{URL: "localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		server := balancer.SelectServer(backend, "192.168.1.1")
+		if server == nil || server.URL != "http://localhost:3002" {
+			t.Fatalf("expected only the valid server to be selected, got %v", server)
+		}
+	}
+}
+
+func TestEnterpriseBalancer_QueueWaitsForFreeSlot(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true, MaxConnections: 1}},
+		Queue:   domain.QueueCfg{MaxDepth: 2, MaxWait: time.Second},
+	}
+
+	busy := balancer.SelectServer(backend, "192.168.1.1")
+	if busy == nil {
+		t.Fatal("expected a server for the first request")
+	}
+
+	result := make(chan *domain.Server, 1)
+	go func() { result <- balancer.SelectServer(backend, "192.168.1.2") }()
+
+	// La request queda en cola y se refleja en WaitingConns
+	state := balancer.servers["http://localhost:3001"]
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&state.ConnectionPool.WaitingConns) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the request to be waiting in the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	balancer.UpdateStats(busy, time.Millisecond, true)
+
+	select {
+	case server := <-result:
+		if server == nil || server.URL != "http://localhost:3001" {
+			t.Fatalf("expected the freed server, got %v", server)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued request was not woken up when the slot was freed")
+	}
+	if waiting := atomic.LoadInt64(&state.ConnectionPool.WaitingConns); waiting != 0 {
+		t.Errorf("expected no waiting connections, got %d", waiting)
+	}
+}
+
+func TestEnterpriseBalancer_QueueBounds(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true, MaxConnections: 1}},
+		Queue:   domain.QueueCfg{MaxDepth: 1, MaxWait: 100 * time.Millisecond},
+	}
+	if balancer.SelectServer(backend, "192.168.1.1") == nil {
+		t.Fatal("expected a server for the first request")
+	}
+
+	waited := make(chan time.Duration, 1)
+	go func() {
+		start := time.Now()
+		if server := balancer.SelectServer(backend, "192.168.1.2"); server != nil {
+			t.Errorf("expected timeout, got %v", server)
+		}
+		waited <- time.Since(start)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for balancer.QueueDepth() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected one queued request")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Con la cola llena se rechaza sin esperar
+	start := time.Now()
+	if server := balancer.SelectServer(backend, "192.168.1.3"); server != nil {
+		t.Fatalf("expected rejection with a full queue, got %v", server)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected immediate rejection, waited %v", elapsed)
+	}
+
+	if elapsed := <-waited; elapsed < 100*time.Millisecond {
+		t.Errorf("expected the queued request to wait max_wait, waited %v", elapsed)
+	}
+	if depth := balancer.QueueDepth(); depth != 0 {
+		t.Errorf("expected empty queue after timeout, got %d", depth)
+	}
+}
+
+func TestEnterpriseBalancer_SelectRetryServer(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name: "test-backend",
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+			{URL: "http://localhost:3003", Weight: 1, Active: true},
+			{URL: "http://localhost:3004", Weight: 1, Active: true},
+		},
+		CircuitBreaker: domain.CircuitBreakerCfg{LastResort: true},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	// 3002 con circuito abierto, 3003 en half-open y 3004 marcado no sano
+	balancer.servers["http://localhost:3002"].CircuitBreaker.State = CircuitOpen
+	balancer.servers["http://localhost:3002"].CircuitBreaker.NextRetryTime = time.Now().Add(time.Minute)
+	balancer.servers["http://localhost:3003"].CircuitBreaker.State = CircuitHalfOpen
+	balancer.servers["http://localhost:3004"].HealthState = Unhealthy
+
+	excludeFailed := func(server *domain.Server) bool { return server.URL != "http://localhost:3001" }
+	if server := balancer.SelectRetryServer(backend, "192.168.1.1", excludeFailed); server != nil {
+		t.Fatalf("expected no retry candidate, got %s", server.URL)
+	}
+
+	for i := 0; i < 10; i++ {
+		server := balancer.SelectRetryServer(backend, "192.168.1.1", nil)
+		if server == nil || server.URL != "http://localhost:3001" {
+			t.Fatalf("expected only the healthy closed-circuit server, got %v", server)
+		}
+		balancer.UpdateStats(server, time.Millisecond, true)
+	}
+}
+
+func TestEnterpriseBalancer_AdaptiveBalancingDisabled(t *testing.T) {
+	disabled := false
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name:              "test-backend",
+		AdaptiveBalancing: &disabled,
+		Servers: []domain.Server{
+			{URL: "http://localhost:3001", Weight: 1, Active: true},
+			{URL: "http://localhost:3002", Weight: 1, Active: true},
+		},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	// Sin balance_mode se fija adaptive_weighted y nunca se reevalúa
+	balancer.adaptiveController.lastEvaluation = time.Time{}
+	for i := 0; i < 10; i++ {
+		server := balancer.SelectServer(backend, "192.168.1.1")
+		balancer.UpdateStats(server, time.Millisecond, true)
+	}
+	if !balancer.adaptiveController.lastEvaluation.IsZero() {
+		t.Error("expected no algorithm evaluation with adaptive balancing disabled")
+	}
+	if _, ok := balancer.selectOptimalAlgorithm().(*AdaptiveWeightedRoundRobin); !ok {
+		t.Error("expected adaptive_weighted to be pinned")
+	}
+
+	// Con balance_mode explícito se fija ese algoritmo
+	backend.BalanceMode = "least_connections"
+	balancer.UpdateServers(backend.Servers, backend)
+	if _, ok := balancer.selectOptimalAlgorithm().(*LeastConnections); !ok {
+		t.Error("expected balance_mode to be pinned")
+	}
+
+	// Por defecto vuelve la selección adaptativa
+	backend.BalanceMode = ""
+	backend.AdaptiveBalancing = nil
+	balancer.UpdateServers(backend.Servers, backend)
+	if balancer.pinnedAlgorithm != "" {
+		t.Errorf("expected adaptive selection by default, got pinned %q", balancer.pinnedAlgorithm)
+	}
+}
+
+func TestEnterpriseBalancer_ResetMetricsConcurrent(t *testing.T) {
+	backend := &domain.Backend{
+		Name:    "web-servers",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}},
+	}
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateServers(backend.Servers, backend)
+
+	const requests = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < requests; i++ {
+			server := balancer.SelectServer(backend, "192.168.1.1")
+			balancer.UpdateStats(server, time.Millisecond, true)
+		}
+	}()
+
+	// Ninguna request se pierde ni se cuenta dos veces entre los resets
+	var counted int64
+	for i := 0; i < 20; i++ {
+		counted += balancer.ResetMetrics().Servers["http://localhost:3001"].SuccessCount
+	}
+	<-done
+	counted += balancer.ResetMetrics().Servers["http://localhost:3001"].SuccessCount
+
+	if counted != requests {
+		t.Errorf("expected %d successes across resets, got %d", requests, counted)
+	}
+}
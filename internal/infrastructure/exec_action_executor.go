@@ -0,0 +1,75 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// maxActionOutput acota la salida del comando que se guarda en logs y errores
+const maxActionOutput = 4096
+
+// ExecActionExecutor ejecuta un comando local por acción, p. ej. un script de
+// escalado en bare-metal. El payload del trigger llega como JSON por stdin.
+type ExecActionExecutor struct{}
+
+func NewExecActionExecutor() *ExecActionExecutor {
+	return &ExecActionExecutor{}
+}
+
+func (e *ExecActionExecutor) Execute(actionName string, config domain.ActionConfig) error {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = domain.DefaultActionTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, config.Command, config.Args...)
+	cmd.Env = append(os.Environ(), "GO_PROXY_ACTION="+actionName)
+	keys := make([]string, 0, len(config.Env))
+	for key := range config.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+config.Env[key])
+	}
+	if config.Payload != nil {
+		if encoded, err := json.Marshal(config.Payload); err == nil {
+			cmd.Stdin = bytes.NewReader(encoded)
+		}
+	}
+	// Si el comando deja hijos con la salida abierta, no esperar más allá del timeout
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	trimmed := truncateOutput(output)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("action %s: command timed out after %v: %s", actionName, timeout, trimmed)
+	}
+	if err != nil {
+		return fmt.Errorf("action %s: %w: %s", actionName, err, trimmed)
+	}
+	slog.Info("Exec action completed", "action", actionName, "command", config.Command,
+		"duration", time.Since(start), "output", trimmed)
+	return nil
+}
+
+func truncateOutput(output []byte) string {
+	text := strings.TrimSpace(string(output))
+	if len(text) > maxActionOutput {
+		return text[:maxActionOutput] + "...(truncated)"
+	}
+	return text
+}
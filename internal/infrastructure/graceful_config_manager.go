@@ -17,26 +17,33 @@ func NewGracefulConfigManager(configManager *ConfigManager) *GracefulConfigManag
 	}
 }
 
+// RemoveServerFromConfig quita serverURL de todos los backends que lo listan
 func (gcm *GracefulConfigManager) RemoveServerFromConfig(serverURL string) error {
 	gcm.mu.Lock()
 	defer gcm.mu.Unlock()
 
-	config := *gcm.configManager.GetConfig()
-	
-	// Buscar y remover servidor de la configuración
-	for i := range config.Backends {
-		for j, server := range config.Backends[i].Servers {
+	// Copiar backends y servidores: la config en memoria la comparten los lectores
+	current := gcm.configManager.GetConfig()
+	config := *current
+	config.Backends = make([]domain.Backend, len(current.Backends))
+	found := false
+	for i, backend := range current.Backends {
+		servers := make([]domain.Server, 0, len(backend.Servers))
+		for _, server := range backend.Servers {
 			if server.URL == serverURL {
-				config.Backends[i].Servers = append(
-					config.Backends[i].Servers[:j],
-					config.Backends[i].Servers[j+1:]...,
-				)
-				return gcm.configManager.Update(&config)
+				found = true
+				continue
 			}
+			servers = append(servers, server)
 		}
+		backend.Servers = servers
+		config.Backends[i] = backend
 	}
-	
-	return nil // Servidor no encontrado, no es error
+
+	if !found {
+		return nil // Servidor no encontrado, no es error
+	}
+	return gcm.configManager.Update(&config)
 }
 
 func (gcm *GracefulConfigManager) GetConfig() *domain.Config {
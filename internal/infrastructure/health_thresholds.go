@@ -0,0 +1,62 @@
+package infrastructure
+
+import "github.com/juanbautista0/go-proxy/internal/domain"
+
+const (
+	defaultHealthyThreshold   = domain.DefaultHealthyThreshold
+	defaultUnhealthyThreshold = domain.DefaultUnhealthyThreshold
+)
+
+type healthCounter struct {
+	successes int
+	failures  int
+}
+
+// healthTransitions evita el flapping: un servidor solo cambia de estado tras
+// N checks consecutivos con el mismo resultado. El primer check de cada
+// servidor se aplica directamente porque aún no hay estado conocido.
+type healthTransitions struct {
+	counters map[string]*healthCounter
+}
+
+func newHealthTransitions() *healthTransitions {
+	return &healthTransitions{counters: make(map[string]*healthCounter)}
+}
+
+// apply registra el resultado del check y actualiza server.Healthy si se alcanza el umbral
+func (t *healthTransitions) apply(server *domain.Server, backend *domain.Backend, healthy bool) {
+	counter, exists := t.counters[server.URL]
+	if !exists {
+		counter = &healthCounter{}
+		t.counters[server.URL] = counter
+		server.Healthy = healthy
+	}
+
+	if healthy {
+		counter.successes++
+		counter.failures = 0
+		if !server.Healthy && counter.successes >= healthyThreshold(backend) {
+			server.Healthy = true
+		}
+	} else {
+		counter.failures++
+		counter.successes = 0
+		if server.Healthy && counter.failures >= unhealthyThreshold(backend) {
+			server.Healthy = false
+		}
+	}
+}
+
+func healthyThreshold(backend *domain.Backend) int {
+	if backend.HealthyThreshold > 0 {
+		return backend.HealthyThreshold
+	}
+	return defaultHealthyThreshold
+}
+
+func unhealthyThreshold(backend *domain.Backend) int {
+	if backend.UnhealthyThreshold > 0 {
+		return backend.UnhealthyThreshold
+	}
+	return defaultUnhealthyThreshold
+}
@@ -0,0 +1,155 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// Credenciales que Kubernetes monta en cada pod
+const (
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// KubernetesActionExecutor cambia las réplicas de un Deployment a través del
+// subrecurso /scale de la API de Kubernetes: lee las réplicas actuales, suma
+// scale_by dentro de min/max y aplica un merge patch. Los clientes se crean una
+// vez por conexión (api_server, token_file, ca_file) y se reutilizan.
+type KubernetesActionExecutor struct {
+	mu      sync.Mutex
+	clients map[kubernetesConnection]kubernetes.Interface
+}
+
+type kubernetesConnection struct {
+	apiServer string
+	tokenFile string
+	caFile    string
+}
+
+func NewKubernetesActionExecutor() *KubernetesActionExecutor {
+	return &KubernetesActionExecutor{clients: make(map[kubernetesConnection]kubernetes.Interface)}
+}
+
+func (e *KubernetesActionExecutor) Execute(actionName string, config domain.ActionConfig) error {
+	k := config.Kubernetes
+	if k == nil || k.Deployment == "" {
+		return fmt.Errorf("action %s: kubernetes.deployment is not configured", actionName)
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = domain.DefaultActionTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := e.client(k)
+	if err != nil {
+		return fmt.Errorf("action %s: %w", actionName, err)
+	}
+	namespace := k.Namespace
+	if namespace == "" {
+		namespace = domain.DefaultKubernetesNamespace
+	}
+	deployments := client.AppsV1().Deployments(namespace)
+
+	scale, err := deployments.GetScale(ctx, k.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("action %s: get scale of %s/%s: %w", actionName, namespace, k.Deployment, err)
+	}
+	current := int(scale.Spec.Replicas)
+
+	desired := current + k.ScaleBy
+	if k.MaxReplicas > 0 && desired > k.MaxReplicas {
+		desired = k.MaxReplicas
+	}
+	if desired < k.MinReplicas {
+		desired = k.MinReplicas
+	}
+	if desired == current {
+		slog.Info("Kubernetes action skipped: replica bound reached", "action", actionName,
+			"deployment", namespace+"/"+k.Deployment, "replicas", desired)
+		return nil
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, desired))
+	if _, err := deployments.Patch(ctx, k.Deployment, types.MergePatchType, patch, metav1.PatchOptions{}, "scale"); err != nil {
+		return fmt.Errorf("action %s: scale %s/%s to %d: %w", actionName, namespace, k.Deployment, desired, err)
+	}
+	slog.Info("Kubernetes deployment scaled", "action", actionName, "deployment", namespace+"/"+k.Deployment,
+		"from", current, "to", desired)
+	return nil
+}
+
+// client devuelve el clientset de la conexión, creándolo la primera vez
+func (e *KubernetesActionExecutor) client(k *domain.KubernetesActionCfg) (kubernetes.Interface, error) {
+	conn := kubernetesConnection{apiServer: k.APIServer, tokenFile: k.TokenFile, caFile: k.CAFile}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if client, ok := e.clients[conn]; ok {
+		return client, nil
+	}
+	restConfig, err := kubernetesRESTConfig(k)
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes client: %w", err)
+	}
+	e.clients[conn] = client
+	return client, nil
+}
+
+// kubernetesRESTConfig usa la cuenta de servicio del pod sin api_server. Con
+// api_server, token_file y ca_file son opcionales y, si faltan, se usan los de
+// la cuenta de servicio cuando existen (p. ej. no existen detrás de kubectl proxy).
+func kubernetesRESTConfig(k *domain.KubernetesActionCfg) (*rest.Config, error) {
+	if k.APIServer == "" {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes.api_server is not set: %w", err)
+		}
+		return restConfig, nil
+	}
+	tokenFile, err := kubernetesCredentialFile(k.TokenFile, serviceAccountTokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("read kubernetes token: %w", err)
+	}
+	caFile, err := kubernetesCredentialFile(k.CAFile, serviceAccountCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read kubernetes CA: %w", err)
+	}
+	return &rest.Config{
+		Host:            k.APIServer,
+		BearerTokenFile: tokenFile,
+		TLSClientConfig: rest.TLSClientConfig{CAFile: caFile},
+	}, nil
+}
+
+// kubernetesCredentialFile exige que exista el fichero configurado; sin él,
+// usa el de la cuenta de servicio solo si existe
+func kubernetesCredentialFile(configured, serviceAccount string) (string, error) {
+	path := configured
+	if path == "" {
+		path = serviceAccount
+	}
+	if _, err := os.Stat(path); err != nil {
+		if configured == "" && errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,103 @@
+package infrastructure
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// DefaultLatencyBuckets se usan cuando metrics.latency_buckets no está configurado
+var DefaultLatencyBuckets = domain.DefaultLatencyBuckets
+
+// LatencyHistogram contiene conteos acumulados (estilo Prometheus "le").
+// Counts tiene un elemento más que Buckets para el bucket +Inf.
+type LatencyHistogram struct {
+	Buckets []time.Duration
+	Counts  []int64
+	Sum     time.Duration
+	Count   int64
+}
+
+func NewLatencyHistogram(buckets []time.Duration) *LatencyHistogram {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	sorted := append([]time.Duration(nil), buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &LatencyHistogram{
+		Buckets: sorted,
+		Counts:  make([]int64, len(sorted)+1),
+	}
+}
+
+func (h *LatencyHistogram) Observe(samples []time.Duration) {
+	for _, sample := range samples {
+		h.observe(sample)
+	}
+}
+
+func (h *LatencyHistogram) observe(sample time.Duration) {
+	for i, bucket := range h.Buckets {
+		if sample <= bucket {
+			h.Counts[i]++
+		}
+	}
+	h.Counts[len(h.Buckets)]++
+	h.Sum += sample
+	h.Count++
+}
+
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	for i := range h.Counts {
+		h.Counts[i] += other.Counts[i]
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+}
+
+// SetLatencyBuckets fija los límites del histograma de tiempos de respuesta.
+// Si cambian, los histogramas empiezan de cero: sus conteos no son comparables.
+func (eb *EnterpriseBalancer) SetLatencyBuckets(buckets []time.Duration) {
+	sorted := NewLatencyHistogram(buckets).Buckets
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	if slices.Equal(eb.latencyBuckets, sorted) {
+		return
+	}
+	eb.latencyBuckets = sorted
+	for _, state := range eb.servers {
+		state.Metrics.Latencies = nil
+	}
+}
+
+// observeLatency suma la respuesta al histograma del servidor. Requiere eb.mu
+// tomado en escritura.
+func (eb *EnterpriseBalancer) observeLatency(state *ServerState, responseTime time.Duration) {
+	if state.Metrics.Latencies == nil {
+		state.Metrics.Latencies = NewLatencyHistogram(eb.latencyBuckets)
+	}
+	state.Metrics.Latencies.observe(responseTime)
+}
+
+// GetLatencyHistograms devuelve una copia del histograma de cada servidor y
+// el agregado de todos. Los conteos solo crecen (salvo reset o cambio de
+// buckets), como espera un histograma de Prometheus.
+func (eb *EnterpriseBalancer) GetLatencyHistograms() (map[string]*LatencyHistogram, *LatencyHistogram) {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	histograms := make(map[string]*LatencyHistogram, len(eb.servers))
+	aggregate := NewLatencyHistogram(eb.latencyBuckets)
+	for url, state := range eb.servers {
+		histogram := NewLatencyHistogram(eb.latencyBuckets)
+		if state.Metrics.Latencies != nil {
+			histogram.Merge(state.Metrics.Latencies)
+		}
+		aggregate.Merge(histogram)
+		histograms[url] = histogram
+	}
+	return histograms, aggregate
+}
@@ -0,0 +1,115 @@
+package infrastructure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestLatencyHistogram_ObserveCumulative(t *testing.T) {
+	histogram := NewLatencyHistogram([]time.Duration{100 * time.Millisecond, 10 * time.Millisecond})
+
+	histogram.Observe([]time.Duration{
+		5 * time.Millisecond,
+		10 * time.Millisecond,
+		50 * time.Millisecond,
+		2 * time.Second,
+	})
+
+	// Los buckets se ordenan y los conteos son acumulados (le)
+	if histogram.Buckets[0] != 10*time.Millisecond {
+		t.Fatalf("expected buckets sorted ascending, got %v", histogram.Buckets)
+	}
+	expected := []int64{2, 3, 4}
+	for i, count := range expected {
+		if histogram.Counts[i] != count {
+			t.Errorf("bucket %d: expected %d, got %d", i, count, histogram.Counts[i])
+		}
+	}
+	if histogram.Count != 4 {
+		t.Errorf("expected count 4, got %d", histogram.Count)
+	}
+	if histogram.Sum != 2065*time.Millisecond {
+		t.Errorf("expected sum 2.065s, got %v", histogram.Sum)
+	}
+}
+
+func TestLatencyHistogram_DefaultBuckets(t *testing.T) {
+	histogram := NewLatencyHistogram(nil)
+
+	if len(histogram.Buckets) != len(DefaultLatencyBuckets) {
+		t.Errorf("expected %d default buckets, got %d", len(DefaultLatencyBuckets), len(histogram.Buckets))
+	}
+	if len(histogram.Counts) != len(DefaultLatencyBuckets)+1 {
+		t.Errorf("expected +Inf bucket in counts, got %d counts", len(histogram.Counts))
+	}
+}
+
+func TestEnterpriseBalancer_GetLatencyHistograms(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	servers := []domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true},
+		{URL: "http://localhost:3002", Weight: 1, Active: true},
+	}
+	balancer.UpdateServers(servers, &domain.Backend{})
+
+	balancer.UpdateStats(&servers[0], 20*time.Millisecond, true)
+	balancer.UpdateStats(&servers[0], 300*time.Millisecond, true)
+
+	histograms, aggregate := balancer.GetLatencyHistograms()
+	if len(histograms) != 2 {
+		t.Fatalf("expected 2 histograms, got %d", len(histograms))
+	}
+	if histograms["http://localhost:3001"].Count != 2 {
+		t.Errorf("expected 2 samples, got %d", histograms["http://localhost:3001"].Count)
+	}
+	if histograms["http://localhost:3002"].Count != 0 {
+		t.Errorf("expected no samples, got %d", histograms["http://localhost:3002"].Count)
+	}
+	if aggregate.Count != 2 {
+		t.Errorf("expected 2 samples in aggregate, got %d", aggregate.Count)
+	}
+}
+
+func TestEnterpriseBalancer_LatencyHistogramIsCumulative(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	balancer.SetLatencySamples(4)
+	servers := []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}}
+	balancer.UpdateServers(servers, &domain.Backend{})
+
+	// Las respuestas lentas salen del RingBuffer, pero el histograma no baja
+	for i := 0; i < 4; i++ {
+		balancer.UpdateStats(&servers[0], 2*time.Second, true)
+	}
+	before, _ := balancer.GetLatencyHistograms()
+	for i := 0; i < 10; i++ {
+		balancer.UpdateStats(&servers[0], 5*time.Millisecond, true)
+	}
+	after, _ := balancer.GetLatencyHistograms()
+
+	previous, current := before["http://localhost:3001"], after["http://localhost:3001"]
+	for i := range current.Counts {
+		if current.Counts[i] < previous.Counts[i] {
+			t.Errorf("bucket %d went down from %d to %d", i, previous.Counts[i], current.Counts[i])
+		}
+	}
+	if current.Count != 14 || current.Sum != 8*time.Second+50*time.Millisecond {
+		t.Errorf("expected 14 samples summing 8.05s, got %d / %v", current.Count, current.Sum)
+	}
+	if inf := current.Counts[len(current.Buckets)]; inf != 14 {
+		t.Errorf("expected +Inf bucket to count every response, got %d", inf)
+	}
+
+	// Otros buckets no son comparables con los conteos anteriores: se empieza de cero
+	balancer.SetLatencyBuckets([]time.Duration{time.Second})
+	histograms, _ := balancer.GetLatencyHistograms()
+	if h := histograms["http://localhost:3001"]; h.Count != 0 || len(h.Buckets) != 1 {
+		t.Errorf("expected empty histogram with the new buckets, got %+v", h)
+	}
+	balancer.UpdateStats(&servers[0], 5*time.Millisecond, true)
+	histograms, _ = balancer.GetLatencyHistograms()
+	if h := histograms["http://localhost:3001"]; h.Counts[0] != 1 {
+		t.Errorf("expected response in the 1s bucket, got %v", h.Counts)
+	}
+}
@@ -0,0 +1,96 @@
+package infrastructure
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// SetPercentiles configura los percentiles de latencia que se calculan para
+// cada servidor; vacío usa domain.DefaultPercentiles
+func (eb *EnterpriseBalancer) SetPercentiles(percentiles []float64) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.percentiles = append([]float64(nil), percentiles...)
+}
+
+// SetLatencySamples fija cuántas latencias guarda cada servidor. Los buffers
+// existentes se redimensionan conservando las muestras más recientes.
+func (eb *EnterpriseBalancer) SetLatencySamples(size int) {
+	if size <= 0 {
+		size = domain.DefaultLatencySamples
+	}
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.latencySamples = size
+	for _, state := range eb.servers {
+		state.Metrics.ResponseTimes.Resize(size)
+	}
+}
+
+// MetricsMemory resume la memoria de las muestras de latencia de todos los
+// servidores, que es la parte de las métricas que crece con la flota
+type MetricsMemory struct {
+	LatencySamples int   `json:"latency_samples"` // capacidad del buffer de cada servidor
+	Servers        int   `json:"servers"`
+	Bytes          int64 `json:"bytes"`
+}
+
+func (eb *EnterpriseBalancer) MetricsMemory() MetricsMemory {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	memory := MetricsMemory{LatencySamples: eb.latencySamples, Servers: len(eb.servers)}
+	for _, state := range eb.servers {
+		memory.Bytes += state.Metrics.ResponseTimes.MemoryBytes()
+	}
+	return memory
+}
+
+// latencyPercentiles calcula los percentiles configurados sobre muestras ya
+// ordenadas. Un percentil alto con pocas muestras sería simplemente el máximo:
+// se omite hasta tener muestras suficientes (1000 para p99.9).
+func latencyPercentiles(sorted []time.Duration, percentiles []float64) map[string]time.Duration {
+	if len(percentiles) == 0 {
+		percentiles = domain.DefaultPercentiles
+	}
+	result := make(map[string]time.Duration, len(percentiles))
+	for _, p := range percentiles {
+		if len(sorted) < domain.PercentileMinSamples(p) {
+			continue
+		}
+		result[domain.PercentileLabel(p)] = percentile(sorted, p)
+	}
+	return result
+}
+
+// percentile aplica nearest-rank sobre muestras ordenadas
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := ceilRank(p / 100 * float64(len(sorted)))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// sortedPercentileLabels ordena las etiquetas "pNN" por valor numérico
+func sortedPercentileLabels(percentiles map[string]time.Duration) []string {
+	labels := make([]string, 0, len(percentiles))
+	for label := range percentiles {
+		labels = append(labels, label)
+	}
+	value := func(label string) float64 {
+		p, _ := strconv.ParseFloat(label[1:], 64)
+		return p
+	}
+	sort.Slice(labels, func(i, j int) bool { return value(labels[i]) < value(labels[j]) })
+	return labels
+}
+
+// ceilRank redondea hacia arriba absorbiendo el error de coma flotante
+// (99.9/100*1000 da 999.0000000000001)
+func ceilRank(x float64) int {
+	return int(math.Ceil(x - 1e-9))
+}
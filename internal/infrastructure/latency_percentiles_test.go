@@ -0,0 +1,100 @@
+package infrastructure
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestLatencyPercentiles_NearestRank(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	result := latencyPercentiles(sorted, []float64{50, 90, 99, 99.9})
+
+	expected := map[string]time.Duration{
+		"p50": 50 * time.Millisecond,
+		"p90": 90 * time.Millisecond,
+		"p99": 99 * time.Millisecond,
+	}
+	for label, want := range expected {
+		if result[label] != want {
+			t.Errorf("%s: expected %v, got %v", label, want, result[label])
+		}
+	}
+	// Con 100 muestras p99.9 sería el máximo: se omite
+	if _, ok := result["p99.9"]; ok {
+		t.Errorf("expected p99.9 omitted with 100 samples, got %v", result["p99.9"])
+	}
+}
+
+func TestLatencyPercentiles_HighPercentileNeedsSamples(t *testing.T) {
+	sorted := make([]time.Duration, 1000)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Microsecond
+	}
+
+	result := latencyPercentiles(sorted, []float64{99.9})
+	if result["p99.9"] != 999*time.Microsecond {
+		t.Errorf("expected p99.9 999µs, got %v", result["p99.9"])
+	}
+
+	// Sin configurar se usan los percentiles por defecto
+	defaults := latencyPercentiles(sorted, nil)
+	if _, ok := defaults["p95"]; !ok {
+		t.Errorf("expected default p95, got %v", defaults)
+	}
+	if _, ok := defaults["p99"]; !ok {
+		t.Errorf("expected default p99, got %v", defaults)
+	}
+}
+
+func TestSortedPercentileLabels(t *testing.T) {
+	labels := sortedPercentileLabels(map[string]time.Duration{"p99.9": 0, "p50": 0, "p9": 0, "p99": 0})
+	expected := []string{"p9", "p50", "p99", "p99.9"}
+	for i, label := range expected {
+		if labels[i] != label {
+			t.Fatalf("expected %v, got %v", expected, labels)
+		}
+	}
+}
+
+func TestEnterpriseBalancer_LatencySamples(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	balancer.SetLatencySamples(20)
+	balancer.SetPercentiles([]float64{50, 95})
+	balancer.UpdateServers([]domain.Server{
+		{URL: "http://localhost:3001", Weight: 1, Active: true},
+		{URL: "http://localhost:3002", Weight: 1, Active: true},
+	}, &domain.Backend{})
+
+	server := &domain.Server{URL: "http://localhost:3001"}
+	// Los percentiles se recalculan solo con requests contadas
+	atomic.StoreInt64(&balancer.servers[server.URL].Metrics.RequestCount, 100)
+	for i := 1; i <= 100; i++ {
+		balancer.UpdateStats(server, time.Duration(i)*time.Millisecond, true)
+	}
+
+	// Solo quedan las 20 últimas muestras (81ms-100ms)
+	percentiles := balancer.GetServerMetrics()[server.URL].LatencyPercentiles
+	if percentiles["p50"] != 90*time.Millisecond || percentiles["p95"] != 99*time.Millisecond {
+		t.Errorf("expected percentiles over the last 20 samples, got %v", percentiles)
+	}
+	if memory := balancer.MetricsMemory(); memory.LatencySamples != 20 || memory.Servers != 2 || memory.Bytes != 2*20*8 {
+		t.Errorf("unexpected metrics memory: %+v", memory)
+	}
+
+	// Al reducir el buffer se conservan las muestras más recientes
+	balancer.SetLatencySamples(10)
+	samples := balancer.servers[server.URL].Metrics.ResponseTimes.GetAll()
+	if len(samples) != 10 || samples[0] != 91*time.Millisecond || samples[9] != 100*time.Millisecond {
+		t.Errorf("expected the 10 most recent samples, got %v", samples)
+	}
+	if memory := balancer.MetricsMemory(); memory.Bytes != 2*10*8 {
+		t.Errorf("expected memory to shrink with the buffers, got %+v", memory)
+	}
+}
@@ -0,0 +1,47 @@
+package infrastructure
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// logLevel es compartido por todos los handlers: una recarga que solo cambia
+// logging.level no necesita recrear el logger
+var logLevel = new(slog.LevelVar)
+
+// ConfigureLogger instala como logger por defecto un slog con el nivel y el
+// formato de logging. slog.SetDefault redirige también el paquete log, así que
+// lo que aún use log.Printf sale por el mismo handler con nivel info.
+func ConfigureLogger(cfg domain.LoggingConfig) {
+	configureLogger(os.Stderr, cfg)
+}
+
+func configureLogger(w io.Writer, cfg domain.LoggingConfig) {
+	logLevel.Set(ParseLogLevel(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	if cfg.Format == domain.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// ParseLogLevel convierte logging.level; un valor vacío o desconocido es info
+func ParseLogLevel(level string) slog.Level {
+	switch level {
+	case domain.LogLevelDebug:
+		return slog.LevelDebug
+	case domain.LogLevelWarn:
+		return slog.LevelWarn
+	case domain.LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
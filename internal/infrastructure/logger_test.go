@@ -0,0 +1,59 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestConfigureLogger_LevelAndFormat(t *testing.T) {
+	// slog.SetDefault redirige el paquete log; se restauran ambos
+	previous, output, flags := slog.Default(), log.Writer(), log.Flags()
+	defer func() {
+		slog.SetDefault(previous)
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	}()
+
+	var buf bytes.Buffer
+	configureLogger(&buf, domain.LoggingConfig{Level: "warn", Format: "json"})
+
+	slog.Info("hidden")
+	slog.Warn("shown", "backend", "web")
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "shown" || entry["level"] != "WARN" || entry["backend"] != "web" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+
+	// Una recarga a debug muestra lo que antes se descartaba, también desde log
+	buf.Reset()
+	configureLogger(&buf, domain.LoggingConfig{Level: "debug"})
+	slog.Debug("evaluation", "score", 0.5)
+	log.Printf("legacy message")
+	if out := buf.String(); !strings.Contains(out, "level=DEBUG msg=evaluation score=0.5") || !strings.Contains(out, "level=INFO msg=\"legacy message\"") {
+		t.Errorf("expected text entries for debug and log package output, got %q", out)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for level, want := range tests {
+		if got := ParseLogLevel(level); got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, want %v", level, got, want)
+		}
+	}
+}
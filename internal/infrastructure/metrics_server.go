@@ -4,35 +4,105 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/juanbautista0/go-proxy/internal/domain"
 )
 
 type MetricsServer struct {
-	proxyService  domain.ProxyService
+	proxyService     domain.ProxyService
 	webSocketMetrics *WebSocketMetrics
+	loadBalancer     *EnterpriseBalancer
+	healthChecker    *AdvancedHealthChecker
+	triggerMetrics   domain.TriggerMetricsProvider
+	latencyBuckets   []time.Duration
+	cors             *domain.CORSConfig
+	mu               sync.RWMutex
 }
 
 func NewMetricsServer(proxyService domain.ProxyService) *MetricsServer {
-	return &MetricsServer{
+	ms := &MetricsServer{
 		proxyService:     proxyService,
 		webSocketMetrics: NewWebSocketMetrics(proxyService),
 	}
+	ms.webSocketMetrics.SetOriginCheck(ms.allowWebSocketOrigin)
+	return ms
 }
 
 func (ms *MetricsServer) SetLoadBalancer(lb *EnterpriseBalancer) {
+	ms.loadBalancer = lb
 	ms.webSocketMetrics.SetLoadBalancer(lb)
 }
 
+func (ms *MetricsServer) SetHealthChecker(hc *AdvancedHealthChecker) {
+	ms.healthChecker = hc
+}
+
+// SetTriggerMetrics habilita /metrics/trigger con el scoring del SmartTrigger
+func (ms *MetricsServer) SetTriggerMetrics(provider domain.TriggerMetricsProvider) {
+	ms.triggerMetrics = provider
+}
+
+// SetCORS aplica el bloque cors de la configuración; nil mantiene lectura pública
+func (ms *MetricsServer) SetCORS(cors *domain.CORSConfig) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.cors = cors
+}
+
+// allowWebSocketOrigin aplica a /ws la misma política que a HTTP y SSE: los
+// navegadores no aplican CORS a WebSocket, así que el origen se comprueba en
+// el handshake. Se aceptan siempre el mismo origen y los clientes sin Origin.
+func (ms *MetricsServer) allowWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || sameOrigin(origin, r.Host) {
+		return true
+	}
+	_, ok := matchOrigin(origin, ms.corsPolicy())
+	return ok
+}
+
+// corsPolicy devuelve la política vigente; sin bloque cors, lectura pública
+func (ms *MetricsServer) corsPolicy() domain.CORSConfig {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if ms.cors != nil && len(ms.cors.AllowedOrigins) > 0 {
+		return *ms.cors
+	}
+	return publicCORS
+}
+
+// withCORS aplica la política CORS vigente y responde los preflight
+func (ms *MetricsServer) withCORS(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if applyCORS(w, r, ms.corsPolicy()) {
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Start usa un mux propio: el servidor de métricas es público y
+// http.DefaultServeMux contiene los handlers de net/http/pprof
 func (ms *MetricsServer) Start(port int) error {
-	http.HandleFunc("/metrics", ms.handleMetrics)
-	http.HandleFunc("/stream", ms.handleStream)
-	http.HandleFunc("/ws", ms.webSocketMetrics.HandleWebSocket)
-	http.HandleFunc("/", ms.handleDashboard)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", ms.withCORS(ms.handleMetrics))
+	mux.HandleFunc("/metrics/prometheus", ms.withCORS(ms.handlePrometheus))
+	mux.HandleFunc("/metrics/server", ms.withCORS(ms.handleServerDetail))
+	mux.HandleFunc("/metrics/health", ms.withCORS(ms.handleHealthMetrics))
+	mux.HandleFunc("/metrics/trigger", ms.withCORS(ms.handleTriggerMetrics))
+	mux.HandleFunc("/metrics/trigger/history", ms.withCORS(ms.handleTriggerHistory))
+	mux.HandleFunc("/stream", ms.withCORS(ms.handleStream))
+	mux.HandleFunc("/ws", ms.withCORS(ms.webSocketMetrics.HandleWebSocket))
+	mux.Handle("/assets/", webAssetsHandler())
+	mux.HandleFunc("/", ms.withCORS(ms.handleDashboard))
 
 	addr := fmt.Sprintf(":%d", port)
-	return http.ListenAndServe(addr, nil)
+	return http.ListenAndServe(addr, mux)
 }
 
 func (ms *MetricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
@@ -41,12 +111,14 @@ func (ms *MetricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 
 	totalRequests := int64(0)
 	activeConnections := int64(0)
+	upgradedConnections := int64(0)
 	successfulRequests := int64(0)
 	failedRequests := int64(0)
 
 	for _, server := range serverStats {
 		totalRequests += server.TotalRequests
 		activeConnections += server.CurrentConns
+		upgradedConnections += server.UpgradedConns
 		successfulRequests += server.TotalRequests - server.FailedRequests
 		failedRequests += server.FailedRequests
 	}
@@ -62,16 +134,42 @@ func (ms *MetricsServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
 			"requests_per_second":   metrics.RequestsPerSecond,
 			"total_requests":        totalRequests,
 			"active_connections":    activeConnections,
+			"upgraded_connections":  upgradedConnections,
 			"successful_requests":   successfulRequests,
 			"failed_requests":       failedRequests,
 			"average_response_time": metrics.AverageResponseTime.String(),
 			"error_rate":            errorRate,
+			"active_sessions":       atomic.LoadInt64(&metrics.ActiveSessions),
+			"stalled_responses":     atomic.LoadInt64(&metrics.StalledResponses),
+			"rejected_requests":     formatRejections(metrics),
 		},
-		"servers": ms.formatServerStats(serverStats),
+		"mirror":   formatMirrorStats(metrics),
+		"pressure": formatPressureStats(metrics),
+		"backends": formatBackendStats(metrics),
+		"servers":  ms.formatServerStats(serverStats),
+	}
+
+	if histograms, aggregate := ms.latencyHistograms(); aggregate != nil {
+		servers := response["servers"].(map[string]interface{})
+		for url, histogram := range histograms {
+			if server, ok := servers[url].(map[string]interface{}); ok {
+				server["latency_histogram"] = formatLatencyHistogram(histogram)
+			}
+		}
+		response["metrics"].(map[string]interface{})["latency_histogram"] = formatLatencyHistogram(aggregate)
+	}
+
+	if ms.loadBalancer != nil {
+		response["metrics_memory"] = ms.loadBalancer.MetricsMemory()
+		servers := response["servers"].(map[string]interface{})
+		for url, histogram := range ms.loadBalancer.GetUpgradeHistograms() {
+			if server, ok := servers[url].(map[string]interface{}); ok {
+				server["upgraded_connections"].(map[string]interface{})["durations"] = formatLatencyHistogram(histogram)
+			}
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Cache-Control", "no-cache")
 	json.NewEncoder(w).Encode(response)
 }
@@ -88,25 +186,443 @@ func (ms *MetricsServer) formatServerStats(serverStats map[string]*domain.Server
 			status = "circuit_open"
 		}
 
-		formatted[url] = map[string]interface{}{
-			"status":          status,
-			"connections":     server.CurrentConns,
-			"total_requests":  server.TotalRequests,
-			"failed_requests": server.FailedRequests,
-			"response_time":   server.ResponseTime.String(),
-			"weight":          server.Weight,
-			"active":          server.Active,
+		stats := map[string]interface{}{
+			"status":           status,
+			"connections":      server.CurrentConns,
+			"total_requests":   server.TotalRequests,
+			"failed_requests":  server.FailedRequests,
+			"response_time":    server.ResponseTime.String(),
+			"weight":           server.Weight,
+			"effective_weight": server.EffectiveWeight,
+			"max_connections":  server.MaxConnections,
+			"active":           server.Active,
+			"circuit_override": server.CircuitOverride,
+			"percentiles":      formatPercentiles(server.LatencyPercentiles),
+			"upgraded_connections": map[string]interface{}{
+				"active": server.UpgradedConns,
+				"closed": server.UpgradedClosed,
+			},
+			"connection_pool": map[string]interface{}{
+				"open":   server.OpenConns,
+				"idle":   server.IdleConns,
+				"new":    server.NewConns,
+				"reused": server.ReusedConns,
+			},
+		}
+		if len(server.Labels) > 0 {
+			stats["labels"] = server.Labels
 		}
+		formatted[url] = stats
+	}
+
+	return formatted
+}
+
+// formatPercentiles convierte las latencias por percentil a texto legible
+func formatPercentiles(percentiles map[string]time.Duration) map[string]string {
+	formatted := make(map[string]string, len(percentiles))
+	for label, latency := range percentiles {
+		formatted[label] = latency.String()
+	}
+	return formatted
+}
+
+// formatMirrorStats resume el tráfico replicado, que no cuenta en los servidores
+func formatMirrorStats(metrics *domain.TrafficMetrics) map[string]interface{} {
+	return map[string]interface{}{
+		"requests": atomic.LoadInt64(&metrics.MirrorRequests),
+		"failures": atomic.LoadInt64(&metrics.MirrorFailures),
+		"dropped":  atomic.LoadInt64(&metrics.MirrorDropped),
+	}
+}
+
+// formatBackendStats resume las requests en curso y el descarte de carga de cada
+// backend; el backend "default" es proxy.default_backend
+// formatRejections separa los 503 por falta de capacidad de los causados por
+// servidores no disponibles
+func formatRejections(metrics *domain.TrafficMetrics) map[string]int64 {
+	return map[string]int64{
+		domain.RejectCapacity:  atomic.LoadInt64(&metrics.CapacityRejections),
+		domain.RejectUnhealthy: atomic.LoadInt64(&metrics.UnhealthyRejections),
+	}
+}
+
+// formatPressureStats expone el último muestreo de proxy.load_shedding
+func formatPressureStats(metrics *domain.TrafficMetrics) map[string]interface{} {
+	pressure := metrics.Pressure
+	return map[string]interface{}{
+		"enabled":       pressure.Enabled,
+		"shedding":      pressure.Shedding,
+		"cpu_percent":   pressure.CPUPercent,
+		"goroutines":    pressure.Goroutines,
+		"heap_bytes":    pressure.HeapBytes,
+		"shed_requests": pressure.Shed,
 	}
+}
 
+func formatBackendStats(metrics *domain.TrafficMetrics) map[string]interface{} {
+	formatted := make(map[string]interface{}, len(metrics.BackendInFlight))
+	for name, inFlight := range metrics.BackendInFlight {
+		stats := map[string]interface{}{
+			"in_flight":     inFlight,
+			"shed_rate":     metrics.BackendShedRate[name],
+			"shed_requests": metrics.BackendShed[name],
+		}
+		if name == domain.DefaultBackendName {
+			stats["requests"] = metrics.DefaultBackendRequests
+			stats["failures"] = metrics.DefaultBackendFailures
+		}
+		formatted[name] = stats
+	}
 	return formatted
 }
 
+// handleServerDetail devuelve el estado completo de un servidor: GET /metrics/server?url=...
+func (ms *MetricsServer) handleServerDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ms.loadBalancer == nil {
+		http.Error(w, "Load balancer not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	serverURL := r.URL.Query().Get("url")
+	if serverURL == "" {
+		http.Error(w, "Missing url parameter", http.StatusBadRequest)
+		return
+	}
+	if _, err := domain.ParseBackendServerURL(serverURL); err != nil {
+		http.Error(w, "Invalid server URL", http.StatusBadRequest)
+		return
+	}
+
+	detail, exists := ms.loadBalancer.GetServerDetail(serverURL)
+	if !exists {
+		http.Error(w, "Server not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// handleHealthMetrics expone el resumen de health checks por backend
+func (ms *MetricsServer) handleHealthMetrics(w http.ResponseWriter, r *http.Request) {
+	if ms.healthChecker == nil {
+		http.Error(w, "Health checker not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timestamp": time.Now(),
+		"backends":  ms.healthChecker.GetHealthMetrics(),
+	})
+}
+
+// latencyHistograms devuelve el histograma por servidor y el agregado de todos ellos
+func (ms *MetricsServer) latencyHistograms() (map[string]*LatencyHistogram, *LatencyHistogram) {
+	if ms.loadBalancer == nil {
+		return nil, nil
+	}
+	return ms.loadBalancer.GetLatencyHistograms()
+}
+
+func formatLatencyHistogram(h *LatencyHistogram) map[string]interface{} {
+	buckets := make([]map[string]interface{}, 0, len(h.Counts))
+	for i, bound := range h.Buckets {
+		buckets = append(buckets, map[string]interface{}{
+			"le":    bound.String(),
+			"count": h.Counts[i],
+		})
+	}
+	buckets = append(buckets, map[string]interface{}{
+		"le":    "+Inf",
+		"count": h.Counts[len(h.Buckets)],
+	})
+
+	return map[string]interface{}{
+		"buckets": buckets,
+		"count":   h.Count,
+		"sum":     h.Sum.String(),
+	}
+}
+
+// handlePrometheus expone las métricas en formato de texto de Prometheus.
+// El histograma de tiempos de respuesta acumula desde el alta de cada servidor.
+func (ms *MetricsServer) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	serverStats := ms.proxyService.GetServerStats()
+
+	urls := make([]string, 0, len(serverStats))
+	for url := range serverStats {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	labels := make(map[string]string, len(urls))
+	for _, url := range urls {
+		labels[url] = prometheusServerLabels(url, serverStats[url].Labels)
+	}
+
+	var b strings.Builder
+	b.WriteString("# HELP go_proxy_requests_total Total requests sent to each server.\n")
+	b.WriteString("# TYPE go_proxy_requests_total counter\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_requests_total{%s} %d\n", labels[url], serverStats[url].TotalRequests)
+	}
+	b.WriteString("# HELP go_proxy_failed_requests_total Failed requests for each server.\n")
+	b.WriteString("# TYPE go_proxy_failed_requests_total counter\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_failed_requests_total{%s} %d\n", labels[url], serverStats[url].FailedRequests)
+	}
+	b.WriteString("# HELP go_proxy_active_connections Current active connections for each server.\n")
+	b.WriteString("# TYPE go_proxy_active_connections gauge\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_active_connections{%s} %d\n", labels[url], serverStats[url].CurrentConns)
+	}
+
+	b.WriteString("# HELP go_proxy_upgraded_connections Upgraded connections (WebSocket) open to each server.\n")
+	b.WriteString("# TYPE go_proxy_upgraded_connections gauge\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_upgraded_connections{%s} %d\n", labels[url], serverStats[url].UpgradedConns)
+	}
+
+	b.WriteString("# HELP go_proxy_open_connections Open TCP connections to each server, busy or idle.\n")
+	b.WriteString("# TYPE go_proxy_open_connections gauge\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_open_connections{%s} %d\n", labels[url], serverStats[url].OpenConns)
+	}
+	b.WriteString("# HELP go_proxy_idle_connections Open connections to each server without a request in flight.\n")
+	b.WriteString("# TYPE go_proxy_idle_connections gauge\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_idle_connections{%s} %d\n", labels[url], serverStats[url].IdleConns)
+	}
+	b.WriteString("# HELP go_proxy_new_connections_total Requests to each server that opened a new connection.\n")
+	b.WriteString("# TYPE go_proxy_new_connections_total counter\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_new_connections_total{%s} %d\n", labels[url], serverStats[url].NewConns)
+	}
+	b.WriteString("# HELP go_proxy_reused_connections_total Requests to each server that reused a keep-alive connection.\n")
+	b.WriteString("# TYPE go_proxy_reused_connections_total counter\n")
+	for _, url := range urls {
+		fmt.Fprintf(&b, "go_proxy_reused_connections_total{%s} %d\n", labels[url], serverStats[url].ReusedConns)
+	}
+
+	b.WriteString("# HELP go_proxy_response_time_percentile_seconds Response time percentiles of recent requests for each server.\n")
+	b.WriteString("# TYPE go_proxy_response_time_percentile_seconds gauge\n")
+	for _, url := range urls {
+		percentiles := serverStats[url].LatencyPercentiles
+		for _, label := range sortedPercentileLabels(percentiles) {
+			fmt.Fprintf(&b, "go_proxy_response_time_percentile_seconds{%s,percentile=%q} %g\n", labels[url], label[1:], percentiles[label].Seconds())
+		}
+	}
+
+	if histograms, _ := ms.latencyHistograms(); histograms != nil {
+		b.WriteString("# HELP go_proxy_response_time_seconds Response time of requests to each server.\n")
+		b.WriteString("# TYPE go_proxy_response_time_seconds histogram\n")
+		for _, url := range urls {
+			histogram, ok := histograms[url]
+			if !ok {
+				continue
+			}
+			for i, bound := range histogram.Buckets {
+				fmt.Fprintf(&b, "go_proxy_response_time_seconds_bucket{%s,le=\"%g\"} %d\n", labels[url], bound.Seconds(), histogram.Counts[i])
+			}
+			fmt.Fprintf(&b, "go_proxy_response_time_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels[url], histogram.Counts[len(histogram.Buckets)])
+			fmt.Fprintf(&b, "go_proxy_response_time_seconds_sum{%s} %g\n", labels[url], histogram.Sum.Seconds())
+			fmt.Fprintf(&b, "go_proxy_response_time_seconds_count{%s} %d\n", labels[url], histogram.Count)
+		}
+	}
+
+	if ms.loadBalancer != nil {
+		histograms := ms.loadBalancer.GetUpgradeHistograms()
+		b.WriteString("# HELP go_proxy_upgraded_connection_duration_seconds Duration of closed upgraded connections (WebSocket) for each server.\n")
+		b.WriteString("# TYPE go_proxy_upgraded_connection_duration_seconds histogram\n")
+		for _, url := range urls {
+			histogram, ok := histograms[url]
+			if !ok {
+				continue
+			}
+			for i, bound := range histogram.Buckets {
+				fmt.Fprintf(&b, "go_proxy_upgraded_connection_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels[url], bound.Seconds(), histogram.Counts[i])
+			}
+			fmt.Fprintf(&b, "go_proxy_upgraded_connection_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels[url], histogram.Counts[len(histogram.Buckets)])
+			fmt.Fprintf(&b, "go_proxy_upgraded_connection_duration_seconds_sum{%s} %g\n", labels[url], histogram.Sum.Seconds())
+			fmt.Fprintf(&b, "go_proxy_upgraded_connection_duration_seconds_count{%s} %d\n", labels[url], histogram.Count)
+		}
+
+		memory := ms.loadBalancer.MetricsMemory()
+		b.WriteString("# HELP go_proxy_latency_samples Latency samples kept per server for percentiles.\n")
+		b.WriteString("# TYPE go_proxy_latency_samples gauge\n")
+		fmt.Fprintf(&b, "go_proxy_latency_samples %d\n", memory.LatencySamples)
+		b.WriteString("# HELP go_proxy_metrics_memory_bytes Memory held by the latency samples of all servers.\n")
+		b.WriteString("# TYPE go_proxy_metrics_memory_bytes gauge\n")
+		fmt.Fprintf(&b, "go_proxy_metrics_memory_bytes %d\n", memory.Bytes)
+	}
+
+	if metrics := ms.proxyService.GetMetrics(); metrics != nil {
+		b.WriteString("# HELP go_proxy_rejected_requests_total Requests answered 503 for lack of a server, by reason.\n")
+		b.WriteString("# TYPE go_proxy_rejected_requests_total counter\n")
+		rejections := formatRejections(metrics)
+		for _, reason := range []string{domain.RejectCapacity, domain.RejectUnhealthy} {
+			fmt.Fprintf(&b, "go_proxy_rejected_requests_total{reason=%q} %d\n", reason, rejections[reason])
+		}
+
+		shedding := 0
+		if metrics.Pressure.Shedding {
+			shedding = 1
+		}
+		b.WriteString("# HELP go_proxy_pressure_shedding Whether new requests are being shed for process pressure (1) or not (0).\n")
+		b.WriteString("# TYPE go_proxy_pressure_shedding gauge\n")
+		fmt.Fprintf(&b, "go_proxy_pressure_shedding %d\n", shedding)
+		b.WriteString("# HELP go_proxy_pressure_shed_requests_total Requests answered 503 because the proxy process was under pressure.\n")
+		b.WriteString("# TYPE go_proxy_pressure_shed_requests_total counter\n")
+		fmt.Fprintf(&b, "go_proxy_pressure_shed_requests_total %d\n", metrics.Pressure.Shed)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, b.String())
+}
+
+// prometheusServerLabels devuelve las dimensiones de las series de un servidor:
+// server más sus labels de la configuración, en orden estable
+func prometheusServerLabels(serverURL string, serverLabels map[string]string) string {
+	names := make([]string, 0, len(serverLabels))
+	for name := range serverLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "server=%q", serverURL)
+	for _, name := range names {
+		fmt.Fprintf(&b, ",%s=%q", name, serverLabels[name])
+	}
+	return b.String()
+}
+
+// handleTriggerMetrics expone los componentes del score del SmartTrigger de
+// cada backend como gauges de Prometheus. Sin evaluaciones la respuesta va vacía.
+func (ms *MetricsServer) handleTriggerMetrics(w http.ResponseWriter, r *http.Request) {
+	if ms.triggerMetrics == nil {
+		http.Error(w, "Smart trigger not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	backends := ms.triggerMetrics.GetTriggerMetrics()
+	if len(backends) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	gauge := func(name, help string, value func(m domain.TriggerMetrics) string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		for _, m := range backends {
+			fmt.Fprint(&b, value(m))
+		}
+	}
+
+	gauge("go_proxy_trigger_score", "Smart trigger score components (0-1).", func(m domain.TriggerMetrics) string {
+		var s strings.Builder
+		for _, c := range []struct {
+			name  string
+			value float64
+		}{
+			{"rps", m.RPSScore},
+			{"latency", m.LatencyScore},
+			{"error", m.ErrorScore},
+			{"connections", m.ConnScore},
+			{"queue", m.QueueScore},
+			{"total", m.TotalScore},
+		} {
+			fmt.Fprintf(&s, "go_proxy_trigger_score{backend=%q,component=%q} %g\n", m.Backend, c.name, c.value)
+		}
+		return s.String()
+	})
+	gauge("go_proxy_trigger_window_average", "Average total score over each time window.", func(m domain.TriggerMetrics) string {
+		return fmt.Sprintf("go_proxy_trigger_window_average{backend=%q,window=\"short\"} %g\n", m.Backend, m.ShortAvg) +
+			fmt.Sprintf("go_proxy_trigger_window_average{backend=%q,window=\"long\"} %g\n", m.Backend, m.LongAvg)
+	})
+	gauge("go_proxy_trigger_trend_slope", "Slope of the short window scores.", func(m domain.TriggerMetrics) string {
+		return fmt.Sprintf("go_proxy_trigger_trend_slope{backend=%q} %g\n", m.Backend, m.TrendSlope)
+	})
+	gauge("go_proxy_trigger_stability", "Stability of the short window scores (0-1).", func(m domain.TriggerMetrics) string {
+		return fmt.Sprintf("go_proxy_trigger_stability{backend=%q} %g\n", m.Backend, m.Stability)
+	})
+	gauge("go_proxy_trigger_confidence", "Confidence of the last decision (0-1).", func(m domain.TriggerMetrics) string {
+		return fmt.Sprintf("go_proxy_trigger_confidence{backend=%q} %g\n", m.Backend, m.Confidence)
+	})
+	gauge("go_proxy_trigger_cooldown_remaining_seconds", "Time until the trigger can fire again.", func(m domain.TriggerMetrics) string {
+		cooldown := time.Until(m.CooldownUntil).Seconds()
+		if cooldown < 0 {
+			cooldown = 0
+		}
+		return fmt.Sprintf("go_proxy_trigger_cooldown_remaining_seconds{backend=%q} %g\n", m.Backend, cooldown)
+	})
+	gauge("go_proxy_trigger_last_evaluation_timestamp_seconds", "Unix time of the last evaluation.", func(m domain.TriggerMetrics) string {
+		return fmt.Sprintf("go_proxy_trigger_last_evaluation_timestamp_seconds{backend=%q} %d\n", m.Backend, m.EvaluatedAt.Unix())
+	})
+
+	gauge("go_proxy_trigger_simulated", "Whether the last evaluation used synthetic metrics from debug.trigger_simulation (1) or not (0).", func(m domain.TriggerMetrics) string {
+		simulated := 0
+		if m.Simulated {
+			simulated = 1
+		}
+		return fmt.Sprintf("go_proxy_trigger_simulated{backend=%q} %d\n", m.Backend, simulated)
+	})
+
+	if provider, ok := ms.triggerMetrics.(domain.TriggerRecoveryProvider); ok {
+		writeTriggerRecovery(&b, provider.GetTriggerRecovery())
+	}
+
+	fmt.Fprint(w, b.String())
+}
+
+// writeTriggerRecovery escribe el histograma del tiempo que tarda el score en
+// volver a la banda neutra después de una acción de escalado
+func writeTriggerRecovery(b *strings.Builder, recovery []domain.TriggerRecovery) {
+	if len(recovery) == 0 {
+		return
+	}
+	b.WriteString("# HELP go_proxy_trigger_recovery_seconds Time from a scaling action until the score returns to the neutral band.\n")
+	b.WriteString("# TYPE go_proxy_trigger_recovery_seconds histogram\n")
+	for _, r := range recovery {
+		for i, bound := range r.Buckets {
+			fmt.Fprintf(b, "go_proxy_trigger_recovery_seconds_bucket{backend=%q,action=%q,le=\"%g\"} %d\n", r.Backend, r.Action, bound.Seconds(), r.Counts[i])
+		}
+		fmt.Fprintf(b, "go_proxy_trigger_recovery_seconds_bucket{backend=%q,action=%q,le=\"+Inf\"} %d\n", r.Backend, r.Action, r.Counts[len(r.Buckets)])
+		fmt.Fprintf(b, "go_proxy_trigger_recovery_seconds_sum{backend=%q,action=%q} %g\n", r.Backend, r.Action, r.Sum.Seconds())
+		fmt.Fprintf(b, "go_proxy_trigger_recovery_seconds_count{backend=%q,action=%q} %d\n", r.Backend, r.Action, r.Count)
+	}
+}
+
+// handleTriggerHistory devuelve las últimas acciones del SmartTrigger, incluidas las simuladas en dry_run
+func (ms *MetricsServer) handleTriggerHistory(w http.ResponseWriter, r *http.Request) {
+	if ms.triggerMetrics == nil {
+		http.Error(w, "Smart trigger not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"timestamp": time.Now(),
+		"events":    ms.triggerMetrics.GetTriggerHistory(),
+	})
+}
+
 func (ms *MetricsServer) handleStream(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -161,227 +677,18 @@ func (ms *MetricsServer) getMetricsData() map[string]interface{} {
 			"failed_requests":       failedRequests,
 			"average_response_time": metrics.AverageResponseTime.String(),
 			"error_rate":            errorRate,
+			"active_sessions":       atomic.LoadInt64(&metrics.ActiveSessions),
+			"stalled_responses":     atomic.LoadInt64(&metrics.StalledResponses),
+			"rejected_requests":     formatRejections(metrics),
 		},
-		"servers": ms.formatServerStats(serverStats),
+		"mirror":   formatMirrorStats(metrics),
+		"pressure": formatPressureStats(metrics),
+		"backends": formatBackendStats(metrics),
+		"servers":  ms.formatServerStats(serverStats),
 	}
 }
 
 func (ms *MetricsServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprint(w, `<!DOCTYPE html>
-<html>
-<head>
-    <title>Go-Proxy Dashboard</title>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body { font-family: Arial, sans-serif; background: linear-gradient(135deg, #667eea 0%, #764ba2 100%); min-height: 100vh; color: #333; }
-        .container { max-width: 1200px; margin: 0 auto; padding: 20px; }
-        .header { text-align: center; color: white; margin-bottom: 30px; }
-        .header h1 { font-size: 2.5em; margin-bottom: 10px; }
-        .status-bar { background: rgba(255,255,255,0.1); padding: 15px; border-radius: 10px; margin-bottom: 20px; text-align: center; color: white; }
-        .grid { display: grid; grid-template-columns: repeat(auto-fit, minmax(300px, 1fr)); gap: 20px; margin-bottom: 20px; }
-        .card { background: white; border-radius: 15px; padding: 25px; box-shadow: 0 8px 32px rgba(0,0,0,0.1); }
-        .card h3 { color: #4a5568; margin-bottom: 20px; font-size: 1.3em; }
-        .metric { display: flex; justify-content: space-between; align-items: center; margin: 15px 0; padding: 10px; background: #f7fafc; border-radius: 8px; }
-        .metric-label { font-weight: 500; color: #4a5568; }
-        .metric-value { font-weight: bold; font-size: 1.2em; color: #2d3748; }
-        .metric-value.success { color: #38a169; }
-        .metric-value.warning { color: #d69e2e; }
-        .metric-value.error { color: #e53e3e; }
-        .server { margin: 15px 0; padding: 15px; border-radius: 10px; border-left: 5px solid; }
-        .healthy { background: #e6fffa; border-color: #38a169; }
-        .unhealthy { background: #fed7d7; border-color: #e53e3e; }
-        .circuit_open { background: #fefcbf; border-color: #d69e2e; }
-        .draining { background: #fef5e7; border-color: #f56500; }
-        .server-header { display: flex; justify-content: space-between; align-items: center; margin-bottom: 10px; }
-        .server-url { font-weight: bold; font-size: 1.1em; }
-        .server-status { padding: 4px 12px; border-radius: 20px; font-size: 0.8em; font-weight: bold; text-transform: uppercase; }
-        .status-healthy { background: #38a169; color: white; }
-        .status-unhealthy { background: #e53e3e; color: white; }
-        .status-circuit_open { background: #d69e2e; color: white; }
-        .status-draining { background: #f56500; color: white; }
-        .status-draining { background: #f56500; color: white; }
-        .server-stats { display: grid; grid-template-columns: repeat(auto-fit, minmax(120px, 1fr)); gap: 10px; font-size: 0.9em; }
-        .stat { text-align: center; padding: 8px; background: rgba(255,255,255,0.7); border-radius: 6px; }
-        .stat-label { display: block; font-size: 0.8em; color: #666; margin-bottom: 4px; }
-        .stat-value { font-weight: bold; color: #2d3748; }
-        .live-indicator { display: inline-block; width: 10px; height: 10px; background: #38a169; border-radius: 50%; margin-right: 8px; animation: pulse 1s infinite; }
-        @keyframes pulse { 0%, 100% { opacity: 1; } 50% { opacity: 0.7; } }
-        .footer { text-align: center; color: rgba(255,255,255,0.8); margin-top: 30px; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>🚀 Go-Proxy Dashboard</h1>
-            <p>Intelligent Load Balancer</p>
-        </div>
-        
-        <div class="status-bar">
-            <span class="live-indicator"></span>
-            <strong>LIVE</strong> | Last Update: <span id="lastUpdate">-</span> | 
-            Algorithm: Adaptive Weighted | 
-            Uptime: <span id="uptime">-</span>
-        </div>
-
-        <div class="grid">
-            <div class="card">
-                <h3>📊 Traffic Metrics</h3>
-                <div class="metric">
-                    <span class="metric-label">Requests/Second</span>
-                    <span class="metric-value success" id="rps">0</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Total Requests</span>
-                    <span class="metric-value" id="total">0</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Active Connections</span>
-                    <span class="metric-value" id="active">0</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Success Rate</span>
-                    <span class="metric-value success" id="success">100%</span>
-                </div>
-            </div>
-
-            <div class="card">
-                <h3>⚡ Performance</h3>
-                <div class="metric">
-                    <span class="metric-label">Avg Response Time</span>
-                    <span class="metric-value" id="response">0ms</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Error Rate</span>
-                    <span class="metric-value" id="error">0%</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Circuit Breakers</span>
-                    <span class="metric-value" id="circuits">0 Open</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Draining Servers</span>
-                    <span class="metric-value warning" id="draining">0</span>
-                </div>
-                <div class="metric">
-                    <span class="metric-label">Load Balance</span>
-                    <span class="metric-value success">Optimal</span>
-                </div>
-            </div>
-        </div>
-
-        <div class="card">
-            <h3>🖥️ Backend Servers</h3>
-            <div id="servers">Loading server stats...</div>
-        </div>
-        
-        <div class="footer">
-            <p>🔄 Auto-refreshing every second</p>
-        </div>
-    </div>
-
-    <script>
-        let startTime = Date.now();
-        
-        function formatNumber(num) {
-            return new Intl.NumberFormat().format(num);
-        }
-        
-        function formatUptime(ms) {
-            const seconds = Math.floor(ms / 1000);
-            const minutes = Math.floor(seconds / 60);
-            const hours = Math.floor(minutes / 60);
-            return hours > 0 ? hours + 'h ' + (minutes % 60) + 'm' : minutes + 'm ' + (seconds % 60) + 's';
-        }
-        
-        let eventSource;
-        
-        function startStream() {
-            eventSource = new EventSource('/ws');
-            eventSource.onmessage = function(event) {
-                const data = JSON.parse(event.data);
-                    document.getElementById('rps').textContent = data.metrics.requests_per_second || 0;
-                    document.getElementById('total').textContent = formatNumber(data.metrics.total_requests || 0);
-                    document.getElementById('active').textContent = data.metrics.active_connections || 0;
-                    
-                    const errorRate = data.metrics.error_rate || 0;
-                    const errorEl = document.getElementById('error');
-                    errorEl.textContent = errorRate.toFixed(2) + '%';
-                    errorEl.className = 'metric-value ' + (errorRate > 5 ? 'error' : errorRate > 1 ? 'warning' : 'success');
-                    
-                    const successRate = 100 - errorRate;
-                    document.getElementById('success').textContent = successRate.toFixed(1) + '%';
-                    
-                    document.getElementById('response').textContent = data.metrics.average_response_time || '0ms';
-                    document.getElementById('uptime').textContent = formatUptime(Date.now() - startTime);
-                    
-                    const serversDiv = document.getElementById('servers');
-                    serversDiv.innerHTML = '';
-                    
-                    let circuitCount = 0;
-                    let drainingCount = data.draining_servers ? data.draining_servers.length : 0;
-                    
-                    for (const [url, server] of Object.entries(data.servers || {})) {
-                        if (server.status === 'circuit_open') circuitCount++;
-                        
-                        const serverDiv = document.createElement('div');
-                        serverDiv.className = 'server ' + (server.status || 'healthy');
-                        
-                        const statusClass = 'status-' + (server.status || 'healthy');
-                        const statusText = (server.status || 'healthy').replace('_', ' ').toUpperCase();
-                        
-                        serverDiv.innerHTML = 
-                            '<div class="server-header">' +
-                                '<span class="server-url">' + url + '</span>' +
-                                '<span class="server-status ' + statusClass + '">' + statusText + '</span>' +
-                            '</div>' +
-                            '<div class="server-stats">' +
-                                '<div class="stat">' +
-                                    '<span class="stat-label">Connections</span>' +
-                                    '<span class="stat-value">' + (server.connections || 0) + '</span>' +
-                                '</div>' +
-                                '<div class="stat">' +
-                                    '<span class="stat-label">Requests</span>' +
-                                    '<span class="stat-value">' + formatNumber(server.total_requests || 0) + '</span>' +
-                                '</div>' +
-                                '<div class="stat">' +
-                                    '<span class="stat-label">Failed</span>' +
-                                    '<span class="stat-value">' + formatNumber(server.failed_requests || 0) + '</span>' +
-                                '</div>' +
-                                '<div class="stat">' +
-                                    '<span class="stat-label">Response</span>' +
-                                    '<span class="stat-value">' + (server.response_time || '0ms') + '</span>' +
-                                '</div>' +
-                                '<div class="stat">' +
-                                    '<span class="stat-label">Weight</span>' +
-                                    '<span class="stat-value">' + (server.weight || 1) + '</span>' +
-                                '</div>' +
-                                (server.draining ? 
-                                    '<div class="stat">' +
-                                        '<span class="stat-label">Draining</span>' +
-                                        '<span class="stat-value">🔄 YES</span>' +
-                                    '</div>' : '') +
-                            '</div>';
-                        
-                        serversDiv.appendChild(serverDiv);
-                    }
-                    
-                    document.getElementById('circuits').textContent = circuitCount + ' Open';
-                    document.getElementById('draining').textContent = drainingCount;
-                    document.getElementById('lastUpdate').textContent = new Date().toLocaleTimeString();
-            };
-            
-            eventSource.onerror = function(event) {
-                console.error('Stream error:', event);
-                document.getElementById('lastUpdate').textContent = 'Connection lost - reconnecting...';
-                setTimeout(startStream, 2000);
-            };
-        }
-        
-        startStream();
-    </script>
-</body>
-</html>`)
+	w.Write(dashboardHTML)
 }
@@ -0,0 +1,255 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestMetricsServer_ServerDetail(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateServers([]domain.Server{
+		{URL: "http://localhost:3001", Weight: 2, Active: true},
+	}, &domain.Backend{
+		CircuitBreaker: domain.CircuitBreakerCfg{FailureThreshold: 5, RecoveryTimeout: 30 * time.Second},
+	})
+
+	server := &domain.Server{URL: "http://localhost:3001"}
+	balancer.UpdateStats(server, 100*time.Millisecond, true)
+	balancer.UpdateStats(server, 200*time.Millisecond, false)
+
+	ms := NewMetricsServer(nil)
+	ms.SetLoadBalancer(balancer)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{name: "known server", query: "?url=" + url.QueryEscape("http://localhost:3001"), expected: http.StatusOK},
+		{name: "unknown server", query: "?url=" + url.QueryEscape("http://localhost:9999"), expected: http.StatusNotFound},
+		{name: "missing url", query: "", expected: http.StatusBadRequest},
+		{name: "invalid url", query: "?url=not-a-url", expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics/server"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			ms.handleServerDetail(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.expected != http.StatusOK {
+				return
+			}
+
+			var detail ServerDetail
+			if err := json.NewDecoder(w.Body).Decode(&detail); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if detail.URL != "http://localhost:3001" {
+				t.Errorf("expected url http://localhost:3001, got %s", detail.URL)
+			}
+			if detail.ConsecutiveFails != 1 {
+				t.Errorf("expected 1 consecutive fail, got %d", detail.ConsecutiveFails)
+			}
+			if detail.CircuitBreaker.State != "closed" || detail.CircuitBreaker.FailureThreshold != 5 {
+				t.Errorf("unexpected circuit breaker detail: %+v", detail.CircuitBreaker)
+			}
+			if detail.Metrics.EWMAResponseTime != "120ms" {
+				t.Errorf("expected EWMA 120ms, got %s", detail.Metrics.EWMAResponseTime)
+			}
+			if detail.HealthState != "healthy" {
+				t.Errorf("expected healthy state, got %s", detail.HealthState)
+			}
+		})
+	}
+}
+
+func TestPrometheusServerLabels(t *testing.T) {
+	labels := prometheusServerLabels("http://10.0.1.5:3001", map[string]string{"zone": "us-east-1a", "class": "spot"})
+	expected := `server="http://10.0.1.5:3001",class="spot",zone="us-east-1a"`
+	if labels != expected {
+		t.Errorf("expected %s, got %s", expected, labels)
+	}
+
+	if labels := prometheusServerLabels("http://10.0.1.6:3001", nil); labels != `server="http://10.0.1.6:3001"` {
+		t.Errorf("expected only the server label, got %s", labels)
+	}
+}
+
+func TestMetricsServer_HealthMetrics(t *testing.T) {
+	ms := NewMetricsServer(nil)
+
+	w := httptest.NewRecorder()
+	ms.handleHealthMetrics(w, httptest.NewRequest("GET", "/metrics/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 without health checker, got %d", w.Code)
+	}
+
+	hc := NewAdvancedHealthChecker()
+	hc.backends["web"] = &domain.Backend{
+		Name:    "web",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Active: true, Healthy: true}},
+	}
+	ms.SetHealthChecker(hc)
+
+	w = httptest.NewRecorder()
+	ms.handleHealthMetrics(w, httptest.NewRequest("GET", "/metrics/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Backends map[string]map[string]interface{} `json:"backends"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Backends["web"]["healthy_servers"] != float64(1) {
+		t.Errorf("expected 1 healthy server, got %v", response.Backends["web"]["healthy_servers"])
+	}
+}
+
+type stubTriggerMetrics struct {
+	metrics  []domain.TriggerMetrics
+	history  []domain.TriggerEvent
+	recovery []domain.TriggerRecovery
+}
+
+func (s *stubTriggerMetrics) GetTriggerRecovery() []domain.TriggerRecovery {
+	return s.recovery
+}
+
+func (s *stubTriggerMetrics) GetTriggerMetrics() []domain.TriggerMetrics {
+	return s.metrics
+}
+
+func (s *stubTriggerMetrics) GetTriggerHistory() []domain.TriggerEvent {
+	return s.history
+}
+
+func TestMetricsServer_TriggerMetrics(t *testing.T) {
+	ms := NewMetricsServer(nil)
+
+	w := httptest.NewRecorder()
+	ms.handleTriggerMetrics(w, httptest.NewRequest("GET", "/metrics/trigger", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 without smart trigger, got %d", w.Code)
+	}
+
+	provider := &stubTriggerMetrics{}
+	ms.SetTriggerMetrics(provider)
+
+	w = httptest.NewRecorder()
+	ms.handleTriggerMetrics(w, httptest.NewRequest("GET", "/metrics/trigger", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected empty 200 before the first evaluation, got %d %q", w.Code, w.Body.String())
+	}
+
+	provider.metrics = []domain.TriggerMetrics{
+		{
+			Backend:       "api",
+			RPSScore:      0.5,
+			TotalScore:    0.42,
+			ShortAvg:      0.4,
+			LongAvg:       0.3,
+			TrendSlope:    -0.05,
+			Stability:     0.9,
+			CooldownUntil: time.Now().Add(time.Minute),
+			EvaluatedAt:   time.Unix(1700000000, 0),
+		},
+		{Backend: "web", TotalScore: 0.1},
+	}
+	provider.recovery = []domain.TriggerRecovery{{
+		Backend: "api",
+		Action:  "scale_up",
+		Buckets: []time.Duration{time.Minute, 5 * time.Minute},
+		Counts:  []int64{1, 2, 3},
+		Sum:     12 * time.Minute,
+		Count:   3,
+	}}
+
+	w = httptest.NewRecorder()
+	ms.handleTriggerMetrics(w, httptest.NewRequest("GET", "/metrics/trigger", nil))
+	body := w.Body.String()
+
+	for _, expected := range []string{
+		"# TYPE go_proxy_trigger_score gauge",
+		`go_proxy_trigger_score{backend="api",component="rps"} 0.5`,
+		`go_proxy_trigger_score{backend="api",component="total"} 0.42`,
+		`go_proxy_trigger_score{backend="web",component="total"} 0.1`,
+		`go_proxy_trigger_window_average{backend="api",window="short"} 0.4`,
+		`go_proxy_trigger_window_average{backend="api",window="long"} 0.3`,
+		`go_proxy_trigger_trend_slope{backend="api"} -0.05`,
+		`go_proxy_trigger_stability{backend="api"} 0.9`,
+		`go_proxy_trigger_cooldown_remaining_seconds{backend="api"} 59.9`,
+		`go_proxy_trigger_cooldown_remaining_seconds{backend="web"} 0`,
+		`go_proxy_trigger_last_evaluation_timestamp_seconds{backend="api"} 1700000000`,
+		"# TYPE go_proxy_trigger_recovery_seconds histogram",
+		`go_proxy_trigger_recovery_seconds_bucket{backend="api",action="scale_up",le="60"} 1`,
+		`go_proxy_trigger_recovery_seconds_bucket{backend="api",action="scale_up",le="+Inf"} 3`,
+		`go_proxy_trigger_recovery_seconds_sum{backend="api",action="scale_up"} 720`,
+		`go_proxy_trigger_recovery_seconds_count{backend="api",action="scale_up"} 3`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, body)
+		}
+	}
+	if strings.Count(body, "# TYPE go_proxy_trigger_score gauge") != 1 {
+		t.Error("expected a single TYPE line per metric")
+	}
+}
+
+func TestMetricsServer_TriggerHistory(t *testing.T) {
+	ms := NewMetricsServer(nil)
+	ms.SetTriggerMetrics(&stubTriggerMetrics{
+		history: []domain.TriggerEvent{{Backend: "web", Action: "scale_up", ActionName: "scale_up", DryRun: true}},
+	})
+
+	w := httptest.NewRecorder()
+	ms.handleTriggerHistory(w, httptest.NewRequest("GET", "/metrics/trigger/history", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Events []domain.TriggerEvent `json:"events"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Events) != 1 || !response.Events[0].DryRun || response.Events[0].Backend != "web" {
+		t.Errorf("unexpected history: %+v", response.Events)
+	}
+}
+
+func TestMetricsServer_DashboardAssetsEmbedded(t *testing.T) {
+	ms := NewMetricsServer(nil)
+
+	w := httptest.NewRecorder()
+	ms.handleDashboard(w, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(w.Body.String(), `href="/assets/dashboard.css"`) {
+		t.Fatalf("expected dashboard to reference embedded CSS")
+	}
+
+	for path, contentType := range map[string]string{
+		"/assets/dashboard.css": "text/css",
+		"/assets/dashboard.js":  "javascript",
+	} {
+		w := httptest.NewRecorder()
+		webAssetsHandler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Type"), contentType) {
+			t.Errorf("%s: expected 200 %s, got %d %q", path, contentType, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package infrastructure
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+const (
+	defaultMetricsPersistInterval = domain.DefaultMetricsPersistInterval
+	defaultRedisMetricsKey        = domain.DefaultRedisMetricsKey
+	redisMetricsTimeout           = 2 * time.Second
+)
+
+// NewMetricsStore crea el store configurado en metrics.persistence
+func NewMetricsStore(cfg *domain.MetricsPersistenceCfg) (domain.MetricsStore, error) {
+	switch cfg.Store {
+	case domain.MetricsStoreFile:
+		return NewFileMetricsStore(cfg.Path), nil
+	case domain.MetricsStoreRedis:
+		return NewRedisMetricsStore(cfg.Address, cfg.Password, cfg.Key), nil
+	default:
+		return nil, fmt.Errorf("unknown metrics store %q", cfg.Store)
+	}
+}
+
+// FileMetricsStore guarda el snapshot como JSON en disco
+type FileMetricsStore struct {
+	path string
+}
+
+func NewFileMetricsStore(path string) *FileMetricsStore {
+	return &FileMetricsStore{path: path}
+}
+
+func (s *FileMetricsStore) Load() (*domain.MetricsSnapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot domain.MetricsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Save escribe en un temporal y lo renombra para no dejar un JSON a medias
+func (s *FileMetricsStore) Save(snapshot *domain.MetricsSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// RedisMetricsStore guarda el snapshot como JSON en una clave de Redis.
+// Habla RESP directamente para no añadir un cliente como dependencia.
+type RedisMetricsStore struct {
+	address  string
+	password string
+	key      string
+	timeout  time.Duration
+}
+
+func NewRedisMetricsStore(address, password, key string) *RedisMetricsStore {
+	if key == "" {
+		key = defaultRedisMetricsKey
+	}
+	return &RedisMetricsStore{
+		address:  address,
+		password: password,
+		key:      key,
+		timeout:  redisMetricsTimeout,
+	}
+}
+
+func (s *RedisMetricsStore) Load() (*domain.MetricsSnapshot, error) {
+	reply, err := s.do("GET", s.key)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+
+	var snapshot domain.MetricsSnapshot
+	if err := json.Unmarshal(reply, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (s *RedisMetricsStore) Save(snapshot *domain.MetricsSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = s.do("SET", s.key, string(data))
+	return err
+}
+
+// do abre una conexión por comando: los snapshots son poco frecuentes y así
+// un Redis caído no deja conexiones colgadas
+func (s *RedisMetricsStore) do(args ...string) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", s.address, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	reader := bufio.NewReader(conn)
+	if s.password != "" {
+		if _, err := redisCommand(conn, reader, "AUTH", s.password); err != nil {
+			return nil, err
+		}
+	}
+	return redisCommand(conn, reader, args...)
+}
+
+func redisCommand(w io.Writer, r *bufio.Reader, args ...string) ([]byte, error) {
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(w, cmd.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(r)
+}
+
+// readRedisReply lee respuestas simples, enteros, errores y bulk strings;
+// un bulk nulo devuelve nil
+func readRedisReply(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// MetricsPersister restaura los contadores al arrancar y guarda snapshots
+// periódicos. Todo el I/O ocurre en su propia goroutine: un store lento
+// retrasa el siguiente snapshot, nunca una request.
+type MetricsPersister struct {
+	store    domain.MetricsStore
+	balancer *EnterpriseBalancer
+	interval time.Duration
+	stopCh   chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewMetricsPersister(store domain.MetricsStore, balancer *EnterpriseBalancer, interval time.Duration) *MetricsPersister {
+	if interval <= 0 {
+		interval = defaultMetricsPersistInterval
+	}
+	return &MetricsPersister{
+		store:    store,
+		balancer: balancer,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *MetricsPersister) Start() {
+	go p.run()
+}
+
+// Stop detiene los snapshots periódicos y guarda uno final
+func (p *MetricsPersister) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+		<-p.done
+	})
+}
+
+func (p *MetricsPersister) run() {
+	defer close(p.done)
+
+	// Sin restaurar no se guarda: se sobrescribiría el histórico con los
+	// contadores de esta ejecución
+	restored := p.restore()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !restored {
+				if restored = p.restore(); !restored {
+					continue
+				}
+			}
+			p.save()
+		case <-p.stopCh:
+			if restored {
+				p.save()
+			}
+			return
+		}
+	}
+}
+
+func (p *MetricsPersister) restore() bool {
+	snapshot, err := p.store.Load()
+	if err != nil {
+		slog.Warn("Could not restore persisted metrics", "error", err)
+		return false
+	}
+	if snapshot != nil {
+		p.balancer.RestoreMetrics(snapshot)
+		slog.Info("Restored persisted metrics", "servers", len(snapshot.Servers), "saved_at", snapshot.SavedAt.Format(time.RFC3339))
+	}
+	return true
+}
+
+func (p *MetricsPersister) save() {
+	if err := p.store.Save(p.balancer.MetricsSnapshot()); err != nil {
+		slog.Warn("Could not persist metrics", "error", err)
+	}
+}
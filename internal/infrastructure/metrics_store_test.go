@@ -0,0 +1,203 @@
+package infrastructure
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestFileMetricsStore_RoundTrip(t *testing.T) {
+	store := NewFileMetricsStore(filepath.Join(t.TempDir(), "metrics.json"))
+
+	snapshot, err := store.Load()
+	if err != nil || snapshot != nil {
+		t.Fatalf("expected empty store, got %v, %v", snapshot, err)
+	}
+
+	saved := &domain.MetricsSnapshot{
+		Servers: map[string]domain.ServerCounters{
+			"http://localhost:3001": {RequestCount: 10, SuccessCount: 9, FailureCount: 1, TotalLatency: 1000},
+		},
+		SavedAt: time.Now(),
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if loaded.Servers["http://localhost:3001"] != saved.Servers["http://localhost:3001"] {
+		t.Errorf("expected %v, got %v", saved.Servers, loaded.Servers)
+	}
+}
+
+func TestRedisMetricsStore_RoundTrip(t *testing.T) {
+	addr := startFakeRedis(t, "secret")
+	store := NewRedisMetricsStore(addr, "secret", "")
+
+	snapshot, err := store.Load()
+	if err != nil || snapshot != nil {
+		t.Fatalf("expected missing key, got %v, %v", snapshot, err)
+	}
+
+	saved := &domain.MetricsSnapshot{
+		Servers: map[string]domain.ServerCounters{"http://localhost:3001": {RequestCount: 5, SuccessCount: 5}},
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if loaded.Servers["http://localhost:3001"].RequestCount != 5 {
+		t.Errorf("unexpected snapshot: %v", loaded.Servers)
+	}
+
+	if _, err := NewRedisMetricsStore(addr, "wrong", "").Load(); err == nil {
+		t.Error("expected AUTH error with a wrong password")
+	}
+}
+
+func TestEnterpriseBalancer_RestoreMetrics(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	balancer.RestoreMetrics(&domain.MetricsSnapshot{
+		Servers: map[string]domain.ServerCounters{
+			"http://localhost:3001": {RequestCount: 100, SuccessCount: 90, FailureCount: 10},
+			"http://localhost:3002": {RequestCount: 7, SuccessCount: 7},
+		},
+	})
+
+	// El servidor se crea después de restaurar y recibe los contadores previos
+	backend := &domain.Backend{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	stats := balancer.GetServerMetrics()["http://localhost:3001"]
+	if stats.TotalRequests != 100 || stats.FailedRequests != 10 {
+		t.Errorf("expected restored totals, got requests=%d failures=%d", stats.TotalRequests, stats.FailedRequests)
+	}
+
+	// Los contadores de servidores sin tráfico se conservan en el siguiente snapshot
+	snapshot := balancer.MetricsSnapshot()
+	if snapshot.Servers["http://localhost:3002"].RequestCount != 7 {
+		t.Errorf("expected pending counters to be kept, got %v", snapshot.Servers)
+	}
+}
+
+func TestMetricsPersister_RestoresAndSavesOnStop(t *testing.T) {
+	store := NewFileMetricsStore(filepath.Join(t.TempDir(), "metrics.json"))
+	store.Save(&domain.MetricsSnapshot{
+		Servers: map[string]domain.ServerCounters{"http://localhost:3001": {RequestCount: 3, SuccessCount: 3}},
+	})
+
+	balancer := NewEnterpriseBalancer()
+	backend := &domain.Backend{
+		Name:    "test-backend",
+		Servers: []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}},
+	}
+	balancer.UpdateServers(backend.Servers, backend)
+
+	persister := NewMetricsPersister(store, balancer, time.Hour)
+	persister.Start()
+
+	server := balancer.SelectServer(backend, "127.0.0.1")
+	balancer.UpdateStats(server, time.Millisecond, true)
+	persister.Stop()
+
+	snapshot, err := store.Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if got := snapshot.Servers["http://localhost:3001"].RequestCount; got != 4 {
+		t.Errorf("expected restored plus new requests (4), got %d", got)
+	}
+}
+
+// startFakeRedis atiende AUTH, GET y SET en memoria, suficiente para el store
+func startFakeRedis(t *testing.T, password string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	data := make(chan map[string]string, 1)
+	data <- map[string]string{}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readFakeRedisCommand(reader)
+					if err != nil {
+						return
+					}
+					store := <-data
+					switch strings.ToUpper(args[0]) {
+					case "AUTH":
+						authed = args[1] == password
+						if authed {
+							conn.Write([]byte("+OK\r\n"))
+						} else {
+							conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+						}
+					case "GET":
+						if !authed {
+							conn.Write([]byte("-NOAUTH Authentication required\r\n"))
+						} else if value, ok := store[args[1]]; ok {
+							conn.Write([]byte("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"))
+						} else {
+							conn.Write([]byte("$-1\r\n"))
+						}
+					case "SET":
+						store[args[1]] = args[2]
+						conn.Write([]byte("+OK\r\n"))
+					}
+					data <- store
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func readFakeRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		value, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, strings.TrimSuffix(value, "\r\n"))
+	}
+	return args, nil
+}
@@ -0,0 +1,42 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// newPprofHandler monta los perfiles de net/http/pprof en un mux propio. El
+// paquete también los registra en http.DefaultServeMux al importarse, por eso
+// ningún servidor del proxy debe usar el mux por defecto.
+func newPprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+func isPprofPath(path string) bool {
+	return path == "/debug/pprof" || strings.HasPrefix(path, "/debug/pprof/")
+}
+
+// servePprof exige una admin key antes de mirar debug.pprof_enabled, de modo
+// que sin ella no se revela si el profiling está activo
+func (api *ConfigAPI) servePprof(w http.ResponseWriter, r *http.Request) {
+	if !api.authenticateAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	if config := api.configManager.GetConfig(); config == nil || !config.Debug.PprofEnabled {
+		http.Error(w, "Profiling disabled (debug.pprof_enabled)", http.StatusNotFound)
+		return
+	}
+	if r.URL.Path == "/debug/pprof" {
+		http.Redirect(w, r, "/debug/pprof/", http.StatusMovedPermanently)
+		return
+	}
+	api.pprof.ServeHTTP(w, r)
+}
@@ -0,0 +1,142 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// Estrategias de DELETE /servers para elegir qué servidores quitar sin
+// conocer sus URLs, p. ej. desde un scale-down automático
+const (
+	RemoveByURL              = "by_url"
+	RemoveLeastConnections   = "least_connections"
+	RemoveLastAdded          = "last_added"
+	RemoveHighestConnections = "highest_connections"
+	RemoveByPattern          = "pattern"
+)
+
+var (
+	errUnknownRemoveStrategy = errors.New("unknown strategy")
+	errNoServerToRemove      = errors.New("server not found")
+	errBelowMinServers       = errors.New("minimum servers limit reached")
+)
+
+// removalStrategy devuelve la estrategia efectiva: by_url si se indica la URL
+// y, si no, el servidor con menos conexiones
+func (req RemoveServerRequest) removalStrategy() string {
+	switch {
+	case req.Strategy != "":
+		return req.Strategy
+	case req.ServerURL != "":
+		return RemoveByURL
+	default:
+		return RemoveLeastConnections
+	}
+}
+
+// pickRemovalVictims elige los servidores del backend a quitar. Los que ya
+// están drenando no son candidatos ni cuentan para min_servers.
+func (api *ConfigAPI) pickRemovalVictims(backend *domain.Backend, req RemoveServerRequest) ([]string, error) {
+	var connections map[string]*domain.Server
+	candidates := make([]domain.Server, 0, len(backend.Servers))
+	for _, server := range backend.Servers {
+		if api.loadBalancer != nil && api.loadBalancer.IsServerDraining(server.URL) {
+			continue
+		}
+		candidates = append(candidates, server)
+	}
+	if api.loadBalancer != nil {
+		connections = api.loadBalancer.GetServerMetrics()
+	}
+	// Con label la estrategia elige solo entre esos servidores (p. ej. class:spot),
+	// pero min_servers sigue contando todo el backend
+	pool := candidates
+	if req.Label != "" {
+		name, value, err := domain.ParseLabelSelector(req.Label)
+		if err != nil {
+			return nil, err
+		}
+		selector := map[string]string{name: value}
+		pool = nil
+		for i := range candidates {
+			if candidates[i].MatchesLabels(selector) {
+				pool = append(pool, candidates[i])
+			}
+		}
+	}
+	activeConns := func(serverURL string) int64 {
+		if stats, ok := connections[serverURL]; ok {
+			return stats.CurrentConns
+		}
+		return 0
+	}
+
+	var victims []string
+	switch req.removalStrategy() {
+	case RemoveByURL:
+		for _, server := range pool {
+			if server.URL == req.ServerURL {
+				victims = append(victims, server.URL)
+			}
+		}
+	case RemoveLastAdded:
+		// Los servidores se añaden al final de la lista
+		if len(pool) > 0 {
+			victims = append(victims, pool[len(pool)-1].URL)
+		}
+	case RemoveLeastConnections, RemoveHighestConnections:
+		// Se recorre desde el final: a igualdad de carga sale el más reciente
+		highest := req.removalStrategy() == RemoveHighestConnections
+		var victim string
+		var best int64
+		for i := len(pool) - 1; i >= 0; i-- {
+			conns := activeConns(pool[i].URL)
+			if victim == "" || (highest && conns > best) || (!highest && conns < best) {
+				victim, best = pool[i].URL, conns
+			}
+		}
+		if victim != "" {
+			victims = append(victims, victim)
+		}
+	case RemoveByPattern:
+		if req.Pattern == "" {
+			return nil, fmt.Errorf("pattern is required for strategy %s", RemoveByPattern)
+		}
+		if _, err := path.Match(req.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", req.Pattern, err)
+		}
+		for _, server := range pool {
+			if matchServerHost(req.Pattern, server.URL) {
+				victims = append(victims, server.URL)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", errUnknownRemoveStrategy, req.Strategy)
+	}
+
+	if len(victims) == 0 {
+		return nil, errNoServerToRemove
+	}
+	if backend.MinServers > 0 && len(candidates)-len(victims) < backend.MinServers {
+		return nil, errBelowMinServers
+	}
+	return victims, nil
+}
+
+// matchServerHost compara el glob con host:puerto del servidor
+// ("10.0.1.*", "*.old.internal:*")
+func matchServerHost(pattern, serverURL string) bool {
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return false
+	}
+	if matched, _ := path.Match(pattern, parsed.Host); matched {
+		return true
+	}
+	matched, _ := path.Match(pattern, parsed.Hostname())
+	return matched
+}
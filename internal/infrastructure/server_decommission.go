@@ -0,0 +1,203 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrServerNotFound = errors.New("server not found in load balancer")
+	ErrServerDraining = errors.New("server is already draining")
+	ErrMinServers     = errors.New("minimum servers limit reached")
+)
+
+// Fases de un decommission: drenado, borrado de la config y resultado final
+const (
+	DecommissionDraining  = "draining"
+	DecommissionRemoving  = "removing"
+	DecommissionCompleted = "completed"
+	DecommissionFailed    = "failed"
+	DecommissionCancelled = "cancelled"
+)
+
+// DecommissionStatus describe el progreso de un decommission
+type DecommissionStatus struct {
+	ServerURL            string     `json:"server_url"`
+	Phase                string     `json:"phase"`
+	RemainingConnections int64      `json:"remaining_connections"`
+	StartedAt            time.Time  `json:"started_at"`
+	Deadline             time.Time  `json:"deadline"`
+	FinishedAt           *time.Time `json:"finished_at,omitempty"`
+	Error                string     `json:"error,omitempty"`
+}
+
+// Finished indica que el decommission ya no va a cambiar de fase
+func (s DecommissionStatus) Finished() bool {
+	return s.Phase == DecommissionCompleted || s.Phase == DecommissionFailed || s.Phase == DecommissionCancelled
+}
+
+type decommission struct {
+	status DecommissionStatus
+	done   chan struct{}
+}
+
+// ServerDecommissioner coordina la baja de un servidor: lo drena en el
+// balanceador, al terminar lo quita de la config persistida y comprueba que
+// el balanceador lo haya soltado
+type ServerDecommissioner struct {
+	mu             sync.Mutex
+	balancer       *EnterpriseBalancer
+	config         *GracefulConfigManager
+	operations     map[string]*decommission
+	confirmTimeout time.Duration
+}
+
+func NewServerDecommissioner(balancer *EnterpriseBalancer, config *GracefulConfigManager) *ServerDecommissioner {
+	d := &ServerDecommissioner{
+		balancer:       balancer,
+		config:         config,
+		operations:     make(map[string]*decommission),
+		confirmTimeout: 5 * time.Second,
+	}
+	// Todo drenado termina borrando el servidor de la config, haya o no decommission
+	balancer.serverLifecycle.SetCallbacks(balancer.forgetServer, d.serverDrained)
+	return d
+}
+
+// Start inicia el drenado de serverURL; el canal se cierra al terminar
+func (d *ServerDecommissioner) Start(serverURL string) (<-chan struct{}, error) {
+	if !d.balancer.HasServer(serverURL) {
+		return nil, ErrServerNotFound
+	}
+	if d.balancer.IsServerDraining(serverURL) {
+		return nil, ErrServerDraining
+	}
+	if backend := d.belowMinServers(serverURL); backend != "" {
+		return nil, fmt.Errorf("%w for backend %s", ErrMinServers, backend)
+	}
+
+	op := &decommission{
+		status: DecommissionStatus{ServerURL: serverURL, Phase: DecommissionDraining, StartedAt: time.Now()},
+		done:   make(chan struct{}),
+	}
+	// Registrar antes de drenar: un servidor sin conexiones termina enseguida
+	d.mu.Lock()
+	if current, exists := d.operations[serverURL]; exists && !current.status.Finished() {
+		d.mu.Unlock()
+		return nil, ErrServerDraining
+	}
+	d.operations[serverURL] = op
+	d.mu.Unlock()
+
+	if !d.balancer.GracefulRemoveServer(serverURL) {
+		d.mu.Lock()
+		delete(d.operations, serverURL)
+		d.mu.Unlock()
+		return nil, ErrServerNotFound
+	}
+	slog.Info("Server decommission started", "server", serverURL)
+	return op.done, nil
+}
+
+// belowMinServers devuelve el backend que quedaría por debajo de min_servers
+func (d *ServerDecommissioner) belowMinServers(serverURL string) string {
+	config := d.config.GetConfig()
+	if config == nil {
+		return ""
+	}
+	for _, backend := range config.Backends {
+		if backend.MinServers == 0 || len(backend.Servers) > backend.MinServers {
+			continue
+		}
+		for _, server := range backend.Servers {
+			if server.URL == serverURL {
+				return backend.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Status devuelve el último decommission de serverURL
+func (d *ServerDecommissioner) Status(serverURL string) (DecommissionStatus, bool) {
+	d.mu.Lock()
+	op, exists := d.operations[serverURL]
+	if !exists {
+		d.mu.Unlock()
+		return DecommissionStatus{}, false
+	}
+	status := op.status
+	d.mu.Unlock()
+
+	if status.Phase == DecommissionDraining {
+		for _, drain := range d.balancer.GetDrainStatus() {
+			if drain.URL == serverURL {
+				status.RemainingConnections = drain.RemainingConnections
+				status.Deadline = drain.Deadline
+			}
+		}
+	}
+	return status, true
+}
+
+// Cancel marca como cancelado un decommission cuyo drenado se abortó
+func (d *ServerDecommissioner) Cancel(serverURL string) {
+	d.finish(serverURL, DecommissionCancelled, nil)
+}
+
+// serverDrained es el callback del lifecycle: borra el servidor de la config
+// y espera a que el balanceador lo suelte
+func (d *ServerDecommissioner) serverDrained(serverURL string) {
+	d.mu.Lock()
+	if op, exists := d.operations[serverURL]; exists && !op.status.Finished() {
+		op.status.Phase = DecommissionRemoving
+	}
+	d.mu.Unlock()
+
+	err := d.config.RemoveServerFromConfig(serverURL)
+	if err == nil {
+		err = d.confirmRemoved(serverURL)
+	}
+	if err != nil {
+		slog.Error("Failed to remove drained server", "server", serverURL, "error", err)
+		d.finish(serverURL, DecommissionFailed, err)
+		return
+	}
+	d.finish(serverURL, DecommissionCompleted, nil)
+}
+
+func (d *ServerDecommissioner) confirmRemoved(serverURL string) error {
+	deadline := time.Now().Add(d.confirmTimeout)
+	for d.balancer.HasServer(serverURL) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("server still in load balancer %s after config update", d.confirmTimeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil
+}
+
+func (d *ServerDecommissioner) finish(serverURL, phase string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	op, exists := d.operations[serverURL]
+	if !exists || op.status.Finished() {
+		return
+	}
+	now := time.Now()
+	op.status.Phase = phase
+	op.status.FinishedAt = &now
+	op.status.RemainingConnections = 0
+	if err != nil {
+		op.status.Error = err.Error()
+	}
+	close(op.done)
+
+	if phase == DecommissionCompleted {
+		slog.Info("Server decommissioned", "server", serverURL, "duration", now.Sub(op.status.StartedAt))
+	}
+}
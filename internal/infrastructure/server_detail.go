@@ -0,0 +1,182 @@
+package infrastructure
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ewmaAlpha es el peso de la última muestra en el promedio móvil exponencial
+const ewmaAlpha = 0.2
+
+func (h HealthState) String() string {
+	switch h {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	case Recovering:
+		return "recovering"
+	case Pending:
+		return "pending"
+	default:
+		return "unknown"
+	}
+}
+
+func (c CircuitState) String() string {
+	switch c {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCircuitState interpreta open, close (o closed) y half_open
+func ParseCircuitState(value string) (CircuitState, bool) {
+	switch value {
+	case "open":
+		return CircuitOpen, true
+	case "close", "closed":
+		return CircuitClosed, true
+	case "half_open":
+		return CircuitHalfOpen, true
+	default:
+		return CircuitClosed, false
+	}
+}
+
+// ServerDetail es una copia consistente del estado completo de un servidor
+type ServerDetail struct {
+	URL              string               `json:"url"`
+	Active           bool                 `json:"active"`
+	Draining         bool                 `json:"draining"`
+	Weight           float64              `json:"weight"`
+	EffectiveWeight  float64              `json:"effective_weight"`
+	HealthState      string               `json:"health_state"`
+	ConsecutiveFails int                  `json:"consecutive_fails"`
+	LastHealthCheck  time.Time            `json:"last_health_check"`
+	Metrics          ServerMetricsDetail  `json:"metrics"`
+	CircuitBreaker   CircuitBreakerDetail `json:"circuit_breaker"`
+	ConnectionPool   ConnectionPoolDetail `json:"connection_pool"`
+	// labels del servidor en la configuración
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+type ServerMetricsDetail struct {
+	RequestCount     int64     `json:"request_count"`
+	SuccessCount     int64     `json:"success_count"`
+	FailureCount     int64     `json:"failure_count"`
+	ErrorRate        float64   `json:"error_rate"`
+	ThroughputRPS    float64   `json:"throughput_rps"`
+	AvgResponseTime  string    `json:"avg_response_time"`
+	EWMAResponseTime string    `json:"ewma_response_time"`
+	P95ResponseTime  string    `json:"p95_response_time"`
+	P99ResponseTime  string    `json:"p99_response_time"`
+	LastUpdate       time.Time `json:"last_update"`
+	// Percentiles de metrics.percentiles con muestras suficientes, p. ej. "p99.9": "850ms"
+	Percentiles map[string]string `json:"percentiles,omitempty"`
+}
+
+type CircuitBreakerDetail struct {
+	State            string    `json:"state"`
+	FailureCount     int64     `json:"failure_count"`
+	SuccessCount     int64     `json:"success_count"`
+	FailureThreshold int       `json:"failure_threshold"`
+	RecoveryTimeout  string    `json:"recovery_timeout"`
+	LastFailureTime  time.Time `json:"last_failure_time"`
+	NextRetryTime    time.Time `json:"next_retry_time"`
+	HalfOpenRequests int       `json:"half_open_requests"`
+	LastResort       bool      `json:"last_resort"`
+	ProbeInFlight    bool      `json:"probe_in_flight"`
+	ManualOverride   bool      `json:"manual_override"`
+	// Éxitos en half-open que cierran el circuito; half_open_requests lleva la cuenta
+	HalfOpenSuccesses int `json:"half_open_successes"`
+}
+
+type ConnectionPoolDetail struct {
+	MaxConnections int   `json:"max_connections"`
+	ActiveConns    int64 `json:"active_connections"`
+	WaitingConns   int64 `json:"waiting_connections"`
+	OpenConns      int64 `json:"open_connections"`
+	IdleConns      int64 `json:"idle_connections"`
+	NewConns       int64 `json:"new_connections"`
+	ReusedConns    int64 `json:"reused_connections"`
+	// Conexiones actualizadas (WebSocket) abiertas y cerradas
+	UpgradedConns  int64 `json:"upgraded_connections"`
+	UpgradedClosed int64 `json:"upgraded_closed"`
+}
+
+// GetServerDetail devuelve el estado completo de un servidor; false si no existe
+func (eb *EnterpriseBalancer) GetServerDetail(serverURL string) (*ServerDetail, bool) {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	state, exists := eb.servers[serverURL]
+	if !exists {
+		return nil, false
+	}
+
+	requests := atomic.LoadInt64(&state.Metrics.RequestCount)
+	avgResponseTime := time.Duration(0)
+	if requests > 0 {
+		avgResponseTime = time.Duration(atomic.LoadInt64(&state.Metrics.TotalLatency) / requests)
+	}
+
+	cb := state.CircuitBreaker
+	return &ServerDetail{
+		URL:              state.Server.URL,
+		Labels:           state.Server.Labels,
+		Active:           state.Server.Active,
+		Draining:         eb.serverLifecycle.IsServerDraining(serverURL),
+		Weight:           state.Weight,
+		EffectiveWeight:  state.EffectiveWeight,
+		HealthState:      state.HealthState.String(),
+		ConsecutiveFails: state.ConsecutiveFails,
+		LastHealthCheck:  state.LastHealthCheck,
+		Metrics: ServerMetricsDetail{
+			RequestCount:     requests,
+			SuccessCount:     atomic.LoadInt64(&state.Metrics.SuccessCount),
+			FailureCount:     atomic.LoadInt64(&state.Metrics.FailureCount),
+			ErrorRate:        state.Metrics.ErrorRate,
+			ThroughputRPS:    state.Metrics.ThroughputRPS,
+			AvgResponseTime:  avgResponseTime.String(),
+			EWMAResponseTime: state.Metrics.EWMAResponseTime.String(),
+			P95ResponseTime:  state.Metrics.P95ResponseTime.String(),
+			P99ResponseTime:  state.Metrics.P99ResponseTime.String(),
+			Percentiles:      formatPercentiles(state.Metrics.Percentiles),
+			LastUpdate:       state.Metrics.LastUpdate,
+		},
+		CircuitBreaker: CircuitBreakerDetail{
+			State:             cb.State.String(),
+			FailureCount:      cb.FailureCount,
+			SuccessCount:      cb.SuccessCount,
+			FailureThreshold:  cb.FailureThreshold,
+			RecoveryTimeout:   cb.RecoveryTimeout.String(),
+			LastFailureTime:   cb.LastFailureTime,
+			NextRetryTime:     cb.NextRetryTime,
+			HalfOpenRequests:  cb.HalfOpenRequests,
+			LastResort:        cb.LastResort,
+			ProbeInFlight:     cb.ProbeInFlight,
+			ManualOverride:    cb.ManualOverride,
+			HalfOpenSuccesses: cb.halfOpenSuccesses(),
+		},
+		ConnectionPool: ConnectionPoolDetail{
+			MaxConnections: state.ConnectionPool.MaxConnections,
+			ActiveConns:    atomic.LoadInt64(&state.ConnectionPool.ActiveConns),
+			WaitingConns:   atomic.LoadInt64(&state.ConnectionPool.WaitingConns),
+			OpenConns:      atomic.LoadInt64(&state.ConnectionPool.OpenConns),
+			IdleConns:      state.ConnectionPool.IdleConns(),
+			NewConns:       atomic.LoadInt64(&state.ConnectionPool.NewConns),
+			ReusedConns:    atomic.LoadInt64(&state.ConnectionPool.ReusedConns),
+			UpgradedConns:  atomic.LoadInt64(&state.ConnectionPool.UpgradedConns),
+			UpgradedClosed: state.Metrics.Upgrades.closed(),
+		},
+	}, true
+}
@@ -0,0 +1,288 @@
+package infrastructure
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// maxDiscoveryLookupTimeout limita cada resolución SRV, que nunca tarda más que
+// el propio intervalo
+const maxDiscoveryLookupTimeout = 5 * time.Second
+
+// SRVResolver resuelve registros DNS SRV; net.DefaultResolver lo implementa
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// ServerDiscovery mantiene los servidores de los backends con discovery:
+// dns_srv. Cada backend resuelve su registro en su propia goroutine y, si el
+// conjunto de servidores cambia, avisa con el callback de OnChange para que se
+// aplique con Apply. Los servidores que siguen en el registro conservan su URL
+// y con ella el estado del balanceador y del health checker.
+type ServerDiscovery struct {
+	resolver SRVResolver
+	onChange func()
+
+	mu       sync.Mutex
+	backends map[string]*discoveredBackend
+}
+
+// discoveredBackend es un backend descubierto; servers es nil hasta la primera
+// resolución correcta y se conserva si una resolución posterior falla
+type discoveredBackend struct {
+	name     string
+	scheme   string
+	interval time.Duration
+	servers  []domain.Server
+	stop     chan struct{}
+}
+
+func NewServerDiscovery() *ServerDiscovery {
+	return &ServerDiscovery{
+		resolver: net.DefaultResolver,
+		backends: make(map[string]*discoveredBackend),
+	}
+}
+
+// SetResolver sustituye el resolver DNS del sistema
+func (d *ServerDiscovery) SetResolver(resolver SRVResolver) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resolver = resolver
+}
+
+// OnChange registra la función llamada cuando cambian los servidores de un backend
+func (d *ServerDiscovery) OnChange(callback func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onChange = callback
+}
+
+// Sync arranca el descubrimiento de los backends nuevos, lo reinicia en los
+// que cambiaron su registro, esquema o intervalo y lo detiene en el resto. La
+// primera resolución es síncrona para que Apply ya devuelva sus servidores;
+// se usa al arrancar.
+func (d *ServerDiscovery) Sync(backends []domain.Backend) {
+	d.sync(backends, true)
+}
+
+// SyncInBackground hace lo mismo que Sync sin esperar al DNS: la primera
+// resolución corre en la goroutine del backend y llega por OnChange. Es la
+// que se usa desde los callbacks de ConfigManager, que se ejecutan con su
+// lock tomado, para que un DNS lento no bloquee GetConfig.
+func (d *ServerDiscovery) SyncInBackground(backends []domain.Backend) {
+	d.sync(backends, false)
+}
+
+func (d *ServerDiscovery) sync(backends []domain.Backend, wait bool) {
+	current := make(map[string]bool, len(backends))
+	for i := range backends {
+		backend := &backends[i]
+		if backend.Discovery != domain.DiscoveryDNSSRV {
+			continue
+		}
+		current[backend.Name] = true
+
+		scheme, interval := backend.DiscoveryScheme, backend.DiscoveryInterval
+		if scheme == "" {
+			scheme = domain.DefaultDiscoveryScheme
+		}
+		if interval <= 0 {
+			interval = domain.DefaultDiscoveryInterval
+		}
+
+		d.mu.Lock()
+		existing, exists := d.backends[backend.Name]
+		if exists && existing.name == backend.DiscoveryName && existing.scheme == scheme && existing.interval == interval {
+			d.mu.Unlock()
+			continue
+		}
+		if exists {
+			close(existing.stop)
+		}
+		entry := &discoveredBackend{name: backend.DiscoveryName, scheme: scheme, interval: interval, stop: make(chan struct{})}
+		d.backends[backend.Name] = entry
+		d.mu.Unlock()
+
+		if wait {
+			d.refresh(backend.Name, entry)
+		}
+		go d.run(backend.Name, entry, !wait)
+		slog.Info("Server discovery started", "backend", backend.Name, "srv", entry.name, "interval", interval)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, entry := range d.backends {
+		if !current[name] {
+			close(entry.stop)
+			delete(d.backends, name)
+			slog.Info("Server discovery stopped", "backend", name)
+		}
+	}
+}
+
+// Stop detiene el descubrimiento de todos los backends
+func (d *ServerDiscovery) Stop() {
+	d.Sync(nil)
+}
+
+// Apply devuelve una copia de config con los servidores descubiertos en lugar
+// de los estáticos; los backends aún sin resolver mantienen su lista. No
+// modifica config.
+func (d *ServerDiscovery) Apply(config *domain.Config) *domain.Config {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	effective := *config
+	effective.Backends = make([]domain.Backend, len(config.Backends))
+	copy(effective.Backends, config.Backends)
+	for i := range effective.Backends {
+		backend := &effective.Backends[i]
+		entry, exists := d.backends[backend.Name]
+		if !exists || backend.Discovery != domain.DiscoveryDNSSRV || entry.servers == nil {
+			continue
+		}
+		backend.Servers = append([]domain.Server(nil), entry.servers...)
+	}
+	return &effective
+}
+
+// run refresca el backend cada intervalo; con resolveFirst resuelve antes de
+// esperar al primero
+func (d *ServerDiscovery) run(backendName string, entry *discoveredBackend, resolveFirst bool) {
+	if resolveFirst && d.refresh(backendName, entry) {
+		d.notify()
+	}
+
+	timer := time.NewTimer(domain.Jitter(entry.interval, domain.DefaultTimerJitter))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if d.refresh(backendName, entry) {
+				d.notify()
+			}
+			timer.Reset(domain.Jitter(entry.interval, domain.DefaultTimerJitter))
+		case <-entry.stop:
+			return
+		}
+	}
+}
+
+func (d *ServerDiscovery) notify() {
+	d.mu.Lock()
+	onChange := d.onChange
+	d.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// refresh resuelve el registro del backend y devuelve si cambiaron sus
+// servidores. Un error o una respuesta vacía conservan los anteriores para que
+// un fallo del DNS no deje el backend sin servidores.
+func (d *ServerDiscovery) refresh(backendName string, entry *discoveredBackend) bool {
+	d.mu.Lock()
+	resolver := d.resolver
+	d.mu.Unlock()
+
+	timeout := entry.interval
+	if timeout > maxDiscoveryLookupTimeout {
+		timeout = maxDiscoveryLookupTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, records, err := resolver.LookupSRV(ctx, "", "", entry.name)
+	if err != nil || len(records) == 0 {
+		slog.Warn("Server discovery lookup failed, keeping previous servers", "backend", backendName,
+			"srv", entry.name, "records", len(records), "error", err)
+		return false
+	}
+	servers := srvServers(records, entry.scheme)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// El backend pudo reiniciarse o detenerse durante la resolución
+	if d.backends[backendName] != entry || sameServers(entry.servers, servers) {
+		return false
+	}
+	previous := entry.servers
+	entry.servers = servers
+	added, removed := diffServers(previous, servers)
+	slog.Info("Server discovery updated servers", "backend", backendName, "srv", entry.name,
+		"servers", len(servers), "added", added, "removed", removed)
+	return true
+}
+
+// srvServers convierte los registros en servidores ordenados por URL. La
+// prioridad SRV más baja pasa a priority 0 y las siguientes a backups 1, 2...;
+// el peso SRV es el peso del servidor, como mínimo 1.
+func srvServers(records []*net.SRV, scheme string) []domain.Server {
+	priorities := make([]uint16, 0, len(records))
+	for _, record := range records {
+		priorities = append(priorities, record.Priority)
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+	rank := make(map[uint16]int, len(priorities))
+	for _, priority := range priorities {
+		if _, exists := rank[priority]; !exists {
+			rank[priority] = len(rank)
+		}
+	}
+
+	servers := make([]domain.Server, 0, len(records))
+	seen := make(map[string]bool, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		url := scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(record.Port)))
+		if host == "" || seen[url] {
+			continue
+		}
+		seen[url] = true
+		weight := int(record.Weight)
+		if weight < 1 {
+			weight = 1
+		}
+		servers = append(servers, domain.Server{URL: url, Weight: weight, Active: true, Priority: rank[record.Priority]})
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].URL < servers[j].URL })
+	return servers
+}
+
+func sameServers(a, b []domain.Server) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].URL != b[i].URL || a[i].Weight != b[i].Weight || a[i].Priority != b[i].Priority {
+			return false
+		}
+	}
+	return true
+}
+
+// diffServers cuenta los servidores añadidos y eliminados entre dos resoluciones
+func diffServers(previous, current []domain.Server) (added, removed int) {
+	before := make(map[string]bool, len(previous))
+	for _, server := range previous {
+		before[server.URL] = true
+	}
+	for _, server := range current {
+		if !before[server.URL] {
+			added++
+		}
+		delete(before, server.URL)
+	}
+	return added, len(before)
+}
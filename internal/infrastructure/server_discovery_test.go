@@ -0,0 +1,230 @@
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+type fakeSRVResolver struct {
+	mu      sync.Mutex
+	records []*net.SRV
+	err     error
+}
+
+func (f *fakeSRVResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return name, f.records, f.err
+}
+
+func (f *fakeSRVResolver) set(records []*net.SRV, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records, f.err = records, err
+}
+
+func TestServerDiscovery_DNSSRV(t *testing.T) {
+	resolver := &fakeSRVResolver{records: []*net.SRV{
+		{Target: "app-2.service.consul.", Port: 8080, Priority: 10, Weight: 5},
+		{Target: "app-1.service.consul.", Port: 8080, Priority: 10, Weight: 0},
+		{Target: "app-dr.service.consul.", Port: 9090, Priority: 20, Weight: 1},
+	}}
+	discovery := NewServerDiscovery()
+	discovery.SetResolver(resolver)
+	changes := 0
+	discovery.OnChange(func() { changes++ })
+
+	config := &domain.Config{Backends: []domain.Backend{
+		{Name: "static", Servers: []domain.Server{{URL: "http://static:3000", Weight: 1}}},
+		{
+			Name:              "api",
+			Servers:           []domain.Server{{URL: "http://seed:3000", Weight: 1}},
+			Discovery:         domain.DiscoveryDNSSRV,
+			DiscoveryName:     "_http._tcp.app.service.consul",
+			DiscoveryInterval: time.Hour,
+		},
+	}}
+	discovery.Sync(config.Backends)
+	defer discovery.Stop()
+
+	effective := discovery.Apply(config)
+	expected := []domain.Server{
+		{URL: "http://app-1.service.consul:8080", Weight: 1, Active: true, Priority: 0},
+		{URL: "http://app-2.service.consul:8080", Weight: 5, Active: true, Priority: 0},
+		{URL: "http://app-dr.service.consul:9090", Weight: 1, Active: true, Priority: 1},
+	}
+	servers := effective.Backends[1].Servers
+	if len(servers) != len(expected) {
+		t.Fatalf("expected %d discovered servers, got %+v", len(expected), servers)
+	}
+	for i, want := range expected {
+		got := servers[i]
+		if got.URL != want.URL || got.Weight != want.Weight || got.Active != want.Active || got.Priority != want.Priority {
+			t.Errorf("server %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if len(effective.Backends[0].Servers) != 1 || effective.Backends[0].Servers[0].URL != "http://static:3000" {
+		t.Errorf("expected static backend untouched, got %+v", effective.Backends[0].Servers)
+	}
+	if config.Backends[1].Servers[0].URL != "http://seed:3000" {
+		t.Error("expected Apply not to modify the original config")
+	}
+
+	// El balanceador conserva el estado de los servidores que siguen en DNS
+	balancer := NewEnterpriseBalancer()
+	balancer.UpdateBackends(effective.Backends)
+	kept := &effective.Backends[1].Servers[1]
+	balancer.servers[kept.URL].Metrics.RequestCount = 7
+
+	entry := discovery.backends["api"]
+	if discovery.refresh("api", entry) {
+		t.Error("expected no change when DNS returns the same targets")
+	}
+
+	resolver.set([]*net.SRV{
+		{Target: "app-2.service.consul.", Port: 8080, Priority: 10, Weight: 5},
+		{Target: "app-3.service.consul.", Port: 8080, Priority: 10, Weight: 5},
+	}, nil)
+	if !discovery.refresh("api", entry) {
+		t.Fatal("expected a change when DNS targets change")
+	}
+	effective = discovery.Apply(config)
+	balancer.UpdateBackends(effective.Backends)
+
+	metrics := balancer.GetServerMetrics()
+	if _, exists := metrics["http://app-1.service.consul:8080"]; exists {
+		t.Error("expected removed target to leave the balancer")
+	}
+	if _, exists := metrics["http://app-3.service.consul:8080"]; !exists {
+		t.Error("expected new target to join the balancer")
+	}
+	if got := metrics[kept.URL]; got == nil || got.TotalRequests != 7 {
+		t.Errorf("expected unchanged target to keep its stats, got %+v", got)
+	}
+
+	// Un fallo de DNS o una respuesta vacía no dejan el backend sin servidores
+	resolver.set(nil, errors.New("no such host"))
+	if discovery.refresh("api", entry) {
+		t.Error("expected a failed lookup to keep the previous servers")
+	}
+	resolver.set([]*net.SRV{}, nil)
+	if discovery.refresh("api", entry) {
+		t.Error("expected an empty answer to keep the previous servers")
+	}
+	if servers := discovery.Apply(config).Backends[1].Servers; len(servers) != 2 {
+		t.Errorf("expected 2 servers kept, got %+v", servers)
+	}
+
+	// Al quitar discovery el backend vuelve a su lista estática
+	config.Backends[1].Discovery = ""
+	discovery.Sync(config.Backends)
+	if servers := discovery.Apply(config).Backends[1].Servers; len(servers) != 1 || servers[0].URL != "http://seed:3000" {
+		t.Errorf("expected static servers once discovery is removed, got %+v", servers)
+	}
+	if len(discovery.backends) != 0 {
+		t.Errorf("expected discovery loop to stop, got %d running", len(discovery.backends))
+	}
+	if changes != 0 {
+		t.Errorf("expected OnChange only from the refresh loop, got %d calls", changes)
+	}
+}
+
+func TestServerDiscovery_NotifiesOnChange(t *testing.T) {
+	resolver := &fakeSRVResolver{records: []*net.SRV{{Target: "app-1.", Port: 80, Weight: 1}}}
+	discovery := NewServerDiscovery()
+	discovery.SetResolver(resolver)
+	changed := make(chan struct{}, 1)
+	discovery.OnChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	discovery.Sync([]domain.Backend{{
+		Name:              "api",
+		Discovery:         domain.DiscoveryDNSSRV,
+		DiscoveryName:     "_http._tcp.app",
+		DiscoveryScheme:   "https",
+		DiscoveryInterval: 10 * time.Millisecond,
+	}})
+	defer discovery.Stop()
+
+	resolver.set([]*net.SRV{{Target: "app-1.", Port: 80, Weight: 1}, {Target: "app-2.", Port: 80, Weight: 1}}, nil)
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("expected OnChange after DNS targets changed")
+	}
+
+	config := &domain.Config{Backends: []domain.Backend{{Name: "api", Discovery: domain.DiscoveryDNSSRV}}}
+	servers := discovery.Apply(config).Backends[0].Servers
+	if len(servers) != 2 || servers[1].URL != "https://app-2:80" {
+		t.Errorf("expected both https targets, got %+v", servers)
+	}
+}
+
+// slowSRVResolver no responde hasta que se cierra release
+type slowSRVResolver struct {
+	release chan struct{}
+	records []*net.SRV
+}
+
+func (s *slowSRVResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	select {
+	case <-s.release:
+		return name, s.records, nil
+	case <-ctx.Done():
+		return name, nil, ctx.Err()
+	}
+}
+
+func TestServerDiscovery_SyncInBackgroundDoesNotWaitForDNS(t *testing.T) {
+	resolver := &slowSRVResolver{release: make(chan struct{}), records: []*net.SRV{{Target: "app-1.", Port: 80, Weight: 1}}}
+	discovery := NewServerDiscovery()
+	discovery.SetResolver(resolver)
+	changed := make(chan struct{}, 1)
+	discovery.OnChange(func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	start := time.Now()
+	discovery.SyncInBackground([]domain.Backend{{
+		Name:              "api",
+		Discovery:         domain.DiscoveryDNSSRV,
+		DiscoveryName:     "_http._tcp.app",
+		DiscoveryInterval: time.Hour,
+	}})
+	defer discovery.Stop()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected SyncInBackground to return without waiting for DNS, took %v", elapsed)
+	}
+
+	// Hasta la primera resolución el backend conserva su lista estática
+	config := &domain.Config{Backends: []domain.Backend{{
+		Name: "api", Discovery: domain.DiscoveryDNSSRV, Servers: []domain.Server{{URL: "http://seed:3000"}},
+	}}}
+	if servers := discovery.Apply(config).Backends[0].Servers; len(servers) != 1 || servers[0].URL != "http://seed:3000" {
+		t.Errorf("expected static servers before the first lookup, got %+v", servers)
+	}
+
+	// La primera resolución llega por OnChange sin esperar a discovery_interval
+	close(resolver.release)
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected OnChange after the first background lookup")
+	}
+	if servers := discovery.Apply(config).Backends[0].Servers; len(servers) != 1 || servers[0].URL != "http://app-1:80" {
+		t.Errorf("expected discovered servers, got %+v", servers)
+	}
+}
@@ -2,6 +2,7 @@ package infrastructure
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -132,6 +133,38 @@ func (sl *ServerLifecycle) GetDrainingServers() []string {
 	return servers
 }
 
+// DrainStatus describe el progreso de un servidor en drenado
+type DrainStatus struct {
+	URL                  string    `json:"url"`
+	RemainingConnections int64     `json:"remaining_connections"`
+	StartedAt            time.Time `json:"started_at"`
+	Deadline             time.Time `json:"deadline"`
+	TimeToDeadline       string    `json:"time_to_deadline"`
+}
+
+func (sl *ServerLifecycle) GetDrainStatus() []DrainStatus {
+	sl.mu.RLock()
+	defer sl.mu.RUnlock()
+
+	now := time.Now()
+	statuses := make([]DrainStatus, 0, len(sl.pendingRemovals))
+	for url, removal := range sl.pendingRemovals {
+		remaining := removal.DrainDeadline.Sub(now)
+		if remaining < 0 {
+			remaining = 0
+		}
+		statuses = append(statuses, DrainStatus{
+			URL:                  url,
+			RemainingConnections: atomic.LoadInt64(removal.ConnectionCount),
+			StartedAt:            removal.StartTime,
+			Deadline:             removal.DrainDeadline,
+			TimeToDeadline:       remaining.Round(time.Second).String(),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses
+}
+
 func (sl *ServerLifecycle) CancelRemoval(serverURL string) bool {
 	sl.mu.Lock()
 	defer sl.mu.Unlock()
@@ -0,0 +1,70 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSwaggerHandler_ServesEmbeddedSpec(t *testing.T) {
+	// Desde otro directorio de trabajo la especificación sigue disponible
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	w := httptest.NewRecorder()
+	NewSwaggerHandler().ServeHTTP(w, httptest.NewRequest("GET", "/api-docs.yaml", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.HasPrefix(w.Body.String(), "openapi:") {
+		t.Errorf("expected OpenAPI document, got %q", w.Body.String()[:min(40, w.Body.Len())])
+	}
+}
+
+// La especificación debe describir rutas, métodos y autenticación reales de ConfigAPI
+func TestSwaggerSpec_MatchesConfigAPI(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Security *[]map[string][]string `yaml:"security"`
+		} `yaml:"paths"`
+	}
+	if err := yaml.Unmarshal(apiSpec, &spec); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+
+	api, tempFile := setupTestConfigAPI(t)
+	defer os.Remove(tempFile)
+
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			t.Run(strings.ToUpper(method)+" "+path, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(strings.ToUpper(method), path, strings.NewReader("{}"))
+				api.ConfigAPI.ServeHTTP(w, req)
+
+				if w.Code == http.StatusNotFound && strings.TrimSpace(w.Body.String()) == "Not found" {
+					t.Fatalf("documented path is not routed")
+				}
+				if w.Code == http.StatusMethodNotAllowed {
+					t.Fatalf("documented method is not allowed")
+				}
+
+				// Sin security propio aplica el global (API key)
+				public := operation.Security != nil && len(*operation.Security) == 0
+				denied := w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden
+				if public && denied {
+					t.Errorf("documented as public but returned %d without API key", w.Code)
+				}
+				if !public && !denied {
+					t.Errorf("documented as authenticated but returned %d without API key", w.Code)
+				}
+			})
+		}
+	}
+}
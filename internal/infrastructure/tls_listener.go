@@ -0,0 +1,26 @@
+package infrastructure
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// NewProxyTLSConfig carga los certificados de proxy.tls. crypto/tls elige en
+// cada handshake el certificado cuyo nombre cubre el SNI del cliente, y el
+// primero cuando ninguno coincide o el cliente no envía SNI.
+func NewProxyTLSConfig(cfg *domain.ProxyTLSCfg) (*tls.Config, error) {
+	certificates := make([]tls.Certificate, 0, len(cfg.Certificates))
+	for _, c := range cfg.Certificates {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading certificate %s: %w", c.CertFile, err)
+		}
+		certificates = append(certificates, cert)
+	}
+	return &tls.Config{
+		Certificates: certificates,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
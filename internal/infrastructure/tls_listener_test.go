@@ -0,0 +1,102 @@
+package infrastructure
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// writeTestCertificate genera un certificado autofirmado para name
+func writeTestCertificate(t *testing.T, dir, name string) domain.TLSCertificateCfg {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := domain.TLSCertificateCfg{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+	os.WriteFile(cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return cfg
+}
+
+func TestNewProxyTLSConfig_SelectsCertificateBySNI(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &domain.ProxyTLSCfg{Certificates: []domain.TLSCertificateCfg{
+		writeTestCertificate(t, dir, "a.example.com"),
+		writeTestCertificate(t, dir, "b.example.com"),
+	}}
+	tlsConfig, err := NewProxyTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("expected certificates to load, got %v", err)
+	}
+
+	var gotSNI string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSNI = r.TLS.ServerName
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	for _, name := range []string{"a.example.com", "b.example.com", ""} {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			ServerName:         name,
+			InsecureSkipVerify: true,
+		}}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("%q: request failed: %v", name, err)
+		}
+		resp.Body.Close()
+
+		// Sin SNI se presenta el primer certificado
+		want := name
+		if want == "" {
+			want = "a.example.com"
+		}
+		if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != want {
+			t.Errorf("SNI %q: expected certificate %q, got %q", name, want, cn)
+		}
+		if gotSNI != name {
+			t.Errorf("expected handler to see SNI %q, got %q", name, gotSNI)
+		}
+	}
+
+	if _, err := NewProxyTLSConfig(&domain.ProxyTLSCfg{Certificates: []domain.TLSCertificateCfg{
+		{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")},
+	}}); err == nil {
+		t.Error("expected error for missing certificate files")
+	}
+}
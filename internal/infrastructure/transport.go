@@ -0,0 +1,197 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+	"golang.org/x/net/http2"
+)
+
+const (
+	defaultMaxIdleConnsPerHost = domain.DefaultMaxIdleConnsPerHost
+	defaultIdleConnTimeout     = domain.DefaultIdleConnTimeout
+	defaultDialTimeout         = domain.DefaultDialTimeout
+	defaultKeepAlive           = 30 * time.Second
+
+	// ProtocolH2C indica un backend que habla HTTP/2 sin TLS (p.ej. gRPC interno)
+	ProtocolH2C = domain.ProtocolH2C
+	// ProtocolGRPC usa h2c y además contabiliza éxito/fallo según grpc-status
+	ProtocolGRPC = domain.ProtocolGRPC
+)
+
+// newBackendTransport elige el transporte según el protocolo del backend y
+// cuenta en pool las conexiones que abre. Para un servidor unix:// conecta al
+// socket en lugar de a la dirección de la URL de destino.
+func newBackendTransport(backend *domain.Backend, pool *ConnectionPool, serverURL string) http.RoundTripper {
+	socket, isSocket := domain.UnixSocketPath(serverURL)
+	if backend.Protocol == ProtocolH2C || backend.Protocol == ProtocolGRPC {
+		transport := newH2CTransport(backend.Transport)
+		dial := transport.DialTLSContext
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+			if isSocket {
+				network, addr = "unix", socket
+			}
+			return pool.trackConn(dial(ctx, network, addr, cfg))
+		}
+		return transport
+	}
+	transport := newServerTransport(backend.Transport)
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if isSocket {
+			network, addr = "unix", socket
+		}
+		return pool.trackConn(dial(ctx, network, addr))
+	}
+	return transport
+}
+
+// trackConn cuenta una conexión recién abierta hasta que se cierre
+func (p *ConnectionPool) trackConn(conn net.Conn, err error) (net.Conn, error) {
+	if err != nil {
+		return conn, err
+	}
+	atomic.AddInt64(&p.OpenConns, 1)
+	return &trackedConn{Conn: conn, pool: p}, nil
+}
+
+type trackedConn struct {
+	net.Conn
+	pool      *ConnectionPool
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() { atomic.AddInt64(&c.pool.OpenConns, -1) })
+	return c.Conn.Close()
+}
+
+// ConnTrace registra, para cada request al servidor, si el transporte abrió
+// una conexión nueva o reutilizó una del pool de inactivas
+func (p *ConnectionPool) ConnTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&p.ReusedConns, 1)
+			} else {
+				atomic.AddInt64(&p.NewConns, 1)
+			}
+		},
+	}
+}
+
+// IdleConns estima las conexiones abiertas sin request en curso ni upgrade
+// (WebSocket). Es exacto con HTTP/1.1; con HTTP/2 varias requests comparten
+// conexión.
+func (p *ConnectionPool) IdleConns() int64 {
+	idle := atomic.LoadInt64(&p.OpenConns) - atomic.LoadInt64(&p.ActiveConns) - atomic.LoadInt64(&p.UpgradedConns)
+	if idle < 0 {
+		return 0
+	}
+	return idle
+}
+
+// newDialer aplica dial_timeout; el valor por defecto es el de http.DefaultTransport
+func newDialer(cfg domain.TransportCfg) *net.Dialer {
+	timeout := defaultDialTimeout
+	if cfg.DialTimeout > 0 {
+		timeout = cfg.DialTimeout
+	}
+	return &net.Dialer{Timeout: timeout, KeepAlive: defaultKeepAlive}
+}
+
+// newH2CTransport habla HTTP/2 con prior knowledge sobre TCP plano, ya que
+// http.Transport solo negocia HTTP/2 mediante ALPN sobre TLS. http2.Transport
+// no admite response_header_timeout, tls_handshake_timeout (no hay TLS) ni
+// expect_continue_timeout.
+func newH2CTransport(cfg domain.TransportCfg) *http2.Transport {
+	idleTimeout := defaultIdleConnTimeout
+	if cfg.IdleConnTimeout > 0 {
+		idleTimeout = cfg.IdleConnTimeout
+	}
+
+	dialer := newDialer(cfg)
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+		IdleConnTimeout: idleTimeout,
+	}
+}
+
+// newServerTransport crea el transporte compartido por todas las requests a un servidor.
+// http.DefaultTransport solo mantiene 2 conexiones inactivas por host, lo que bajo
+// carga concurrente obliga a abrir una conexión TCP nueva en casi cada request.
+func newServerTransport(cfg domain.TransportCfg) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	} else if transport.MaxIdleConns < transport.MaxIdleConnsPerHost {
+		transport.MaxIdleConns = transport.MaxIdleConnsPerHost
+	}
+
+	transport.IdleConnTimeout = defaultIdleConnTimeout
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+
+	transport.ForceAttemptHTTP2 = cfg.ForceAttemptHTTP2
+
+	// Separar el tiempo para conectar del tiempo que se espera al servidor:
+	// conexiones lentas entre regiones sin tolerar backends colgados
+	transport.DialContext = newDialer(cfg).DialContext
+	if cfg.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	if cfg.ExpectContinueTimeout > 0 {
+		transport.ExpectContinueTimeout = cfg.ExpectContinueTimeout
+	} else if cfg.ExpectContinueTimeout < 0 {
+		transport.ExpectContinueTimeout = 0
+	}
+	return transport
+}
+
+// GetConnTrace devuelve el trace que cuenta conexiones nuevas y reutilizadas del servidor
+func (eb *EnterpriseBalancer) GetConnTrace(serverURL string) *httptrace.ClientTrace {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	state, exists := eb.servers[serverURL]
+	if !exists {
+		return nil
+	}
+	return state.ConnectionPool.ConnTrace()
+}
+
+// GetTransport devuelve el transporte del servidor para reutilizar sus conexiones keep-alive
+func (eb *EnterpriseBalancer) GetTransport(serverURL string) http.RoundTripper {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	state, exists := eb.servers[serverURL]
+	if !exists || state.Transport == nil {
+		return nil
+	}
+	return state.Transport
+}
+
+// closeIdleConnections libera las conexiones inactivas de un transporte descartado
+func closeIdleConnections(transport http.RoundTripper) {
+	if closer, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
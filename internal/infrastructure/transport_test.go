@@ -0,0 +1,146 @@
+package infrastructure
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+	"golang.org/x/net/http2"
+)
+
+func TestNewServerTransport_Defaults(t *testing.T) {
+	transport := newServerTransport(domain.TransportCfg{})
+
+	if transport.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost {
+		t.Errorf("expected MaxIdleConnsPerHost %d, got %d", defaultMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != defaultIdleConnTimeout {
+		t.Errorf("expected IdleConnTimeout %v, got %v", defaultIdleConnTimeout, transport.IdleConnTimeout)
+	}
+	if transport.ForceAttemptHTTP2 {
+		t.Error("expected ForceAttemptHTTP2 disabled by default")
+	}
+}
+
+func TestNewServerTransport_FromConfig(t *testing.T) {
+	transport := newServerTransport(domain.TransportCfg{
+		MaxIdleConnsPerHost: 256,
+		IdleConnTimeout:     30 * time.Second,
+		ForceAttemptHTTP2:   true,
+	})
+
+	if transport.MaxIdleConnsPerHost != 256 {
+		t.Errorf("expected MaxIdleConnsPerHost 256, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.MaxIdleConns < 256 {
+		t.Errorf("expected MaxIdleConns to allow 256 idle connections, got %d", transport.MaxIdleConns)
+	}
+	if transport.IdleConnTimeout != 30*time.Second {
+		t.Errorf("expected IdleConnTimeout 30s, got %v", transport.IdleConnTimeout)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("expected ForceAttemptHTTP2 enabled")
+	}
+}
+
+func TestNewServerTransport_MaxIdleConns(t *testing.T) {
+	transport := newServerTransport(domain.TransportCfg{MaxIdleConns: 500, MaxIdleConnsPerHost: 50})
+	if transport.MaxIdleConns != 500 {
+		t.Errorf("expected MaxIdleConns 500, got %d", transport.MaxIdleConns)
+	}
+	if transport.MaxIdleConnsPerHost != 50 {
+		t.Errorf("expected MaxIdleConnsPerHost 50, got %d", transport.MaxIdleConnsPerHost)
+	}
+}
+
+func TestNewServerTransport_Timeouts(t *testing.T) {
+	transport := newServerTransport(domain.TransportCfg{})
+	if transport.ResponseHeaderTimeout != 0 {
+		t.Errorf("expected no response header timeout by default, got %v", transport.ResponseHeaderTimeout)
+	}
+	if transport.TLSHandshakeTimeout != http.DefaultTransport.(*http.Transport).TLSHandshakeTimeout {
+		t.Errorf("expected default TLS handshake timeout, got %v", transport.TLSHandshakeTimeout)
+	}
+
+	transport = newServerTransport(domain.TransportCfg{
+		DialTimeout:           5 * time.Second,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 2 * time.Second,
+	})
+	if transport.TLSHandshakeTimeout != 3*time.Second {
+		t.Errorf("expected TLSHandshakeTimeout 3s, got %v", transport.TLSHandshakeTimeout)
+	}
+	if transport.ResponseHeaderTimeout != 2*time.Second {
+		t.Errorf("expected ResponseHeaderTimeout 2s, got %v", transport.ResponseHeaderTimeout)
+	}
+	if transport.ExpectContinueTimeout != http.DefaultTransport.(*http.Transport).ExpectContinueTimeout {
+		t.Errorf("expected default expect continue timeout, got %v", transport.ExpectContinueTimeout)
+	}
+	if transport := newServerTransport(domain.TransportCfg{ExpectContinueTimeout: 3 * time.Second}); transport.ExpectContinueTimeout != 3*time.Second {
+		t.Errorf("expected ExpectContinueTimeout 3s, got %v", transport.ExpectContinueTimeout)
+	}
+	if transport := newServerTransport(domain.TransportCfg{ExpectContinueTimeout: -1}); transport.ExpectContinueTimeout != 0 {
+		t.Errorf("expected negative expect_continue_timeout to send the body without waiting, got %v", transport.ExpectContinueTimeout)
+	}
+	if dialer := newDialer(domain.TransportCfg{DialTimeout: 5 * time.Second}); dialer.Timeout != 5*time.Second {
+		t.Errorf("expected dial timeout 5s, got %v", dialer.Timeout)
+	}
+	if dialer := newDialer(domain.TransportCfg{}); dialer.Timeout != defaultDialTimeout {
+		t.Errorf("expected default dial timeout, got %v", dialer.Timeout)
+	}
+}
+
+func TestEnterpriseBalancer_TransportLifecycle(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	servers := []domain.Server{{URL: "http://localhost:3001", Weight: 1, Active: true}}
+	backend := &domain.Backend{}
+	balancer.UpdateServers(servers, backend)
+
+	transport := balancer.GetTransport("http://localhost:3001")
+	if transport == nil {
+		t.Fatal("expected a transport for the server")
+	}
+
+	// Una recarga sin cambios de transporte conserva el pool de conexiones
+	balancer.UpdateServers([]domain.Server{{URL: "http://localhost:3001", Weight: 2, Active: true}}, backend)
+	if balancer.GetTransport("http://localhost:3001") != transport {
+		t.Error("expected transport to be reused when its config is unchanged")
+	}
+
+	backend.Transport = domain.TransportCfg{MaxIdleConnsPerHost: 10}
+	balancer.UpdateServers(servers, backend)
+	updated, ok := balancer.GetTransport("http://localhost:3001").(*http.Transport)
+	if !ok || updated == transport {
+		t.Fatal("expected a new transport after config change")
+	}
+	if updated.MaxIdleConnsPerHost != 10 {
+		t.Errorf("expected MaxIdleConnsPerHost 10, got %d", updated.MaxIdleConnsPerHost)
+	}
+
+	if balancer.GetTransport("http://unknown:9999") != nil {
+		t.Error("expected nil transport for unknown server")
+	}
+}
+
+func TestEnterpriseBalancer_H2CTransport(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	servers := []domain.Server{{URL: "http://localhost:50051", Weight: 1, Active: true}}
+	backend := &domain.Backend{Protocol: ProtocolH2C}
+	balancer.UpdateServers(servers, backend)
+
+	transport, ok := balancer.GetTransport("http://localhost:50051").(*http2.Transport)
+	if !ok {
+		t.Fatal("expected an HTTP/2 transport for h2c backend")
+	}
+	if !transport.AllowHTTP {
+		t.Error("expected AllowHTTP for cleartext backend")
+	}
+
+	// Cambiar el protocolo reemplaza el transporte
+	backend.Protocol = ""
+	balancer.UpdateServers(servers, backend)
+	if _, ok := balancer.GetTransport("http://localhost:50051").(*http.Transport); !ok {
+		t.Error("expected HTTP/1.1 transport after protocol change")
+	}
+}
@@ -0,0 +1,158 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+const triggerSimulationPath = "/debug/trigger-simulation"
+
+// TriggerSimulationRequest son las métricas sintéticas que verá el
+// SmartTrigger; las omitidas conservan el valor medido
+type TriggerSimulationRequest struct {
+	Backend     string   `json:"backend,omitempty"`
+	RPS         *float64 `json:"rps,omitempty"`
+	Latency     string   `json:"latency,omitempty"`
+	ErrorRate   *float64 `json:"error_rate,omitempty"`
+	Connections *int64   `json:"connections,omitempty"`
+	Duration    string   `json:"duration,omitempty"`
+}
+
+// TriggerSimulationResponse describe la simulación vigente
+type TriggerSimulationResponse struct {
+	Active      bool       `json:"active"`
+	Backend     string     `json:"backend,omitempty"`
+	RPS         *float64   `json:"rps,omitempty"`
+	Latency     string     `json:"latency,omitempty"`
+	ErrorRate   *float64   `json:"error_rate,omitempty"`
+	Connections *int64     `json:"connections,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// SetMetricsSimulator habilita /debug/trigger-simulation sobre las métricas del proxy
+func (api *ConfigAPI) SetMetricsSimulator(simulator domain.MetricsSimulator) {
+	api.simulator = simulator
+}
+
+// serveTriggerSimulation exige una admin key antes de mirar
+// debug.trigger_simulation, igual que /debug/pprof/
+func (api *ConfigAPI) serveTriggerSimulation(w http.ResponseWriter, r *http.Request) {
+	if !api.authenticateAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	config := api.configManager.GetConfig()
+	if config == nil || !config.Debug.TriggerSimulation {
+		http.Error(w, "Trigger simulation disabled (debug.trigger_simulation)", http.StatusNotFound)
+		return
+	}
+	if api.simulator == nil {
+		http.Error(w, "Trigger simulation not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeTriggerSimulation(w, api.simulator.SimulatedMetrics(time.Now()))
+	case http.MethodPut:
+		api.startTriggerSimulation(w, r, config)
+	case http.MethodDelete:
+		api.simulator.SimulateMetrics(nil)
+		slog.Info("Trigger simulation stopped", "remote_addr", r.RemoteAddr)
+		writeTriggerSimulation(w, nil)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (api *ConfigAPI) startTriggerSimulation(w http.ResponseWriter, r *http.Request, config *domain.Config) {
+	var req TriggerSimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	simulation, err := req.simulation(config, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	api.simulator.SimulateMetrics(simulation)
+	slog.Warn("Trigger simulation started, smart trigger is reading synthetic metrics",
+		"backend", simulation.Backend, "expires_at", simulation.ExpiresAt, "remote_addr", r.RemoteAddr)
+	writeTriggerSimulation(w, simulation)
+}
+
+// simulation valida la petición y la convierte en la simulación que leerá el trigger
+func (req *TriggerSimulationRequest) simulation(config *domain.Config, now time.Time) (*domain.SimulatedMetrics, error) {
+	if req.RPS == nil && req.Latency == "" && req.ErrorRate == nil && req.Connections == nil {
+		return nil, fmt.Errorf("at least one of rps, latency, error_rate or connections is required")
+	}
+	simulation := &domain.SimulatedMetrics{
+		Backend:     req.Backend,
+		RPS:         req.RPS,
+		ErrorRate:   req.ErrorRate,
+		Connections: req.Connections,
+	}
+
+	if req.Backend != "" {
+		found := false
+		for i := range config.Backends {
+			found = found || config.Backends[i].Name == req.Backend
+		}
+		if !found {
+			return nil, fmt.Errorf("backend %q not found", req.Backend)
+		}
+	}
+	if req.RPS != nil && *req.RPS < 0 {
+		return nil, fmt.Errorf("rps must not be negative")
+	}
+	if req.ErrorRate != nil && (*req.ErrorRate < 0 || *req.ErrorRate > 1) {
+		return nil, fmt.Errorf("error_rate must be between 0 and 1")
+	}
+	if req.Connections != nil && *req.Connections < 0 {
+		return nil, fmt.Errorf("connections must not be negative")
+	}
+	if req.Latency != "" {
+		latency, err := time.ParseDuration(req.Latency)
+		if err != nil || latency < 0 {
+			return nil, fmt.Errorf("invalid latency %q", req.Latency)
+		}
+		simulation.Latency = &latency
+	}
+
+	duration := domain.DefaultTriggerSimulationDuration
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil || parsed <= 0 || parsed > domain.MaxTriggerSimulationDuration {
+			return nil, fmt.Errorf("duration must be between 0 and %s", domain.MaxTriggerSimulationDuration)
+		}
+		duration = parsed
+	}
+	simulation.ExpiresAt = now.Add(duration)
+	return simulation, nil
+}
+
+func writeTriggerSimulation(w http.ResponseWriter, simulation *domain.SimulatedMetrics) {
+	response := TriggerSimulationResponse{}
+	if simulation != nil {
+		response = TriggerSimulationResponse{
+			Active:      true,
+			Backend:     simulation.Backend,
+			RPS:         simulation.RPS,
+			ErrorRate:   simulation.ErrorRate,
+			Connections: simulation.Connections,
+			ExpiresAt:   &simulation.ExpiresAt,
+		}
+		if simulation.Latency != nil {
+			response.Latency = simulation.Latency.String()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
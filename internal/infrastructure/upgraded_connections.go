@@ -0,0 +1,98 @@
+package infrastructure
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+// UpgradeDurationBuckets son los límites del histograma de duración de las
+// conexiones actualizadas: una sesión WebSocket dura de segundos a horas
+var UpgradeDurationBuckets = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	4 * time.Hour,
+}
+
+// upgradeStats acumula las conexiones actualizadas ya cerradas de un servidor.
+// Las abiertas se cuentan en ConnectionPool.UpgradedConns.
+type upgradeStats struct {
+	mu        sync.Mutex
+	durations *LatencyHistogram
+}
+
+func (u *upgradeStats) observe(duration time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.durations == nil {
+		u.durations = NewLatencyHistogram(UpgradeDurationBuckets)
+	}
+	u.durations.Observe([]time.Duration{duration})
+}
+
+// snapshot devuelve una copia del histograma, vacía si no se cerró ninguna
+func (u *upgradeStats) snapshot() *LatencyHistogram {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	histogram := NewLatencyHistogram(UpgradeDurationBuckets)
+	if u.durations != nil {
+		histogram.Merge(u.durations)
+	}
+	return histogram
+}
+
+func (u *upgradeStats) closed() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.durations == nil {
+		return 0
+	}
+	return u.durations.Count
+}
+
+func (u *upgradeStats) reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.durations = nil
+}
+
+// TrackUpgrade implementa domain.UpgradeTracker. La conexión cuenta como
+// abierta hasta que se llama a la función devuelta, que registra su duración.
+func (eb *EnterpriseBalancer) TrackUpgrade(server *domain.Server) func() {
+	eb.mu.RLock()
+	state, exists := eb.servers[server.URL]
+	clock := eb.clock
+	eb.mu.RUnlock()
+	if !exists {
+		return func() {}
+	}
+
+	opened := clockNow(clock)
+	atomic.AddInt64(&state.ConnectionPool.UpgradedConns, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			atomic.AddInt64(&state.ConnectionPool.UpgradedConns, -1)
+			state.Metrics.Upgrades.observe(clockNow(clock).Sub(opened))
+		})
+	}
+}
+
+// GetUpgradeHistograms devuelve por servidor la duración de las conexiones
+// actualizadas ya cerradas
+func (eb *EnterpriseBalancer) GetUpgradeHistograms() map[string]*LatencyHistogram {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	histograms := make(map[string]*LatencyHistogram, len(eb.servers))
+	for url, state := range eb.servers {
+		histograms[url] = state.Metrics.Upgrades.snapshot()
+	}
+	return histograms
+}
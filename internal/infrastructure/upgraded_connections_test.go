@@ -0,0 +1,56 @@
+package infrastructure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+func TestEnterpriseBalancer_TrackUpgrade(t *testing.T) {
+	clock := newFakeClock()
+	balancer := NewEnterpriseBalancer()
+	balancer.SetClock(clock)
+	server := domain.Server{URL: "http://localhost:3001", Weight: 1, Active: true}
+	balancer.UpdateServers([]domain.Server{server}, &domain.Backend{})
+
+	short := balancer.TrackUpgrade(&server)
+	long := balancer.TrackUpgrade(&server)
+	if got := balancer.GetServerMetrics()[server.URL].UpgradedConns; got != 2 {
+		t.Fatalf("expected 2 upgraded connections, got %d", got)
+	}
+
+	clock.Advance(5 * time.Second)
+	short()
+	short() // cerrar dos veces no descuenta de más
+	clock.Advance(2 * time.Hour)
+	long()
+
+	stats := balancer.GetServerMetrics()[server.URL]
+	if stats.UpgradedConns != 0 || stats.UpgradedClosed != 2 {
+		t.Errorf("expected 0 open and 2 closed, got %d and %d", stats.UpgradedConns, stats.UpgradedClosed)
+	}
+	if stats.CurrentConns != 0 || stats.TotalRequests != 0 {
+		t.Errorf("expected upgrades outside request metrics, got %d conns and %d requests", stats.CurrentConns, stats.TotalRequests)
+	}
+
+	histogram := balancer.GetUpgradeHistograms()[server.URL]
+	// 5s cae en el bucket de 10s; 2h05s solo en el de 4h
+	if histogram.Counts[0] != 0 || histogram.Counts[1] != 1 || histogram.Counts[5] != 1 || histogram.Counts[6] != 2 {
+		t.Errorf("unexpected duration buckets %v", histogram.Counts)
+	}
+	if histogram.Sum != 2*time.Hour+10*time.Second {
+		t.Errorf("expected sum 2h0m10s, got %v", histogram.Sum)
+	}
+
+	balancer.ResetMetrics()
+	if closed := balancer.GetServerMetrics()[server.URL].UpgradedClosed; closed != 0 {
+		t.Errorf("expected reset to clear closed upgrades, got %d", closed)
+	}
+}
+
+func TestEnterpriseBalancer_TrackUpgradeUnknownServer(t *testing.T) {
+	balancer := NewEnterpriseBalancer()
+	closed := balancer.TrackUpgrade(&domain.Server{URL: "http://localhost:3009"})
+	closed()
+}
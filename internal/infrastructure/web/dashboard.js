@@ -0,0 +1,126 @@
+let startTime = Date.now();
+
+function formatNumber(num) {
+    return new Intl.NumberFormat().format(num);
+}
+
+function formatUptime(ms) {
+    const seconds = Math.floor(ms / 1000);
+    const minutes = Math.floor(seconds / 60);
+    const hours = Math.floor(minutes / 60);
+    return hours > 0 ? hours + 'h ' + (minutes % 60) + 'm' : minutes + 'm ' + (seconds % 60) + 's';
+}
+
+function render(data) {
+            document.getElementById('rps').textContent = data.metrics.requests_per_second || 0;
+            document.getElementById('total').textContent = formatNumber(data.metrics.total_requests || 0);
+            document.getElementById('active').textContent = data.metrics.active_connections || 0;
+            document.getElementById('upgraded').textContent = data.metrics.upgraded_connections || 0;
+
+            const errorRate = data.metrics.error_rate || 0;
+            const errorEl = document.getElementById('error');
+            errorEl.textContent = errorRate.toFixed(2) + '%';
+            errorEl.className = 'metric-value ' + (errorRate > 5 ? 'error' : errorRate > 1 ? 'warning' : 'success');
+
+            const successRate = 100 - errorRate;
+            document.getElementById('success').textContent = successRate.toFixed(1) + '%';
+
+            document.getElementById('response').textContent = data.metrics.average_response_time || '0ms';
+            document.getElementById('uptime').textContent = formatUptime(Date.now() - startTime);
+
+            const serversDiv = document.getElementById('servers');
+            serversDiv.innerHTML = '';
+
+            let circuitCount = 0;
+            let drainingCount = data.draining_servers ? data.draining_servers.length : 0;
+
+            for (const [url, server] of Object.entries(data.servers || {})) {
+                if (server.status === 'circuit_open') circuitCount++;
+
+                const serverDiv = document.createElement('div');
+                serverDiv.className = 'server ' + (server.status || 'healthy');
+
+                const statusClass = 'status-' + (server.status || 'healthy');
+                const statusText = (server.status || 'healthy').replace('_', ' ').toUpperCase();
+
+                serverDiv.innerHTML = 
+                    '<div class="server-header">' +
+                        '<span class="server-url">' + url + '</span>' +
+                        '<span class="server-status ' + statusClass + '">' + statusText + '</span>' +
+                    '</div>' +
+                    '<div class="server-stats">' +
+                        '<div class="stat">' +
+                            '<span class="stat-label">Connections</span>' +
+                            '<span class="stat-value">' + (server.connections || 0) + '</span>' +
+                        '</div>' +
+                        (server.upgraded_connections ?
+                            '<div class="stat">' +
+                                '<span class="stat-label">WebSockets</span>' +
+                                '<span class="stat-value">' + server.upgraded_connections + '</span>' +
+                            '</div>' : '') +
+                        '<div class="stat">' +
+                            '<span class="stat-label">Requests</span>' +
+                            '<span class="stat-value">' + formatNumber(server.total_requests || 0) + '</span>' +
+                        '</div>' +
+                        '<div class="stat">' +
+                            '<span class="stat-label">Failed</span>' +
+                            '<span class="stat-value">' + formatNumber(server.failed_requests || 0) + '</span>' +
+                        '</div>' +
+                        '<div class="stat">' +
+                            '<span class="stat-label">Response</span>' +
+                            '<span class="stat-value">' + (server.response_time || '0ms') + '</span>' +
+                        '</div>' +
+                        '<div class="stat">' +
+                            '<span class="stat-label">Weight</span>' +
+                            '<span class="stat-value">' + (server.weight || 1) + ' → ' + (server.effective_weight || 0).toFixed(2) + '</span>' +
+                        '</div>' +
+                        '<div class="stat">' +
+                            '<span class="stat-label">Max Conns</span>' +
+                            '<span class="stat-value">' + (server.max_connections || 0) + '</span>' +
+                        '</div>' +
+                        (server.draining ? 
+                            '<div class="stat">' +
+                                '<span class="stat-label">Draining</span>' +
+                                '<span class="stat-value">🔄 YES</span>' +
+                            '</div>' : '') +
+                    '</div>';
+
+                serversDiv.appendChild(serverDiv);
+            }
+
+            document.getElementById('circuits').textContent = circuitCount + ' Open';
+            document.getElementById('draining').textContent = drainingCount;
+            document.getElementById('lastUpdate').textContent = new Date().toLocaleTimeString();
+}
+
+let reconnectDelay = 1000;
+
+function startStream() {
+    if (!('WebSocket' in window)) {
+        // Navegadores sin WebSocket: SSE sobre el mismo endpoint
+        const eventSource = new EventSource('/ws');
+        eventSource.onmessage = function(event) { render(JSON.parse(event.data)); };
+        return;
+    }
+
+    const protocol = location.protocol === 'https:' ? 'wss://' : 'ws://';
+    const socket = new WebSocket(protocol + location.host + '/ws');
+
+    socket.onopen = function() {
+        reconnectDelay = 1000;
+    };
+    socket.onmessage = function(event) {
+        render(JSON.parse(event.data));
+    };
+    socket.onclose = function() {
+        document.getElementById('lastUpdate').textContent = 'Connection lost - reconnecting...';
+        setTimeout(startStream, reconnectDelay);
+        reconnectDelay = Math.min(reconnectDelay * 2, 30000);
+    };
+    socket.onerror = function(event) {
+        console.error('WebSocket error:', event);
+        socket.close();
+    };
+}
+
+startStream();
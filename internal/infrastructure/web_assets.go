@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Recursos estáticos embebidos en el binario para no depender del directorio de trabajo
+var (
+	//go:embed docs/api-docs-en.yaml
+	apiSpec []byte
+
+	//go:embed web/swagger.html
+	swaggerHTML []byte
+
+	//go:embed web/dashboard.html
+	dashboardHTML []byte
+
+	//go:embed web
+	webFS embed.FS
+)
+
+// webAssetsHandler sirve el contenido de web/ bajo /assets/ (CSS y JS del dashboard)
+func webAssetsHandler() http.Handler {
+	assets, _ := fs.Sub(webFS, "web")
+	return http.StripPrefix("/assets/", http.FileServer(http.FS(assets)))
+}
@@ -1,16 +1,20 @@
 package infrastructure
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/juanbautista0/go-proxy/internal/domain"
 )
 
 type WebSocketMetrics struct {
 	proxyService domain.ProxyService
 	loadBalancer *EnterpriseBalancer
+	// Decide qué orígenes pueden abrir /ws; nil acepta cualquiera
+	checkOrigin func(r *http.Request) bool
 }
 
 type MetricsData struct {
@@ -19,6 +23,7 @@ type MetricsData struct {
 		RequestsPerSecond   int     `json:"requests_per_second"`
 		TotalRequests       int64   `json:"total_requests"`
 		ActiveConnections   int64   `json:"active_connections"`
+		UpgradedConnections int64   `json:"upgraded_connections"`
 		SuccessfulRequests  int64   `json:"successful_requests"`
 		FailedRequests      int64   `json:"failed_requests"`
 		AverageResponseTime string  `json:"average_response_time"`
@@ -29,14 +34,18 @@ type MetricsData struct {
 }
 
 type ServerStatus struct {
-	Status         string `json:"status"`
-	Connections    int64  `json:"connections"`
-	TotalRequests  int64  `json:"total_requests"`
-	FailedRequests int64  `json:"failed_requests"`
-	ResponseTime   string `json:"response_time"`
-	Weight         int    `json:"weight"`
-	Active         bool   `json:"active"`
-	Draining       bool   `json:"draining"`
+	Status          string  `json:"status"`
+	Connections     int64   `json:"connections"`
+	UpgradedConns   int64   `json:"upgraded_connections"`
+	TotalRequests   int64   `json:"total_requests"`
+	FailedRequests  int64   `json:"failed_requests"`
+	ResponseTime    string  `json:"response_time"`
+	Weight          int     `json:"weight"`
+	EffectiveWeight float64 `json:"effective_weight"`
+	MaxConnections  int     `json:"max_connections"`
+	Active          bool    `json:"active"`
+	Draining        bool    `json:"draining"`
+	CircuitOverride bool    `json:"circuit_override"`
 }
 
 func NewWebSocketMetrics(proxyService domain.ProxyService) *WebSocketMetrics {
@@ -49,14 +58,20 @@ func (ws *WebSocketMetrics) SetLoadBalancer(lb *EnterpriseBalancer) {
 	ws.loadBalancer = lb
 }
 
+// SetOriginCheck aplica una política de orígenes al handshake WebSocket
+func (ws *WebSocketMetrics) SetOriginCheck(check func(r *http.Request) bool) {
+	ws.checkOrigin = check
+}
+
 func (ws *WebSocketMetrics) collectMetrics() MetricsData {
 	metrics := ws.proxyService.GetMetrics()
 	serverStats := ws.proxyService.GetServerStats()
 
-	var totalRequests, activeConnections, successfulRequests, failedRequests int64
+	var totalRequests, activeConnections, upgradedConnections, successfulRequests, failedRequests int64
 	for _, server := range serverStats {
 		totalRequests += server.TotalRequests
 		activeConnections += server.CurrentConns
+		upgradedConnections += server.UpgradedConns
 		successfulRequests += server.TotalRequests - server.FailedRequests
 		failedRequests += server.FailedRequests
 	}
@@ -74,6 +89,7 @@ func (ws *WebSocketMetrics) collectMetrics() MetricsData {
 	data.Metrics.RequestsPerSecond = metrics.RequestsPerSecond
 	data.Metrics.TotalRequests = totalRequests
 	data.Metrics.ActiveConnections = activeConnections
+	data.Metrics.UpgradedConnections = upgradedConnections
 	data.Metrics.SuccessfulRequests = successfulRequests
 	data.Metrics.FailedRequests = failedRequests
 	data.Metrics.AverageResponseTime = metrics.AverageResponseTime.String()
@@ -104,25 +120,124 @@ func (ws *WebSocketMetrics) collectMetrics() MetricsData {
 		}
 
 		data.Servers[url] = ServerStatus{
-			Status:         status,
-			Connections:    server.CurrentConns,
-			TotalRequests:  server.TotalRequests,
-			FailedRequests: server.FailedRequests,
-			ResponseTime:   server.ResponseTime.String(),
-			Weight:         server.Weight,
-			Active:         server.Active,
-			Draining:       draining,
+			Status:          status,
+			Connections:     server.CurrentConns,
+			UpgradedConns:   server.UpgradedConns,
+			TotalRequests:   server.TotalRequests,
+			FailedRequests:  server.FailedRequests,
+			ResponseTime:    server.ResponseTime.String(),
+			Weight:          server.Weight,
+			EffectiveWeight: server.EffectiveWeight,
+			MaxConnections:  server.MaxConnections,
+			Active:          server.Active,
+			Draining:        draining,
+			CircuitOverride: server.CircuitOverride,
 		}
 	}
 
 	return data
 }
 
+const (
+	wsWriteTimeout = 5 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// HandleWebSocket sirve WebSocket real y mantiene SSE para clientes que no
+// solicitan el upgrade (p.ej. EventSource).
 func (ws *WebSocketMetrics) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		ws.serveWebSocket(w, r)
+		return
+	}
+	ws.serveSSE(w, r)
+}
+
+func (ws *WebSocketMetrics) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := wsUpgrader
+	// Sin política las métricas son públicas igual que /metrics
+	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	if ws.checkOrigin != nil {
+		upgrader.CheckOrigin = ws.checkOrigin
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade ya respondió con el error
+	}
+	defer conn.Close()
+
+	// El lector detecta la desconexión del cliente y procesa pongs/close
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	pingTicker := time.NewTicker(wsPingInterval)
+	defer pingTicker.Stop()
+
+	var last []byte
+	send := func() bool {
+		data := ws.collectMetrics()
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return true
+		}
+
+		// Solo enviar cuando algo cambió además del timestamp
+		data.Timestamp = time.Time{}
+		fingerprint, _ := json.Marshal(data)
+		if bytes.Equal(fingerprint, last) {
+			return true
+		}
+		last = fingerprint
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteMessage(websocket.TextMessage, payload) == nil
+	}
+
+	if !send() {
+		return
+	}
+	for {
+		select {
+		case <-ticker.C:
+			if !send() {
+				return
+			}
+		case <-pingTicker.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)) != nil {
+				return
+			}
+		case <-done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (ws *WebSocketMetrics) serveSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -0,0 +1,93 @@
+package infrastructure
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/juanbautista0/go-proxy/internal/domain"
+)
+
+type stubProxyService struct {
+	servers map[string]*domain.Server
+}
+
+func (s *stubProxyService) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+func (s *stubProxyService) UpdateConfig(config *domain.Config) error         { return nil }
+func (s *stubProxyService) GetMetrics() *domain.TrafficMetrics               { return &domain.TrafficMetrics{} }
+func (s *stubProxyService) GetServerStats() map[string]*domain.Server        { return s.servers }
+
+func newTestWebSocketMetrics() *WebSocketMetrics {
+	return NewWebSocketMetrics(&stubProxyService{
+		servers: map[string]*domain.Server{
+			"http://localhost:3001": {URL: "http://localhost:3001", Weight: 1, Active: true, Healthy: true},
+		},
+	})
+}
+
+func TestWebSocketMetrics_WebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(newTestWebSocketMetrics().HandleWebSocket))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	var data MetricsData
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&data); err != nil {
+		t.Fatalf("expected metrics message: %v", err)
+	}
+	if _, ok := data.Servers["http://localhost:3001"]; !ok {
+		t.Errorf("expected server in metrics, got %v", data.Servers)
+	}
+}
+
+func TestWebSocketMetrics_DisconnectReleasesHandler(t *testing.T) {
+	handlerDone := make(chan struct{})
+	ws := newTestWebSocketMetrics()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.HandleWebSocket(w, r)
+		close(handlerDone)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn.ReadMessage()
+	conn.Close()
+
+	select {
+	case <-handlerDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler kept running after client disconnect")
+	}
+
+}
+
+func TestWebSocketMetrics_SSEFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(newTestWebSocketMetrics().HandleWebSocket))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected SSE content type, got %q", ct)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") {
+		t.Errorf("expected SSE data line, got %q (%v)", line, err)
+	}
+}